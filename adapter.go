@@ -0,0 +1,144 @@
+package opencat_api
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// ProviderAdapter translates chat requests and responses to and from the API format of a provider,
+// see RegisterAdapter. Requests are sent to the configured endpoint with the configured authentication.
+type ProviderAdapter interface {
+	// EncodeRequest returns the API path, like /v1/messages, and the JSON body of a chat request.
+	EncodeRequest(chat ChatRequest) (path string, body []byte, err error)
+	// DecodeResponse decodes the body of a successful, non-streamed response.
+	DecodeResponse(body []byte) (ChatResponse, error)
+	// DecodeStreamEvent decodes the data of a stream event into deltas. Events without content,
+	// like pings, have no deltas. An error event sent by the server is returned as an *APIError,
+	// which ends the stream.
+	DecodeStreamEvent(data []byte) ([]ChatDelta, error)
+}
+
+var (
+	adaptersMu sync.RWMutex
+	adapters   = map[ChatModel]ProviderAdapter{}
+)
+
+// RegisterAdapter makes requests for model, and its versioned names like model-0613, use adapter
+// instead of the built-in translation.
+func RegisterAdapter(model ChatModel, adapter ProviderAdapter) {
+	adaptersMu.Lock()
+	defer adaptersMu.Unlock()
+	adapters[model] = adapter
+}
+
+func registeredAdapter(model ChatModel) (ProviderAdapter, bool) {
+	adaptersMu.RLock()
+	defer adaptersMu.RUnlock()
+	if a, ok := adapters[model]; ok {
+		return a, true
+	}
+	var (
+		best  ProviderAdapter
+		found ChatModel
+	)
+	for m, a := range adapters {
+		if strings.HasPrefix(string(model), string(m)+"-") && len(m) > len(found) {
+			best, found = a, m
+		}
+	}
+	return best, found != ""
+}
+
+// adapterFor returns the adapter of a chat request: a registered one, or the built-in one of its provider.
+func adapterFor(cfg *Config, chat ChatRequest) ProviderAdapter {
+	if a, ok := registeredAdapter(chat.Model); ok {
+		return a
+	}
+	switch chat.provider() {
+	case ProviderAnthropic:
+		if usesClaudeMessages(cfg, chat) {
+			return claudeMessagesAdapter{}
+		}
+		return claudeCompletionAdapter{}
+	case ProviderBaidu:
+		return ernieAdapter{}
+	case ProviderAlibaba:
+		return qwenAdapter{}
+	case ProviderIFlytek:
+		return sparkAdapter{}
+	default:
+		return openCatAdapter{}
+	}
+}
+
+// requestBody returns the JSON body of a chat request as a map, with the fields of params,
+// the options of a provider, added at the top level.
+func requestBody(chat ChatRequest, params any) (map[string]any, error) {
+	data, err := json.Marshal(chat)
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]any
+	err = json.Unmarshal(data, &body)
+	if err != nil {
+		return nil, err
+	}
+	data, err = json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	// Nil params are null, which leaves the body unchanged.
+	err = json.Unmarshal(data, &body)
+	return body, err
+}
+
+// openCatAdapter sends requests in OpenCat's own format, which the gateway translates for most providers.
+type openCatAdapter struct{}
+
+func (openCatAdapter) EncodeRequest(chat ChatRequest) (string, []byte, error) {
+	body, err := json.Marshal(chat)
+	return "/1/chat", body, err
+}
+
+func (openCatAdapter) DecodeResponse(body []byte) (ChatResponse, error) {
+	var r ChatResponse
+	err := json.Unmarshal(body, &r)
+	return r, err
+}
+
+func (openCatAdapter) DecodeStreamEvent(data []byte) ([]ChatDelta, error) {
+	return parseStreamEvent(data)
+}
+
+// claudeCompletionAdapter uses the legacy completion API of Anthropic.
+type claudeCompletionAdapter struct{}
+
+func (claudeCompletionAdapter) EncodeRequest(chat ChatRequest) (string, []byte, error) {
+	body, err := claudeCompletionBody(chat)
+	return "/v1/complete", body, err
+}
+
+func (claudeCompletionAdapter) DecodeResponse(body []byte) (ChatResponse, error) {
+	return decodeClaudeResponse(body)
+}
+
+func (claudeCompletionAdapter) DecodeStreamEvent(data []byte) ([]ChatDelta, error) {
+	return parseStreamEvent(data)
+}
+
+// claudeMessagesAdapter uses the Messages API of Anthropic.
+type claudeMessagesAdapter struct{}
+
+func (claudeMessagesAdapter) EncodeRequest(chat ChatRequest) (string, []byte, error) {
+	body, err := claudeMessagesBody(chat)
+	return "/v1/messages", body, err
+}
+
+func (claudeMessagesAdapter) DecodeResponse(body []byte) (ChatResponse, error) {
+	return decodeClaudeResponse(body)
+}
+
+func (claudeMessagesAdapter) DecodeStreamEvent(data []byte) ([]ChatDelta, error) {
+	return parseStreamEvent(data)
+}
@@ -0,0 +1,115 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// echoAdapter is a made-up provider format, with the prompt in a "q" field and the reply in an "a" field.
+type echoAdapter struct{}
+
+func (echoAdapter) EncodeRequest(chat ChatRequest) (string, []byte, error) {
+	body, err := json.Marshal(map[string]any{"q": chat.Messages[len(chat.Messages)-1].Text(), "stream": chat.Stream})
+	return "/v1/echo", body, err
+}
+
+func (echoAdapter) DecodeResponse(body []byte) (ChatResponse, error) {
+	var r struct {
+		A string `json:"a"`
+	}
+	err := json.Unmarshal(body, &r)
+	resp := ChatResponse{Choices: []ChatResponseChoice{{FinishReason: "stop"}}}
+	resp.Choices[0].Message.Content = r.A
+	return resp, err
+}
+
+func (echoAdapter) DecodeStreamEvent(data []byte) ([]ChatDelta, error) {
+	return []ChatDelta{{Content: string(data)}}, nil
+}
+
+func TestRegisterAdapter(t *testing.T) {
+	RegisterAdapter("echo", echoAdapter{})
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/echo" {
+				http.NotFound(w, r)
+				return
+			}
+			var req struct {
+				Q      string `json:"q"`
+				Stream bool   `json:"stream"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if req.Stream {
+				w.Header().Set("Content-Type", "text/event-stream")
+				for _, word := range strings.Fields(req.Q) {
+					fmt.Fprintf(w, "data: %s\n\n", word)
+				}
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"a": req.Q})
+		},
+	)
+
+	chat := ChatRequest{Model: "echo-2", Messages: []Message{{Role: RoleUser, Content: "hello there"}}}
+	resp, err := c.Chat(context.Background(), chat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Choices[0].Message.Content != "hello there" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	var got string
+	chat.Stream = true
+	err = c.StreamChat(context.Background(), chat, func(delta string, done bool) { got += delta })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "hellothere" {
+		t.Errorf("streamed %q", got)
+	}
+}
+
+func TestWithProvider(t *testing.T) {
+	var body map[string]any
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "hi"}}]}`)
+		},
+	)
+
+	_, err := c.Chat(
+		context.Background(),
+		ChatRequest{Model: ChatModelGPT4, Messages: []Message{User("hello")}, LogitBias: map[string]int{"50256": -100}},
+		WithProvider(ProviderAzure),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body["provider"] != "azure" || body["model"] != "gpt-4" {
+		t.Errorf("unexpected request: %v", body)
+	}
+
+	// A model the registry doesn't know, served by Baidu.
+	chat := ChatRequest{
+		Model:    "my-ernie",
+		Messages: []Message{System("Be brief."), User("hello")},
+		Ernie:    &ErnieParams{DisableSearch: true},
+	}
+	if err := chat.validate(); err == nil {
+		t.Error("expected an error for ERNIE options without the Baidu provider")
+	}
+	WithProvider(ProviderBaidu)(&chat)
+	if err := chat.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := adapterFor(&Config{}, chat).(ernieAdapter); !ok {
+		t.Error("request not translated for ERNIE")
+	}
+}
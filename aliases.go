@@ -0,0 +1,58 @@
+package opencat_api
+
+import (
+	"fmt"
+)
+
+// ModelAlias is what a model name like fast or smart stands for, see Config.Aliases.
+// Product code can then ask for an intent, and the model behind it be changed by reloading the configuration.
+type ModelAlias struct {
+	Model ChatModel `json:"model"`
+	// Provider routes the requests, see WithProvider.
+	Provider Provider `json:"provider,omitempty"`
+	// Preset, Temperature and MaxTokens are used when a request doesn't set its own.
+	// Temperature takes precedence over Preset.
+	Preset      Preset  `json:"preset,omitempty"`
+	Temperature float64 `json:"temperature,omitempty"`
+	MaxTokens   int     `json:"max_tokens,omitempty"`
+}
+
+func validateAliases(aliases map[ChatModel]ModelAlias) error {
+	for name, alias := range aliases {
+		key := fmt.Sprintf("aliases.%s", name)
+		if alias.Model == "" {
+			return &ConfigError{key, fmt.Errorf("alias %s has no model", name)}
+		}
+		if _, ok := aliases[alias.Model]; ok {
+			return &ConfigError{key + ".model", fmt.Errorf("alias %s refers to alias %s", name, alias.Model)}
+		}
+		if _, ok := presets[alias.Preset]; alias.Preset != "" && !ok {
+			return &ConfigError{key + ".preset", fmt.Errorf("alias %s has unknown preset %s", name, alias.Preset)}
+		}
+	}
+	return nil
+}
+
+// resolveAlias replaces the model of r by the model its alias stands for, if any,
+// and fills in the parameters of the alias that r doesn't set.
+func (cfg *Config) resolveAlias(r *ChatRequest) {
+	alias, ok := cfg.Aliases[r.Model]
+	if !ok {
+		return
+	}
+	r.Model = alias.Model
+	if r.Provider == "" {
+		r.Provider = alias.Provider
+	}
+	if r.Temperature == 0 {
+		if alias.Preset != "" {
+			WithPreset(alias.Preset)(r)
+		}
+		if alias.Temperature != 0 {
+			r.Temperature = alias.Temperature
+		}
+	}
+	if r.MaxTokens == 0 {
+		r.MaxTokens = alias.MaxTokens
+	}
+}
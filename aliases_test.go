@@ -0,0 +1,103 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModelAliases(t *testing.T) {
+	var body struct {
+		Model       ChatModel `json:"model"`
+		Temperature float64   `json:"temperature"`
+		MaxTokens   int       `json:"maxTokens"`
+	}
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				body.Temperature, body.MaxTokens = 0, 0
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				io.WriteString(w, `{"choices": [{"message": {"role": "assistant", "content": "Hi"}}]}`)
+			},
+		),
+	)
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(
+		path, []byte(`{"token": "token", "base_url": "`+srv.URL+`", "default_model": "smart", "aliases": {`+
+			`"fast": {"model": "gpt-3.5-turbo", "max_tokens": 500}, "smart": {"model": "gpt-4", "preset": "precise"}}}`),
+		0644,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient("token")
+	err = c.ApplyConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat := func(req ChatRequest, opts ...RequestOption) {
+		t.Helper()
+		req.Messages = []Message{{Role: RoleUser, Content: "Hi"}}
+		_, err := c.Chat(context.Background(), req, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	chat(ChatRequest{})
+	if body.Model != ChatModelGPT4 || body.Temperature != 0.1 {
+		t.Errorf("default alias not resolved: %+v", body)
+	}
+	chat(ChatRequest{Model: "fast"})
+	if body.Model != ChatModelGPT3Dot5Turbo || body.MaxTokens != 500 {
+		t.Errorf("alias not resolved: %+v", body)
+	}
+	chat(ChatRequest{Model: "fast", MaxTokens: 100})
+	if body.MaxTokens != 100 {
+		t.Errorf("request parameters overridden by the alias: %+v", body)
+	}
+	chat(ChatRequest{}, WithModel("fast"))
+	if body.Model != ChatModelGPT3Dot5Turbo {
+		t.Errorf("alias set by an option not resolved: %+v", body)
+	}
+
+	conv := NewConversation(c, "fast")
+	conv.SetBudget(ConversationBudget{Cost: Cost{Amount: 0.000001, Currency: "USD"}})
+	_, err = conv.Ask(context.Background(), "Hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, cost := conv.Spent(); cost.Amount == 0 || body.Model != ChatModelGPT3Dot5Turbo || body.MaxTokens != 500 {
+		t.Errorf("alias of a conversation not resolved, spent %v on %+v", cost, body)
+	}
+	_, err = conv.Ask(context.Background(), "Hi")
+	if !errors.Is(err, ErrConversationBudgetExceeded) {
+		t.Errorf("budget of a conversation on an alias not enforced: %v", err)
+	}
+
+	cfg.Aliases = map[ChatModel]ModelAlias{"fast": {Model: ChatModelGPT4Turbo}}
+	err = c.ApplyConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat(ChatRequest{Model: "fast"})
+	if body.Model != ChatModelGPT4Turbo {
+		t.Errorf("alias not reloaded: %+v", body)
+	}
+
+	cfg.Aliases = map[ChatModel]ModelAlias{"fast": {Model: "quick"}, "quick": {Model: ChatModelGPT4}}
+	if c.ApplyConfig(cfg) == nil {
+		t.Error("expected an error for an alias of an alias")
+	}
+}
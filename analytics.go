@@ -0,0 +1,144 @@
+package opencat_api
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ConversationReport are the metrics of a conversation, see Analytics.
+type ConversationReport struct {
+	Session string `json:"session"`
+	// Turns is the number of replies in the history.
+	Turns int `json:"turns"`
+	// Requests and Errors count the chat requests of the session, including retries.
+	Requests int `json:"requests"`
+	Errors   int `json:"errors"`
+	// AverageLatency is the average time to the response headers of the successful requests.
+	AverageLatency time.Duration `json:"average_latency"`
+	// TokensPerTurn is the estimated number of tokens of the history per turn.
+	TokensPerTurn float64 `json:"tokens_per_turn"`
+	// Models is the number of requests to each model.
+	Models map[string]int `json:"models"`
+	// Abandoned reports whether the last request of the session failed and nothing followed.
+	Abandoned bool `json:"abandoned"`
+	// Start and End are the times of the first and last requests.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Analytics records the chat requests of the sessions of a client from its events, to report on
+// conversations with their stored history. Requests without a session are ignored.
+//
+//	analytics := NewAnalytics()
+//	c.Events().Subscribe(analytics.Add)
+//	...
+//	report := analytics.Report(conv.Session(), history)
+type Analytics struct {
+	mu       sync.Mutex
+	sessions map[string]*sessionStats
+}
+
+type sessionStats struct {
+	requests, errors int
+	latency          time.Duration
+	models           map[string]int
+	lastFailed       bool
+	start, end       time.Time
+}
+
+func NewAnalytics() *Analytics {
+	return &Analytics{sessions: map[string]*sessionStats{}}
+}
+
+// isChatRequest reports whether a request is a chat request, whose path depends on its adapter.
+func isChatRequest(method, path string) bool {
+	return method == http.MethodPost && endpointOf(path) == EndpointChat &&
+		path != "/v1/embeddings" && path != "/v1/moderations"
+}
+
+// Add records an event, it is meant to be subscribed to an EventBus.
+func (a *Analytics) Add(e Event) {
+	finished, ok := e.(RequestFinishedEvent)
+	if !ok || finished.Session == "" || !isChatRequest(finished.Method, finished.Path) {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st := a.sessions[finished.Session]
+	if st == nil {
+		st = &sessionStats{models: map[string]int{}, start: finished.Time.Add(-finished.Duration)}
+		a.sessions[finished.Session] = st
+	}
+	st.requests++
+	st.models[finished.Model]++
+	st.end = finished.Time
+	st.lastFailed = finished.Err != nil || finished.StatusCode >= 400
+	if st.lastFailed {
+		st.errors++
+	} else {
+		st.latency += finished.Duration
+	}
+}
+
+// Sessions returns the sessions with recorded requests, sorted.
+func (a *Analytics) Sessions() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	sessions := make([]string, 0, len(a.sessions))
+	for session := range a.sessions {
+		sessions = append(sessions, session)
+	}
+	sort.Strings(sessions)
+	return sessions
+}
+
+// Forget removes the records of a session, once it is reported.
+func (a *Analytics) Forget(session string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.sessions, session)
+}
+
+// Report returns the metrics of a session, with its stored history. The history may be nil,
+// then the turns are the successful requests and the tokens are unknown.
+func (a *Analytics) Report(session string, history []HistoryEntry) ConversationReport {
+	report := ConversationReport{Session: session, Models: map[string]int{}}
+	a.mu.Lock()
+	if st := a.sessions[session]; st != nil {
+		report.Requests, report.Errors = st.requests, st.errors
+		if n := st.requests - st.errors; n > 0 {
+			report.AverageLatency = st.latency / time.Duration(n)
+		}
+		for model, n := range st.models {
+			report.Models[model] = n
+		}
+		report.Abandoned = st.lastFailed
+		report.Start, report.End = st.start, st.end
+	}
+	a.mu.Unlock()
+
+	if history == nil {
+		report.Turns = report.Requests - report.Errors
+		return report
+	}
+	messages := HistoryMessages(history)
+	for _, msg := range messages {
+		if msg.Role == RoleAssistant {
+			report.Turns++
+		}
+	}
+	if report.Turns > 0 {
+		// Tokens are estimated for the model used the most.
+		top := ""
+		for model, n := range report.Models {
+			if n > report.Models[top] || n == report.Models[top] && model < top {
+				top = model
+			}
+		}
+		tokens := estimateTokens(providerOf(ChatModel(top)), messages)
+		report.TokensPerTurn = float64(tokens) / float64(report.Turns)
+	}
+	return report
+}
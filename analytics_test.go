@@ -0,0 +1,66 @@
+package opencat_api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAnalytics(t *testing.T) {
+	fail := false
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			if fail {
+				http.Error(w, `{"error": {"message": "overloaded"}}`, http.StatusServiceUnavailable)
+				return
+			}
+			io.WriteString(w, `{"choices": [{"message": {"role": "assistant", "content": "Hello there"}}]}`)
+		},
+		WithClock(clock),
+	)
+	analytics := NewAnalytics()
+	c.Events().Subscribe(analytics.Add)
+
+	conv := NewConversation(c, ChatModelGPT4)
+	_, err := conv.Ask(context.Background(), "Hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail = true
+	_, err = conv.Ask(context.Background(), "Are you there?")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	// Requests of other sessions and other endpoints are not counted.
+	_, _ = c.Chat(context.Background(), ChatRequest{Model: ChatModelGPT4, Messages: []Message{{Role: RoleUser, Content: "Hi"}}})
+	_, _ = c.Moderate(WithSession(context.Background(), conv.Session()), []string{"Hi"})
+
+	var history []HistoryEntry
+	for i, msg := range conv.History() {
+		history = append(history, HistoryEntry{ID: string(rune('a' + i)), Message: msg})
+	}
+	report := analytics.Report(conv.Session(), history)
+	if report.Requests != 2 || report.Errors != 1 || !report.Abandoned || report.Turns != 1 ||
+		report.Models[string(ChatModelGPT4)] != 2 || report.TokensPerTurn == 0 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if sessions := analytics.Sessions(); len(sessions) != 1 || sessions[0] != conv.Session() {
+		t.Errorf("unexpected sessions %v", sessions)
+	}
+
+	fail = false
+	_, err = conv.Ask(context.Background(), "Are you there?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report := analytics.Report(conv.Session(), nil); report.Abandoned || report.Turns != 2 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	analytics.Forget(conv.Session())
+	if len(analytics.Sessions()) != 0 {
+		t.Error("session not forgotten")
+	}
+}
@@ -0,0 +1,47 @@
+package opencat_api
+
+import (
+	"net/http"
+)
+
+// AuthScheme is how the token is sent to the API, see WithAuth.
+// The zero value sends it in an Authorization header with the Bearer prefix.
+type AuthScheme struct {
+	// Header is a header that carries the bare token, like api-key.
+	Header string
+	// Query is a query parameter that carries the token, like key.
+	Query string
+}
+
+// AuthBearer sends the token as "Authorization: Bearer <token>", the default.
+var AuthBearer = AuthScheme{}
+
+// AuthHeader sends the token in the header name, as used by Azure OpenAI and some self-hosted proxies.
+func AuthHeader(name string) AuthScheme {
+	return AuthScheme{Header: name}
+}
+
+// AuthQuery sends the token in the query parameter name.
+func AuthQuery(name string) AuthScheme {
+	return AuthScheme{Query: name}
+}
+
+// WithAuth sets how the client authenticates to the API.
+func WithAuth(scheme AuthScheme) ClientOption {
+	return func(c *Client) {
+		c.configOption(func(cfg *Config) { cfg.Auth = scheme })
+	}
+}
+
+func (a AuthScheme) apply(req *http.Request, token string) {
+	switch {
+	case a.Query != "":
+		q := req.URL.Query()
+		q.Set(a.Query, token)
+		req.URL.RawQuery = q.Encode()
+	case a.Header != "":
+		req.Header.Set(a.Header, token)
+	default:
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
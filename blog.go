@@ -0,0 +1,117 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// BlogRequest turns a recording, such as a talk or a podcast episode, into a blog post.
+type BlogRequest struct {
+	Transcription TranscriptionRequest
+	// Model writes the post.
+	Model ChatModel
+	// ChunkTokens is the most tokens of transcript the model rewrites at once, 2000 by default.
+	// Longer transcripts are split between sentences and their sections concatenated.
+	ChunkTokens int
+}
+
+// BlogSection is a section of a blog post.
+type BlogSection struct {
+	Heading string `json:"heading" description:"short heading of the section"`
+	Body    string `json:"body" description:"Markdown paragraphs of the section, without headings"`
+}
+
+// Blog is a blog post written from a transcript.
+type Blog struct {
+	Title    string
+	Sections []BlogSection
+	// Transcript is the text the post was written from.
+	Transcript string
+}
+
+// Markdown returns the post with the title as a level 1 heading and the sections as level 2 headings.
+func (b Blog) Markdown() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "# %s\n", b.Title)
+	for _, section := range b.Sections {
+		fmt.Fprintf(&s, "\n## %s\n\n%s\n", section.Heading, strings.TrimSpace(section.Body))
+	}
+	return s.String()
+}
+
+// blogPart is what the model writes from a chunk of the transcript.
+type blogPart struct {
+	Title    string        `json:"title" description:"title of the whole post"`
+	Sections []BlogSection `json:"sections"`
+}
+
+const blogPrompt = "Rewrite this part of a transcript as sections of a blog post. " +
+	"Keep the content and the voice of the speaker, drop filler words, repetitions and small talk."
+
+// TranscribeToBlog transcribes a recording, then has the model rewrite the transcript into the sections
+// of a blog post, one chunk of transcript at a time, see ChatInto.
+func (c *Client) TranscribeToBlog(ctx context.Context, req BlogRequest) (Blog, error) {
+	t, err := c.Transcribe(ctx, req.Transcription)
+	if err != nil {
+		return Blog{}, fmt.Errorf("transcribe: %w", err)
+	}
+	blog := Blog{Transcript: t.Text}
+	maxTokens := req.ChunkTokens
+	if maxTokens <= 0 {
+		maxTokens = 2000
+	}
+	chunks := chunkText(ChatRequest{Model: req.Model}.provider(), t.Text, maxTokens)
+	if len(chunks) == 0 {
+		return Blog{}, errors.New("transcript is empty")
+	}
+	for i, chunk := range chunks {
+		prompt := blogPrompt
+		if len(blog.Sections) > 0 {
+			prompt += fmt.Sprintf(" It follows a section headed %q.", blog.Sections[len(blog.Sections)-1].Heading)
+		}
+		part, err := ChatInto[blogPart](
+			ctx, c, ChatRequest{
+				Model: req.Model,
+				Messages: []Message{
+					{Role: RoleSystem, Content: prompt},
+					{Role: RoleUser, Content: chunk},
+				},
+			},
+		)
+		if err != nil {
+			return Blog{}, fmt.Errorf("rewrite chunk %d of %d: %w", i+1, len(chunks), err)
+		}
+		if blog.Title == "" {
+			blog.Title = part.Title
+		}
+		blog.Sections = append(blog.Sections, part.Sections...)
+	}
+	return blog, nil
+}
+
+// chunkText splits text between sentences into chunks of about maxTokens tokens for the tokenizer of provider.
+// A sentence longer than maxTokens is a chunk of its own.
+func chunkText(provider Provider, text string, maxTokens int) []string {
+	var chunks []string
+	var chunk strings.Builder
+	tokens := 0
+	for _, sentence := range splitSentences(text) {
+		n := estimateTextTokens(provider, sentence)
+		if chunk.Len() > 0 && tokens+n > maxTokens {
+			chunks = append(chunks, chunk.String())
+			chunk.Reset()
+			tokens = 0
+		}
+		if chunk.Len() > 0 {
+			chunk.WriteByte(' ')
+		}
+		chunk.WriteString(sentence)
+		tokens += n
+	}
+	if chunk.Len() > 0 {
+		chunks = append(chunks, chunk.String())
+	}
+	return chunks
+}
@@ -0,0 +1,56 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTranscribeToBlog(t *testing.T) {
+	var chunks []string
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/v1/audio/transcriptions" {
+				io.WriteString(w, `{"text": "First point. Second point. Third point."}`)
+				return
+			}
+			var req ChatRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			chunk := req.Messages[len(req.Messages)-1].Content
+			chunks = append(chunks, chunk)
+			part, _ := json.Marshal(
+				blogPart{
+					Title:    fmt.Sprintf("Title %d", len(chunks)),
+					Sections: []BlogSection{{Heading: chunk, Body: "About " + chunk}},
+				},
+			)
+			content, _ := json.Marshal(string(part))
+			fmt.Fprintf(w, `{"choices": [{"message": {"role": "assistant", "content": %s}}]}`, content)
+		},
+	)
+
+	blog, err := c.TranscribeToBlog(
+		context.Background(), BlogRequest{
+			Transcription: TranscriptionRequest{
+				Model: TranscriptionModelWhisper1, Audio: strings.NewReader("ID3..."), Filename: "talk.mp3",
+			},
+			Model:       ChatModelGPT4,
+			ChunkTokens: 8,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 || chunks[0] != "First point. Second point." || chunks[1] != "Third point." {
+		t.Errorf("unexpected chunks: %q", chunks)
+	}
+	want := "# Title 1\n\n## First point. Second point.\n\nAbout First point. Second point.\n" +
+		"\n## Third point.\n\nAbout Third point.\n"
+	if got := blog.Markdown(); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
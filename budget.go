@@ -0,0 +1,150 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrConversationBudgetExceeded is returned by the questions of a conversation that used up its budget,
+// see Conversation.SetBudget.
+var ErrConversationBudgetExceeded = errors.New("conversation budget exceeded")
+
+// ConversationBudget caps what a conversation uses over its lifetime, so runaway sessions can't consume
+// unbounded quota. It is soft: the question that crosses it is answered, the next ones are not.
+type ConversationBudget struct {
+	// Tokens caps the prompt and reply tokens of all questions, 0 for no limit.
+	Tokens int
+	// Cost caps the cost of all questions, counting models priced in Cost.Currency, see PriceOf.
+	// A zero amount is no limit, an empty currency is USD.
+	Cost Cost
+	// SummarizeWith, if set, makes the conversation replace its history with a summary written by that model
+	// once the budget is used up, and start counting again, instead of failing with ErrConversationBudgetExceeded.
+	SummarizeWith ChatModel
+}
+
+// MessageUsage is what a reply of a conversation used.
+type MessageUsage struct {
+	Model            ChatModel
+	PromptTokens     int
+	CompletionTokens int
+	// Estimated is set when the API didn't report the usage, such as for streams, and the tokens were estimated.
+	Estimated bool
+	// Cost is zero for models without a price, see PriceOf.
+	Cost Cost
+}
+
+type conversationSpend struct {
+	tokens int
+	cost   float64
+	// from is the index of the first usage counted.
+	from int
+}
+
+// SetBudget sets the budget of the conversation, counting from what it has used so far.
+func (conv *Conversation) SetBudget(budget ConversationBudget) {
+	if budget.Cost.Currency == "" {
+		budget.Cost.Currency = "USD"
+	}
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	if budget.Cost.Currency != conv.budget.Cost.Currency {
+		conv.spent.cost = 0
+		for _, u := range conv.usage[conv.spent.from:] {
+			if u.Cost.Currency == budget.Cost.Currency {
+				conv.spent.cost += u.Cost.Amount
+			}
+		}
+	}
+	conv.budget = budget
+}
+
+// Spent returns the tokens and cost used by the conversation, since its creation or since it was last summarized
+// to stay within its budget. The cost counts models priced in the currency of the budget.
+func (conv *Conversation) Spent() (tokens int, cost Cost) {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	return conv.spent.tokens, Cost{Amount: conv.spent.cost, Currency: conv.budget.Cost.Currency}
+}
+
+// Usage returns what each reply of the conversation used, in order. It includes the replies no longer
+// in the history, since they were paid for all the same.
+func (conv *Conversation) Usage() []MessageUsage {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	return slices.Clone(conv.usage)
+}
+
+// Cost returns the total cost of the replies of the conversation, counting models priced in the currency
+// of its budget, USD by default. Unlike Spent, it isn't reset when the conversation is summarized.
+func (conv *Conversation) Cost() Cost {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	total := Cost{Currency: conv.budget.Cost.Currency}
+	for _, u := range conv.usage {
+		if u.Cost.Currency == total.Currency {
+			total.Amount += u.Cost.Amount
+		}
+	}
+	return total
+}
+
+// addSpent adds the tokens used by a question to model, and queues a BudgetEvent if the cost budget crossed
+// a threshold. estimated is set if the tokens were estimated. conv.mu must be held.
+func (conv *Conversation) addSpent(model ChatModel, prompt, completion int, estimated bool) {
+	conv.spent.tokens += prompt + completion
+	price, ok := PriceOf(model)
+	u := MessageUsage{Model: model, PromptTokens: prompt, CompletionTokens: completion, Estimated: estimated}
+	if ok {
+		u.Cost = price.cost(prompt, completion)
+	}
+	conv.usage = append(conv.usage, u)
+	if !ok || price.Currency != conv.budget.Cost.Currency {
+		return
+	}
+	before := conv.spent.cost
+	conv.spent.cost += u.Cost.Amount
+	if threshold := crossedThreshold(before, conv.spent.cost, conv.budget.Cost.Amount); threshold > 0 {
+		conv.pending = append(
+			conv.pending, BudgetEvent{
+				Time:      conv.client.clock.Now(),
+				Session:   conv.session,
+				Threshold: threshold,
+				Spent:     Cost{Amount: conv.spent.cost, Currency: conv.budget.Cost.Currency},
+				Budget:    conv.budget.Cost,
+			},
+		)
+	}
+}
+
+// unlock releases conv.mu, then publishes the events queued while it was held,
+// so subscribers can use the conversation.
+func (conv *Conversation) unlock() {
+	pending := conv.pending
+	conv.pending = nil
+	conv.mu.Unlock()
+	for _, e := range pending {
+		conv.client.events.Publish(e)
+	}
+}
+
+// checkBudget returns ErrConversationBudgetExceeded if the budget is used up, or summarizes the history
+// if the budget says so. conv.mu must be held.
+func (conv *Conversation) checkBudget(ctx context.Context) error {
+	b := conv.budget
+	exceeded := b.Tokens > 0 && conv.spent.tokens >= b.Tokens || b.Cost.Amount > 0 && conv.spent.cost >= b.Cost.Amount
+	if !exceeded {
+		return nil
+	}
+	if b.SummarizeWith == "" || len(conv.history) == 0 {
+		return ErrConversationBudgetExceeded
+	}
+	summary, err := summarize(ctx, conv.client, b.SummarizeWith, conv.history)
+	if err != nil {
+		return fmt.Errorf("summarize history: %w", err)
+	}
+	conv.history = []Message{{Role: RoleSystem, Content: summary}}
+	conv.spent = conversationSpend{from: len(conv.usage)}
+	return nil
+}
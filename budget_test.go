@@ -0,0 +1,192 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestConversationBudget(t *testing.T) {
+	var requests []ChatRequest
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var req ChatRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			requests = append(requests, req)
+			fmt.Fprint(
+				w, `{"choices": [{"message": {"role": "assistant", "content": "ok"}}],`+
+					`"usage": {"prompt_tokens": 60, "completion_tokens": 10, "total_tokens": 70}}`,
+			)
+		},
+	)
+
+	conv := NewConversation(c, ChatModelGPT4)
+	conv.SetBudget(ConversationBudget{Tokens: 100, Cost: Cost{Amount: 1, Currency: "USD"}})
+	_, err := conv.Ask(context.Background(), "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Soft: under the budget before the question, over it after.
+	_, err = conv.Ask(context.Background(), "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, cost := conv.Spent()
+	if tokens != 140 || cost.Currency != "USD" || cost.Amount < 0.0047 || cost.Amount > 0.0049 {
+		t.Errorf("spent %d tokens, %+v", tokens, cost)
+	}
+	_, err = conv.Ask(context.Background(), "third")
+	if !errors.Is(err, ErrConversationBudgetExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(requests) != 2 {
+		t.Errorf("%d requests sent, want 2", len(requests))
+	}
+
+	conv.SetBudget(ConversationBudget{Tokens: 100, SummarizeWith: ChatModelGPT3Dot5Turbo})
+	_, err = conv.Ask(context.Background(), "third")
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, question := requests[2], requests[3]
+	if summary.Model != ChatModelGPT3Dot5Turbo || !strings.Contains(summary.Messages[0].Content, "second") {
+		t.Errorf("unexpected summary request: %+v", summary)
+	}
+	if len(question.Messages) != 2 || !strings.HasPrefix(question.Messages[0].Content, "Summary of") {
+		t.Errorf("history not replaced by the summary: %+v", question.Messages)
+	}
+	if tokens, _ := conv.Spent(); tokens != 70 {
+		t.Errorf("spent %d tokens after summarizing, want 70", tokens)
+	}
+}
+
+func TestConversationBudgetSetLate(t *testing.T) {
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(
+				w, `{"choices": [{"message": {"role": "assistant", "content": "ok"}}],`+
+					`"usage": {"prompt_tokens": 60, "completion_tokens": 10, "total_tokens": 70}}`,
+			)
+		},
+	)
+
+	conv := NewConversation(c, ChatModelGPT4)
+	_, err := conv.Ask(context.Background(), "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, cost := conv.Spent(); cost.Currency != "USD" || cost.Amount < 0.0023 || cost.Amount > 0.0025 {
+		t.Errorf("spent %+v before setting a budget", cost)
+	}
+	// The cost of the first question counts towards a budget set after it.
+	conv.SetBudget(ConversationBudget{Cost: Cost{Amount: 0.002}})
+	_, err = conv.Ask(context.Background(), "second")
+	if !errors.Is(err, ErrConversationBudgetExceeded) {
+		t.Errorf("expected the budget to be exceeded, got %v", err)
+	}
+	// Models priced in another currency don't count.
+	conv.SetBudget(ConversationBudget{Cost: Cost{Amount: 0.002, Currency: "CNY"}})
+	if _, cost := conv.Spent(); cost != (Cost{Currency: "CNY"}) {
+		t.Errorf("spent %+v in CNY", cost)
+	}
+}
+
+func TestConversationBudgetFailures(t *testing.T) {
+	fail := true
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			if fail {
+				http.Error(w, `{"error": {"message": "overloaded"}}`, http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`)
+		},
+	)
+
+	conv := NewConversation(c, ChatModelGPT4)
+	conv.SetBudget(ConversationBudget{Cost: Cost{Amount: 0.0001}})
+	for i := 0; i < 3; i++ {
+		_, err := conv.AskStream(context.Background(), "Hi", func(string, bool) {})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+	if tokens, cost := conv.Spent(); tokens != 0 || cost.Amount != 0 {
+		t.Errorf("failed questions charged %d tokens, %+v", tokens, cost)
+	}
+
+	fail = false
+	var events []BudgetEvent
+	c.Events().Subscribe(
+		func(e Event) {
+			if e, ok := e.(BudgetEvent); ok {
+				events = append(events, e)
+				// Subscribers can use the conversation.
+				conv.Spent()
+			}
+		},
+	)
+	_, err := conv.Ask(context.Background(), "Hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Session != conv.Session() || events[0].Threshold != 1 {
+		t.Errorf("unexpected budget events %+v", events)
+	}
+	if _, cost := conv.Spent(); cost.Currency != "USD" || cost.Amount == 0 {
+		t.Errorf("spent %+v on a budget without currency", cost)
+	}
+	_, err = conv.Ask(context.Background(), "Hi")
+	if !errors.Is(err, ErrConversationBudgetExceeded) {
+		t.Errorf("budget without currency not enforced: %v", err)
+	}
+}
+
+func TestConversationUsage(t *testing.T) {
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var req ChatRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if req.Stream {
+				fmt.Fprint(w, "data: {\"choices\": [{\"delta\": {\"content\": \"streamed\"}}]}\n\ndata: [DONE]\n\n")
+				return
+			}
+			fmt.Fprint(
+				w, `{"choices": [{"message": {"role": "assistant", "content": "ok"}}],`+
+					`"usage": {"prompt_tokens": 60, "completion_tokens": 10, "total_tokens": 70}}`,
+			)
+		},
+	)
+
+	conv := NewConversation(c, ChatModelGPT4)
+	_, err := conv.Ask(context.Background(), "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conv.AskStream(context.Background(), "second", func(string, bool) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := conv.Usage()
+	if len(usage) != 2 {
+		t.Fatalf("usage of %d replies, want 2", len(usage))
+	}
+	first := usage[0]
+	if first.Model != ChatModelGPT4 || first.PromptTokens != 60 || first.CompletionTokens != 10 || first.Estimated ||
+		first.Cost.Currency != "USD" || first.Cost.Amount < 0.0023 || first.Cost.Amount > 0.0025 {
+		t.Errorf("unexpected usage of the reply: %+v", first)
+	}
+	if !usage[1].Estimated || usage[1].CompletionTokens == 0 || usage[1].Cost.Amount == 0 {
+		t.Errorf("unexpected usage of the streamed reply: %+v", usage[1])
+	}
+
+	conv.Reset()
+	total := conv.Cost()
+	if total.Currency != "USD" || total.Amount != first.Cost.Amount+usage[1].Cost.Amount {
+		t.Errorf("unexpected cost %+v of %+v", total, usage)
+	}
+}
@@ -1,19 +1,26 @@
 package opencat_api
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
-	"html"
 	"io"
 	"net/http"
+	"os"
+	"slices"
+	"sort"
 	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/j178/opencat-api/internal/sse"
 )
 
+// baseURL is the default API endpoint.
 const baseURL = "https://api.opencat.app"
 
 type ImageModel string
@@ -53,6 +60,20 @@ var (
 	ChatModelSparkDeskV3 ChatModel = "SparkDesk-V3.0"
 )
 
+// Provider is the vendor behind a chat model.
+type Provider string
+
+var (
+	ProviderOpenAI    Provider = "openai"
+	ProviderAnthropic Provider = "anthropic"
+	ProviderGoogle    Provider = "google"
+	ProviderBaidu     Provider = "baidu"
+	ProviderAlibaba   Provider = "alibaba"
+	// ProviderAzure serves OpenAI models from Azure, see WithProvider.
+	ProviderAzure   Provider = "azure"
+	ProviderIFlytek Provider = "iflytek"
+)
+
 type SpeechModel string
 
 var (
@@ -67,29 +88,317 @@ var (
 	RoleSystem    Role = "system"
 	RoleUser      Role = "user"
 	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
 )
 
 type Message struct {
 	Role    Role    `json:"role"`
 	Content string  `json:"content"`
 	Images  []Image `json:"images,omitempty"`
+	// Parts is the content of a message that interleaves text, images and audio. It is sent after Content.
+	Parts []ContentPart `json:"-"`
+	// ToolCalls are the tools called by an assistant message.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID is the call a RoleTool message is the result of.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+// Tool is a function the model may call.
+type Tool struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+type FunctionDefinition struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Parameters is the JSON schema of the function arguments.
+	Parameters any `json:"parameters"`
+}
+
+// NewFunctionTool returns a function Tool, parameters is its JSON schema.
+func NewFunctionTool(name, description string, parameters any) Tool {
+	return Tool{
+		Type: "function",
+		Function: FunctionDefinition{
+			Name:        name,
+			Description: description,
+			Parameters:  parameters,
+		},
+	}
+}
+
+// ToolChoice controls which tool the model calls, see ToolChoiceAuto, ToolChoiceNone and ToolChoiceFunction.
+type ToolChoice struct {
+	Type     string
+	Function string
 }
 
+var (
+	ToolChoiceAuto = &ToolChoice{Type: "auto"}
+	ToolChoiceNone = &ToolChoice{Type: "none"}
+)
+
+// ToolChoiceFunction forces the model to call the named function.
+func ToolChoiceFunction(name string) *ToolChoice {
+	return &ToolChoice{Type: "function", Function: name}
+}
+
+func (t ToolChoice) MarshalJSON() ([]byte, error) {
+	if t.Function == "" {
+		return json.Marshal(t.Type)
+	}
+	var v struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	v.Type = t.Type
+	v.Function.Name = t.Function
+	return json.Marshal(v)
+}
+
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name string `json:"name"`
+	// Arguments is the JSON encoded arguments generated by the model, it is not guaranteed to be valid.
+	Arguments string `json:"arguments"`
+}
+
+// ResponseFormat constrains the format of the model output, see ResponseFormatJSON.
+type ResponseFormat struct {
+	Type string `json:"type"`
+}
+
+var (
+	ResponseFormatText = &ResponseFormat{Type: "text"}
+	// ResponseFormatJSON makes the model output a valid JSON object.
+	// The messages must instruct the model to produce JSON, otherwise the request is rejected.
+	ResponseFormatJSON = &ResponseFormat{Type: "json_object"}
+)
+
 type ChatRequest struct {
-	Temperature float64   `json:"temperature,omitempty"`
-	MaxTokens   int       `json:"maxTokens,omitempty"`
-	Model       ChatModel `json:"model"`
-	Stream      bool      `json:"stream,omitempty"`
-	Messages    []Message `json:"messages"`
+	Temperature      float64  `json:"temperature,omitempty"`
+	TopP             float64  `json:"top_p,omitempty"`
+	PresencePenalty  float64  `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float64  `json:"frequency_penalty,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	// LogitBias maps token IDs, as strings, to a bias from -100 to 100 added to their logits.
+	// -100 effectively bans a token and 100 forces it.
+	LogitBias map[string]int `json:"logit_bias,omitempty"`
+	MaxTokens int            `json:"maxTokens,omitempty"`
+	// N is the number of choices to generate.
+	N     int       `json:"n,omitempty"`
+	Model ChatModel `json:"model"`
+	// Provider routes the request to a provider of the model other than the one inferred from its name,
+	// for gateways that serve a model from several upstreams, see WithProvider.
+	Provider       Provider        `json:"provider,omitempty"`
+	Stream         bool            `json:"stream,omitempty"`
+	Messages       []Message       `json:"messages"`
+	Tools          []Tool          `json:"tools,omitempty"`
+	ToolChoice     *ToolChoice     `json:"tool_choice,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	// Logprobs requests the log probabilities of the output tokens.
+	Logprobs bool `json:"logprobs,omitempty"`
+	// TopLogprobs is the number of most likely alternatives, up to 20, returned for each token. Requires Logprobs.
+	TopLogprobs int `json:"top_logprobs,omitempty"`
+	// Gemini are options only supported by Gemini models.
+	Gemini *GeminiParams `json:"gemini,omitempty"`
+	// Ernie are options only supported by ERNIE-Bot models.
+	Ernie *ErnieParams `json:"ernie,omitempty"`
+	// Qwen are options only supported by Qwen models.
+	Qwen *QwenParams `json:"qwen,omitempty"`
+	// Spark are options only supported by SparkDesk models.
+	Spark *SparkParams `json:"spark,omitempty"`
+	// Language is the BCP 47 tag of the language the reply must be in, like fr or zh-CN, see WithLanguage.
+	// The model is told to reply in it, and asked again once if the reply is detected to be in another
+	// language, so the request must not use images created with NewImage. Only supported by Chat.
+	Language string `json:"-"`
+	// Constraints limit the length and format of the reply, see WithConstraints.
+	// Like Language, they are enforced with one retry and only supported by Chat.
+	Constraints *OutputConstraints `json:"-"`
+}
+
+// RequestOption adjusts a ChatRequest before it is sent.
+type RequestOption func(*ChatRequest)
+
+func WithModel(model ChatModel) RequestOption {
+	return func(r *ChatRequest) {
+		r.Model = model
+	}
+}
+
+// WithProvider sends the request to the model as served by provider, like gpt-4 from ProviderAzure,
+// instead of the provider inferred from the model name. The request is translated and checked
+// for that provider, and the gateway is asked to route it there.
+func WithProvider(provider Provider) RequestOption {
+	return func(r *ChatRequest) {
+		r.Provider = provider
+	}
+}
+
+func WithTemperature(temperature float64) RequestOption {
+	return func(r *ChatRequest) {
+		r.Temperature = temperature
+	}
+}
+
+func WithMaxTokens(n int) RequestOption {
+	return func(r *ChatRequest) {
+		r.MaxTokens = n
+	}
+}
+
+func WithTools(tools ...Tool) RequestOption {
+	return func(r *ChatRequest) {
+		r.Tools = tools
+	}
+}
+
+func WithResponseFormat(format ResponseFormat) RequestOption {
+	return func(r *ChatRequest) {
+		r.ResponseFormat = &format
+	}
+}
+
+// prepareChat fills in defaults, applies the request options, replaces retired models and validates the result.
+func (c *Client) prepareChat(chat ChatRequest, opts []RequestOption) (ChatRequest, error) {
+	cfg := c.config()
+	if chat.Model == "" {
+		chat.Model = cfg.DefaultModel
+	}
+	// Aliases are resolved before the options, which may depend on the provider of the model,
+	// and after them, which may set an alias with WithModel.
+	cfg.resolveAlias(&chat)
+	for _, opt := range opts {
+		opt(&chat)
+	}
+	cfg.resolveAlias(&chat)
+	chat.Model = c.checkDeprecated(chat.Model)
+	return chat, chat.validate()
+}
+
+// provider returns the provider the request is sent to: Provider if set, otherwise the provider of the model.
+func (r ChatRequest) provider() Provider {
+	if r.Provider != "" {
+		return r.Provider
+	}
+	return providerOf(r.Model)
+}
+
+func (r ChatRequest) validate() error {
+	if r.ResponseFormat != nil && r.ResponseFormat.Type == ResponseFormatJSON.Type {
+		mentioned := false
+		for _, msg := range r.Messages {
+			if strings.Contains(strings.ToLower(msg.Text()), "json") {
+				mentioned = true
+				break
+			}
+		}
+		if !mentioned {
+			return errors.New("JSON response format requires a message, usually the system message, to ask for JSON")
+		}
+	}
+	if r.N > 1 && r.provider() == ProviderAnthropic {
+		return fmt.Errorf("%s does not support multiple choices", r.Model)
+	}
+	if p := r.provider(); len(r.LogitBias) > 0 && p != ProviderOpenAI && p != ProviderAzure {
+		return fmt.Errorf("%s does not support logit bias", r.Model)
+	}
+	for token, bias := range r.LogitBias {
+		if bias < -100 || bias > 100 {
+			return fmt.Errorf("logit bias of token %s must be between -100 and 100, got %d", token, bias)
+		}
+	}
+	if r.TopLogprobs < 0 || r.TopLogprobs > 20 {
+		return fmt.Errorf("TopLogprobs must be between 0 and 20, got %d", r.TopLogprobs)
+	}
+	if r.TopLogprobs > 0 && !r.Logprobs {
+		return errors.New("TopLogprobs requires Logprobs")
+	}
+	if r.Gemini != nil {
+		err := r.Gemini.validate(r.Model, r.provider())
+		if err != nil {
+			return err
+		}
+	}
+	if r.Ernie != nil {
+		err := r.Ernie.validate(r.Model, r.provider())
+		if err != nil {
+			return err
+		}
+	}
+	if r.Qwen != nil {
+		err := r.Qwen.validate(r.Model, r.provider())
+		if err != nil {
+			return err
+		}
+	}
+	if r.Spark != nil {
+		err := r.Spark.validate(r.Model, r.provider())
+		if err != nil {
+			return err
+		}
+	}
+	if r.Constraints != nil {
+		return r.Constraints.validate()
+	}
+	return nil
 }
 
 type ChatResponseChoice struct {
-	Index   int `json:"index"`
-	Message struct {
-		Content string `json:"content"`
-		Role    Role   `json:"role"`
-	} `json:"message"`
-	FinishReason string `json:"finish_reason"`
+	Index        int             `json:"index"`
+	Message      ResponseMessage `json:"message"`
+	FinishReason string          `json:"finish_reason"`
+	Logprobs     *ChoiceLogprobs `json:"logprobs,omitempty"`
+}
+
+// ChoiceLogprobs holds the log probabilities of the tokens of a choice, see ChatRequest.Logprobs.
+type ChoiceLogprobs struct {
+	Content []TokenLogprob `json:"content"`
+}
+
+type TokenLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	// Bytes is the UTF-8 encoding of the token, useful when a character spans several tokens.
+	Bytes []int `json:"bytes"`
+	// TopLogprobs are the most likely tokens at this position, see ChatRequest.TopLogprobs.
+	TopLogprobs []TopLogprob `json:"top_logprobs"`
+}
+
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes"`
+}
+
+// ChatDelta is an incremental update of a streamed chat response.
+type ChatDelta struct {
+	// Index is the index of the choice this delta belongs to.
+	Index        int
+	Content      string
+	ToolCalls    []ToolCallDelta
+	FinishReason string
+	Logprobs     *ChoiceLogprobs
+}
+
+// ToolCallDelta is a fragment of a streamed tool call.
+// The first fragment of a call carries its ID and function name,
+// the following ones carry pieces of the arguments to be concatenated.
+type ToolCallDelta struct {
+	// Index identifies the tool call within the choice.
+	Index    int          `json:"index"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
 }
 
 type ChatResponse struct {
@@ -98,21 +407,116 @@ type ChatResponse struct {
 	Created int64                `json:"created"`
 	Model   string               `json:"model"`
 	Choices []ChatResponseChoice `json:"choices"`
-	Usage   Usage                `json:"usage"`
+	Usage   TokenUsage           `json:"usage"`
 }
 
+// TokenUsage is the number of tokens used by a chat request. It is zero for models that don't report it.
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// DallEParams are the parameters of DALL-E 3, which DALL-E 2 ignores.
 type DallEParams struct {
+	// Quality is standard or hd, standard if empty.
 	Quality string `json:"quality"`
-	Style   string `json:"style"`
+	// Style is vivid or natural, vivid if empty.
+	Style string `json:"style"`
+}
+
+// SDXLSampler is the sampler Stable Diffusion XL denoises images with.
+type SDXLSampler string
+
+const (
+	SDXLSamplerDDIM             SDXLSampler = "DDIM"
+	SDXLSamplerDDPM             SDXLSampler = "DDPM"
+	SDXLSamplerDPMPP2M          SDXLSampler = "K_DPMPP_2M"
+	SDXLSamplerDPMPP2SAncestral SDXLSampler = "K_DPMPP_2S_ANCESTRAL"
+	SDXLSamplerDPM2             SDXLSampler = "K_DPM_2"
+	SDXLSamplerDPM2Ancestral    SDXLSampler = "K_DPM_2_ANCESTRAL"
+	SDXLSamplerEuler            SDXLSampler = "K_EULER"
+	SDXLSamplerEulerAncestral   SDXLSampler = "K_EULER_ANCESTRAL"
+	SDXLSamplerHeun             SDXLSampler = "K_HEUN"
+	SDXLSamplerLMS              SDXLSampler = "K_LMS"
+)
+
+var sdxlSamplers = []SDXLSampler{
+	SDXLSamplerDDIM, SDXLSamplerDDPM, SDXLSamplerDPMPP2M, SDXLSamplerDPMPP2SAncestral, SDXLSamplerDPM2,
+	SDXLSamplerDPM2Ancestral, SDXLSamplerEuler, SDXLSamplerEulerAncestral, SDXLSamplerHeun, SDXLSamplerLMS,
+}
+
+// SDXLStyle is a style preset that guides Stable Diffusion XL towards a style.
+type SDXLStyle string
+
+const (
+	SDXLStyle3DModel          SDXLStyle = "3d-model"
+	SDXLStyleAnalogFilm       SDXLStyle = "analog-film"
+	SDXLStyleAnime            SDXLStyle = "anime"
+	SDXLStyleCinematic        SDXLStyle = "cinematic"
+	SDXLStyleComicBook        SDXLStyle = "comic-book"
+	SDXLStyleDigitalArt       SDXLStyle = "digital-art"
+	SDXLStyleEnhance          SDXLStyle = "enhance"
+	SDXLStyleFantasyArt       SDXLStyle = "fantasy-art"
+	SDXLStyleIsometric        SDXLStyle = "isometric"
+	SDXLStyleLineArt          SDXLStyle = "line-art"
+	SDXLStyleLowPoly          SDXLStyle = "low-poly"
+	SDXLStyleModelingCompound SDXLStyle = "modeling-compound"
+	SDXLStyleNeonPunk         SDXLStyle = "neon-punk"
+	SDXLStyleOrigami          SDXLStyle = "origami"
+	SDXLStylePhotographic     SDXLStyle = "photographic"
+	SDXLStylePixelArt         SDXLStyle = "pixel-art"
+	SDXLStyleTileTexture      SDXLStyle = "tile-texture"
+)
+
+var sdxlStyles = []SDXLStyle{
+	SDXLStyle3DModel, SDXLStyleAnalogFilm, SDXLStyleAnime, SDXLStyleCinematic, SDXLStyleComicBook,
+	SDXLStyleDigitalArt, SDXLStyleEnhance, SDXLStyleFantasyArt, SDXLStyleIsometric, SDXLStyleLineArt,
+	SDXLStyleLowPoly, SDXLStyleModelingCompound, SDXLStyleNeonPunk, SDXLStyleOrigami, SDXLStylePhotographic,
+	SDXLStylePixelArt, SDXLStyleTileTexture,
 }
 
+// StableDiffusionXLParams are the parameters of Stable Diffusion XL. Zero values mean the defaults of the model.
 type StableDiffusionXLParams struct {
-	Steps       int    `json:"steps"`
-	Sampler     string `json:"sampler"`
-	StylePreset string `json:"style_preset"`
-	Scale       int    `json:"scale"`
+	// Steps is the number of diffusion steps, between 10 and 50.
+	Steps int `json:"steps"`
+	// Sampler is chosen by the model if empty.
+	Sampler     SDXLSampler `json:"sampler"`
+	StylePreset SDXLStyle   `json:"style_preset"`
+	// Scale is how strictly the image follows the prompt, between 0 and 35.
+	Scale int `json:"scale"`
+	// Seed makes the generation reproducible: the same request with the same seed generates the same image.
+	// Zero means a random seed, which is reported in GeneratedImage.Seed.
+	Seed uint32 `json:"seed,omitempty"`
 }
 
+func (p StableDiffusionXLParams) validate() error {
+	if p.Steps != 0 && (p.Steps < 10 || p.Steps > 50) {
+		return fmt.Errorf("steps must be between 10 and 50, got %d", p.Steps)
+	}
+	if p.Scale < 0 || p.Scale > 35 {
+		return fmt.Errorf("scale must be between 0 and 35, got %d", p.Scale)
+	}
+	if p.Sampler != "" && !slices.Contains(sdxlSamplers, p.Sampler) {
+		return fmt.Errorf("unknown sampler %q", p.Sampler)
+	}
+	if p.StylePreset != "" && !slices.Contains(sdxlStyles, p.StylePreset) {
+		return fmt.Errorf("unknown style preset %q", p.StylePreset)
+	}
+	return nil
+}
+
+// ImageFormat is how generated images are returned.
+type ImageFormat string
+
+const (
+	// ImageFormatData returns the content of the images, the default.
+	ImageFormatData ImageFormat = "b64_json"
+	// ImageFormatURL returns URLs the images can be downloaded from, for about an hour. It saves downloading
+	// large images that are passed on elsewhere.
+	ImageFormatURL ImageFormat = "url"
+)
+
 type ImageRequest struct {
 	Width             int                     `json:"width"`
 	Height            int                     `json:"height"`
@@ -122,12 +526,223 @@ type ImageRequest struct {
 	NegativePrompt    string                  `json:"negativePrompt"`
 	DallE             DallEParams             `json:"dallE,omitempty"`
 	StableDiffusionXL StableDiffusionXLParams `json:"stable_diffusion_xl,omitempty"`
+	// ResponseFormat is ImageFormatData if empty.
+	ResponseFormat ImageFormat `json:"response_format,omitempty"`
+	// Seed makes the generation reproducible with the models that support it, like Stable Diffusion XL,
+	// where it sets StableDiffusionXL.Seed. Zero means a random seed. DALL-E doesn't support seeds.
+	Seed uint32 `json:"seed,omitempty"`
+	// InitImage is an image Stable Diffusion XL starts from instead of noise, to generate a variation of it
+	// following the prompt. It is sent in the request as a data URI, so it can't be an image URL.
+	InitImage *Image `json:"init_image,omitempty"`
+	// Strength is how much of the init image is kept, between 0 and 1: 0 ignores it and 1 returns it unchanged.
+	// Zero means 0.35.
+	Strength float64 `json:"image_strength,omitempty"`
+}
+
+func (f ImageFormat) validate() error {
+	if f != "" && f != ImageFormatData && f != ImageFormatURL {
+		return fmt.Errorf("unknown image format %q", f)
+	}
+	return nil
+}
+
+// imageSizes are the sizes, width by height, DALL-E models can generate.
+var imageSizes = map[ImageModel][][2]int{
+	ImageModelDallE2: {{256, 256}, {512, 512}, {1024, 1024}},
+	ImageModelDallE3: {{1024, 1024}, {1792, 1024}, {1024, 1792}},
+}
+
+func (r ImageRequest) validate() error {
+	if r.Prompt == "" {
+		return errors.New("image request needs a prompt")
+	}
+	if sizes, ok := imageSizes[r.Model]; ok && !slices.Contains(sizes, [2]int{r.Width, r.Height}) {
+		supported := make([]string, len(sizes))
+		for i, size := range sizes {
+			supported[i] = fmt.Sprintf("%dx%d", size[0], size[1])
+		}
+		return fmt.Errorf(
+			"%s can't generate %dx%d images, only %s", r.Model, r.Width, r.Height, strings.Join(supported, ", "),
+		)
+	}
+	if _, ok := imageSizes[r.Model]; ok && r.Seed != 0 {
+		return fmt.Errorf("%s doesn't support seeds", r.Model)
+	}
+	if sdxl := r.StableDiffusionXL.Seed; r.Seed != 0 && sdxl != 0 && sdxl != r.Seed {
+		return fmt.Errorf("seed %d differs from the Stable Diffusion XL seed %d", r.Seed, sdxl)
+	}
+	if r.InitImage != nil {
+		if r.Model != ImageModelStableDiffusionXL {
+			return fmt.Errorf("%s can't start from an init image", r.Model)
+		}
+		if r.InitImage.url != "" {
+			return errors.New("init image must be uploaded, it can't be an image URL")
+		}
+	}
+	if r.Strength != 0 && r.InitImage == nil {
+		return errors.New("strength is only used with an init image")
+	}
+	if r.Strength < 0 || r.Strength > 1 {
+		return fmt.Errorf("strength must be between 0 and 1, got %v", r.Strength)
+	}
+	switch r.Model {
+	case ImageModelDallE2:
+		if r.Num < 0 || r.Num > 10 {
+			return fmt.Errorf("num must be between 1 and 10, got %d", r.Num)
+		}
+	case ImageModelDallE3:
+		if r.Num > 1 {
+			return fmt.Errorf("%s generates one image per request, got num %d", r.Model, r.Num)
+		}
+		if q := r.DallE.Quality; q != "" && q != "standard" && q != "hd" {
+			return fmt.Errorf("quality must be standard or hd, got %q", q)
+		}
+		if s := r.DallE.Style; s != "" && s != "vivid" && s != "natural" {
+			return fmt.Errorf("style must be vivid or natural, got %q", s)
+		}
+	case ImageModelStableDiffusionXL:
+		err := r.StableDiffusionXL.validate()
+		if err != nil {
+			return err
+		}
+	}
+	return r.ResponseFormat.validate()
+}
+
+// GeneratedImage is an image generated by Image, ImageEdit or ImageVariations.
+type GeneratedImage struct {
+	// Data is the content of the image, with ImageFormatData.
+	Data []byte
+	// URL is where the image can be downloaded from, with ImageFormatURL.
+	URL string
+	// RevisedPrompt is the prompt DALL-E 3 rewrote the request's into and generated the image from, if any.
+	RevisedPrompt string
+	// Seed is the seed the image was generated from, if the model reports it, like Stable Diffusion,
+	// or the seed of the request. Requesting it again reproduces the image.
+	Seed int64
+	// Filtered reports whether the content filter blurred or replaced the image, and FilterCategories
+	// lists the categories it flagged, like violence, when the server reports them.
+	Filtered         bool
+	FilterCategories []string
+}
+
+// SpeechFormat is the audio format of generated speech.
+type SpeechFormat string
+
+const (
+	SpeechMP3  SpeechFormat = "mp3"
+	SpeechOpus SpeechFormat = "opus"
+	SpeechAAC  SpeechFormat = "aac"
+	SpeechFLAC SpeechFormat = "flac"
+	SpeechWAV  SpeechFormat = "wav"
+	// SpeechPCM is raw 24kHz 16-bit signed little-endian samples, without a header.
+	SpeechPCM SpeechFormat = "pcm"
+)
+
+// azureSpeechFormats maps speech formats to the output formats of Azure, which doesn't support AAC and FLAC.
+var azureSpeechFormats = map[SpeechFormat]string{
+	SpeechMP3:  "audio-16khz-128kbitrate-mono-mp3",
+	SpeechOpus: "ogg-24khz-16bit-mono-opus",
+	SpeechWAV:  "riff-24khz-16bit-mono-pcm",
+	SpeechPCM:  "raw-24khz-16bit-mono-pcm",
 }
 
 type SpeechRequest struct {
 	Input string      `json:"input"`
 	Voice string      `json:"voice"`
 	Model SpeechModel `json:"model"`
+	// ResponseFormat is SpeechMP3 if empty.
+	ResponseFormat SpeechFormat `json:"response_format,omitempty"`
+	// Speed is between 0.25 and 4, or between 0.5 and 2 for Azure; 0 means the normal speed of 1.
+	Speed float64 `json:"speed,omitempty"`
+	// Azure overrides the Azure speech settings of the client for this request.
+	Azure AzureSpeechConfig `json:"-"`
+	// SSML is a complete SSML document, a speak element, sent to Azure instead of Input, to use breaks,
+	// styles, prosody or several voices. Voice, Speed and the language of the settings are then ignored.
+	// https://learn.microsoft.com/en-us/azure/ai-services/speech-service/speech-synthesis-markup
+	SSML string `json:"-"`
+}
+
+const defaultAzureRegion = "eastasia"
+
+// AzureSpeechConfig sets how speech is synthesized with SpeechModelAzure.
+type AzureSpeechConfig struct {
+	// Region is the region of the Azure speech resource, eastasia if empty.
+	Region string
+	// Language is the language of the input, like zh-CN. If empty, it is the locale of the voice,
+	// like zh-CN for zh-CN-XiaoxiaoNeural.
+	Language string
+	// OutputFormat is an Azure output format, like audio-48khz-192kbitrate-mono-mp3.
+	// The ResponseFormat of a request takes precedence over the OutputFormat of the client.
+	OutputFormat string
+}
+
+// validateSSML checks that ssml is a well-formed speak element, so mistakes fail before the request is sent.
+func validateSSML(ssml string) error {
+	dec := xml.NewDecoder(strings.NewReader(ssml))
+	root := ""
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid SSML: %w", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok && root == "" {
+			root = start.Name.Local
+		}
+	}
+	if root != "speak" {
+		return errors.New("invalid SSML: the root element must be speak")
+	}
+	return nil
+}
+
+// voiceLanguage returns the locale an Azure voice name starts with, or en-US.
+func voiceLanguage(voice string) string {
+	if i := strings.LastIndexByte(voice, '-'); i > 0 {
+		return voice[:i]
+	}
+	return "en-US"
+}
+
+// WithAzureSpeech sets the Azure speech settings of the client, see AzureSpeechConfig.
+func WithAzureSpeech(azure AzureSpeechConfig) ClientOption {
+	return func(c *Client) {
+		c.configOption(func(cfg *Config) { cfg.AzureSpeech = azure })
+	}
+}
+
+func (r SpeechRequest) validate() error {
+	if r.ResponseFormat != "" && !slices.Contains(
+		[]SpeechFormat{SpeechMP3, SpeechOpus, SpeechAAC, SpeechFLAC, SpeechWAV, SpeechPCM}, r.ResponseFormat,
+	) {
+		return fmt.Errorf("unknown speech format %q", r.ResponseFormat)
+	}
+	if r.SSML != "" {
+		if r.Model != SpeechModelAzure {
+			return errors.New("SSML is only supported by Azure")
+		}
+		if r.Input != "" || r.Speed != 0 {
+			return errors.New("SSML replaces the input and the speed, which must be set in the document")
+		}
+		err := validateSSML(r.SSML)
+		if err != nil {
+			return err
+		}
+	}
+	minSpeed, maxSpeed := 0.25, 4.0
+	if r.Model == SpeechModelAzure {
+		minSpeed, maxSpeed = 0.5, 2
+		if _, ok := azureSpeechFormats[r.ResponseFormat]; r.ResponseFormat != "" && r.Azure.OutputFormat == "" && !ok {
+			return fmt.Errorf("speech format %s is not supported by Azure", r.ResponseFormat)
+		}
+	}
+	if r.Speed != 0 && (r.Speed < minSpeed || r.Speed > maxSpeed) {
+		return fmt.Errorf("speed must be between %v and %v, got %v", minSpeed, maxSpeed, r.Speed)
+	}
+	return nil
 }
 
 type Usage struct {
@@ -152,84 +767,296 @@ func (e *APIError) Error() string {
 	return fmt.Sprintf("API returned error: code=%d, body=%s", e.HTTPStatusCode, e.Body)
 }
 
+// ErrStreamInterrupted is returned by StreamChat when the stream is cut off before it completes,
+// e.g. because the context was cancelled. Content holds the text received so far.
+type ErrStreamInterrupted struct {
+	Content string
+	// Choices holds the text received so far for each choice when ChatRequest.N > 1, Content is Choices[0].
+	Choices []string
+	Err     error
+}
+
+func (e *ErrStreamInterrupted) Error() string {
+	return fmt.Sprintf("stream interrupted after %d bytes: %v", len(e.Content), e.Err)
+}
+
+func (e *ErrStreamInterrupted) Unwrap() error {
+	return e.Err
+}
+
 type Client struct {
-	token  string
-	client http.Client
+	cfg atomic.Pointer[Config]
+	// options are the changes of ClientOptions to the configuration, applied again by ApplyConfig.
+	options    []func(cfg *Config)
+	client     http.Client
+	events     *EventBus
+	scoreboard scoreboard
+	spend      spendTracker
+	limiters   rateLimiters
+	tenants    *TenantLimiter
+	clock      Clock
+	usage      usageRatios
+	moderation bool
+	imageJobs  imageJobs
+}
+
+type ClientOption func(*Client)
+
+// WithStreamReconnect makes StreamChat reconnect up to n times when a stream drops midway.
+// Content already received is sent back as an assistant prefix so the model continues where it stopped,
+// which only works for models that support prefilling the reply (Claude). For other models, a stream is
+// only reconnected if it dropped before any content arrived.
+func WithStreamReconnect(n int) ClientOption {
+	return func(c *Client) {
+		c.configOption(func(cfg *Config) { cfg.StreamReconnects = n })
+	}
 }
 
-func NewClient(token string) *Client {
-	return &Client{
-		token: token,
+func NewClient(token string, opts ...ClientOption) *Client {
+	c := &Client{
+		events: NewEventBus(),
+		clock:  realClock{},
+	}
+	c.cfg.Store(&Config{Token: token, BaseURL: baseURL})
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 type Image struct {
-	r io.Reader
+	r    io.Reader
+	data []byte
+	url  string
+	// mimeType overrides the type sniffed from the content.
+	mimeType string
+	detail   ImageDetail
 }
 
+// NewImage reads an image from r when the request is sent.
+// Since r can only be read once, such an image can't be sent again, e.g. by a retry. See NewImageFromBytes.
 func NewImage(r io.Reader) Image {
 	return Image{r: r}
 }
 
+// NewImageFromBytes returns an image that can be sent any number of times.
+func NewImageFromBytes(data []byte) Image {
+	return Image{data: data}
+}
+
+// NewImageFromFile reads an image file.
+func NewImageFromFile(path string) (Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Image{}, err
+	}
+	return NewImageFromBytes(data), nil
+}
+
+// replayable returns an image that can be sent any number of times, reading a reader-backed image into memory.
+func (img Image) replayable() (Image, error) {
+	if img.r == nil {
+		return img, nil
+	}
+	data, err := io.ReadAll(img.r)
+	if err != nil {
+		return Image{}, err
+	}
+	img.r, img.data = nil, data
+	return img, nil
+}
+
+// NewImageURL references a remote image by its http(s) URL, which the model fetches itself.
+func NewImageURL(url string) Image {
+	return Image{url: url}
+}
+
+// WithMIMEType returns the image with its type set to mimeType, like image/png, instead of detecting it from the content.
+func (img Image) WithMIMEType(mimeType string) Image {
+	img.mimeType = mimeType
+	return img
+}
+
+// ImageDetail is the resolution at which a vision model looks at an image, trading accuracy for tokens.
+type ImageDetail string
+
+const (
+	// ImageDetailLow looks at a 512x512 version of the image for a fixed, small number of tokens.
+	ImageDetailLow ImageDetail = "low"
+	// ImageDetailHigh looks at the details of the image, for up to several times more tokens.
+	ImageDetailHigh ImageDetail = "high"
+	// ImageDetailAuto lets the model choose depending on the size of the image.
+	ImageDetailAuto ImageDetail = "auto"
+)
+
+// WithDetail returns the image with its detail level set. Without it, the model's default, usually auto, is used.
+func (img Image) WithDetail(detail ImageDetail) Image {
+	img.detail = detail
+	return img
+}
+
+// MarshalJSON encodes the image as its URL or data URI, or as an object with the URL and the detail level if set.
 func (img *Image) MarshalJSON() ([]byte, error) {
-	buf := bytes.NewBuffer(nil)
-	buf.WriteString(`"data:image/jpeg;base64,`)
-	enc := base64.NewEncoder(base64.StdEncoding, buf)
-	_, err := io.Copy(enc, img.r)
+	uri, err := img.uri()
 	if err != nil {
 		return nil, err
 	}
+	if img.detail == "" {
+		return json.Marshal(uri)
+	}
+	return json.Marshal(
+		struct {
+			URL    string      `json:"url"`
+			Detail ImageDetail `json:"detail"`
+		}{uri, img.detail},
+	)
+}
+
+// UnmarshalJSON decodes an image encoded by MarshalJSON. Images sent as data URIs keep their content and type.
+func (img *Image) UnmarshalJSON(data []byte) error {
+	var ref struct {
+		URL    string      `json:"url"`
+		Detail ImageDetail `json:"detail"`
+	}
+	var err error
+	if len(data) > 0 && data[0] == '"' {
+		err = json.Unmarshal(data, &ref.URL)
+	} else {
+		err = json.Unmarshal(data, &ref)
+	}
+	if err != nil {
+		return err
+	}
+	decoded, err := imageFromURI(ref.URL)
+	if err != nil {
+		return err
+	}
+	*img = decoded.WithDetail(ref.Detail)
+	return nil
+}
+
+// imageFromURI returns the image of a URL, or of the content of a data URI.
+func imageFromURI(uri string) (Image, error) {
+	header, data, ok := strings.Cut(uri, ",")
+	if !ok || !strings.HasPrefix(header, "data:") {
+		return NewImageURL(uri), nil
+	}
+	mimeType, ok := strings.CutSuffix(strings.TrimPrefix(header, "data:"), ";base64")
+	if !ok {
+		return Image{}, fmt.Errorf("image data URI is not base64: %.40s", uri)
+	}
+	content, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return Image{}, err
+	}
+	return NewImageFromBytes(content).WithMIMEType(mimeType), nil
+}
+
+// uri returns the URL of the image, or its content as a data URI.
+func (img *Image) uri() (string, error) {
+	if img.url != "" {
+		return img.url, nil
+	}
+
+	r := img.r
+	if r == nil {
+		r = bytes.NewReader(img.data)
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	head = head[:n]
+	mimeType := img.mimeType
+	if mimeType == "" {
+		mimeType = http.DetectContentType(head)
+		if !strings.HasPrefix(mimeType, "image/") {
+			mimeType = "image/jpeg"
+		}
+	}
+
+	var buf strings.Builder
+	buf.WriteString("data:" + mimeType + ";base64,")
+	enc := base64.NewEncoder(base64.StdEncoding, &buf)
+	_, err = io.Copy(enc, io.MultiReader(bytes.NewReader(head), r))
+	if err != nil {
+		return "", err
+	}
 	err = enc.Close()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	buf.WriteString(`"`)
-	return buf.Bytes(), nil
+	return buf.String(), nil
 }
 
-func (c *Client) addHeaders(req *http.Request) {
+// newRequest creates an API request. The client configuration is captured once,
+// so a request is never built from a mix of old and new settings.
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	cfg := c.config()
+	backend := cfg.backend(ctx)
+	ctx = context.WithValue(ctx, configKey{}, cfg)
+	ctx = context.WithValue(ctx, endpointKey{}, endpointOf(path))
+	req, err := http.NewRequestWithContext(ctx, method, backend.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.token)
+	cfg.Auth.apply(req, backend.Token)
 	req.Header.Set("User-Agent", "OpenCat/424 CFNetwork/1490.0.4 Darwin/23.2.0")
 	req.Header.Set("Accept", "*/*")
+	return req, nil
 }
 
-func (c *Client) chat(ctx context.Context, chat ChatRequest) (*http.Response, error) {
-	var req *http.Request
-	var err error
-	if strings.HasPrefix(string(chat.Model), "claude") {
-		req, err = c.claudeRequest(ctx, chat)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		var body []byte
-		body, err = json.Marshal(chat)
-		if err != nil {
-			return nil, err
-		}
-		req, err = http.NewRequestWithContext(ctx, "POST", baseURL+"/1/chat", bytes.NewReader(body))
-		if err != nil {
-			return nil, err
-		}
-		c.addHeaders(req)
+// chat sends a chat request, translated by the adapter of its model, which is returned to decode the response.
+func (c *Client) chat(ctx context.Context, chat ChatRequest) (*http.Response, ProviderAdapter, error) {
+	adapter := adapterFor(c.config(), chat)
+	path, body, err := adapter.EncodeRequest(chat)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := c.newRequest(ctx, "POST", path, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
 	}
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req, string(chat.Model))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	return resp, adapter, nil
+}
+
+// Chat generates a response from a list of messages. If the model fails because its provider is down,
+// overloaded or unreachable, the request is sent to its fallbacks in turn, see Config.Fallbacks.
+func (c *Client) Chat(ctx context.Context, chat ChatRequest, opts ...RequestOption) (ChatResponse, error) {
+	chat, err := c.prepareChat(chat, opts)
+	if err != nil {
+		return ChatResponse{}, err
 	}
-	return resp, nil
+	return c.chatWithFallbacks(ctx, chat)
 }
 
-// Chat generates a response from a list of messages.
-func (c *Client) Chat(ctx context.Context, chat ChatRequest) (_ ChatResponse, err error) {
+// chatPrepared is Chat for a request already prepared by prepareChat.
+func (c *Client) chatPrepared(ctx context.Context, chat ChatRequest) (_ ChatResponse, err error) {
 	if chat.Stream {
 		err = errors.New("use StreamChat for streaming chat instead")
 		return
 	}
+	if chat.Language != "" || chat.Constraints != nil {
+		return c.chatConstrained(ctx, chat)
+	}
+	err = c.screen(ctx, chat)
+	if err != nil {
+		return
+	}
+
+	start := c.clock.Now()
+	defer func() {
+		c.recordCall(chat.Model, start, 0, err)
+	}()
 
-	resp, err := c.chat(ctx, chat)
+	resp, adapter, err := c.chat(ctx, chat)
 	if err != nil {
 		return
 	}
@@ -240,106 +1067,366 @@ func (c *Client) Chat(ctx context.Context, chat ChatRequest) (_ ChatResponse, er
 		return
 	}
 
-	if strings.HasPrefix(string(chat.Model), "claude") {
-		var r struct {
-			Type       string `json:"type"`
-			ID         string `json:"id"`
-			Model      string `json:"model"`
-			Completion string `json:"completion"`
-			StopReason string `json:"stop_reason"`
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	r, err := adapter.DecodeResponse(body)
+	if err != nil {
+		return
+	}
+	c.checkChatResponse(chat.Model, r)
+	var reply strings.Builder
+	for _, choice := range r.Choices {
+		reply.WriteString(choice.Message.Content)
+	}
+	c.recordTokens(ctx, chat, r.Usage, reply.String())
+	err = checkSafetyBlocked(chat, r)
+	if err != nil {
+		return
+	}
+	return r, nil
+}
+
+// StreamChat generates a response from a list of messages, and streams the response.
+// If the stream is cut off midway, the returned error is an *ErrStreamInterrupted
+// carrying the content received so far.
+// Use StreamChatDeltas to stream multiple choices.
+func (c *Client) StreamChat(
+	ctx context.Context,
+	chat ChatRequest,
+	fn func(delta string, done bool),
+	opts ...RequestOption,
+) error {
+	if chat.N > 1 {
+		return errors.New("use StreamChatDeltas for streaming multiple choices instead")
+	}
+	err := c.StreamChatDeltas(
+		ctx, chat, func(delta ChatDelta) {
+			if delta.Content != "" {
+				fn(delta.Content, false)
+			}
+		},
+		opts...,
+	)
+	if err != nil {
+		return err
+	}
+	fn("", true)
+	return nil
+}
+
+// StreamChatDeltas is like StreamChat, but passes every structured delta to fn,
+// including tool call fragments and finish reasons. With ChatRequest.N > 1, the deltas of
+// the choices are interleaved, tell them apart with ChatDelta.Index.
+func (c *Client) StreamChatDeltas(
+	ctx context.Context,
+	chat ChatRequest,
+	fn func(delta ChatDelta),
+	opts ...RequestOption,
+) (err error) {
+	chat, err = c.prepareChat(chat, opts)
+	if err != nil {
+		return err
+	}
+	if !chat.Stream {
+		return errors.New("use Chat for non-streaming chat instead")
+	}
+	if chat.Language != "" || chat.Constraints != nil {
+		return errors.New("the reply language and constraints can't be enforced on a stream")
+	}
+	err = c.screen(ctx, chat)
+	if err != nil {
+		return err
+	}
+
+	start := c.clock.Now()
+	var ttft time.Duration
+	defer func() {
+		c.recordCall(chat.Model, start, ttft, err)
+	}()
+
+	var (
+		// Partial content of each choice.
+		received     = map[int]*strings.Builder{}
+		sawToolCalls bool
+	)
+	interrupted := func(err error) *ErrStreamInterrupted {
+		e := &ErrStreamInterrupted{Choices: make([]string, max(chat.N, 1, len(received))), Err: err}
+		for i, b := range received {
+			if i < len(e.Choices) {
+				e.Choices[i] = b.String()
+			}
 		}
-		err = json.NewDecoder(resp.Body).Decode(&r)
-		if err != nil {
-			return
+		e.Content = e.Choices[0]
+		return e
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, reqCtx := chat, ctx
+		if attempt > 0 {
+			reqCtx = withRetry(ctx)
+		}
+		if len(received) > 0 {
+			// Let the model continue its partial reply.
+			req.Messages = append(
+				chat.Messages[:len(chat.Messages):len(chat.Messages)],
+				Message{Role: RoleAssistant, Content: received[0].String()},
+			)
 		}
 
-		cr := ChatResponse{
-			ID:      r.ID,
-			Object:  "chat.completion",
-			Model:   r.Model,
-			Choices: []ChatResponseChoice{{}},
+		var reply strings.Builder
+		err := c.streamChat(
+			reqCtx, req, func(delta ChatDelta) {
+				if ttft == 0 {
+					ttft = c.clock.Now().Sub(start)
+				}
+				reply.WriteString(delta.Content)
+				if delta.Content != "" {
+					b := received[delta.Index]
+					if b == nil {
+						b = &strings.Builder{}
+						received[delta.Index] = b
+					}
+					b.WriteString(delta.Content)
+				}
+				sawToolCalls = sawToolCalls || len(delta.ToolCalls) > 0
+				fn(delta)
+			},
+		)
+		if err == nil || reply.Len() > 0 {
+			// Streams don't report usage.
+			c.recordTokens(reqCtx, req, TokenUsage{}, reply.String())
 		}
-		cr.Choices[0].Message.Role = RoleAssistant
-		cr.Choices[0].Message.Content = r.Completion
-		cr.Choices[0].FinishReason = r.StopReason
-		return cr, nil
-	} else {
-		var r ChatResponse
-		err = json.NewDecoder(resp.Body).Decode(&r)
-		if err != nil {
-			return
+		if err == nil {
+			return nil
 		}
-		return r, nil
+
+		var streamErr *ErrStreamInterrupted
+		if !errors.As(err, &streamErr) {
+			if attempt == 0 {
+				return err
+			}
+			// Reconnecting failed.
+			return interrupted(err)
+		}
+		if ctx.Err() != nil || attempt >= c.config().StreamReconnects || sawToolCalls ||
+			(len(received) > 0 && (chat.N > 1 || !supportsPrefill(chat))) {
+			return interrupted(streamErr.Err)
+		}
+		c.events.Publish(
+			RetryEvent{
+				Time:    c.clock.Now(),
+				Model:   string(chat.Model),
+				Attempt: attempt + 2,
+				Cause:   streamErr.Err,
+			},
+		)
 	}
 }
 
-// StreamChat generates a response from a list of messages, and streams the response.
-func (c *Client) StreamChat(ctx context.Context, chat ChatRequest, fn func(delta string, done bool)) error {
-	if !chat.Stream {
-		return errors.New("use Chat for non-streaming chat instead")
-	}
-	resp, err := c.chat(ctx, chat)
+// supportsPrefill reports whether the model continues a trailing assistant message instead of starting a new one.
+func supportsPrefill(chat ChatRequest) bool {
+	return chat.provider() == ProviderAnthropic
+}
+
+// streamChat sends a streaming chat request and calls fn for every delta.
+func (c *Client) streamChat(ctx context.Context, chat ChatRequest, fn func(delta ChatDelta)) error {
+	resp, adapter, err := c.chat(ctx, chat)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+	if resp.StatusCode != 200 {
 		return NewAPIError(resp)
 	}
+	s := stream{c: c, chat: chat, adapter: adapter, fn: fn}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return s.fallback(ctx, resp)
+	}
+	return s.readEvents(ctx, resp.Body)
+}
+
+// stream reads the deltas of a streamed chat response.
+type stream struct {
+	c       *Client
+	chat    ChatRequest
+	adapter ProviderAdapter
+	fn      func(delta ChatDelta)
+}
 
-	r := bufio.NewReader(resp.Body)
+// readEvents reads the deltas of a server-sent events stream.
+func (s stream) readEvents(ctx context.Context, r io.Reader) error {
+	dec := sse.NewDecoder(r)
 	for {
-		line, err := r.ReadBytes('\n')
+		event, err := dec.Next()
 		if err != nil {
 			if errors.Is(err, io.EOF) {
-				break
+				return nil
 			}
-			return err
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			// The caller fills in the content received.
+			return &ErrStreamInterrupted{Err: err}
 		}
 
-		line = bytes.TrimSpace(line)
-		if !bytes.HasPrefix(line, []byte("data: ")) {
+		if event.Data == "[DONE]" {
+			return nil
+		}
+
+		deltas, err := s.adapter.DecodeStreamEvent([]byte(event.Data))
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			// The server failed midway, the caller fills in the content received.
+			return &ErrStreamInterrupted{Err: apiErr}
+		}
+		if err != nil {
+			s.c.warn(WarningStreamParse, s.chat.Model, "skipped %s event %q: %v", event.Event, event.Data, err)
 			continue
 		}
-		line = bytes.TrimPrefix(line, []byte("data: "))
-		if bytes.Equal(line, []byte("[DONE]")) {
-			break
+		for _, delta := range deltas {
+			s.fn(delta)
 		}
+	}
+}
+
+// claudeErrorStatus returns the HTTP status code of a type of Claude error sent in a stream.
+func claudeErrorStatus(typ string) int {
+	switch typ {
+	case "invalid_request_error":
+		return http.StatusBadRequest
+	case "authentication_error":
+		return http.StatusUnauthorized
+	case "permission_error":
+		return http.StatusForbidden
+	case "not_found_error":
+		return http.StatusNotFound
+	case "rate_limit_error":
+		return http.StatusTooManyRequests
+	case "overloaded_error":
+		return 529
+	default:
+		return http.StatusInternalServerError
+	}
+}
 
+// parseStreamEvent decodes the data of a stream event into deltas. It understands OpenCat's own chunks,
+// Claude completion chunks and Messages API events, and OpenAI style chat.completion.chunk objects.
+func parseStreamEvent(data []byte) ([]ChatDelta, error) {
+	var chunk struct {
+		Type string `json:"type"`
+		// Delta is a string in OpenCat chunks, and an object in Claude events.
+		Delta json.RawMessage `json:"delta"`
+		// Index and ContentBlock are those of Claude content block events.
+		Index        int                `json:"index"`
+		ContentBlock claudeContentBlock `json:"content_block"`
+		Completion   string             `json:"completion"`
+		FinishReason string             `json:"finishReason"`
+		StopReason   string             `json:"stop_reason"`
+		ToolCalls    []ToolCallDelta    `json:"toolCalls"`
+		Choices      []struct {
+			Index int `json:"index"`
+			Delta struct {
+				Content   string          `json:"content"`
+				ToolCalls []ToolCallDelta `json:"tool_calls"`
+			} `json:"delta"`
+			FinishReason string          `json:"finish_reason"`
+			Logprobs     *ChoiceLogprobs `json:"logprobs"`
+		} `json:"choices"`
+	}
+	err := json.Unmarshal(data, &chunk)
+	if err != nil {
+		return nil, err
+	}
+
+	switch chunk.Type {
+	case "", "completion":
+	case "content_block_start":
+		// Tool calls are identified by the index of their content block.
+		if chunk.ContentBlock.Type != "tool_use" {
+			return nil, nil
+		}
+		call := ToolCallDelta{
+			Index:    chunk.Index,
+			ID:       chunk.ContentBlock.ID,
+			Type:     "function",
+			Function: FunctionCall{Name: chunk.ContentBlock.Name},
+		}
+		return []ChatDelta{{ToolCalls: []ToolCallDelta{call}}}, nil
+	case "content_block_delta", "message_delta":
+		// Text or tool arguments of a content block, or the stop reason once the message is complete.
 		var delta struct {
-			Type         string `json:"type"`
-			Model        string `json:"model"`
-			Delta        string `json:"delta"`
-			Completion   string `json:"completion"`
-			FinishReason string `json:"finishReason"`
+			Type        string `json:"type"`
+			Text        string `json:"text"`
+			PartialJSON string `json:"partial_json"`
+			StopReason  string `json:"stop_reason"`
 		}
-		err = json.Unmarshal(line, &delta)
+		err = json.Unmarshal(chunk.Delta, &delta)
 		if err != nil {
-			return err
+			return nil, err
 		}
-
-		if delta.Type != "" && delta.Type != "completion" {
-			continue
+		if delta.Type == "input_json_delta" {
+			call := ToolCallDelta{Index: chunk.Index, Function: FunctionCall{Arguments: delta.PartialJSON}}
+			return []ChatDelta{{ToolCalls: []ToolCallDelta{call}}}, nil
+		}
+		return []ChatDelta{{Content: delta.Text, FinishReason: claudeFinishReason(delta.StopReason)}}, nil
+	case "error":
+		// An error after the stream started, such as overloaded_error.
+		var event struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
 		}
+		err = json.Unmarshal(data, &event)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &APIError{HTTPStatusCode: claudeErrorStatus(event.Error.Type), Body: string(data)}
+	default:
+		// message_start, content_block_stop, message_stop, ping
+		return nil, nil
+	}
 
-		text := delta.Delta
-		if delta.Completion != "" {
-			text = delta.Completion
+	if len(chunk.Choices) > 0 {
+		deltas := make([]ChatDelta, len(chunk.Choices))
+		for i, choice := range chunk.Choices {
+			deltas[i] = ChatDelta{
+				Index:        choice.Index,
+				Content:      choice.Delta.Content,
+				ToolCalls:    choice.Delta.ToolCalls,
+				FinishReason: choice.FinishReason,
+				Logprobs:     choice.Logprobs,
+			}
 		}
-		fn(text, false)
+		return deltas, nil
 	}
-	fn("", true)
-	return nil
-}
 
-func (c *Client) claudeRequest(ctx context.Context, chat ChatRequest) (*http.Request, error) {
-	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/v1/complete", nil)
-	if err != nil {
-		return nil, err
+	var content string
+	if len(chunk.Delta) > 0 {
+		err = json.Unmarshal(chunk.Delta, &content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	delta := ChatDelta{
+		Content:      content,
+		ToolCalls:    chunk.ToolCalls,
+		FinishReason: chunk.FinishReason,
 	}
-	c.addHeaders(req)
+	if chunk.Completion != "" {
+		delta.Content = chunk.Completion
+	}
+	if chunk.StopReason != "" {
+		delta.FinishReason = chunk.StopReason
+	}
+	return []ChatDelta{delta}, nil
+}
 
+// claudeCompletionBody returns the body of a request to the legacy completion API, see claudeMessagesBody.
+func claudeCompletionBody(chat ChatRequest) ([]byte, error) {
 	var prompt strings.Builder
 	for _, msg := range chat.Messages {
 		switch msg.Role {
@@ -348,38 +1435,56 @@ func (c *Client) claudeRequest(ctx context.Context, chat ChatRequest) (*http.Req
 		case RoleAssistant:
 			prompt.WriteString("\n\nAssistant: ")
 		}
-		prompt.WriteString(msg.Content)
+		prompt.WriteString(msg.Text())
+	}
+	if n := len(chat.Messages); n > 0 && chat.Messages[n-1].Role == RoleAssistant {
+		// Prefilled reply, the prompt must not end with whitespace.
+		trimmed := strings.TrimRight(prompt.String(), " \t\n")
+		prompt.Reset()
+		prompt.WriteString(trimmed)
+	} else {
+		prompt.WriteString("\n\nAssistant:")
 	}
-	prompt.WriteString("\n\nAssistant:")
 
 	body := map[string]any{
 		"model":                chat.Model,
 		"temperature":          chat.Temperature,
 		"stream":               chat.Stream,
-		"max_tokens_to_sample": chat.MaxTokens,
+		"max_tokens_to_sample": claudeMaxTokens(chat),
 		"prompt":               prompt.String(),
 	}
-	bodyBytes, err := json.Marshal(body)
-	if err != nil {
-		return nil, err
+	if chat.TopP != 0 {
+		body["top_p"] = chat.TopP
 	}
-	req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-	return req, nil
+	if len(chat.Stop) > 0 {
+		body["stop_sequences"] = chat.Stop
+	}
+	return json.Marshal(body)
 }
 
-// Image generates an image from a text prompt.
-func (c *Client) Image(ctx context.Context, image ImageRequest) ([][]byte, error) {
+// Image generates images from a text prompt. The size of DALL-E images is checked before the request is sent,
+// and is 1024x1024 if the width and height are zero.
+func (c *Client) Image(ctx context.Context, image ImageRequest) ([]GeneratedImage, error) {
+	if _, ok := imageSizes[image.Model]; ok && image.Width == 0 && image.Height == 0 {
+		image.Width, image.Height = 1024, 1024
+	}
+	err := image.validate()
+	if err != nil {
+		return nil, err
+	}
+	if image.Model == ImageModelStableDiffusionXL && image.Seed != 0 {
+		image.StableDiffusionXL.Seed = image.Seed
+	}
 	body, err := json.Marshal(image)
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/1/images/generations", bytes.NewReader(body))
+	req, err := c.newRequest(ctx, "POST", "/1/images/generations", bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
-	c.addHeaders(req)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req, string(image.Model))
 	if err != nil {
 		return nil, err
 	}
@@ -389,20 +1494,103 @@ func (c *Client) Image(ctx context.Context, image ImageRequest) ([][]byte, error
 		return nil, NewAPIError(resp)
 	}
 
+	images, err := decodeImages(resp)
+	if err != nil {
+		return nil, err
+	}
+	seed := image.Seed
+	if image.Model == ImageModelStableDiffusionXL {
+		seed = image.StableDiffusionXL.Seed
+	}
+	for i := range images {
+		// The seed isn't always reported, but it is the one requested.
+		if images[i].Seed == 0 {
+			images[i].Seed = int64(seed)
+		}
+	}
+	return images, nil
+}
+
+// imageFilterResults are the content filter results of an image, by category.
+type imageFilterResults map[string]struct {
+	Filtered bool `json:"filtered"`
+}
+
+// flagged returns the categories that were filtered, sorted.
+func (r imageFilterResults) flagged() []string {
+	var categories []string
+	for category, result := range r {
+		if result.Filtered {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// decodeImages decodes the images of a successful image response, which are either the content of the images
+// in image_data, OpenAI's data objects, or Stability's artifacts.
+func decodeImages(resp *http.Response) ([]GeneratedImage, error) {
+	verifyBody(resp)
 	var images struct {
 		ImageData [][]byte `json:"image_data"`
+		Data      []struct {
+			B64JSON              []byte             `json:"b64_json"`
+			URL                  string             `json:"url"`
+			RevisedPrompt        string             `json:"revised_prompt"`
+			Seed                 int64              `json:"seed"`
+			ContentFilterResults imageFilterResults `json:"content_filter_results"`
+		} `json:"data"`
+		Artifacts []struct {
+			Base64       []byte `json:"base64"`
+			Seed         int64  `json:"seed"`
+			FinishReason string `json:"finishReason"`
+		} `json:"artifacts"`
+	}
+	err := json.NewDecoder(resp.Body).Decode(&images)
+	if err != nil {
+		return nil, err
 	}
-	err = json.NewDecoder(resp.Body).Decode(&images)
+	// Read to the end, so the checksum trailers are verified.
+	_, err = io.Copy(io.Discard, resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	return images.ImageData, nil
+	var generated []GeneratedImage
+	for _, data := range images.ImageData {
+		generated = append(generated, GeneratedImage{Data: data})
+	}
+	for _, d := range images.Data {
+		categories := d.ContentFilterResults.flagged()
+		generated = append(
+			generated, GeneratedImage{
+				Data:             d.B64JSON,
+				URL:              d.URL,
+				RevisedPrompt:    d.RevisedPrompt,
+				Seed:             d.Seed,
+				Filtered:         len(categories) > 0,
+				FilterCategories: categories,
+			},
+		)
+	}
+	for _, a := range images.Artifacts {
+		generated = append(
+			generated, GeneratedImage{Data: a.Base64, Seed: a.Seed, Filtered: a.FinishReason == "CONTENT_FILTERED"},
+		)
+	}
+	return generated, nil
 }
 
 // Speech generates speech from a text input.
-// The returned io.ReadCloser is an MP3 audio stream. Caller must close it.
+// The returned io.ReadCloser is an audio stream in the requested format, MP3 by default,
+// read as it arrives rather than buffered, so it can be piped into a player. Caller must close it.
+// Reading it fails with ErrTruncatedResponse if the audio is cut short or doesn't match its checksum.
 func (c *Client) Speech(ctx context.Context, speech SpeechRequest) (io.ReadCloser, error) {
+	err := speech.validate()
+	if err != nil {
+		return nil, err
+	}
 	if speech.Model == SpeechModelAzure {
 		return c.azureSpeech(ctx, speech)
 	}
@@ -411,13 +1599,12 @@ func (c *Client) Speech(ctx context.Context, speech SpeechRequest) (io.ReadClose
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/v1/audio/speech", bytes.NewReader(body))
+	req, err := c.newRequest(ctx, "POST", "/v1/audio/speech", bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
-	c.addHeaders(req)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req, string(speech.Model))
 	if err != nil {
 		return nil, err
 	}
@@ -426,28 +1613,84 @@ func (c *Client) Speech(ctx context.Context, speech SpeechRequest) (io.ReadClose
 		return nil, NewAPIError(resp)
 	}
 
+	verifyBody(resp)
 	return resp.Body, nil
 }
 
+// SpeechTo generates speech from a text input and copies the audio to w as it arrives,
+// flushing w after each write if it is an http.Flusher, like an http.ResponseWriter.
+// It returns the number of bytes written.
+func (c *Client) SpeechTo(ctx context.Context, speech SpeechRequest, w io.Writer) (int64, error) {
+	audio, err := c.Speech(ctx, speech)
+	if err != nil {
+		return 0, err
+	}
+	defer audio.Close()
+	if f, ok := w.(http.Flusher); ok {
+		w = flushWriter{w, f}
+	}
+	return io.Copy(w, audio)
+}
+
+type flushWriter struct {
+	io.Writer
+	f http.Flusher
+}
+
+func (w flushWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.f.Flush()
+	return n, err
+}
+
 // https://learn.microsoft.com/en-us/azure/ai-services/speech-service/rest-text-to-speech
 // https://learn.microsoft.com/en-us/azure/ai-services/speech-service/speech-synthesis-markup
 // https://learn.microsoft.com/en-us/azure/ai-services/speech-service/language-support?tabs=tts
 
 func (c *Client) azureSpeech(ctx context.Context, speech SpeechRequest) (io.ReadCloser, error) {
-	body := fmt.Sprintf(
-		`
-<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="en-US">
-<voice name="%s">%s</voice>
-</speak>
-`, speech.Voice, html.EscapeString(speech.Input),
-	)
-	req, _ := http.NewRequestWithContext(ctx, "POST", baseURL+"/cognitiveservices/v1", strings.NewReader(body))
-	c.addHeaders(req)
-	req.Header.Set("X-Microsoft-OutputFormat", "audio-16khz-128kbitrate-mono-mp3")
-	req.Header.Set("X-Region", "eastasia")
+	azure := c.config().AzureSpeech
+	if speech.Azure.Region != "" {
+		azure.Region = speech.Azure.Region
+	}
+	if speech.Azure.Language != "" {
+		azure.Language = speech.Azure.Language
+	}
+	switch {
+	case speech.Azure.OutputFormat != "":
+		azure.OutputFormat = speech.Azure.OutputFormat
+	case speech.ResponseFormat != "" || azure.OutputFormat == "":
+		format := speech.ResponseFormat
+		if format == "" {
+			format = SpeechMP3
+		}
+		azure.OutputFormat = azureSpeechFormats[format]
+	}
+	if azure.Region == "" {
+		azure.Region = defaultAzureRegion
+	}
+	if azure.Language == "" {
+		azure.Language = voiceLanguage(speech.Voice)
+	}
+
+	body := speech.SSML
+	if body == "" {
+		ssml := NewSSML(azure.Language, speech.Voice)
+		if speech.Speed != 0 {
+			ssml.Prosody(Prosody{Rate: speech.Speed}, speech.Input)
+		} else {
+			ssml.Say(speech.Input)
+		}
+		body = ssml.String()
+	}
+	req, err := c.newRequest(ctx, "POST", "/cognitiveservices/v1", strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Microsoft-OutputFormat", azure.OutputFormat)
+	req.Header.Set("X-Region", azure.Region)
 	req.Header.Set("Content-Type", "application/ssml+xml")
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req, string(speech.Model))
 	if err != nil {
 		return nil, err
 	}
@@ -456,18 +1699,18 @@ func (c *Client) azureSpeech(ctx context.Context, speech SpeechRequest) (io.Read
 		return nil, NewAPIError(resp)
 	}
 
+	verifyBody(resp)
 	return resp.Body, nil
 }
 
 // Usage returns the current usage of the API.
 func (c *Client) Usage(ctx context.Context) ([]Usage, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/1.1/me/usage", nil)
+	req, err := c.newRequest(ctx, "GET", "/1.1/me/usage", nil)
 	if err != nil {
 		return nil, err
 	}
-	c.addHeaders(req)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req, "")
 	if err != nil {
 		return nil, err
 	}
@@ -485,5 +1728,6 @@ func (c *Client) Usage(ctx context.Context) ([]Usage, error) {
 		return nil, err
 	}
 
+	c.checkUsage(data.Data)
 	return data.Data, nil
 }
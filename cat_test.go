@@ -2,8 +2,14 @@ package opencat_api
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -14,6 +20,15 @@ func client() *Client {
 	return NewClient(os.Getenv("TOKEN"))
 }
 
+// newTestClient starts a server with handler, closed at the end of the test, and returns a client of it.
+func newTestClient(t testing.TB, handler http.HandlerFunc, opts ...ClientOption) *Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c := NewClient("token", opts...)
+	c.updateConfig(func(cfg *Config) { cfg.BaseURL = srv.URL })
+	return c
+}
+
 func TestChat(t *testing.T) {
 	c := client()
 	resp, err := c.Chat(
@@ -68,6 +83,106 @@ func TestChatClaude(t *testing.T) {
 	t.Logf("resp: %s", resp.Choices[0].Message.Content)
 }
 
+func TestChatPreset(t *testing.T) {
+	c := client()
+	resp, err := c.Chat(
+		context.Background(),
+		ChatRequest{
+			Model:     ChatModelGPT3Dot5Turbo,
+			MaxTokens: 1024,
+			Messages: []Message{
+				{
+					Role:    "user",
+					Content: "Give me a name for a cat.",
+				},
+			},
+		},
+		WithPreset(PresetCreative),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("resp: %s", resp.Choices[0].Message.Content)
+}
+
+func TestChatSampling(t *testing.T) {
+	c := client()
+	seed := 42
+	for _, model := range []ChatModel{ChatModelGPT4Turbo, ChatModelClaudeInstant1} {
+		resp, err := c.Chat(
+			context.Background(),
+			ChatRequest{
+				Model:     model,
+				MaxTokens: 1024,
+				TopP:      0.5,
+				Stop:      []string{"5"},
+				Seed:      &seed,
+				Messages: []Message{
+					{
+						Role:    "user",
+						Content: "Count from 1 to 10, separated by spaces.",
+					},
+				},
+			},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%s: %s", model, resp.Choices[0].Message.Content)
+	}
+}
+
+func TestChatLogprobs(t *testing.T) {
+	c := client()
+	resp, err := c.Chat(
+		context.Background(),
+		ChatRequest{
+			Model:       ChatModelGPT4Turbo,
+			MaxTokens:   16,
+			Logprobs:    true,
+			TopLogprobs: 3,
+			Messages: []Message{
+				{
+					Role:    "user",
+					Content: "Is the sky blue? Answer yes or no.",
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Choices[0].Logprobs == nil || len(resp.Choices[0].Logprobs.Content) == 0 {
+		t.Fatal("expected logprobs")
+	}
+	for _, tok := range resp.Choices[0].Logprobs.Content {
+		t.Logf("%q: %f %+v", tok.Token, tok.Logprob, tok.TopLogprobs)
+	}
+}
+
+func TestChatLogitBias(t *testing.T) {
+	c := client()
+	resp, err := c.Chat(
+		context.Background(),
+		ChatRequest{
+			Model:     ChatModelGPT3Dot5Turbo,
+			MaxTokens: 16,
+			// "Yes" and "yes" in cl100k_base.
+			LogitBias: map[string]int{"9642": -100, "9891": -100},
+			Messages: []Message{
+				{
+					Role:    "user",
+					Content: "Is the sky blue? Answer yes or no.",
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("resp: %s", resp.Choices[0].Message.Content)
+}
+
 func TestStreamChat(t *testing.T) {
 	c := client()
 	content := ""
@@ -130,6 +245,222 @@ func TestStreamChatClaude(t *testing.T) {
 	t.Logf("resp: %s", content)
 }
 
+func TestStreamChatMultipleChoices(t *testing.T) {
+	c := client()
+	contents := map[int]string{}
+	err := c.StreamChatDeltas(
+		context.Background(),
+		ChatRequest{
+			Model:     ChatModelGPT3Dot5Turbo,
+			MaxTokens: 256,
+			Stream:    true,
+			N:         3,
+			Messages: []Message{
+				{
+					Role:    "user",
+					Content: "Suggest a name for a cat.",
+				},
+			},
+		},
+		func(delta ChatDelta) {
+			contents[delta.Index] += delta.Content
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contents) != 3 {
+		t.Fatalf("got %d choices, want 3", len(contents))
+	}
+	t.Logf("resp: %v", contents)
+}
+
+func TestStreamChatInterrupted(t *testing.T) {
+	c := client()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	content := ""
+	err := c.StreamChat(
+		ctx,
+		ChatRequest{
+			Model:       ChatModelGPT3Dot5Turbo,
+			Temperature: 1,
+			MaxTokens:   4096,
+			Stream:      true,
+			Messages: []Message{
+				{
+					Role:    "user",
+					Content: "Count from 1 to 100.",
+				},
+			},
+		},
+		func(delta string, done bool) {
+			content += delta
+			cancel()
+		},
+	)
+	var interrupted *ErrStreamInterrupted
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("expected ErrStreamInterrupted, got %v", err)
+	}
+	if interrupted.Content != content {
+		t.Fatalf("partial content = %q, want %q", interrupted.Content, content)
+	}
+	t.Logf("partial: %s", interrupted.Content)
+}
+
+func TestChatTools(t *testing.T) {
+	c := client()
+	resp, err := c.Chat(
+		context.Background(),
+		ChatRequest{
+			Model:     ChatModelGPT4Turbo,
+			MaxTokens: 1024,
+			Messages: []Message{
+				{
+					Role:    "user",
+					Content: "What's the weather like in Beijing?",
+				},
+			},
+			Tools: []Tool{
+				NewFunctionTool(
+					"get_weather", "Get the current weather of a city", map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"city": map[string]any{"type": "string"},
+						},
+						"required": []string{"city"},
+					},
+				),
+			},
+			ToolChoice: ToolChoiceAuto,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Choices[0].Message.ToolCalls) == 0 {
+		t.Fatal("expected a tool call")
+	}
+	t.Logf("tool call: %+v", resp.Choices[0].Message.ToolCalls[0])
+}
+
+func TestChatJSONMode(t *testing.T) {
+	c := client()
+	req := ChatRequest{
+		Model:          ChatModelGPT4Turbo,
+		MaxTokens:      1024,
+		ResponseFormat: ResponseFormatJSON,
+		Messages: []Message{
+			{
+				Role:    "user",
+				Content: "List three primary colors.",
+			},
+		},
+	}
+	_, err := c.Chat(context.Background(), req)
+	if err == nil {
+		t.Fatal("expected an error for JSON mode without mentioning JSON")
+	}
+
+	req.Messages = append(
+		[]Message{{Role: "system", Content: `Reply in JSON like {"colors": []}.`}},
+		req.Messages...,
+	)
+	resp, err := c.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]any
+	err = json.Unmarshal([]byte(resp.Choices[0].Message.Content), &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("resp: %v", v)
+}
+
+func TestParseStreamEvent(t *testing.T) {
+	tests := []struct {
+		data string
+		want []ChatDelta
+	}{
+		{
+			data: `{"delta":"Hi","finishReason":""}`,
+			want: []ChatDelta{{Content: "Hi"}},
+		},
+		{
+			data: `{"type":"completion","completion":" there","stop_reason":"stop_sequence"}`,
+			want: []ChatDelta{{Content: " there", FinishReason: "stop_sequence"}},
+		},
+		{
+			data: `{"type":"ping"}`,
+			want: nil,
+		},
+		{
+			data: `{"type":"message_start","message":{"id":"msg_1","role":"assistant","content":[]}}`,
+			want: nil,
+		},
+		{
+			data: `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+			want: []ChatDelta{{Content: "Hello"}},
+		},
+		{
+			data: `{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":15}}`,
+			want: []ChatDelta{{FinishReason: "end_turn"}},
+		},
+		{
+			data: `{"type":"message_stop"}`,
+			want: nil,
+		},
+		{
+			data: `{"type":"content_block_start","index":1,` +
+				`"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+			want: []ChatDelta{
+				{
+					ToolCalls: []ToolCallDelta{
+						{Index: 1, ID: "toolu_1", Type: "function", Function: FunctionCall{Name: "get_weather"}},
+					},
+				},
+			},
+		},
+		{
+			data: `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city"}}`,
+			want: []ChatDelta{{ToolCalls: []ToolCallDelta{{Index: 1, Function: FunctionCall{Arguments: `{"city`}}}}},
+		},
+		{
+			data: `{"type":"message_delta","delta":{"stop_reason":"tool_use"}}`,
+			want: []ChatDelta{{FinishReason: "tool_calls"}},
+		},
+		{
+			data: `{"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[` +
+				`{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+			want: []ChatDelta{
+				{
+					ToolCalls: []ToolCallDelta{
+						{ID: "call_1", Type: "function", Function: FunctionCall{Name: "get_weather"}},
+					},
+				},
+			},
+		},
+		{
+			data: `{"choices":[{"index":1,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city"}}]}}]}`,
+			want: []ChatDelta{
+				{Index: 1, ToolCalls: []ToolCallDelta{{Function: FunctionCall{Arguments: `{"city`}}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := parseStreamEvent([]byte(tt.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseStreamEvent(%s) = %+v, want %+v", tt.data, got, tt.want)
+		}
+	}
+}
+
 func TestChatImage(t *testing.T) {
 	c := client()
 	img, err := os.Open("testdata/1.jpeg")
@@ -167,6 +498,81 @@ func TestChatImage(t *testing.T) {
 	t.Logf("resp: %s", content)
 }
 
+func TestReadImageText(t *testing.T) {
+	c := client()
+	img, err := os.Open("testdata/1.jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer img.Close()
+
+	text, err := c.ReadImageText(context.Background(), NewImage(img))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("text: %s", text)
+}
+
+func TestReadImageTextRetry(t *testing.T) {
+	var bodies []string
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			content := "The image says hello."
+			if len(bodies) > 1 {
+				content = "```json\n{\"lines\": [{\"text\": \"hello\"}]}\n```"
+			}
+			resp := ChatResponse{
+				Choices: []ChatResponseChoice{{Message: ResponseMessage{Role: RoleAssistant, Content: content}}},
+			}
+			json.NewEncoder(w).Encode(resp)
+		},
+	)
+
+	text, err := c.ReadImageText(context.Background(), NewImage(strings.NewReader("png")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text.String() != "hello" {
+		t.Errorf("unexpected text %+v", text)
+	}
+	// The image is sent again, with the invalid reply to fix.
+	if len(bodies) != 2 || !strings.Contains(bodies[1], "cG5n") || !strings.Contains(bodies[1], "The image says hello.") {
+		t.Errorf("unexpected requests %q", bodies)
+	}
+}
+
+func TestCaption(t *testing.T) {
+	c := client()
+	img, err := os.Open("testdata/1.jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer img.Close()
+
+	caption, err := c.Caption(context.Background(), NewImage(img), CaptionAltText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("caption: %s", caption)
+}
+
+func TestExtractReceipt(t *testing.T) {
+	c := client()
+	img, err := os.Open("testdata/1.jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer img.Close()
+
+	receipt, err := c.ExtractReceipt(context.Background(), NewImage(img))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("receipt: %+v", receipt)
+}
+
 func TestGenImage(t *testing.T) {
 	c := client()
 	imgs, err := c.Image(
@@ -186,23 +592,12 @@ func TestGenImage(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = os.WriteFile("output/gen.jpg", imgs[0], 0644)
+	_, err = SaveImages("output", imgs)
 	if err != nil {
 		t.Fatal(err)
 	}
 }
 
-func writeFile(name string, in io.Reader) error {
-	f, err := os.Create(name)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	_, err = io.Copy(f, in)
-	return err
-}
-
 func TestGenSpeech(t *testing.T) {
 	c := client()
 	speech, err := c.Speech(
@@ -218,7 +613,7 @@ func TestGenSpeech(t *testing.T) {
 	}
 	defer speech.Close()
 
-	err = writeFile("output/speech.wav", speech)
+	_, err = SaveAudio("output/speech", speech)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -239,7 +634,7 @@ func TestGenSpeechAzure(t *testing.T) {
 	}
 	defer speech.Close()
 
-	err = writeFile("output/speech2.wav", speech)
+	_, err = SaveAudio("output/speech2", speech)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,202 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Step is one stage of a Pipeline: it turns the output of the previous step into the input of the next one.
+// Steps are created with ChatStep, Transform, Branch and Chain.
+type Step interface {
+	Name() string
+	run(ctx context.Context, in any, emit func(ChainEvent)) (any, error)
+}
+
+// ChainEvent reports the progress of a pipeline run.
+// While a chat step streams, events carry a Delta of its reply; when a step completes, an event carries its Output.
+type ChainEvent struct {
+	Step   string
+	Delta  string
+	Done   bool
+	Output any
+}
+
+// Pipeline runs steps one after another, feeding the output of each step to the next.
+type Pipeline struct {
+	name  string
+	steps []Step
+}
+
+// Chain returns a pipeline of steps. A pipeline is itself a step and can be nested.
+func Chain(steps ...Step) *Pipeline {
+	return &Pipeline{name: "chain", steps: steps}
+}
+
+// Named sets the name of the pipeline, used in events and errors when it is nested in another one.
+func (p *Pipeline) Named(name string) *Pipeline {
+	p.name = name
+	return p
+}
+
+func (p *Pipeline) Name() string {
+	return p.name
+}
+
+// Run runs the pipeline on in and returns the output of the last step.
+func (p *Pipeline) Run(ctx context.Context, in any) (any, error) {
+	return p.run(ctx, in, nil)
+}
+
+// Stream is like Run, but streams the replies of chat steps and reports the output of every step to fn.
+func (p *Pipeline) Stream(ctx context.Context, in any, fn func(ChainEvent)) (any, error) {
+	return p.run(ctx, in, fn)
+}
+
+func (p *Pipeline) run(ctx context.Context, in any, emit func(ChainEvent)) (any, error) {
+	if len(p.steps) == 0 {
+		return nil, errors.New("pipeline has no steps")
+	}
+	for _, step := range p.steps {
+		out, err := step.run(ctx, in, emit)
+		if err != nil {
+			return nil, fmt.Errorf("step %s: %w", step.Name(), err)
+		}
+		if emit != nil {
+			emit(ChainEvent{Step: step.Name(), Done: true, Output: out})
+		}
+		in = out
+	}
+	return in, nil
+}
+
+// RunChain runs the pipeline and converts its output to Out.
+func RunChain[Out any](ctx context.Context, p *Pipeline, in any) (Out, error) {
+	var zero Out
+	out, err := p.Run(ctx, in)
+	if err != nil {
+		return zero, err
+	}
+	v, ok := out.(Out)
+	if !ok {
+		return zero, fmt.Errorf("pipeline output is %T, not %T", out, zero)
+	}
+	return v, nil
+}
+
+func stepInput[In any](in any) (In, error) {
+	v, ok := in.(In)
+	if !ok {
+		return v, fmt.Errorf("input is %T, not %T", in, v)
+	}
+	return v, nil
+}
+
+type chatStep[In any] struct {
+	name   string
+	client *Client
+	build  func(In) ChatRequest
+}
+
+// ChatStep returns a step that sends the request built from its input and outputs the reply as a string.
+// The reply is streamed when the pipeline runs with Stream.
+func ChatStep[In any](c *Client, name string, build func(in In) ChatRequest) Step {
+	return &chatStep[In]{name: name, client: c, build: build}
+}
+
+func (s *chatStep[In]) Name() string {
+	return s.name
+}
+
+func (s *chatStep[In]) run(ctx context.Context, in any, emit func(ChainEvent)) (any, error) {
+	v, err := stepInput[In](in)
+	if err != nil {
+		return nil, err
+	}
+	req := s.build(v)
+
+	if emit == nil || req.N > 1 {
+		req.Stream = false
+		resp, err := s.client.Chat(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Choices) == 0 {
+			return nil, errors.New("response has no choices")
+		}
+		return resp.Choices[0].Message.Content, nil
+	}
+
+	req.Stream = true
+	var reply string
+	err = s.client.StreamChat(
+		ctx, req, func(delta string, done bool) {
+			reply += delta
+			if delta != "" {
+				emit(ChainEvent{Step: s.name, Delta: delta})
+			}
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+type transformStep[In, Out any] struct {
+	name string
+	fn   func(context.Context, In) (Out, error)
+}
+
+// Transform returns a step that runs fn on its input, such as a tool call, a parser or a lookup.
+func Transform[In, Out any](name string, fn func(ctx context.Context, in In) (Out, error)) Step {
+	return &transformStep[In, Out]{name: name, fn: fn}
+}
+
+func (s *transformStep[In, Out]) Name() string {
+	return s.name
+}
+
+func (s *transformStep[In, Out]) run(ctx context.Context, in any, _ func(ChainEvent)) (any, error) {
+	v, err := stepInput[In](in)
+	if err != nil {
+		return nil, err
+	}
+	return s.fn(ctx, v)
+}
+
+type branchStep[In any] struct {
+	name      string
+	cond      func(In) bool
+	then      Step
+	otherwise Step
+}
+
+// Branch returns a step that passes its input to then if cond reports true, and to otherwise if not.
+// A nil step passes the input through unchanged.
+func Branch[In any](name string, cond func(in In) bool, then, otherwise Step) Step {
+	return &branchStep[In]{name: name, cond: cond, then: then, otherwise: otherwise}
+}
+
+func (s *branchStep[In]) Name() string {
+	return s.name
+}
+
+func (s *branchStep[In]) run(ctx context.Context, in any, emit func(ChainEvent)) (any, error) {
+	v, err := stepInput[In](in)
+	if err != nil {
+		return nil, err
+	}
+	step := s.otherwise
+	if s.cond(v) {
+		step = s.then
+	}
+	if step == nil {
+		return in, nil
+	}
+	out, err := step.run(ctx, in, emit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", step.Name(), err)
+	}
+	return out, nil
+}
@@ -0,0 +1,75 @@
+package opencat_api
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestChainTransform(t *testing.T) {
+	double := Transform("double", func(ctx context.Context, n int) (int, error) { return n * 2, nil })
+	p := Chain(
+		Transform("parse", func(ctx context.Context, s string) (int, error) { return strconv.Atoi(s) }),
+		Branch("big", func(n int) bool { return n > 10 }, nil, double),
+		Transform("format", func(ctx context.Context, n int) (string, error) { return "n=" + strconv.Itoa(n), nil }),
+	)
+
+	var steps []string
+	out, err := p.Stream(
+		context.Background(), "4", func(e ChainEvent) {
+			if e.Done {
+				steps = append(steps, e.Step)
+			}
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "n=8" || strings.Join(steps, ",") != "parse,big,format" {
+		t.Errorf("got %v after %v", out, steps)
+	}
+
+	got, err := RunChain[string](context.Background(), p, "42")
+	if err != nil || got != "n=42" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	_, err = p.Run(context.Background(), 42)
+	if err == nil || !strings.Contains(err.Error(), "step parse") {
+		t.Errorf("expected input type error, got %v", err)
+	}
+}
+
+func TestChainChat(t *testing.T) {
+	c := client()
+	p := Chain(
+		ChatStep(
+			c, "translate", func(text string) ChatRequest {
+				return ChatRequest{
+					Model:    ChatModelGPT3Dot5Turbo,
+					Messages: []Message{{Role: RoleUser, Content: "Translate to English, reply with the translation only: " + text}},
+				}
+			},
+		),
+		ChatStep(
+			c, "summarize", func(text string) ChatRequest {
+				return ChatRequest{
+					Model:    ChatModelGPT3Dot5Turbo,
+					Messages: []Message{{Role: RoleUser, Content: "Summarize in five words: " + text}},
+				}
+			},
+		),
+	)
+	out, err := p.Stream(
+		context.Background(), "今天天气很好，我们去公园散步，然后在湖边吃了午饭。", func(e ChainEvent) {
+			if e.Done {
+				t.Logf("%s: %v", e.Step, e.Output)
+			}
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(out)
+}
@@ -0,0 +1,143 @@
+package opencat_api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// streamFallback reads a streamed reply that didn't come as server-sent events, as happens behind proxies
+// that strip or buffer event streams:
+//   - an event stream sent with another content type is read as usual;
+//   - chunked JSON, a sequence of stream chunks, one per line or simply concatenated, is read chunk by chunk;
+//   - a complete chat response, when the stream was buffered or the gateway doesn't stream, is one delta
+//     per choice.
+//
+// Other bodies, like the error page of a proxy, are returned as an APIError.
+func (s stream) fallback(ctx context.Context, resp *http.Response) error {
+	body := bufio.NewReader(resp.Body)
+	first, err := firstNonSpace(body)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return &ErrStreamInterrupted{Err: err}
+	}
+	switch {
+	case first == '{' || first == '[':
+		s.c.warn(
+			WarningStreamFallback, s.chat.Model, "stream received as %q instead of text/event-stream, reading JSON chunks",
+			resp.Header.Get("Content-Type"),
+		)
+		return s.readJSON(ctx, body)
+	case first == 'd' || first == 'e' || first == ':':
+		// data:, event: or a comment.
+		s.c.warn(
+			WarningStreamFallback, s.chat.Model, "stream received as %q instead of text/event-stream, reading events",
+			resp.Header.Get("Content-Type"),
+		)
+		return s.readEvents(ctx, body)
+	default:
+		resp.Body = io.NopCloser(body)
+		return NewAPIError(resp)
+	}
+}
+
+// firstNonSpace returns the first byte of r that isn't white space, without consuming it.
+func firstNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b)) {
+			return b, r.UnreadByte()
+		}
+	}
+}
+
+// readJSON reads the deltas of a sequence of JSON values.
+func (s stream) readJSON(ctx context.Context, r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			return &ErrStreamInterrupted{Err: err}
+		}
+
+		var values []json.RawMessage
+		if raw[0] == '[' {
+			// An array of chunks.
+			err = json.Unmarshal(raw, &values)
+			if err != nil {
+				s.c.warn(WarningStreamParse, s.chat.Model, "skipped JSON chunk %q: %v", raw, err)
+				continue
+			}
+		} else {
+			values = []json.RawMessage{raw}
+		}
+		for _, v := range values {
+			deltas, err := s.parseJSONChunk(v)
+			var apiErr *APIError
+			if errors.As(err, &apiErr) {
+				return &ErrStreamInterrupted{Err: apiErr}
+			}
+			if err != nil {
+				s.c.warn(WarningStreamParse, s.chat.Model, "skipped JSON chunk %q: %v", v, err)
+				continue
+			}
+			for _, delta := range deltas {
+				s.fn(delta)
+			}
+		}
+	}
+}
+
+// parseJSONChunk decodes a stream chunk or a complete chat response into deltas.
+func (s stream) parseJSONChunk(data []byte) ([]ChatDelta, error) {
+	var probe struct {
+		Type    string `json:"type"`
+		Choices []struct {
+			Message *json.RawMessage `json:"message"`
+		} `json:"choices"`
+	}
+	err := json.Unmarshal(data, &probe)
+	if err != nil {
+		return nil, err
+	}
+
+	// A complete response has a message, of the Claude Messages API or in choices.
+	if probe.Type != "message" && (len(probe.Choices) == 0 || probe.Choices[0].Message == nil) {
+		return s.adapter.DecodeStreamEvent(data)
+	}
+	resp, err := s.adapter.DecodeResponse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	deltas := make([]ChatDelta, len(resp.Choices))
+	for i, choice := range resp.Choices {
+		calls := make([]ToolCallDelta, len(choice.Message.ToolCalls))
+		for j, call := range choice.Message.ToolCalls {
+			calls[j] = ToolCallDelta{Index: j, ID: call.ID, Type: call.Type, Function: call.Function}
+		}
+		deltas[i] = ChatDelta{
+			Index:        choice.Index,
+			Content:      choice.Message.Content,
+			FinishReason: choice.FinishReason,
+			Logprobs:     choice.Logprobs,
+		}
+		if len(calls) > 0 {
+			deltas[i].ToolCalls = calls
+		}
+	}
+	return deltas, nil
+}
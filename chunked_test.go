@@ -0,0 +1,73 @@
+package opencat_api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestStreamChatFallback(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		want        string
+		wantErr     bool
+	}{
+		{
+			contentType: "application/x-ndjson",
+			body:        `{"delta":"Hel"}` + "\n" + `{"delta":"lo","finishReason":"stop"}` + "\n",
+			want:        "Hello",
+		},
+		{
+			contentType: "application/json",
+			body:        `{"choices": [{"message": {"role": "assistant", "content": "Hello"}, "finish_reason": "stop"}]}`,
+			want:        "Hello",
+		},
+		{
+			contentType: "application/json",
+			body:        `[{"delta":"Hel"},{"delta":"lo"}]`,
+			want:        "Hello",
+		},
+		{
+			contentType: "text/plain",
+			body:        "data: {\"delta\":\"Hello\"}\n\ndata: [DONE]\n\n",
+			want:        "Hello",
+		},
+		{
+			contentType: "text/html",
+			body:        "<html>Blocked by policy</html>",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.contentType, func(t *testing.T) {
+				c := newTestClient(
+					t, func(w http.ResponseWriter, r *http.Request) {
+						w.Header().Set("Content-Type", tt.contentType)
+						_, _ = w.Write([]byte(tt.body))
+					},
+				)
+
+				var got string
+				err := c.StreamChat(
+					context.Background(),
+					ChatRequest{Model: ChatModelGPT4, Stream: true, Messages: []Message{{Role: RoleUser, Content: "hi"}}},
+					func(delta string, done bool) { got += delta },
+				)
+				if tt.wantErr {
+					if _, ok := err.(*APIError); !ok {
+						t.Errorf("expected an APIError, got %v", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+			},
+		)
+	}
+}
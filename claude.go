@@ -0,0 +1,246 @@
+package opencat_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// usesClaudeMessages reports whether a Claude request is sent to the Messages API of Anthropic instead of
+// the legacy completion API. Claude 3 models and tools are only supported by the Messages API.
+func usesClaudeMessages(cfg *Config, chat ChatRequest) bool {
+	return cfg.ClaudeMessagesAPI || strings.HasPrefix(string(chat.Model), "claude-3") || len(chat.Tools) > 0
+}
+
+type claudeContentBlock struct {
+	Type   string             `json:"type"`
+	Text   string             `json:"text,omitempty"`
+	Source *claudeImageSource `json:"source,omitempty"`
+	// ID, Name and Input are those of a tool_use block.
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// ToolUseID and Content are those of a tool_result block.
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+type claudeTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
+}
+
+type claudeImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type claudeMessage struct {
+	Role    Role                 `json:"role"`
+	Content []claudeContentBlock `json:"content"`
+}
+
+// claudeMessages maps chat messages to the Messages API: system messages are moved to the system prompt,
+// tool calls and results become tool_use and tool_result blocks, and consecutive messages of the same role
+// are merged, since the roles must alternate starting with the user.
+func claudeMessages(messages []Message) (system string, out []claudeMessage, err error) {
+	var systems []string
+	for _, msg := range messages {
+		if msg.Role == RoleSystem {
+			systems = append(systems, msg.Text())
+			continue
+		}
+		role := RoleUser
+		if msg.Role == RoleAssistant {
+			role = RoleAssistant
+		}
+
+		if msg.Role == RoleTool {
+			result := claudeContentBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Text()}
+			out = appendClaudeMessage(out, RoleUser, []claudeContentBlock{result})
+			continue
+		}
+
+		var blocks []claudeContentBlock
+		for _, img := range msg.images() {
+			source, err := claudeImage(img)
+			if err != nil {
+				return "", nil, err
+			}
+			blocks = append(blocks, claudeContentBlock{Type: "image", Source: source})
+		}
+		if text := msg.Text(); text != "" {
+			blocks = append(blocks, claudeContentBlock{Type: "text", Text: text})
+		}
+		for _, call := range msg.ToolCalls {
+			input := json.RawMessage(call.Function.Arguments)
+			if !json.Valid(input) {
+				input = json.RawMessage("{}")
+			}
+			blocks = append(
+				blocks, claudeContentBlock{Type: "tool_use", ID: call.ID, Name: call.Function.Name, Input: input},
+			)
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		if len(out) == 0 && role == RoleAssistant {
+			return "", nil, fmt.Errorf("the first message must not be from the %s", RoleAssistant)
+		}
+		out = appendClaudeMessage(out, role, blocks)
+	}
+	if n := len(out); n > 0 && out[n-1].Role == RoleAssistant {
+		// Prefilled reply, which must not end with whitespace.
+		blocks := out[n-1].Content
+		if last := &blocks[len(blocks)-1]; last.Type == "text" {
+			last.Text = strings.TrimRight(last.Text, " \t\n")
+		}
+	}
+	return strings.Join(systems, "\n\n"), out, nil
+}
+
+// appendClaudeMessage appends blocks to the last message if it has the same role, or as a new message.
+func appendClaudeMessage(messages []claudeMessage, role Role, blocks []claudeContentBlock) []claudeMessage {
+	if n := len(messages); n > 0 && messages[n-1].Role == role {
+		messages[n-1].Content = append(messages[n-1].Content, blocks...)
+		return messages
+	}
+	return append(messages, claudeMessage{Role: role, Content: blocks})
+}
+
+func claudeImage(img Image) (*claudeImageSource, error) {
+	uri, err := img.uri()
+	if err != nil {
+		return nil, err
+	}
+	header, data, ok := strings.Cut(uri, ",")
+	if !ok || !strings.HasPrefix(header, "data:") {
+		return &claudeImageSource{Type: "url", URL: uri}, nil
+	}
+	mediaType := strings.TrimSuffix(strings.TrimPrefix(header, "data:"), ";base64")
+	return &claudeImageSource{Type: "base64", MediaType: mediaType, Data: data}, nil
+}
+
+// claudeMaxTokens returns the maximum number of tokens of the reply, which Claude requires:
+// defaultReplyTokens if the request doesn't set it.
+func claudeMaxTokens(chat ChatRequest) int {
+	if chat.MaxTokens <= 0 {
+		return defaultReplyTokens
+	}
+	return chat.MaxTokens
+}
+
+// claudeMessagesBody returns the body of a request to the Messages API.
+func claudeMessagesBody(chat ChatRequest) ([]byte, error) {
+	system, messages, err := claudeMessages(chat.Messages)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]any{
+		"model":       chat.Model,
+		"temperature": chat.Temperature,
+		"stream":      chat.Stream,
+		"max_tokens":  claudeMaxTokens(chat),
+		"messages":    messages,
+	}
+	if system != "" {
+		body["system"] = system
+	}
+	if chat.TopP != 0 {
+		body["top_p"] = chat.TopP
+	}
+	if len(chat.Stop) > 0 {
+		body["stop_sequences"] = chat.Stop
+	}
+	if len(chat.Tools) > 0 {
+		tools := make([]claudeTool, len(chat.Tools))
+		for i, tool := range chat.Tools {
+			tools[i] = claudeTool{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				InputSchema: tool.Function.Parameters,
+			}
+		}
+		body["tools"] = tools
+	}
+	if choice := chat.ToolChoice; choice != nil {
+		switch {
+		case choice.Function != "":
+			body["tool_choice"] = map[string]string{"type": "tool", "name": choice.Function}
+		default:
+			body["tool_choice"] = map[string]string{"type": choice.Type}
+		}
+	}
+	return json.Marshal(body)
+}
+
+// claudeResponse is a response of either the completion or the Messages API.
+type claudeResponse struct {
+	Type       string               `json:"type"`
+	ID         string               `json:"id"`
+	Model      string               `json:"model"`
+	Completion string               `json:"completion"`
+	Content    []claudeContentBlock `json:"content"`
+	StopReason string               `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+func decodeClaudeResponse(body []byte) (ChatResponse, error) {
+	var r claudeResponse
+	err := json.Unmarshal(body, &r)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	return r.chatResponse(), nil
+}
+
+func (r claudeResponse) chatResponse() ChatResponse {
+	text := r.Completion
+	var calls []ToolCall
+	for _, block := range r.Content {
+		switch block.Type {
+		case "text":
+			text += block.Text
+		case "tool_use":
+			calls = append(
+				calls, ToolCall{
+					ID:       block.ID,
+					Type:     "function",
+					Function: FunctionCall{Name: block.Name, Arguments: string(block.Input)},
+				},
+			)
+		}
+	}
+	cr := ChatResponse{
+		ID:      r.ID,
+		Object:  "chat.completion",
+		Model:   r.Model,
+		Choices: []ChatResponseChoice{{}},
+		Usage: TokenUsage{
+			PromptTokens:     r.Usage.InputTokens,
+			CompletionTokens: r.Usage.OutputTokens,
+			TotalTokens:      r.Usage.InputTokens + r.Usage.OutputTokens,
+		},
+	}
+	cr.Choices[0].Message.Role = RoleAssistant
+	cr.Choices[0].Message.Content = text
+	cr.Choices[0].Message.Parts = textParts(text)
+	cr.Choices[0].Message.ToolCalls = calls
+	cr.Choices[0].FinishReason = claudeFinishReason(r.StopReason)
+	return cr
+}
+
+// claudeFinishReason maps the stop reason of a tool call to the finish reason used by the other providers,
+// so callers check for tool calls the same way. Other stop reasons are kept.
+func claudeFinishReason(stopReason string) string {
+	if stopReason == "tool_use" {
+		return "tool_calls"
+	}
+	return stopReason
+}
@@ -0,0 +1,231 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestClaudeMessages(t *testing.T) {
+	system, messages, err := claudeMessages(
+		[]Message{
+			{Role: RoleSystem, Content: "Be brief."},
+			{Role: RoleUser, Content: "What is this?", Images: []Image{NewImageURL("https://example.com/cat.png")}},
+			{Role: RoleUser, Content: "And in French?"},
+			{Role: RoleSystem, Content: "Answer in French."},
+			{Role: RoleAssistant, Content: "C'est "},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if system != "Be brief.\n\nAnswer in French." {
+		t.Errorf("system = %q", system)
+	}
+	if len(messages) != 2 || messages[0].Role != RoleUser || messages[1].Role != RoleAssistant {
+		t.Fatalf("roles don't alternate: %+v", messages)
+	}
+	user := messages[0].Content
+	if len(user) != 3 || user[0].Type != "image" || user[0].Source.URL != "https://example.com/cat.png" ||
+		user[1].Text != "What is this?" || user[2].Text != "And in French?" {
+		t.Errorf("unexpected user content: %+v", user)
+	}
+	if text := messages[1].Content[0].Text; text != "C'est" {
+		t.Errorf("prefill not trimmed: %q", text)
+	}
+
+	source, err := claudeImage(NewImageFromBytes([]byte("\x89PNG\r\n\x1a\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.Type != "base64" || source.MediaType != "image/png" || source.Data != "iVBORw0KGgo=" {
+		t.Errorf("unexpected image source: %+v", source)
+	}
+
+	_, _, err = claudeMessages([]Message{{Role: RoleAssistant, Content: "Hi"}})
+	if err == nil {
+		t.Error("expected an error for a conversation starting with the assistant")
+	}
+}
+
+func TestChatClaudeMessages(t *testing.T) {
+	var path string
+	var body map[string]any
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			fmt.Fprint(
+				w, `{"id": "msg_1", "type": "message", "model": "claude-2.1", "role": "assistant",`+
+					`"content": [{"type": "text", "text": "Hello"}], "stop_reason": "end_turn",`+
+					`"usage": {"input_tokens": 12, "output_tokens": 3}}`,
+			)
+		},
+	)
+	c.updateConfig(func(cfg *Config) { cfg.ClaudeMessagesAPI = true })
+
+	resp, err := c.Chat(
+		context.Background(), ChatRequest{
+			Model:     ChatModelClaude2,
+			MaxTokens: 100,
+			Messages:  []Message{{Role: RoleSystem, Content: "Be brief."}, {Role: RoleUser, Content: "Hi"}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v1/messages" || body["system"] != "Be brief." || body["max_tokens"] != float64(100) {
+		t.Errorf("unexpected request to %s: %v", path, body)
+	}
+	if resp.Choices[0].Message.Content != "Hello" || resp.Choices[0].FinishReason != "end_turn" ||
+		resp.Usage.TotalTokens != 15 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	_, err = c.Chat(context.Background(), ChatRequest{Model: ChatModelClaude2, Messages: []Message{User("Hi")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body["max_tokens"] != float64(defaultReplyTokens) {
+		t.Errorf("max_tokens = %v without MaxTokens, want %d", body["max_tokens"], defaultReplyTokens)
+	}
+}
+
+func TestStreamClaudeError(t *testing.T) {
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(
+				w, "event: content_block_delta\n"+
+					`data: {"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": "Hel"}}`+"\n\n"+
+					"event: error\n"+
+					`data: {"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`+"\n\n",
+			)
+		},
+	)
+	c.updateConfig(func(cfg *Config) { cfg.ClaudeMessagesAPI = true })
+
+	var got string
+	err := c.StreamChat(
+		context.Background(),
+		ChatRequest{Model: ChatModelClaude2, MaxTokens: 100, Stream: true, Messages: []Message{User("Hi")}},
+		func(delta string, done bool) { got += delta },
+	)
+	var interrupted *ErrStreamInterrupted
+	if !errors.As(err, &interrupted) || interrupted.Content != "Hel" || got != "Hel" {
+		t.Fatalf("expected an interrupted stream after %q, got %v", got, err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.HTTPStatusCode != 529 || CategorizeError(err) != ErrorServer {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestStreamReconnect(t *testing.T) {
+	type request struct {
+		Messages []claudeMessage `json:"messages"`
+	}
+	var bodies []request
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var body request
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			bodies = append(bodies, body)
+			w.Header().Set("Content-Type", "text/event-stream")
+			if len(bodies) == 1 {
+				fmt.Fprint(
+					w, "event: content_block_delta\n"+
+						`data: {"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": "Hello"}}`+"\n\n",
+				)
+				w.(http.Flusher).Flush()
+				// Drop the connection midway.
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+			fmt.Fprint(
+				w, "event: content_block_delta\n"+
+					`data: {"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": " world"}}`+"\n\n"+
+					"event: message_stop\n"+`data: {"type": "message_stop"}`+"\n\n",
+			)
+		},
+		WithStreamReconnect(1),
+	)
+	c.updateConfig(func(cfg *Config) { cfg.ClaudeMessagesAPI = true })
+
+	var deltas []string
+	err := c.StreamChatDeltas(
+		context.Background(),
+		ChatRequest{Model: ChatModelClaude2, MaxTokens: 100, Stream: true, Messages: []Message{User("Hi")}},
+		func(delta ChatDelta) {
+			if delta.Content != "" {
+				deltas = append(deltas, delta.Content)
+			}
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("%d requests sent, want 2", len(bodies))
+	}
+	resumed := bodies[1].Messages
+	if len(resumed) != 2 || resumed[1].Role != RoleAssistant || resumed[1].Content[0].Text != "Hello" {
+		t.Errorf("partial reply not prefilled: %+v", resumed)
+	}
+	if strings.Join(deltas, "|") != "Hello| world" {
+		t.Errorf("unexpected deltas %q", deltas)
+	}
+}
+
+func TestClaudeTools(t *testing.T) {
+	_, messages, err := claudeMessages(
+		[]Message{
+			{Role: RoleUser, Content: "Weather in Paris and Rome?"},
+			{
+				Role: RoleAssistant,
+				ToolCalls: []ToolCall{
+					{ID: "toolu_1", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+					// Invalid arguments, as generated by the model.
+					{ID: "toolu_2", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":`}},
+				},
+			},
+			{Role: RoleTool, ToolCallID: "toolu_1", Content: "sunny"},
+			{Role: RoleTool, ToolCallID: "toolu_2", Content: "rainy"},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 3 || messages[2].Role != RoleUser || len(messages[2].Content) != 2 {
+		t.Fatalf("tool results not merged into one user message: %+v", messages)
+	}
+	calls := messages[1].Content
+	if calls[0].Type != "tool_use" || string(calls[0].Input) != `{"city":"Paris"}` || string(calls[1].Input) != "{}" {
+		t.Errorf("unexpected tool uses: %+v", calls)
+	}
+	if result := messages[2].Content[1]; result.Type != "tool_result" || result.ToolUseID != "toolu_2" ||
+		result.Content != "rainy" {
+		t.Errorf("unexpected tool result: %+v", result)
+	}
+
+	var r claudeResponse
+	err = json.Unmarshal(
+		[]byte(`{"id": "msg_1", "content": [{"type": "text", "text": "Let me check."},`+
+			`{"type": "tool_use", "id": "toolu_3", "name": "get_weather", "input": {"city": "Oslo"}}],`+
+			`"stop_reason": "tool_use"}`),
+		&r,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	choice := r.chatResponse().Choices[0]
+	if choice.FinishReason != "tool_calls" || len(choice.Message.ToolCalls) != 1 ||
+		choice.Message.ToolCalls[0].Function.Arguments != `{"city": "Oslo"}` {
+		t.Errorf("unexpected choice: %+v", choice)
+	}
+}
@@ -0,0 +1,45 @@
+package opencat_api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFakeClockBackoff(t *testing.T) {
+	var calls atomic.Int32
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) <= 2 {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, `{"id": "1", "choices": [{"message": {"role": "assistant", "content": "hi"}}]}`)
+		},
+		WithClock(clock), WithMaxRetries(3),
+	)
+	var retries []time.Time
+	c.OnRetry(func(e RetryEvent) { retries = append(retries, e.Time) })
+
+	_, err := c.Chat(context.Background(), ChatRequest{Model: ChatModelGPT4, Messages: []Message{User("hello")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := clock.Sleeps(), []time.Duration{500 * time.Millisecond, time.Second}; !reflect.DeepEqual(got, want) {
+		t.Errorf("slept %v, want %v", got, want)
+	}
+	if want := []time.Time{start, start.Add(500 * time.Millisecond)}; !reflect.DeepEqual(retries, want) {
+		t.Errorf("retried at %v, want %v", retries, want)
+	}
+
+	clock.Advance(statsWindow + time.Second)
+	if stats := c.Stats(); len(stats) != 0 {
+		t.Errorf("calls older than the window still counted: %+v", stats)
+	}
+}
@@ -0,0 +1,453 @@
+package opencat_api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/j178/opencat-api/internal/toml"
+)
+
+// Config holds the settings of a Client that can be changed while it is in use, see Client.ApplyConfig.
+type Config struct {
+	Token   string
+	BaseURL string
+	// Auth is how the token is sent, see WithAuth.
+	Auth AuthScheme
+	// Timeout limits the duration of each request, including reading the response body. Zero means no limit.
+	Timeout time.Duration
+	// StreamReconnects is the number of times a dropped stream is reconnected, see WithStreamReconnect.
+	StreamReconnects int
+	// MaxRetries is the number of times a failed request is retried, see WithMaxRetries.
+	MaxRetries int
+	// DefaultModel is used for chat requests that don't set a model.
+	DefaultModel ChatModel
+	// Endpoints overrides the timeout and retries, and sets rate limits, per endpoint.
+	// For example, image generation may need a longer timeout than the usage query.
+	Endpoints map[Endpoint]EndpointConfig
+	// Backends are other endpoints or accounts that share the load of requests made with WithSession.
+	// Each session sticks to one of them or to the main endpoint.
+	Backends []Backend
+	// ClaudeMessagesAPI sends Claude requests to the Messages API, with a system prompt and role-structured
+	// messages, instead of the legacy completion API. Claude 3 models always use the Messages API.
+	ClaudeMessagesAPI bool
+	// ReplaceDeprecatedModels makes requests for a model past its sunset date use its replacement,
+	// see DeprecationOf. A warning is published either way.
+	ReplaceDeprecatedModels bool
+	// AzureSpeech sets the region, language and output format of speech synthesized by Azure.
+	AzureSpeech AzureSpeechConfig
+	// Aliases are model names, like fast or smart, that stand for a model and its default parameters.
+	// They can be used wherever a chat model is, including DefaultModel.
+	Aliases map[ChatModel]ModelAlias
+	// Fallbacks are the models a chat request is sent to, in order, when its model fails because its provider
+	// is down, overloaded or unreachable. They may be aliases. Only Chat falls back, not streaming requests.
+	Fallbacks map[ChatModel][]ChatModel
+}
+
+// ConfigError is an invalid setting of a Config. Key names the setting as in the configuration file,
+// like endpoints.image.timeout, see LoadConfig.
+type ConfigError struct {
+	Key string
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Key + ": " + e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+func (cfg *Config) validate() error {
+	if cfg.Token == "" {
+		return &ConfigError{"token", errors.New("token is empty")}
+	}
+	if cfg.Auth.Header != "" && cfg.Auth.Query != "" {
+		return &ConfigError{"auth", errors.New("auth must use either a header or a query parameter")}
+	}
+	err := validateBaseURL(cfg.BaseURL)
+	if err != nil {
+		return &ConfigError{"base_url", err}
+	}
+	for name, e := range cfg.Endpoints {
+		err = e.validate()
+		if err != nil {
+			return &ConfigError{fmt.Sprintf("endpoints.%s", name), err}
+		}
+	}
+	for i, b := range cfg.Backends {
+		err = validateBaseURL(b.BaseURL)
+		if err != nil {
+			return &ConfigError{fmt.Sprintf("backends[%d].base_url", i), err}
+		}
+	}
+	for key, n := range map[string]int64{
+		"timeout":           int64(cfg.Timeout),
+		"stream_reconnects": int64(cfg.StreamReconnects),
+		"max_retries":       int64(cfg.MaxRetries),
+	} {
+		if n < 0 {
+			return &ConfigError{key, errors.New("must not be negative")}
+		}
+	}
+	err = validateAliases(cfg.Aliases)
+	if err != nil {
+		return err
+	}
+	return validateFallbacks(cfg.Fallbacks, cfg.Aliases)
+}
+
+func validateBaseURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid base URL %q: scheme must be http or https", s)
+	}
+	return nil
+}
+
+type configKey struct{}
+
+// config returns the current configuration, which must not be modified.
+func (c *Client) config() *Config {
+	return c.cfg.Load()
+}
+
+// requestConfig returns the configuration a request was created with.
+func requestConfig(ctx context.Context) *Config {
+	cfg, _ := ctx.Value(configKey{}).(*Config)
+	return cfg
+}
+
+func (c *Client) updateConfig(fn func(cfg *Config)) {
+	cfg := *c.config()
+	fn(&cfg)
+	c.cfg.Store(&cfg)
+}
+
+// configOption changes the configuration for a ClientOption, now and whenever it is replaced by ApplyConfig.
+func (c *Client) configOption(fn func(cfg *Config)) {
+	c.options = append(c.options, fn)
+	c.updateConfig(fn)
+}
+
+// Config returns a copy of the current configuration.
+func (c *Client) Config() Config {
+	return *c.config()
+}
+
+// ApplyConfig replaces the configuration of the client. It is safe to call while requests are in flight:
+// requests already started keep the configuration they started with, new requests use the new one.
+// An empty BaseURL means the default OpenCat endpoint. Settings made by the ClientOptions of the client,
+// like WithAzureSpeech or WithMaxRetries, are kept: they take precedence over cfg.
+func (c *Client) ApplyConfig(cfg Config) error {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = baseURL
+	}
+	for _, opt := range c.options {
+		opt(&cfg)
+	}
+	err := cfg.validate()
+	if err != nil {
+		return err
+	}
+	c.cfg.Store(&cfg)
+	return nil
+}
+
+// configFile is the content of a configuration file, see LoadConfig.
+type configFile struct {
+	Token   string `json:"token"`
+	BaseURL string `json:"base_url"`
+	Auth    struct {
+		Header string `json:"header"`
+		Query  string `json:"query"`
+	} `json:"auth"`
+	Timeout          string    `json:"timeout"`
+	StreamReconnects int       `json:"stream_reconnects"`
+	MaxRetries       int       `json:"max_retries"`
+	DefaultModel     ChatModel `json:"default_model"`
+	Endpoints        map[Endpoint]struct {
+		Timeout    string  `json:"timeout"`
+		MaxRetries int     `json:"max_retries"`
+		RateLimit  float64 `json:"rate_limit"`
+		Burst      int     `json:"burst"`
+	} `json:"endpoints"`
+	Backends []struct {
+		BaseURL string `json:"base_url"`
+		Token   string `json:"token"`
+	} `json:"backends"`
+	ClaudeMessagesAPI       bool `json:"claude_messages_api"`
+	ReplaceDeprecatedModels bool `json:"replace_deprecated_models"`
+	AzureSpeech             struct {
+		Region       string `json:"region"`
+		Language     string `json:"language"`
+		OutputFormat string `json:"output_format"`
+	} `json:"azure_speech"`
+	Aliases   map[ChatModel]ModelAlias  `json:"aliases"`
+	Fallbacks map[ChatModel][]ChatModel `json:"fallbacks"`
+
+	// Moderation and Tenants are settings of the client made by NewClientFromConfig, which can't be
+	// changed while it is in use.
+	Moderation bool `json:"moderation"`
+	Tenants    *struct {
+		Default tenantLimitsFile            `json:"default"`
+		Limits  map[string]tenantLimitsFile `json:"limits"`
+	} `json:"tenants"`
+}
+
+type tenantLimitsFile struct {
+	RequestsPerMinute int `json:"requests_per_minute"`
+	TokensPerMinute   int `json:"tokens_per_minute"`
+	Budget            struct {
+		Amount   float64 `json:"amount"`
+		Currency string  `json:"currency"`
+	} `json:"budget"`
+}
+
+func (t tenantLimitsFile) limits(key string) (TenantLimits, error) {
+	if t.RequestsPerMinute < 0 || t.TokensPerMinute < 0 || t.Budget.Amount < 0 {
+		return TenantLimits{}, &ConfigError{key, errors.New("limits must not be negative")}
+	}
+	return TenantLimits{
+		RequestsPerMinute: t.RequestsPerMinute,
+		TokensPerMinute:   t.TokensPerMinute,
+		Budget:            Cost{Amount: t.Budget.Amount, Currency: t.Budget.Currency},
+	}, nil
+}
+
+// readConfigFile reads a configuration file. If strict, unknown keys are rejected so typos don't go unnoticed.
+// A TOML file is decoded as the JSON file it maps to, so both report errors the same way.
+func readConfigFile(path string, strict bool) (configFile, error) {
+	var v configFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return v, err
+	}
+	if filepath.Ext(path) == ".toml" {
+		var doc map[string]any
+		doc, err = toml.Unmarshal(data)
+		if err == nil {
+			data, err = json.Marshal(doc)
+		}
+		if err != nil {
+			return v, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	err = dec.Decode(&v)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		err = &ConfigError{typeErr.Field, fmt.Errorf("cannot be a %s", typeErr.Value)}
+	}
+	if err != nil {
+		return v, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return v, nil
+}
+
+// LoadConfig reads a configuration from a JSON file like:
+//
+//	{
+//	  "token": "...",
+//	  "base_url": "https://api.opencat.app",
+//	  "auth": {"header": "api-key"},
+//	  "timeout": "60s",
+//	  "stream_reconnects": 2,
+//	  "max_retries": 3,
+//	  "default_model": "gpt-4",
+//	  "endpoints": {"image": {"timeout": "120s", "max_retries": 1, "rate_limit": 0.5, "burst": 2}},
+//	  "backends": [{"base_url": "https://gateway.example.com", "token": "..."}],
+//	  "claude_messages_api": true,
+//	  "replace_deprecated_models": true,
+//	  "azure_speech": {"region": "westus", "language": "en-US", "output_format": "audio-24khz-96kbitrate-mono-mp3"},
+//	  "aliases": {"fast": {"model": "gpt-3.5-turbo", "max_tokens": 500}, "smart": {"model": "gpt-4", "preset": "precise"}},
+//	  "fallbacks": {"gpt-4": ["claude-2.1", "fast"]}
+//	}
+//
+// or from a TOML file with the same keys, if its name ends in .toml:
+//
+//	token = "..."
+//	timeout = "60s"
+//	default_model = "smart"
+//
+//	[endpoints.image]
+//	timeout = "120s"
+//
+//	[aliases.smart]
+//	model = "gpt-4"
+//	preset = "precise"
+//
+//	[fallbacks]
+//	gpt-4 = ["claude-2.1"]
+//
+// TOML files may use tables, arrays of tables, inline tables and arrays, but not multi-line strings or dates.
+// YAML is not supported.
+//
+// Unknown keys are ignored, like the settings of NewClientFromConfig the file may also hold.
+// The file configures the client, not what it is used for: bots, with their prompts and conversations,
+// are built in code, see the prompt package and Conversation.
+func LoadConfig(path string) (Config, error) {
+	v, err := readConfigFile(path, false)
+	if err != nil {
+		return Config{}, err
+	}
+	return v.config(path)
+}
+
+// config returns the Config set by the file at path.
+func (v *configFile) config(path string) (Config, error) {
+	var err error
+	cfg := Config{
+		Token:                   v.Token,
+		BaseURL:                 v.BaseURL,
+		Auth:                    AuthScheme{Header: v.Auth.Header, Query: v.Auth.Query},
+		StreamReconnects:        v.StreamReconnects,
+		MaxRetries:              v.MaxRetries,
+		DefaultModel:            v.DefaultModel,
+		ClaudeMessagesAPI:       v.ClaudeMessagesAPI,
+		ReplaceDeprecatedModels: v.ReplaceDeprecatedModels,
+		AzureSpeech: AzureSpeechConfig{
+			Region:       v.AzureSpeech.Region,
+			Language:     v.AzureSpeech.Language,
+			OutputFormat: v.AzureSpeech.OutputFormat,
+		},
+		Aliases:   v.Aliases,
+		Fallbacks: v.Fallbacks,
+	}
+	for name, e := range v.Endpoints {
+		ec := EndpointConfig{MaxRetries: e.MaxRetries, RateLimit: e.RateLimit, Burst: e.Burst}
+		if e.Timeout != "" {
+			ec.Timeout, err = time.ParseDuration(e.Timeout)
+			if err != nil {
+				return Config{}, fmt.Errorf("parse %s: %w", path, &ConfigError{fmt.Sprintf("endpoints.%s.timeout", name), err})
+			}
+		}
+		if cfg.Endpoints == nil {
+			cfg.Endpoints = map[Endpoint]EndpointConfig{}
+		}
+		cfg.Endpoints[name] = ec
+	}
+	for _, b := range v.Backends {
+		cfg.Backends = append(cfg.Backends, Backend{BaseURL: b.BaseURL, Token: b.Token})
+	}
+	if v.Timeout != "" {
+		cfg.Timeout, err = time.ParseDuration(v.Timeout)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse %s: %w", path, &ConfigError{"timeout", err})
+		}
+	}
+	return cfg, nil
+}
+
+// NewClientFromConfig returns a client configured by a file, see LoadConfig, which may also set:
+//
+//	{
+//	  "moderation": true,
+//	  "tenants": {
+//	    "default": {"requests_per_minute": 20, "tokens_per_minute": 40000},
+//	    "limits": {"acme": {"requests_per_minute": 100, "budget": {"amount": 50, "currency": "USD"}}}
+//	  }
+//	}
+//
+// to screen chat requests, see WithModeration, and limit tenants, see WithTenantLimiter.
+// Invalid settings are reported as a *ConfigError naming their key, and unknown keys are an error.
+// opts are applied after the file.
+func NewClientFromConfig(path string, opts ...ClientOption) (*Client, error) {
+	v, err := readConfigFile(path, true)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := v.config(path)
+	if err != nil {
+		return nil, err
+	}
+	c := NewClient(cfg.Token)
+	err = c.ApplyConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if v.Moderation {
+		WithModeration()(c)
+	}
+	if v.Tenants != nil {
+		defaults, err := v.Tenants.Default.limits("tenants.default")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		l := NewTenantLimiter(defaults)
+		for id, t := range v.Tenants.Limits {
+			limits, err := t.limits(fmt.Sprintf("tenants.limits.%s", id))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			l.SetLimits(id, limits)
+		}
+		WithTenantLimiter(l)(c)
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// WatchConfigFile applies the configuration file at path, see LoadConfig, and applies it again whenever
+// the file changes, checking every interval. It blocks until ctx is done.
+// An error loading the file initially is returned, later errors are passed to onError, if not nil,
+// and the previous configuration stays in effect.
+// Only the settings of LoadConfig are applied: changes to the moderation and tenants settings of
+// NewClientFromConfig are ignored until the client is created again. Settings missing from the file
+// are reset to their defaults, except those made by ClientOptions, see ApplyConfig.
+func (c *Client) WatchConfigFile(ctx context.Context, path string, interval time.Duration, onError func(error)) error {
+	apply := func() error {
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			return err
+		}
+		return c.ApplyConfig(cfg)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	err = apply()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	modTime, size := info.ModTime(), info.Size()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err == nil {
+			if info.ModTime().Equal(modTime) && info.Size() == size {
+				continue
+			}
+			modTime, size = info.ModTime(), info.Size()
+			err = apply()
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"token": "a", "timeout": "30s"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient("initial", WithAzureSpeech(AzureSpeechConfig{Region: "westus"}), WithMaxRetries(2))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 10)
+	go func() {
+		_ = c.WatchConfigFile(ctx, path, 10*time.Millisecond, func(err error) { errs <- err })
+	}()
+
+	waitFor := func(cond func(Config) bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond(c.Config()) {
+			if time.Now().After(deadline) {
+				t.Fatalf("config not applied: %+v", c.Config())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	waitFor(func(cfg Config) bool { return cfg.Token == "a" && cfg.Timeout == 30*time.Second })
+	if c.Config().BaseURL != baseURL {
+		t.Errorf("base URL = %q, want default", c.Config().BaseURL)
+	}
+
+	err = os.WriteFile(path, []byte(`{"token": "b", "base_url": "ftp://example.com"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		t.Logf("invalid config rejected: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("invalid config not reported")
+	}
+	if c.Config().Token != "a" {
+		t.Errorf("invalid config was applied")
+	}
+
+	err = os.WriteFile(path, []byte(`{"token": "token-c", "default_model": "gpt-4"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(func(cfg Config) bool { return cfg.Token == "token-c" && cfg.DefaultModel == ChatModelGPT4 })
+	// The settings of the client options survive reloads.
+	if cfg := c.Config(); cfg.AzureSpeech.Region != "westus" || cfg.MaxRetries != 2 {
+		t.Errorf("settings of the client options lost: %+v", cfg)
+	}
+}
+
+func TestSessionBackend(t *testing.T) {
+	cfg := &Config{
+		Token:   "main",
+		BaseURL: baseURL,
+		Backends: []Backend{
+			{BaseURL: "https://a.example.com"},
+			{BaseURL: "https://b.example.com", Token: "b"},
+		},
+	}
+	ctx := context.Background()
+	if b := cfg.backend(ctx); b.BaseURL != baseURL || b.Token != "main" {
+		t.Errorf("request without session routed to %+v", b)
+	}
+
+	used := map[string]int{}
+	for i := 0; i < 100; i++ {
+		sctx := WithSession(ctx, fmt.Sprint("session-", i))
+		b := cfg.backend(sctx)
+		if b != cfg.backend(sctx) {
+			t.Fatalf("session %d routed to different backends", i)
+		}
+		if b.BaseURL == "https://a.example.com" && b.Token != "main" {
+			t.Errorf("backend without token should use the main token, got %q", b.Token)
+		}
+		used[b.BaseURL]++
+	}
+	if len(used) != 3 {
+		t.Errorf("sessions not spread over all backends: %v", used)
+	}
+
+	// Removing a backend only moves its own sessions.
+	fewer := &Config{Token: cfg.Token, BaseURL: cfg.BaseURL, Backends: cfg.Backends[:1]}
+	for i := 0; i < 100; i++ {
+		sctx := WithSession(ctx, fmt.Sprint("session-", i))
+		if b := cfg.backend(sctx); b.BaseURL != "https://b.example.com" && fewer.backend(sctx) != b {
+			t.Errorf("session %d moved from %s", i, b.BaseURL)
+		}
+	}
+}
+
+func TestEndpointConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(
+		path,
+		[]byte(`{"token": "a", "timeout": "30s", "max_retries": 2, "endpoints": {"image": {"timeout": "120s"}, "usage": {"timeout": "5s", "max_retries": 1, "rate_limit": 10}, "speech": {"max_retries": -1}}}`),
+		0644,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.timeout(EndpointImage) != 120*time.Second || cfg.timeout(EndpointChat) != 30*time.Second ||
+		cfg.timeout(EndpointUsage) != 5*time.Second {
+		t.Errorf("timeouts not applied per endpoint: %+v", cfg.Endpoints)
+	}
+	if cfg.maxRetries(EndpointUsage) != 1 || cfg.maxRetries(EndpointChat) != 2 || cfg.maxRetries(EndpointSpeech) != 0 {
+		t.Errorf("max retries not applied per endpoint: %+v", cfg.Endpoints)
+	}
+	if endpointOf("/1/images/generations") != EndpointImage || endpointOf("/v1/audio/speech") != EndpointSpeech ||
+		endpointOf("/1.1/me/usage") != EndpointUsage || endpointOf("/v1/complete") != EndpointChat {
+		t.Errorf("wrong endpoint of paths")
+	}
+
+	var limiters rateLimiters
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	for i := 0; i < 3; i++ {
+		err = limiters.wait(context.Background(), clock, &cfg, EndpointUsage)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first request goes through at once, the others wait 100ms each.
+	if elapsed := clock.Now().Sub(start); elapsed < 200*time.Millisecond || elapsed > 201*time.Millisecond {
+		t.Errorf("rate limit not applied, 3 requests took %v", elapsed)
+	}
+}
+
+func TestAuthScheme(t *testing.T) {
+	tests := []struct {
+		scheme AuthScheme
+		check  func(*http.Request) bool
+	}{
+		{AuthBearer, func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer secret" }},
+		{
+			AuthHeader("api-key"), func(r *http.Request) bool {
+				return r.Header.Get("api-key") == "secret" && r.Header.Get("Authorization") == ""
+			},
+		},
+		{AuthQuery("key"), func(r *http.Request) bool { return r.URL.Query().Get("key") == "secret" }},
+	}
+	for _, tt := range tests {
+		c := NewClient("secret", WithAuth(tt.scheme))
+		req, err := c.newRequest(context.Background(), "GET", "/1.1/me/usage", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tt.check(req) {
+			t.Errorf("%+v: token not sent as expected: %v %v", tt.scheme, req.URL, req.Header)
+		}
+	}
+}
+
+func TestNewClientFromConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(
+		path,
+		[]byte(`{"token": "a", "max_retries": 2, "aliases": {"fast": {"model": "gpt-3.5-turbo"}}, "moderation": true,
+"tenants": {"default": {"requests_per_minute": 20}, "limits": {"acme": {"budget": {"amount": 50, "currency": "USD"}}}}}`),
+		0644,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClientFromConfig(path, WithMaxRetries(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := c.Config()
+	if cfg.Token != "a" || cfg.MaxRetries != 3 || cfg.Aliases["fast"].Model != ChatModelGPT3Dot5Turbo {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if !c.moderation || c.tenants == nil || c.tenants.limitsOf("other").RequestsPerMinute != 20 ||
+		c.tenants.limitsOf("acme").Budget != (Cost{Amount: 50, Currency: "USD"}) {
+		t.Errorf("client settings not applied")
+	}
+	// The client settings don't get in the way of LoadConfig.
+	_, err = LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Neither do unknown keys, unlike for NewClientFromConfig.
+	err = os.WriteFile(path, []byte(`{"token": "a", "tokn": "b"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadConfig(path)
+	if err != nil {
+		t.Errorf("unknown key rejected by LoadConfig: %v", err)
+	}
+
+	for content, key := range map[string]string{
+		`{"token": "a", "tokn": "b"}`:                                                          "",
+		`{"token": "a", "max_retries": "2"}`:                                                   "max_retries",
+		`{"token": "a", "endpoints": {"image": {"timeout": "2 minutes"}}}`:                     "endpoints.image.timeout",
+		`{"token": "a", "endpoints": {"image": {"rate_limit": -1}}}`:                           "endpoints.image",
+		`{"token": "a", "backends": [{"base_url": "ftp://example.com"}]}`:                      "backends[0].base_url",
+		`{"token": "a", "aliases": {"fast": {"model": "smart"}, "smart": {"model": "gpt-4"}}}`: "aliases.fast.model",
+		`{"token": "a", "tenants": {"limits": {"acme": {"tokens_per_minute": -5}}}}`:           "tenants.limits.acme",
+		`{"base_url": "https://example.com"}`:                                                  "token",
+	} {
+		err = os.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = NewClientFromConfig(path)
+		var cfgErr *ConfigError
+		switch {
+		case err == nil:
+			t.Errorf("%s: expected an error", content)
+		case key == "" && !strings.Contains(err.Error(), `"tokn"`):
+			t.Errorf("%s: unknown key not reported: %v", content, err)
+		case key != "" && (!errors.As(err, &cfgErr) || cfgErr.Key != key):
+			t.Errorf("%s: error %v doesn't point at %s", content, err, key)
+		}
+	}
+}
+
+func TestNewClientFromTOMLConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	err := os.WriteFile(
+		path, []byte(`token = "a"
+timeout = "60s"
+moderation = true
+
+[endpoints.image]
+timeout = "120s"
+
+[aliases]
+fast = { model = "gpt-3.5-turbo", max_tokens = 500 }
+
+[fallbacks]
+gpt-4 = ["claude-2.1", "fast"]
+
+[[backends]]
+base_url = "https://gateway.example.com"
+token = "b"
+
+[tenants.limits.acme]
+requests_per_minute = 100
+budget = { amount = 50, currency = "USD" }
+`),
+		0644,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClientFromConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := c.Config()
+	if cfg.Token != "a" || cfg.Timeout != time.Minute || cfg.Endpoints[EndpointImage].Timeout != 2*time.Minute ||
+		cfg.Aliases["fast"].MaxTokens != 500 || len(cfg.Fallbacks[ChatModelGPT4]) != 2 ||
+		len(cfg.Backends) != 1 || cfg.Backends[0].Token != "b" {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if !c.moderation || c.tenants.limitsOf("acme").RequestsPerMinute != 100 {
+		t.Errorf("client settings not applied")
+	}
+
+	for content, key := range map[string]string{
+		"token = \"a\"\nmax_retries = \"2\"":                  "max_retries",
+		"token = \"a\"\n[endpoints.image]\ntimeout = 120":     "endpoints.image.timeout",
+		"token = \"a\"\n[fallbacks]\ngpt-4 = [\"\"]":          "fallbacks.gpt-4[0]",
+		"token = \"a\"\n[[backends]]\nbase_url = \"ftp://x\"": "backends[0].base_url",
+	} {
+		err = os.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = NewClientFromConfig(path)
+		var cfgErr *ConfigError
+		if !errors.As(err, &cfgErr) || cfgErr.Key != key {
+			t.Errorf("%q: error %v doesn't point at %s", content, err, key)
+		}
+	}
+	err = os.WriteFile(path, []byte("token = \"a\"\nstarted = 2024-01-01"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewClientFromConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected a syntax error on line 2, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// OutputConstraints limit the length and format of a reply, for surfaces with hard layout limits.
+// They are added to the prompt as instructions, and a reply that breaks them is asked again once.
+// Zero fields are not constrained.
+type OutputConstraints struct {
+	// MaxChars is the maximum number of characters, counted as Unicode code points.
+	MaxChars int
+	// MaxSentences is the maximum number of sentences.
+	MaxSentences int
+	// NoMarkdown asks for plain text, without headings, emphasis, lists, links or code.
+	NoMarkdown bool
+	// BulletItems asks for a bulleted list of exactly that many items and nothing else.
+	BulletItems int
+}
+
+// WithConstraints makes the reply follow constraints, see ChatRequest.Constraints.
+func WithConstraints(constraints OutputConstraints) RequestOption {
+	return func(r *ChatRequest) {
+		r.Constraints = &constraints
+	}
+}
+
+func (o *OutputConstraints) validate() error {
+	if o.MaxChars < 0 || o.MaxSentences < 0 || o.BulletItems < 0 {
+		return errors.New("output constraints must not be negative")
+	}
+	if o.NoMarkdown && o.BulletItems > 0 {
+		return errors.New("a bulleted list is Markdown, it can't be asked for with NoMarkdown")
+	}
+	return nil
+}
+
+func (o *OutputConstraints) instructions() []string {
+	var instructions []string
+	if o.MaxChars > 0 {
+		instructions = append(instructions, fmt.Sprintf("Keep the reply under %d characters.", o.MaxChars))
+	}
+	switch {
+	case o.MaxSentences == 1:
+		instructions = append(instructions, "Reply in a single sentence.")
+	case o.MaxSentences > 1:
+		instructions = append(instructions, fmt.Sprintf("Use at most %d sentences.", o.MaxSentences))
+	}
+	if o.NoMarkdown {
+		instructions = append(instructions, "Reply in plain text, without any Markdown formatting.")
+	}
+	if o.BulletItems > 0 {
+		instructions = append(
+			instructions, fmt.Sprintf(
+				`Reply with a bulleted list of exactly %d items, each on its own line starting with "- ", and nothing else.`,
+				o.BulletItems,
+			),
+		)
+	}
+	return instructions
+}
+
+var (
+	sentenceEndRe = regexp.MustCompile(`[.!?]+(\s|$)|[。！？]+`)
+	markdownRe    = regexp.MustCompile(
+		"(?m)^\\s{0,3}#{1,6}\\s|^\\s*([-*+]|\\d+\\.)\\s|\\*\\*[^*]+\\*\\*|__[^_]+__|`|\\[[^\\]]*\\]\\([^)]*\\)",
+	)
+	bulletRe = regexp.MustCompile(`^\s*[-*•]\s+\S`)
+)
+
+// countSentences counts the sentences of text, ended by punctuation or by the end of the text.
+func countSentences(text string) int {
+	n := 0
+	for _, sentence := range sentenceEndRe.Split(strings.TrimSpace(text), -1) {
+		if strings.TrimSpace(sentence) != "" {
+			n++
+		}
+	}
+	return n
+}
+
+func (o *OutputConstraints) check(content string) error {
+	content = strings.TrimSpace(content)
+	var errs []error
+	if n := utf8.RuneCountInString(content); o.MaxChars > 0 && n > o.MaxChars {
+		errs = append(errs, fmt.Errorf("the reply has %d characters, more than %d", n, o.MaxChars))
+	}
+	if n := countSentences(content); o.MaxSentences > 0 && n > o.MaxSentences {
+		errs = append(errs, fmt.Errorf("the reply has %d sentences, more than %d", n, o.MaxSentences))
+	}
+	if o.NoMarkdown && markdownRe.MatchString(content) {
+		errs = append(errs, errors.New("the reply uses Markdown formatting"))
+	}
+	if o.BulletItems > 0 {
+		items, other := 0, false
+		for _, line := range strings.Split(content, "\n") {
+			switch {
+			case strings.TrimSpace(line) == "":
+			case bulletRe.MatchString(line):
+				items++
+			default:
+				other = true
+			}
+		}
+		switch {
+		case other:
+			errs = append(errs, errors.New("the reply has text outside the bulleted list"))
+		case items != o.BulletItems:
+			errs = append(errs, fmt.Errorf("the reply has %d items instead of %d", items, o.BulletItems))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// chatConstrained sends a chat request with instructions to follow its language and constraints,
+// and asks again once if the reply doesn't.
+func (c *Client) chatConstrained(ctx context.Context, chat ChatRequest) (ChatResponse, error) {
+	var (
+		instructions []string
+		checks       []func(content string) error
+	)
+	if chat.Language != "" {
+		instruction, check := languageRequirement(chat.Language)
+		instructions, checks = append(instructions, instruction), append(checks, check)
+	}
+	if o := chat.Constraints; o != nil {
+		instructions, checks = append(instructions, o.instructions()...), append(checks, o.check)
+	}
+	messages := chat.Messages
+	if len(instructions) > 0 {
+		instruction := Message{Role: RoleSystem, Content: strings.Join(instructions, " ")}
+		messages = append(messages[:len(messages):len(messages)], instruction)
+	}
+
+	return c.chatValidated(
+		ctx,
+		func() ChatRequest {
+			r := chat
+			r.Language, r.Constraints, r.Messages = "", nil, messages
+			return r
+		},
+		func(content string) error {
+			var errs []error
+			for _, check := range checks {
+				if err := check(content); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			return errors.Join(errs...)
+		},
+		1,
+	)
+}
@@ -0,0 +1,74 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOutputConstraints(t *testing.T) {
+	tests := []struct {
+		constraints OutputConstraints
+		content     string
+		ok          bool
+	}{
+		{OutputConstraints{MaxChars: 5}, "你好，世界", true},
+		{OutputConstraints{MaxChars: 5}, "Hello, world", false},
+		{OutputConstraints{MaxSentences: 2}, "One. Two! Three?", false},
+		{OutputConstraints{MaxSentences: 2}, "第一句。第二句。", true},
+		{OutputConstraints{MaxSentences: 1}, "Version 1.5 is out", true},
+		{OutputConstraints{NoMarkdown: true}, "Plain text, 3 items.", true},
+		{OutputConstraints{NoMarkdown: true}, "## Title\nText", false},
+		{OutputConstraints{NoMarkdown: true}, "Use **bold**", false},
+		{OutputConstraints{BulletItems: 2}, "- one\n- two\n", true},
+		{OutputConstraints{BulletItems: 2}, "- one\n- two\n- three", false},
+		{OutputConstraints{BulletItems: 2}, "Here you go:\n- one\n- two", false},
+	}
+	for _, tt := range tests {
+		if err := tt.constraints.check(tt.content); (err == nil) != tt.ok {
+			t.Errorf("%+v.check(%q) = %v", tt.constraints, tt.content, err)
+		}
+	}
+	if err := (&OutputConstraints{NoMarkdown: true, BulletItems: 3}).validate(); err == nil {
+		t.Error("expected an error for a bulleted list without Markdown")
+	}
+}
+
+func TestChatConstraints(t *testing.T) {
+	var requests [][]Message
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var body struct{ Messages []Message }
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			requests = append(requests, body.Messages)
+			reply := "Paris is the capital. It is large. It is old."
+			if len(requests) > 1 {
+				reply = "Paris is the capital."
+			}
+			fmt.Fprintf(w, `{"choices": [{"message": {"role": "assistant", "content": %q}}]}`, reply)
+		},
+	)
+
+	resp, err := c.Chat(
+		context.Background(),
+		ChatRequest{Model: ChatModelGPT4, Messages: []Message{User("Tell me about Paris.")}},
+		WithConstraints(OutputConstraints{MaxSentences: 1, NoMarkdown: true}),
+		WithLanguage("en"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 || resp.Choices[0].Message.Content != "Paris is the capital." {
+		t.Fatalf("got %q after %d requests", resp.Choices[0].Message.Content, len(requests))
+	}
+	if instruction := requests[0][1].Content; !strings.Contains(instruction, "English") ||
+		!strings.Contains(instruction, "single sentence") || !strings.Contains(instruction, "Markdown") {
+		t.Errorf("unexpected instruction: %q", instruction)
+	}
+	if retry := requests[1]; !strings.Contains(retry[len(retry)-1].Content, "3 sentences, more than 1") {
+		t.Errorf("no correction in %+v", retry)
+	}
+}
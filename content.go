@@ -0,0 +1,199 @@
+package opencat_api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ContentPart is a piece of a multi-part message, see Message.Parts.
+type ContentPart struct {
+	Type  PartType
+	Text  string
+	Image Image
+	// Audio is the content of an audio part, in AudioFormat, like wav or mp3.
+	Audio       []byte
+	AudioFormat string
+}
+
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: PartText, Text: text}
+}
+
+func ImagePart(image Image) ContentPart {
+	return ContentPart{Type: PartImage, Image: image}
+}
+
+func AudioPart(data []byte, format string) ContentPart {
+	return ContentPart{Type: PartAudio, Audio: data, AudioFormat: format}
+}
+
+// Text returns the text of the message: its Content, followed by its text parts, one per line.
+func (m Message) Text() string {
+	if len(m.Parts) == 0 {
+		return m.Content
+	}
+	var texts []string
+	if m.Content != "" {
+		texts = append(texts, m.Content)
+	}
+	for _, p := range m.Parts {
+		if p.Type == PartText {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// images returns the images of the message, attached or in parts.
+func (m Message) images() []Image {
+	images := m.Images
+	for _, p := range m.Parts {
+		if p.Type == PartImage {
+			images = append(images[:len(images):len(images)], p.Image)
+		}
+	}
+	return images
+}
+
+// replayable returns the message with its images, in Images and Parts, made replayable, see Image.replayable.
+func (m Message) replayable() (Message, error) {
+	var err error
+	m.Images = slices.Clone(m.Images)
+	for i := range m.Images {
+		m.Images[i], err = m.Images[i].replayable()
+		if err != nil {
+			return Message{}, err
+		}
+	}
+	m.Parts = slices.Clone(m.Parts)
+	for i := range m.Parts {
+		if m.Parts[i].Type == PartImage {
+			m.Parts[i].Image, err = m.Parts[i].Image.replayable()
+			if err != nil {
+				return Message{}, err
+			}
+		}
+	}
+	return m, nil
+}
+
+// MarshalJSON encodes a message with parts with a list of content parts, and other messages
+// with the content as a string and the images apart.
+func (m Message) MarshalJSON() ([]byte, error) {
+	type message Message
+	if len(m.Parts) == 0 {
+		return json.Marshal(message(m))
+	}
+
+	type imageURL struct {
+		URL    string      `json:"url"`
+		Detail ImageDetail `json:"detail,omitempty"`
+	}
+	type inputAudio struct {
+		Data   string `json:"data"`
+		Format string `json:"format"`
+	}
+	type contentPart struct {
+		Type       string      `json:"type"`
+		Text       string      `json:"text,omitempty"`
+		ImageURL   *imageURL   `json:"image_url,omitempty"`
+		InputAudio *inputAudio `json:"input_audio,omitempty"`
+	}
+
+	var parts []contentPart
+	if m.Content != "" {
+		parts = append(parts, contentPart{Type: "text", Text: m.Content})
+	}
+	addImage := func(img Image) error {
+		uri, err := img.uri()
+		if err != nil {
+			return err
+		}
+		parts = append(parts, contentPart{Type: "image_url", ImageURL: &imageURL{URL: uri, Detail: img.detail}})
+		return nil
+	}
+	for _, p := range m.Parts {
+		switch p.Type {
+		case PartText:
+			parts = append(parts, contentPart{Type: "text", Text: p.Text})
+		case PartImage:
+			err := addImage(p.Image)
+			if err != nil {
+				return nil, err
+			}
+		case PartAudio:
+			parts = append(
+				parts, contentPart{
+					Type:       "input_audio",
+					InputAudio: &inputAudio{Data: base64.StdEncoding.EncodeToString(p.Audio), Format: p.AudioFormat},
+				},
+			)
+		}
+	}
+	for _, img := range m.Images {
+		err := addImage(img)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	msg := message(m)
+	msg.Images = nil
+	return json.Marshal(
+		struct {
+			message
+			Content []contentPart `json:"content"`
+		}{msg, parts},
+	)
+}
+
+// UnmarshalJSON decodes a message whose content is either a string, or a list of content parts decoded as Parts.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type message Message
+	var raw struct {
+		message
+		Content json.RawMessage `json:"content"`
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	*m = Message(raw.message)
+
+	content := strings.TrimSpace(string(raw.Content))
+	switch {
+	case content == "" || content == "null":
+		return nil
+	case !strings.HasPrefix(content, "["):
+		return json.Unmarshal(raw.Content, &m.Content)
+	}
+	var parts []struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		ImageURL   *Image `json:"image_url"`
+		InputAudio *struct {
+			Data   []byte `json:"data"`
+			Format string `json:"format"`
+		} `json:"input_audio"`
+	}
+	err = json.Unmarshal(raw.Content, &parts)
+	if err != nil {
+		return err
+	}
+	for _, p := range parts {
+		switch {
+		case p.Type == "text":
+			m.Parts = append(m.Parts, TextPart(p.Text))
+		case p.Type == "image_url" && p.ImageURL != nil:
+			m.Parts = append(m.Parts, ImagePart(*p.ImageURL))
+		case p.Type == "input_audio" && p.InputAudio != nil:
+			m.Parts = append(m.Parts, AudioPart(p.InputAudio.Data, p.InputAudio.Format))
+		default:
+			return fmt.Errorf("unsupported content part of type %q", p.Type)
+		}
+	}
+	return nil
+}
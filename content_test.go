@@ -0,0 +1,83 @@
+package opencat_api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMessageParts(t *testing.T) {
+	messages, err := NewMessages().
+		User(
+			"Which is bigger?", WithParts(
+				TextPart("This one:"),
+				ImagePart(NewImageURL("https://example.com/a.png")),
+				TextPart("or this one:"),
+				ImagePart(NewImageFromBytes([]byte("GIF89a")).WithDetail(ImageDetailHigh)),
+			),
+		).
+		BuildFor(ChatModelGPT4VisionPreview)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"role":"user","content":[{"type":"text","text":"Which is bigger?"},{"type":"text","text":"This one:"},` +
+		`{"type":"image_url","image_url":{"url":"https://example.com/a.png"}},{"type":"text","text":"or this one:"},` +
+		`{"type":"image_url","image_url":{"url":"data:image/gif;base64,R0lGODlh","detail":"high"}}]}]`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+
+	// Messages without parts keep the string content.
+	data, err = json.Marshal(Message{Role: RoleUser, Content: "hi"})
+	if err != nil || string(data) != `{"role":"user","content":"hi"}` {
+		t.Errorf("got %s, %v", data, err)
+	}
+
+	if text := messages[0].Text(); text != "Which is bigger?\nThis one:\nor this one:" {
+		t.Errorf("Text() = %q", text)
+	}
+}
+
+func TestMessageJSONRoundTrip(t *testing.T) {
+	messages := []Message{
+		{Role: RoleSystem, Content: "Be brief."},
+		{
+			Role:    RoleUser,
+			Content: "What is this?",
+			Parts: []ContentPart{
+				ImagePart(NewImage(strings.NewReader("GIF89a")).WithDetail(ImageDetailLow)),
+				AudioPart([]byte("RIFF"), "wav"),
+			},
+		},
+		{Role: RoleUser, Content: "And this?", Images: []Image{NewImageURL("https://example.com/a.png")}},
+	}
+	data, err := json.Marshal(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Message
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("got  %s\nwant %s", again, data)
+	}
+	if len(decoded[1].Parts) != 3 || decoded[1].Text() != "What is this?" ||
+		string(decoded[1].Parts[1].Image.data) != "GIF89a" {
+		t.Errorf("unexpected parts %+v", decoded[1].Parts)
+	}
+
+	err = json.Unmarshal([]byte(`{"role": "user", "content": [{"type": "video"}]}`), &decoded[0])
+	if err == nil {
+		t.Error("expected an error for an unknown content part")
+	}
+}
@@ -0,0 +1,271 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// Conversation keeps the message history of a chat, so every question is asked with the context of the previous ones.
+// It is safe for concurrent use, questions are asked one at a time.
+// All its requests belong to the same session, see WithSession, unless the context already has one.
+type Conversation struct {
+	client  *Client
+	model   ChatModel
+	session string
+
+	mu         sync.Mutex
+	system     string
+	history    []Message
+	truncation TruncationPolicy
+	defaults   []RequestOption
+	budget     ConversationBudget
+	spent      conversationSpend
+	usage      []MessageUsage
+	// pending are the events to publish once mu is released.
+	pending []Event
+}
+
+func NewConversation(c *Client, model ChatModel) *Conversation {
+	return &Conversation{
+		client:  c,
+		model:   model,
+		session: newSessionID(),
+		// Costs are counted in USD until a budget says otherwise.
+		budget: ConversationBudget{Cost: Cost{Currency: "USD"}},
+	}
+}
+
+func (conv *Conversation) withSession(ctx context.Context) context.Context {
+	if _, ok := SessionFromContext(ctx); ok {
+		return ctx
+	}
+	return WithSession(ctx, conv.session)
+}
+
+// Session returns the session of the requests of the conversation, see WithSession.
+// A session set on the context of a request takes precedence.
+func (conv *Conversation) Session() string {
+	return conv.session
+}
+
+// SetSystemPrompt pins a system prompt at the start of the conversation. It is kept by Reset.
+func (conv *Conversation) SetSystemPrompt(prompt string) {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	conv.system = prompt
+}
+
+// SetModel changes the model used for the next questions.
+func (conv *Conversation) SetModel(model ChatModel) {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	conv.model = model
+}
+
+// SetDefaults sets the request options applied to every question of the conversation, such as
+// WithTemperature, WithTools or WithResponseFormat. Options passed to Ask and AskStream are applied after them.
+func (conv *Conversation) SetDefaults(opts ...RequestOption) {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	conv.defaults = opts
+}
+
+// request returns the request for the next question, without messages. Its model is resolved if it is an alias,
+// so the context window, tokens and price are those of the model behind it.
+func (conv *Conversation) request(opts []RequestOption) ChatRequest {
+	req := ChatRequest{Model: conv.model}
+	for _, opt := range conv.defaults {
+		opt(&req)
+	}
+	for _, opt := range opts {
+		opt(&req)
+	}
+	cfg := conv.client.config()
+	if req.Model == "" {
+		req.Model = cfg.DefaultModel
+	}
+	cfg.resolveAlias(&req)
+	return req
+}
+
+// SetTruncation makes the conversation shorten its history with policy whenever the next question
+// would not fit in the context window of the model. The pinned system prompt is always kept.
+// Without a policy, long conversations fail once they exceed the context window.
+func (conv *Conversation) SetTruncation(policy TruncationPolicy) {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	conv.truncation = policy
+}
+
+// Messages returns the messages sent with the next question, starting with the system prompt if there is one.
+func (conv *Conversation) Messages() []Message {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	return conv.messages()
+}
+
+func (conv *Conversation) messages() []Message {
+	return conv.withSystem(conv.history)
+}
+
+func (conv *Conversation) withSystem(history []Message) []Message {
+	messages := make([]Message, 0, len(history)+1)
+	if conv.system != "" {
+		messages = append(messages, Message{Role: RoleSystem, Content: conv.system})
+	}
+	return append(messages, history...)
+}
+
+// fit returns the history to send with question in req, shortened by the truncation policy if needed.
+func (conv *Conversation) fit(ctx context.Context, req ChatRequest, question Message) ([]Message, error) {
+	if conv.truncation == nil {
+		return conv.history, nil
+	}
+	messages := append(slices.Clip(conv.history), question)
+	reply := req.MaxTokens
+	if reply <= 0 {
+		reply = defaultReplyTokens
+	}
+	// Count the tokens for the provider the request goes to, like the truncation policy does.
+	budget := contextWindow(req.Model) - reply
+	if conv.system != "" {
+		budget -= countTokens(req.provider(), []Message{{Role: RoleSystem, Content: conv.system}})
+	}
+	if countTokens(req.provider(), messages) <= budget {
+		return conv.history, nil
+	}
+
+	fitted, err := conv.truncation.Truncate(withTokenProvider(ctx, req.provider()), messages, budget)
+	if err != nil {
+		return nil, err
+	}
+	return fitted[:len(fitted)-1], nil
+}
+
+// Reset forgets the history, keeping the system prompt.
+func (conv *Conversation) Reset() {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	conv.history = nil
+}
+
+// Ask sends a question and returns the reply, both are added to the history.
+// opts override the defaults of the conversation for this question only.
+// If the call fails, the history is left unchanged.
+func (conv *Conversation) Ask(ctx context.Context, text string, opts ...RequestOption) (string, error) {
+	conv.mu.Lock()
+	defer conv.unlock()
+	ctx = conv.withSession(ctx)
+	err := conv.checkBudget(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	req := conv.request(opts)
+	question := Message{Role: RoleUser, Content: text}
+	history, err := conv.fit(ctx, req, question)
+	if err != nil {
+		return "", err
+	}
+	req.Messages = append(conv.withSystem(history), question)
+	resp, err := conv.client.Chat(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("response has no choices")
+	}
+	if usage := resp.Usage; usage.TotalTokens > 0 {
+		conv.addSpent(req.Model, usage.PromptTokens, usage.CompletionTokens, false)
+	} else {
+		content := resp.Choices[0].Message.Content
+		conv.addSpent(
+			req.Model, estimateTokens(req.provider(), req.Messages), estimateTextTokens(req.provider(), content), true,
+		)
+	}
+
+	reply := resp.Choices[0].Message
+	conv.history = append(
+		slices.Clip(history), question,
+		Message{Role: RoleAssistant, Content: reply.Content, ToolCalls: reply.ToolCalls},
+	)
+	return reply.Content, nil
+}
+
+// AskStream is like Ask, but streams the reply to fn.
+// If the stream is interrupted, the partial reply is kept in the history since the user has already seen it.
+// See StartStream for a turn that can be aborted and regenerated.
+func (conv *Conversation) AskStream(
+	ctx context.Context,
+	text string,
+	fn func(delta string, done bool),
+	opts ...RequestOption,
+) (string, error) {
+	conv.mu.Lock()
+	defer conv.unlock()
+	reply, _, err := conv.askStream(ctx, text, fn, opts)
+	return reply, err
+}
+
+// askStream implements AskStream, reporting whether the question and reply were added to the history.
+// conv.mu must be held.
+func (conv *Conversation) askStream(
+	ctx context.Context,
+	text string,
+	fn func(delta string, done bool),
+	opts []RequestOption,
+) (_ string, added bool, _ error) {
+	ctx = conv.withSession(ctx)
+	err := conv.checkBudget(ctx)
+	if err != nil {
+		return "", false, err
+	}
+
+	req := conv.request(opts)
+	question := Message{Role: RoleUser, Content: text}
+	history, err := conv.fit(ctx, req, question)
+	if err != nil {
+		return "", false, err
+	}
+	req.Stream = true
+	req.Messages = append(conv.withSystem(history), question)
+	var reply string
+	err = conv.client.StreamChat(
+		ctx,
+		req,
+		func(delta string, done bool) {
+			reply += delta
+			fn(delta, done)
+		},
+	)
+	// Streams don't report usage, and failed requests are only charged for the reply they received.
+	spend := func(reply string) {
+		conv.addSpent(
+			req.Model, estimateTokens(req.provider(), req.Messages), estimateTextTokens(req.provider(), reply), true,
+		)
+	}
+	if err != nil {
+		var interrupted *ErrStreamInterrupted
+		if errors.As(err, &interrupted) && interrupted.Content != "" {
+			spend(interrupted.Content)
+			conv.history = append(
+				slices.Clip(history), question, Message{Role: RoleAssistant, Content: interrupted.Content},
+			)
+			return reply, true, err
+		}
+		return reply, false, err
+	}
+
+	spend(reply)
+	conv.history = append(slices.Clip(history), question, Message{Role: RoleAssistant, Content: reply})
+	return reply, true, nil
+}
+
+// History returns the questions and replies so far, without the system prompt.
+func (conv *Conversation) History() []Message {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	return slices.Clone(conv.history)
+}
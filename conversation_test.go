@@ -0,0 +1,69 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestConversation(t *testing.T) {
+	c := client()
+	conv := NewConversation(c, ChatModelGPT3Dot5Turbo)
+	conv.SetSystemPrompt("You are a helpful assistant. Keep answers short.")
+
+	_, err := conv.Ask(context.Background(), "My name is Alice.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := conv.AskStream(
+		context.Background(), "What is my name?", func(delta string, done bool) {},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(reply, "Alice") {
+		t.Errorf("reply %q does not remember the name", reply)
+	}
+	if n := len(conv.History()); n != 4 {
+		t.Errorf("history has %d messages, want 4", n)
+	}
+	if n := len(conv.Messages()); n != 5 {
+		t.Errorf("messages has %d messages, want 5", n)
+	}
+}
+
+func TestConversationDefaults(t *testing.T) {
+	var requests []ChatRequest
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var req ChatRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			requests = append(requests, req)
+			fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`)
+		},
+	)
+
+	conv := NewConversation(c, ChatModelGPT3Dot5Turbo)
+	conv.SetDefaults(WithTemperature(0.2), WithMaxTokens(100))
+	_, err := conv.Ask(context.Background(), "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conv.Ask(context.Background(), "second", WithModel(ChatModelGPT4), WithTemperature(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests", len(requests))
+	}
+	if r := requests[0]; r.Model != ChatModelGPT3Dot5Turbo || r.Temperature != 0.2 || r.MaxTokens != 100 {
+		t.Errorf("defaults not applied: %+v", r)
+	}
+	if r := requests[1]; r.Model != ChatModelGPT4 || r.Temperature != 1 || r.MaxTokens != 100 || len(r.Messages) != 3 {
+		t.Errorf("overrides not applied: %+v", r)
+	}
+}
@@ -0,0 +1,80 @@
+package opencat_api
+
+import (
+	"fmt"
+)
+
+// Price is the list price of a model, per 1000 tokens.
+type Price struct {
+	Prompt     float64
+	Completion float64
+	// Currency is an ISO 4217 code, like USD or CNY.
+	Currency string
+}
+
+// Cost is an amount of money.
+type Cost struct {
+	Amount   float64
+	Currency string
+}
+
+// SetPrice sets the price of a model, for models missing from the registry or prices that changed.
+// See RegisterModel to declare other information about a model.
+func SetPrice(model ChatModel, price Price) {
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+	// A versioned name starts from the information of its base model.
+	info, ok := modelInfoLocked(model)
+	if !ok {
+		info = ModelInfo{Provider: guessProvider(model), Streaming: true}
+	}
+	info.Name, info.Price = model, price
+	models[model] = info
+}
+
+// PriceOf returns the price of model. Versioned names like gpt-4-0613 get the price of their base model.
+func PriceOf(model ChatModel) (Price, bool) {
+	info, ok := ModelInfoOf(model)
+	if !ok || info.Price == (Price{}) {
+		return Price{}, false
+	}
+	return info.Price, true
+}
+
+func (p Price) cost(promptTokens, completionTokens int) Cost {
+	return Cost{
+		Amount:   (float64(promptTokens)*p.Prompt + float64(completionTokens)*p.Completion) / 1000,
+		Currency: p.Currency,
+	}
+}
+
+// EstimateCost returns the most a request can cost before it is sent: the estimated prompt tokens,
+// see Tokens, plus MaxTokens of reply for every choice, or a default of 1024 if MaxTokens isn't set.
+func EstimateCost(req ChatRequest) (Cost, error) {
+	price, ok := PriceOf(req.Model)
+	if !ok {
+		return Cost{}, fmt.Errorf("no price for model %s", req.Model)
+	}
+	prompt, err := Tokens(req.Model, req.Messages)
+	if err != nil {
+		return Cost{}, err
+	}
+	completion := req.MaxTokens
+	if completion <= 0 {
+		completion = defaultReplyTokens
+	}
+	return price.cost(prompt, completion*max(req.N, 1)), nil
+}
+
+// Cost returns what the response cost, from its token usage. It reports false if the price
+// of the model is unknown or the provider didn't report the usage.
+func (r ChatResponse) Cost() (Cost, bool) {
+	if r.Usage.TotalTokens == 0 {
+		return Cost{}, false
+	}
+	price, ok := PriceOf(ChatModel(r.Model))
+	if !ok {
+		return Cost{}, false
+	}
+	return price.cost(r.Usage.PromptTokens, r.Usage.CompletionTokens), true
+}
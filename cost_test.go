@@ -0,0 +1,38 @@
+package opencat_api
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCost(t *testing.T) {
+	resp := ChatResponse{Model: "gpt-4-0613", Usage: TokenUsage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500}}
+	cost, ok := resp.Cost()
+	if !ok || math.Abs(cost.Amount-0.06) > 1e-9 || cost.Currency != "USD" {
+		t.Errorf("Cost = %+v, %v", cost, ok)
+	}
+	if _, ok := (ChatResponse{Model: "gpt-4"}).Cost(); ok {
+		t.Errorf("cost without usage should be unknown")
+	}
+	if _, ok := PriceOf("gpt-4-32k-0613"); !ok {
+		t.Errorf("versioned model has no price")
+	}
+	if p, _ := PriceOf("gpt-4-32k-0613"); p.Prompt != 0.06 {
+		t.Errorf("versioned model should get the price of the longest matching model, got %+v", p)
+	}
+
+	cost, err := EstimateCost(
+		ChatRequest{
+			Model:     ChatModelERNIEBot,
+			MaxTokens: 100,
+			Messages:  []Message{{Role: RoleUser, Content: "你好"}},
+		},
+	)
+	if err != nil || cost.Currency != "CNY" || cost.Amount <= 0.0012 {
+		t.Errorf("EstimateCost = %+v, %v", cost, err)
+	}
+	_, err = EstimateCost(ChatRequest{Model: "unknown"})
+	if err == nil {
+		t.Errorf("expected error for unknown model")
+	}
+}
@@ -0,0 +1,47 @@
+package opencat_api
+
+import (
+	"sync"
+	"time"
+)
+
+// Deprecation records that a model is being retired by its provider.
+type Deprecation struct {
+	// Replacement is the model to use instead.
+	Replacement ChatModel
+	// Sunset is the day the model stops working, zero if not announced.
+	Sunset time.Time
+}
+
+// sunsetPassed reports whether the model is retired at now.
+func (d Deprecation) sunsetPassed(now time.Time) bool {
+	return !d.Sunset.IsZero() && !now.Before(d.Sunset)
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+var (
+	deprecationsMu sync.RWMutex
+	deprecations   = map[ChatModel]Deprecation{
+		ChatModelClaudeInstant1: {ChatModelClaude2, date(2024, time.November, 6)},
+		ChatModelSparkDeskV1:    {ChatModelSparkDeskV3, time.Time{}},
+	}
+)
+
+// Deprecate adds a model to the deprecation table, or changes its entry, for retirements announced
+// after this package was released.
+func Deprecate(model ChatModel, d Deprecation) {
+	deprecationsMu.Lock()
+	defer deprecationsMu.Unlock()
+	deprecations[model] = d
+}
+
+// DeprecationOf returns the deprecation of model, if it is deprecated.
+func DeprecationOf(model ChatModel) (Deprecation, bool) {
+	deprecationsMu.RLock()
+	defer deprecationsMu.RUnlock()
+	d, ok := deprecations[model]
+	return d, ok
+}
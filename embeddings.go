@@ -0,0 +1,227 @@
+package opencat_api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type EmbeddingModel string
+
+const (
+	EmbeddingModelAda002 EmbeddingModel = "text-embedding-ada-002"
+	EmbeddingModel3Small EmbeddingModel = "text-embedding-3-small"
+	EmbeddingModel3Large EmbeddingModel = "text-embedding-3-large"
+)
+
+// embeddingBatchSize is the maximum number of inputs the API accepts in one request.
+const embeddingBatchSize = 2048
+
+type EmbeddingRequest struct {
+	Model EmbeddingModel `json:"model"`
+	// Input are the texts to embed. More inputs than the API accepts at once are sent in several requests.
+	Input []string `json:"input"`
+	// Dimensions shortens the embeddings to the given number of dimensions.
+	// Only supported by text-embedding-3 models, 0 keeps the full size.
+	Dimensions int `json:"dimensions,omitempty"`
+	// User identifies the end user, for abuse monitoring.
+	User string `json:"user,omitempty"`
+}
+
+func (r EmbeddingRequest) validate() error {
+	if len(r.Input) == 0 {
+		return errors.New("embedding request has no input")
+	}
+	if r.Dimensions < 0 {
+		return fmt.Errorf("dimensions must not be negative, got %d", r.Dimensions)
+	}
+	if r.Dimensions > 0 && !strings.HasPrefix(string(r.Model), "text-embedding-3") {
+		return fmt.Errorf("%s does not support dimensions", r.Model)
+	}
+	return nil
+}
+
+type Embedding struct {
+	// Index is the index of the input the embedding is of.
+	Index     int       `json:"index"`
+	Embedding []float32 `json:"embedding"`
+}
+
+type EmbeddingResponse struct {
+	Model string `json:"model"`
+	// Data has an embedding per input, in the order of the inputs.
+	Data  []Embedding `json:"data"`
+	Usage TokenUsage  `json:"usage"`
+}
+
+// Embeddings returns the embeddings of texts, for search and retrieval.
+// The tokens used are added to the spend of the client, and cost only if a price is set with SetPrice.
+func (c *Client) Embeddings(ctx context.Context, embedding EmbeddingRequest) (EmbeddingResponse, error) {
+	return c.embedBatches(ctx, embedding, embeddingBatchSize, 1)
+}
+
+type embedOptions struct {
+	request     EmbeddingRequest
+	batchSize   int
+	concurrency int
+}
+
+// EmbedOption adjusts how EmbedAll embeds texts.
+type EmbedOption func(*embedOptions)
+
+// WithEmbeddingModel sets the model of EmbedAll, text-embedding-3-small by default.
+func WithEmbeddingModel(model EmbeddingModel) EmbedOption {
+	return func(o *embedOptions) {
+		o.request.Model = model
+	}
+}
+
+// WithDimensions shortens the embeddings of EmbedAll, see EmbeddingRequest.Dimensions.
+func WithDimensions(n int) EmbedOption {
+	return func(o *embedOptions) {
+		o.request.Dimensions = n
+	}
+}
+
+// WithBatchSize sets the number of texts per request of EmbedAll, up to the 2048 the API accepts.
+func WithBatchSize(n int) EmbedOption {
+	return func(o *embedOptions) {
+		o.batchSize = n
+	}
+}
+
+// WithConcurrency sets the number of requests EmbedAll sends at once, 4 by default.
+func WithConcurrency(n int) EmbedOption {
+	return func(o *embedOptions) {
+		o.concurrency = n
+	}
+}
+
+// EmbedAll returns the embeddings of any number of texts, in the order of the texts. The texts are split
+// into batches sent concurrently, and the usage of all batches is added up. It stops at the first error.
+func (c *Client) EmbedAll(ctx context.Context, texts []string, opts ...EmbedOption) (EmbeddingResponse, error) {
+	o := embedOptions{
+		request:     EmbeddingRequest{Model: EmbeddingModel3Small},
+		batchSize:   embeddingBatchSize,
+		concurrency: 4,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.batchSize < 1 || o.batchSize > embeddingBatchSize {
+		return EmbeddingResponse{}, fmt.Errorf(
+			"batch size must be between 1 and %d, got %d", embeddingBatchSize, o.batchSize,
+		)
+	}
+	o.request.Input = texts
+	return c.embedBatches(ctx, o.request, o.batchSize, max(o.concurrency, 1))
+}
+
+// embedBatches sends the inputs of embedding in batches of batchSize, up to concurrency at once.
+func (c *Client) embedBatches(
+	ctx context.Context,
+	embedding EmbeddingRequest,
+	batchSize, concurrency int,
+) (EmbeddingResponse, error) {
+	err := embedding.validate()
+	if err != nil {
+		return EmbeddingResponse{}, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		results  = make([]EmbeddingResponse, (len(embedding.Input)+batchSize-1)/batchSize)
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, concurrency)
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := range results {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			batch := embedding
+			start := i * batchSize
+			batch.Input = embedding.Input[start:min(start+batchSize, len(embedding.Input))]
+			resp, err := c.embeddings(ctx, batch)
+			if err != nil {
+				errOnce.Do(
+					func() {
+						firstErr = err
+						cancel()
+					},
+				)
+				return
+			}
+			slices.SortFunc(resp.Data, func(a, b Embedding) int { return a.Index - b.Index })
+			for j := range resp.Data {
+				resp.Data[j].Index += start
+			}
+			results[i] = resp
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return EmbeddingResponse{}, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return EmbeddingResponse{}, err
+	}
+
+	all := EmbeddingResponse{Data: make([]Embedding, 0, len(embedding.Input))}
+	for _, resp := range results {
+		all.Model = resp.Model
+		all.Data = append(all.Data, resp.Data...)
+		all.Usage.PromptTokens += resp.Usage.PromptTokens
+		all.Usage.TotalTokens += resp.Usage.TotalTokens
+	}
+	return all, nil
+}
+
+func (c *Client) embeddings(ctx context.Context, embedding EmbeddingRequest) (EmbeddingResponse, error) {
+	body, err := json.Marshal(embedding)
+	if err != nil {
+		return EmbeddingResponse{}, err
+	}
+	req, err := c.newRequest(ctx, "POST", "/v1/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return EmbeddingResponse{}, err
+	}
+
+	resp, err := c.do(req, string(embedding.Model))
+	if err != nil {
+		return EmbeddingResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return EmbeddingResponse{}, NewAPIError(resp)
+	}
+
+	var r EmbeddingResponse
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	if err != nil {
+		return EmbeddingResponse{}, err
+	}
+	if len(r.Data) != len(embedding.Input) {
+		return EmbeddingResponse{}, fmt.Errorf("got %d embeddings for %d inputs", len(r.Data), len(embedding.Input))
+	}
+	c.addTokens(ctx, ChatModel(embedding.Model), r.Usage.PromptTokens, 0)
+	return r, nil
+}
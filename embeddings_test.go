@@ -0,0 +1,101 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEmbeddings(t *testing.T) {
+	var batches []int
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var req EmbeddingRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			batches = append(batches, len(req.Input))
+			resp := EmbeddingResponse{Model: string(req.Model), Usage: TokenUsage{PromptTokens: len(req.Input)}}
+			// Out of order, the client must sort them.
+			for i := len(req.Input) - 1; i >= 0; i-- {
+				resp.Data = append(resp.Data, Embedding{Index: i, Embedding: []float32{float32(len(req.Input[i]))}})
+			}
+			_ = json.NewEncoder(w).Encode(resp)
+		},
+	)
+
+	input := make([]string, embeddingBatchSize+1)
+	for i := range input {
+		input[i] = "x"
+	}
+	input[embeddingBatchSize] = "last"
+	resp, err := c.Embeddings(
+		context.Background(), EmbeddingRequest{Model: EmbeddingModel3Small, Input: input, Dimensions: 256},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 2 || batches[0] != embeddingBatchSize || batches[1] != 1 {
+		t.Errorf("unexpected batches: %v", batches)
+	}
+	last := resp.Data[len(resp.Data)-1]
+	if len(resp.Data) != len(input) || last.Index != embeddingBatchSize || last.Embedding[0] != 4 {
+		t.Errorf("unexpected embeddings: %d, last %+v", len(resp.Data), last)
+	}
+	if resp.Usage.PromptTokens != len(input) || c.Spend().Organic.PromptTokens != len(input) {
+		t.Errorf("unexpected usage: %+v, spend %+v", resp.Usage, c.Spend())
+	}
+
+	_, err = c.Embeddings(context.Background(), EmbeddingRequest{Model: EmbeddingModelAda002, Input: input, Dimensions: 256})
+	if err == nil {
+		t.Error("expected an error for dimensions with ada-002")
+	}
+}
+
+func TestEmbedAll(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for m := maxInFlight.Load(); n > m && !maxInFlight.CompareAndSwap(m, n); m = maxInFlight.Load() {
+			}
+			var req EmbeddingRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if strings.Contains(strings.Join(req.Input, ""), "fail") {
+				http.Error(w, "bad input", http.StatusBadRequest)
+				return
+			}
+			resp := EmbeddingResponse{Model: string(req.Model), Usage: TokenUsage{PromptTokens: len(req.Input)}}
+			for i, text := range req.Input {
+				resp.Data = append(resp.Data, Embedding{Index: i, Embedding: []float32{float32(len(text))}})
+			}
+			_ = json.NewEncoder(w).Encode(resp)
+		},
+	)
+
+	texts := []string{"a", "bb", "ccc", "dddd", "eeeee", "ffffff", "ggggggg"}
+	resp, err := c.EmbedAll(context.Background(), texts, WithBatchSize(2), WithConcurrency(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range resp.Data {
+		if e.Index != i || e.Embedding[0] != float32(i+1) {
+			t.Errorf("embedding %d out of order: %+v", i, e)
+		}
+	}
+	if len(resp.Data) != len(texts) || resp.Usage.PromptTokens != len(texts) || resp.Model != "text-embedding-3-small" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if maxInFlight.Load() > 3 {
+		t.Errorf("%d requests at once, want at most 3", maxInFlight.Load())
+	}
+
+	_, err = c.EmbedAll(context.Background(), append(texts, "fail"), WithBatchSize(2))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.HTTPStatusCode != http.StatusBadRequest {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Endpoint is a group of API operations that can be configured separately, see Config.Endpoints.
+type Endpoint string
+
+const (
+	EndpointChat   Endpoint = "chat"
+	EndpointImage  Endpoint = "image"
+	EndpointSpeech Endpoint = "speech"
+	EndpointUsage  Endpoint = "usage"
+)
+
+// EndpointConfig overrides the settings of a Config for the requests to one endpoint.
+type EndpointConfig struct {
+	// Timeout limits the duration of each request. Zero means the Timeout of the Config.
+	Timeout time.Duration
+	// MaxRetries is the number of times a failed request is retried. Zero means the MaxRetries of the Config,
+	// use NoRetries to not retry requests to the endpoint at all.
+	MaxRetries int
+	// RateLimit is the maximum number of requests per second, requests over the limit wait for their turn.
+	// Zero means no limit.
+	RateLimit float64
+	// Burst is the number of requests that can be sent at once before RateLimit applies, at least 1.
+	Burst int
+}
+
+// NoRetries as the MaxRetries of an EndpointConfig disables retries for the endpoint, since zero means
+// the MaxRetries of the Config. It is -1 in config files.
+const NoRetries = -1
+
+func (e EndpointConfig) validate() error {
+	if e.Timeout < 0 || e.RateLimit < 0 || e.Burst < 0 {
+		return errors.New("timeout, rate limit and burst must not be negative")
+	}
+	if e.MaxRetries < NoRetries {
+		return fmt.Errorf("max retries must be positive, 0 or %d for no retries", NoRetries)
+	}
+	return nil
+}
+
+// endpointOf returns the endpoint of an API path.
+func endpointOf(path string) Endpoint {
+	switch {
+	case strings.HasPrefix(path, "/1/images"):
+		return EndpointImage
+	case strings.Contains(path, "/audio/") || strings.HasPrefix(path, "/cognitiveservices/"):
+		return EndpointSpeech
+	case strings.HasSuffix(path, "/usage"):
+		return EndpointUsage
+	default:
+		return EndpointChat
+	}
+}
+
+type endpointKey struct{}
+
+func requestEndpoint(ctx context.Context) Endpoint {
+	e, _ := ctx.Value(endpointKey{}).(Endpoint)
+	return e
+}
+
+// timeout returns the timeout of requests to endpoint.
+func (cfg *Config) timeout(endpoint Endpoint) time.Duration {
+	if e := cfg.Endpoints[endpoint]; e.Timeout > 0 {
+		return e.Timeout
+	}
+	return cfg.Timeout
+}
+
+// maxRetries returns the number of retries of requests to endpoint.
+func (cfg *Config) maxRetries(endpoint Endpoint) int {
+	if e := cfg.Endpoints[endpoint]; e.MaxRetries != 0 {
+		return max(e.MaxRetries, 0)
+	}
+	return cfg.MaxRetries
+}
+
+// rateLimiter is a token bucket.
+type rateLimiter struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// wait blocks until a request can be sent under rate and burst.
+func (l *rateLimiter) wait(ctx context.Context, clock Clock, rate float64, burst int) error {
+	burst = max(burst, 1)
+	for {
+		l.mu.Lock()
+		now := clock.Now()
+		if l.last.IsZero() {
+			l.tokens = float64(burst)
+		} else {
+			l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*rate, float64(burst))
+		}
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - l.tokens) / rate * float64(time.Second))
+		l.mu.Unlock()
+
+		err := clock.Sleep(ctx, delay)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type rateLimiters struct {
+	mu       sync.Mutex
+	limiters map[Endpoint]*rateLimiter
+}
+
+// wait blocks until a request to endpoint is allowed by the rate limit of cfg.
+func (r *rateLimiters) wait(ctx context.Context, clock Clock, cfg *Config, endpoint Endpoint) error {
+	e := cfg.Endpoints[endpoint]
+	if e.RateLimit <= 0 {
+		return nil
+	}
+	r.mu.Lock()
+	if r.limiters == nil {
+		r.limiters = map[Endpoint]*rateLimiter{}
+	}
+	l := r.limiters[endpoint]
+	if l == nil {
+		l = &rateLimiter{}
+		r.limiters[endpoint] = l
+	}
+	r.mu.Unlock()
+	return l.wait(ctx, clock, e.RateLimit, e.Burst)
+}
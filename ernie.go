@@ -0,0 +1,87 @@
+package opencat_api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErnieParams are options of ERNIE-Bot models, see ChatRequest.Ernie.
+type ErnieParams struct {
+	// PenaltyScore penalizes repeated tokens, from 1.0, no penalty, to 2.0.
+	PenaltyScore float64 `json:"penalty_score,omitempty"`
+	// DisableSearch stops the model from searching the web.
+	DisableSearch bool `json:"disable_search,omitempty"`
+	// EnableCitation adds references to the search results used in the reply.
+	EnableCitation bool `json:"enable_citation,omitempty"`
+	// UserID identifies the end user, for abuse monitoring.
+	UserID string `json:"user_id,omitempty"`
+}
+
+func (p *ErnieParams) validate(model ChatModel, provider Provider) error {
+	if provider != ProviderBaidu {
+		return fmt.Errorf("ERNIE options are not supported by %s", model)
+	}
+	if p.PenaltyScore != 0 && (p.PenaltyScore < 1 || p.PenaltyScore > 2) {
+		return fmt.Errorf("ERNIE penalty score must be between 1 and 2, got %v", p.PenaltyScore)
+	}
+	return nil
+}
+
+// ernieAdapter translates requests for ERNIE-Bot models, which take the system prompt in a field of its own,
+// messages alternating between user and assistant, and options of their own.
+type ernieAdapter struct {
+	openCatAdapter
+}
+
+func (ernieAdapter) EncodeRequest(chat ChatRequest) (string, []byte, error) {
+	if chat.Temperature > 1 {
+		return "", nil, fmt.Errorf("temperature of %s must not be over 1, got %v", chat.Model, chat.Temperature)
+	}
+	system, messages, err := ernieMessages(chat.Messages)
+	if err != nil {
+		return "", nil, err
+	}
+	params := chat.Ernie
+	chat.Ernie, chat.Messages = nil, messages
+
+	body, err := requestBody(chat, params)
+	if err != nil {
+		return "", nil, err
+	}
+	if system != "" {
+		body["system"] = system
+	}
+	data, err := json.Marshal(body)
+	return "/1/chat", data, err
+}
+
+// ernieMessages moves system messages to the system prompt and merges consecutive messages of the same role,
+// since ERNIE requires the roles to alternate, starting and ending with the user.
+func ernieMessages(messages []Message) (system string, out []Message, err error) {
+	var systems []string
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			systems = append(systems, msg.Text())
+			continue
+		case RoleUser, RoleAssistant:
+		default:
+			return "", nil, fmt.Errorf("ERNIE does not support %s messages", msg.Role)
+		}
+		if len(out) == 0 && msg.Role == RoleAssistant {
+			return "", nil, fmt.Errorf("the first message must not be from the %s", RoleAssistant)
+		}
+		if n := len(out); n > 0 && out[n-1].Role == msg.Role {
+			out[n-1].Content = out[n-1].Text() + "\n\n" + msg.Text()
+			out[n-1].Parts = nil
+			continue
+		}
+		out = append(out, Message{Role: msg.Role, Content: msg.Text()})
+	}
+	if len(out) == 0 || out[len(out)-1].Role != RoleUser {
+		return "", nil, errors.New("the last message must be from the user")
+	}
+	return strings.Join(systems, "\n\n"), out, nil
+}
@@ -0,0 +1,57 @@
+package opencat_api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestErnieRequest(t *testing.T) {
+	chat := ChatRequest{
+		Model: ChatModelERNIEBot4,
+		Messages: []Message{
+			{Role: RoleSystem, Content: "你是一个翻译。"},
+			{Role: RoleUser, Content: "翻译："},
+			{Role: RoleUser, Content: "你好"},
+		},
+		Ernie: &ErnieParams{PenaltyScore: 1.2, DisableSearch: true},
+	}
+	if err := chat.validate(); err != nil {
+		t.Fatal(err)
+	}
+	path, data, err := ernieAdapter{}.EncodeRequest(chat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		System        string    `json:"system"`
+		Messages      []Message `json:"messages"`
+		PenaltyScore  float64   `json:"penalty_score"`
+		DisableSearch bool      `json:"disable_search"`
+		Ernie         any       `json:"ernie"`
+	}
+	err = json.Unmarshal(data, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/1/chat" || body.System != "你是一个翻译。" || body.PenaltyScore != 1.2 || !body.DisableSearch ||
+		body.Ernie != nil {
+		t.Errorf("unexpected request to %s: %s", path, data)
+	}
+	if len(body.Messages) != 1 || body.Messages[0].Content != "翻译：\n\n你好" {
+		t.Errorf("messages not merged: %+v", body.Messages)
+	}
+
+	chat.Ernie.PenaltyScore = 3
+	if err := chat.validate(); err == nil {
+		t.Error("expected an error for a penalty score over 2")
+	}
+	chat.Ernie.PenaltyScore = 0
+	chat.Model = ChatModelGPT4
+	if err := chat.validate(); err == nil {
+		t.Error("expected an error for ERNIE options on another model")
+	}
+	_, _, err = ernieAdapter{}.EncodeRequest(ChatRequest{Model: ChatModelERNIEBot, Messages: []Message{Assistant("hi")}})
+	if err == nil {
+		t.Error("expected an error for a conversation starting with the assistant")
+	}
+}
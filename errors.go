@@ -0,0 +1,153 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrorCategory is a user-facing classification of an error.
+type ErrorCategory string
+
+var (
+	ErrorUnknown         ErrorCategory = "unknown"
+	ErrorInvalidKey      ErrorCategory = "invalid_key"
+	ErrorQuotaExceeded   ErrorCategory = "quota_exceeded"
+	ErrorRateLimited     ErrorCategory = "rate_limited"
+	ErrorContentFiltered ErrorCategory = "content_filtered"
+	ErrorContextTooLong  ErrorCategory = "context_too_long"
+	ErrorServer          ErrorCategory = "server"
+	ErrorNetwork         ErrorCategory = "network"
+	ErrorCanceled        ErrorCategory = "canceled"
+)
+
+// Category classifies the error from the status code and body returned by the API.
+func (e *APIError) Category() ErrorCategory {
+	body := strings.ToLower(e.Body)
+	containsAny := func(subs ...string) bool {
+		for _, sub := range subs {
+			if strings.Contains(body, sub) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case e.HTTPStatusCode == 401 || e.HTTPStatusCode == 403:
+		return ErrorInvalidKey
+	case containsAny("quota", "insufficient", "billing", "exceeded your", "usage limit"):
+		return ErrorQuotaExceeded
+	case e.HTTPStatusCode == 429:
+		return ErrorRateLimited
+	case containsAny("content_filter", "content management policy", "content_policy", "safety", "sensitive"):
+		return ErrorContentFiltered
+	case containsAny("context_length_exceeded", "maximum context length", "too long"):
+		return ErrorContextTooLong
+	case e.HTTPStatusCode >= 500:
+		return ErrorServer
+	default:
+		return ErrorUnknown
+	}
+}
+
+// CategorizeError classifies any error returned by the client.
+func CategorizeError(err error) ErrorCategory {
+	var apiErr *APIError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &apiErr):
+		return apiErr.Category()
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorNetwork
+	case errors.Is(err, ErrContextTooLong):
+		return ErrorContextTooLong
+	}
+	var (
+		interrupted *ErrStreamInterrupted
+		truncated   *ErrTruncatedResponse
+		tenantLimit *ErrTenantLimit
+		blocked     *ErrSafetyBlocked
+		flagged     *ErrModerationFlagged
+	)
+	if errors.As(err, &blocked) || errors.As(err, &flagged) {
+		return ErrorContentFiltered
+	}
+	if errors.As(err, &interrupted) || errors.As(err, &truncated) {
+		return ErrorNetwork
+	}
+	if errors.As(err, &tenantLimit) {
+		if tenantLimit.Limit == TenantLimitBudget {
+			return ErrorQuotaExceeded
+		}
+		return ErrorRateLimited
+	}
+	return ErrorUnknown
+}
+
+// Catalog maps error categories to messages in one language.
+type Catalog map[ErrorCategory]string
+
+var (
+	catalogsMu sync.RWMutex
+	catalogs   = map[string]Catalog{
+		"en": {
+			ErrorUnknown:         "Something went wrong. Please try again later.",
+			ErrorInvalidKey:      "The API key is invalid or has expired.",
+			ErrorQuotaExceeded:   "Your usage quota has been exceeded.",
+			ErrorRateLimited:     "Too many requests. Please slow down and try again shortly.",
+			ErrorContentFiltered: "The content was blocked by the content filter.",
+			ErrorContextTooLong:  "The conversation is too long for this model. Please start a new one.",
+			ErrorServer:          "The service is temporarily unavailable. Please try again later.",
+			ErrorNetwork:         "The network connection was interrupted. Please check your connection.",
+			ErrorCanceled:        "The request was canceled.",
+		},
+		"zh": {
+			ErrorUnknown:         "出错了，请稍后重试。",
+			ErrorInvalidKey:      "API 密钥无效或已过期。",
+			ErrorQuotaExceeded:   "使用额度已用完。",
+			ErrorRateLimited:     "请求过于频繁，请稍后再试。",
+			ErrorContentFiltered: "内容被安全策略拦截。",
+			ErrorContextTooLong:  "对话内容超出了模型的长度限制，请开启新的对话。",
+			ErrorServer:          "服务暂时不可用，请稍后重试。",
+			ErrorNetwork:         "网络连接中断，请检查网络。",
+			ErrorCanceled:        "请求已取消。",
+		},
+	}
+)
+
+// RegisterCatalog adds or replaces the messages of a language, identified by a BCP 47 tag like "ja" or "zh-TW".
+// Missing categories fall back to the base language and then to English.
+func RegisterCatalog(lang string, catalog Catalog) {
+	catalogsMu.Lock()
+	defer catalogsMu.Unlock()
+	catalogs[strings.ToLower(lang)] = catalog
+}
+
+// LocalizeError returns a message describing err that is suitable for end users, in the given language.
+func LocalizeError(err error, lang string) string {
+	if err == nil {
+		return ""
+	}
+	category := CategorizeError(err)
+
+	catalogsMu.RLock()
+	defer catalogsMu.RUnlock()
+
+	lang = strings.ToLower(strings.ReplaceAll(lang, "_", "-"))
+	for {
+		if msg, ok := catalogs[lang][category]; ok {
+			return msg
+		}
+		i := strings.LastIndexByte(lang, '-')
+		if i < 0 {
+			break
+		}
+		lang = lang[:i]
+	}
+	return catalogs["en"][category]
+}
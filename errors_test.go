@@ -0,0 +1,30 @@
+package opencat_api
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestLocalizeError(t *testing.T) {
+	RegisterCatalog("zh-TW", Catalog{ErrorQuotaExceeded: "使用額度已用完。"})
+
+	tests := []struct {
+		err  error
+		lang string
+		want string
+	}{
+		{&APIError{HTTPStatusCode: 401}, "en", "The API key is invalid or has expired."},
+		{&APIError{HTTPStatusCode: 429, Body: `{"error":"You exceeded your current quota"}`}, "zh-CN", "使用额度已用完。"},
+		{&APIError{HTTPStatusCode: 429, Body: `{"error":"rate limit"}`}, "zh", "请求过于频繁，请稍后再试。"},
+		{&APIError{HTTPStatusCode: 429, Body: "insufficient quota"}, "zh_TW", "使用額度已用完。"},
+		{&APIError{HTTPStatusCode: 502}, "zh-TW", "服务暂时不可用，请稍后重试。"},
+		{fmt.Errorf("chat: %w", context.Canceled), "fr", "The request was canceled."},
+		{&APIError{HTTPStatusCode: 400, Body: "content_filter"}, "zh-Hans-CN", "内容被安全策略拦截。"},
+	}
+	for _, tt := range tests {
+		if got := LocalizeError(tt.err, tt.lang); got != tt.want {
+			t.Errorf("LocalizeError(%v, %s) = %q, want %q", tt.err, tt.lang, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package opencat_api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Event is something that happened in a Client, see the *Event types for the possible values.
+type Event interface {
+	event()
+}
+
+// RequestStartedEvent is published before a request is sent to the API.
+type RequestStartedEvent struct {
+	Time   time.Time
+	Method string
+	Path   string
+	// Model is the model of chat, image and speech requests.
+	Model string
+	// Session is the session of the request, see WithSession.
+	Session string
+}
+
+// RequestFinishedEvent is published when the response headers of a request are received, or when it fails.
+type RequestFinishedEvent struct {
+	Time       time.Time
+	Method     string
+	Path       string
+	Model      string
+	Session    string
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// RetryEvent is published when a failed operation is attempted again.
+type RetryEvent struct {
+	Time  time.Time
+	Model string
+	// Attempt is the number of the upcoming attempt, starting at 2.
+	Attempt int
+	// Delay is how long the client waits before the attempt.
+	Delay time.Duration
+	Cause error
+}
+
+// BudgetEvent is published when the spend of a cost budget crosses a threshold: when most of it is used,
+// then when all of it is. Budgets are those of tenants, see TenantLimits, and of conversations,
+// see ConversationBudget.
+type BudgetEvent struct {
+	Time time.Time
+	// Tenant is the tenant of the budget, or Session the session of its conversation.
+	Tenant  string
+	Session string
+	// Threshold is the fraction of the budget crossed, see budgetThresholds.
+	Threshold float64
+	Spent     Cost
+	Budget    Cost
+}
+
+// FallbackEvent is published when a chat request that failed with its model is sent to a fallback,
+// see Config.Fallbacks.
+type FallbackEvent struct {
+	Time    time.Time
+	Session string
+	// Model failed with Cause, the request is sent to Fallback next.
+	Model    ChatModel
+	Fallback ChatModel
+	Cause    error
+}
+
+// HealthEvent is published when the health of a model changes after a chat call, see HealthState.
+// The client has no circuit breaker: a model becoming HealthFailing is what comes closest to a circuit
+// opening, and its recovery to one closing, but requests for it are sent all the same.
+type HealthEvent struct {
+	Time     time.Time
+	Model    ChatModel
+	Provider Provider
+	From     HealthState
+	To       HealthState
+	// SuccessRate is that of the model over the last 10 minutes, see ModelStats.
+	SuccessRate float64
+}
+
+// budgetThresholds are the fractions of a budget whose crossing publishes a BudgetEvent.
+var budgetThresholds = []float64{0.8, 1}
+
+// crossedThreshold returns the highest threshold of budget crossed by a spend going from before to after,
+// 0 if none.
+func crossedThreshold(before, after, budget float64) float64 {
+	crossed := 0.0
+	if budget <= 0 {
+		return crossed
+	}
+	for _, t := range budgetThresholds {
+		if before < t*budget && after >= t*budget {
+			crossed = t
+		}
+	}
+	return crossed
+}
+
+func (RequestStartedEvent) event()  {}
+func (RequestFinishedEvent) event() {}
+func (RetryEvent) event()           {}
+func (BudgetEvent) event()          {}
+func (FallbackEvent) event()        {}
+func (HealthEvent) event()          {}
+
+// EventBus delivers events to subscribers.
+// Events are delivered synchronously on the goroutine that publishes them, so subscribers must not block.
+// Subscribers may subscribe and unsubscribe, such as to listen for a single event.
+type EventBus struct {
+	mu   sync.RWMutex
+	next int
+	subs map[int]func(Event)
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: map[int]func(Event){}}
+}
+
+// Subscribe registers fn to receive all published events. Call the returned function to unsubscribe.
+func (b *EventBus) Subscribe(fn func(Event)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.next
+	b.next++
+	b.subs[id] = fn
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, id)
+	}
+}
+
+func (b *EventBus) Publish(e Event) {
+	b.mu.RLock()
+	subs := make([]func(Event), 0, len(b.subs))
+	for _, fn := range b.subs {
+		subs = append(subs, fn)
+	}
+	b.mu.RUnlock()
+	for _, fn := range subs {
+		fn(e)
+	}
+}
+
+// WithEventBus makes the client publish its events to bus, so several clients can share subscribers.
+func WithEventBus(bus *EventBus) ClientOption {
+	return func(c *Client) {
+		c.events = bus
+	}
+}
+
+// Events returns the bus the client publishes its events to.
+func (c *Client) Events() *EventBus {
+	return c.events
+}
+
+// do sends an API request, publishing its start and end, and retries it as configured.
+func (c *Client) do(req *http.Request, model string) (*http.Response, error) {
+	ctx := req.Context()
+	cfg, endpoint := requestConfig(ctx), requestEndpoint(ctx)
+	maxRetries := 0
+	if cfg != nil {
+		maxRetries = cfg.maxRetries(endpoint)
+	}
+	if req.Body != nil && req.GetBody == nil {
+		// The body can't be sent again.
+		maxRetries = 0
+	}
+
+	tenant, hasTenant := TenantFromContext(ctx)
+	for retry := 0; ; retry++ {
+		if c.tenants != nil && hasTenant {
+			err := c.tenants.allow(tenant, c.clock.Now())
+			if err != nil {
+				return nil, err
+			}
+		}
+		if cfg != nil {
+			err := c.limiters.wait(ctx, c.clock, cfg, endpoint)
+			if err != nil {
+				return nil, err
+			}
+		}
+		c.spend.addRequest(isRetry(ctx) || retry > 0)
+		resp, err := c.send(req, model)
+		if retry >= maxRetries || !shouldRetry(ctx, resp, err) {
+			return resp, err
+		}
+
+		delay := retryDelay(retry+1, resp)
+		cause := err
+		if resp != nil {
+			cause = NewAPIError(resp)
+		}
+		c.events.Publish(
+			RetryEvent{
+				Time:    c.clock.Now(),
+				Model:   model,
+				Attempt: retry + 2,
+				Delay:   delay,
+				Cause:   cause,
+			},
+		)
+		if err := c.clock.Sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+	}
+}
+
+// send sends a request once, publishing its start and end.
+func (c *Client) send(req *http.Request, model string) (*http.Response, error) {
+	start := c.clock.Now()
+	session, _ := SessionFromContext(req.Context())
+	c.events.Publish(
+		RequestStartedEvent{
+			Time:    start,
+			Method:  req.Method,
+			Path:    req.URL.Path,
+			Model:   model,
+			Session: session,
+		},
+	)
+
+	var cancel context.CancelFunc = func() {}
+	if cfg := requestConfig(req.Context()); cfg != nil {
+		if timeout := cfg.timeout(requestEndpoint(req.Context())); timeout > 0 {
+			var ctx context.Context
+			ctx, cancel = context.WithTimeout(req.Context(), timeout)
+			req = req.WithContext(ctx)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		cancel()
+	} else {
+		resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	}
+
+	finished := RequestFinishedEvent{
+		Time:     c.clock.Now(),
+		Method:   req.Method,
+		Path:     req.URL.Path,
+		Model:    model,
+		Session:  session,
+		Duration: c.clock.Now().Sub(start),
+		Err:      err,
+	}
+	if resp != nil {
+		finished.StatusCode = resp.StatusCode
+	}
+	c.events.Publish(finished)
+	return resp, err
+}
+
+// cancelOnClose releases the request timeout when the response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,54 @@
+package opencat_api
+
+import (
+	"testing"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus()
+	var a, b []Event
+	unsubscribe := bus.Subscribe(func(e Event) { a = append(a, e) })
+	bus.Subscribe(func(e Event) { b = append(b, e) })
+
+	bus.Publish(RequestStartedEvent{Path: "/1/chat"})
+	unsubscribe()
+	bus.Publish(RetryEvent{Attempt: 2})
+
+	if len(a) != 1 || len(b) != 2 {
+		t.Fatalf("got %d and %d events, want 1 and 2", len(a), len(b))
+	}
+	if e, ok := b[1].(RetryEvent); !ok || e.Attempt != 2 {
+		t.Errorf("unexpected event %#v", b[1])
+	}
+
+	// A subscriber listening for a single event.
+	var once []Event
+	var stop func()
+	stop = bus.Subscribe(
+		func(e Event) {
+			once = append(once, e)
+			stop()
+		},
+	)
+	bus.Publish(RetryEvent{Attempt: 3})
+	bus.Publish(RetryEvent{Attempt: 4})
+	if len(once) != 1 {
+		t.Errorf("got %d events, want 1", len(once))
+	}
+}
+
+func TestCrossedThreshold(t *testing.T) {
+	for _, tt := range []struct {
+		before, after, want float64
+	}{
+		{0, 0.5, 0},
+		{0.5, 0.85, 0.8},
+		{0.85, 0.9, 0},
+		{0.5, 1.2, 1},
+		{1, 1.5, 0},
+	} {
+		if got := crossedThreshold(tt.before, tt.after, 1); got != tt.want {
+			t.Errorf("crossedThreshold(%g, %g) = %g, want %g", tt.before, tt.after, got, tt.want)
+		}
+	}
+}
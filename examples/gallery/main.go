@@ -0,0 +1,107 @@
+// Command gallery generates an image per prompt, one prompt per line of a file, and writes them to a folder
+// with an index.html showing them with their prompts.
+//
+//	TOKEN=... go run ./examples/gallery -prompts prompts.txt -out gallery
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	api "github.com/j178/opencat-api"
+)
+
+var indexTemplate = template.Must(
+	template.New("index").Parse(
+		`<!doctype html>
+<title>Gallery</title>
+{{range .}}<figure><img src="{{.File}}" width="256"><figcaption>{{.Prompt}}</figcaption></figure>
+{{end}}`,
+	),
+)
+
+type picture struct {
+	File   string
+	Prompt string
+}
+
+// generate generates the images of prompts into dir and writes its index.html.
+func generate(ctx context.Context, c *api.Client, model api.ImageModel, prompts []string, dir string) ([]picture, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	var pictures []picture
+	for i, prompt := range prompts {
+		images, err := c.Image(
+			ctx, api.ImageRequest{
+				Width:  1024,
+				Height: 1024,
+				Num:    1,
+				Model:  model,
+				Prompt: prompt,
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("prompt %d: %w", i+1, err)
+		}
+		p := picture{File: fmt.Sprintf("%03d.png", i+1), Prompt: prompt}
+		err = os.WriteFile(filepath.Join(dir, p.File), images[0].Data, 0644)
+		if err != nil {
+			return nil, err
+		}
+		pictures = append(pictures, p)
+	}
+
+	f, err := os.Create(filepath.Join(dir, "index.html"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	err = indexTemplate.Execute(f, pictures)
+	if err != nil {
+		return nil, err
+	}
+	return pictures, f.Close()
+}
+
+func readPrompts(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var prompts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			prompts = append(prompts, line)
+		}
+	}
+	return prompts, scanner.Err()
+}
+
+func main() {
+	promptsPath := flag.String("prompts", "prompts.txt", "file of prompts, one per line")
+	out := flag.String("out", "gallery", "output folder")
+	model := flag.String("model", string(api.ImageModelDallE3), "image model")
+	flag.Parse()
+
+	prompts, err := readPrompts(*promptsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c := api.NewClient(os.Getenv("TOKEN"))
+	pictures, err := generate(context.Background(), c, api.ImageModel(*model), prompts, *out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d images written to %s\n", len(pictures), filepath.Join(*out, "index.html"))
+}
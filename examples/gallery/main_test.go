@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	api "github.com/j178/opencat-api"
+	"github.com/j178/opencat-api/examples/internal/fake"
+)
+
+func TestGallery(t *testing.T) {
+	dir := t.TempDir()
+	promptsPath := filepath.Join(dir, "prompts.txt")
+	err := os.WriteFile(promptsPath, []byte("a kitten in a basket\n\na <cat> & a dog\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompts, err := readPrompts(promptsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "gallery")
+	pictures, err := generate(context.Background(), fake.NewClient(t), api.ImageModelDallE3, prompts, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pictures) != 2 {
+		t.Fatalf("got %d pictures, want 2", len(pictures))
+	}
+	data, err := os.ReadFile(filepath.Join(out, "002.png"))
+	if err != nil || string(data) != "image 0 of a <cat> & a dog" {
+		t.Errorf("unexpected image %q, %v", data, err)
+	}
+	index, err := os.ReadFile(filepath.Join(out, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`<img src="001.png"`, "<figcaption>a &lt;cat&gt; &amp; a dog</figcaption>"} {
+		if !strings.Contains(string(index), want) {
+			t.Errorf("missing %q in %s", want, index)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Package fake is a fake OpenCat API the examples are tested against. Its answers are predictable:
+// chat replies echo the last message, speech is the input text, transcriptions are the content of the
+// uploaded file, images are their prompt, and embeddings count the words of their input.
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	api "github.com/j178/opencat-api"
+)
+
+// EmbeddingDimensions is the size of the embeddings, each word of an input counts in one dimension.
+const EmbeddingDimensions = 64
+
+// NewClient starts a fake server, closed at the end of the test, and returns a client of it.
+func NewClient(t testing.TB) *api.Client {
+	srv := httptest.NewServer(http.HandlerFunc(serve))
+	t.Cleanup(srv.Close)
+	c := api.NewClient("token")
+	err := c.ApplyConfig(api.Config{Token: "token", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// Reply returns the reply of the fake server to the last message of a chat.
+func Reply(last string) string {
+	return "echo: " + last
+}
+
+func serve(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/1/chat":
+		chat(w, r)
+	case "/v1/embeddings":
+		embeddings(w, r)
+	case "/1/images/generations":
+		var req api.ImageRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		images := make([][]byte, max(req.Num, 1))
+		for i := range images {
+			images[i] = []byte(fmt.Sprintf("image %d of %s", i, req.Prompt))
+		}
+		json.NewEncoder(w).Encode(map[string]any{"image_data": images})
+	case "/v1/audio/speech":
+		var req api.SpeechRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		io.WriteString(w, req.Input)
+	case "/v1/audio/transcriptions":
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		text, _ := io.ReadAll(file)
+		json.NewEncoder(w).Encode(api.Transcription{Text: string(text)})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func chat(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Messages []api.Message `json:"messages"`
+		Stream   bool          `json:"stream"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	if len(req.Messages) == 0 {
+		http.Error(w, `{"error": {"message": "no messages"}}`, http.StatusBadRequest)
+		return
+	}
+	reply := Reply(req.Messages[len(req.Messages)-1].Content)
+	if !req.Stream {
+		json.NewEncoder(w).Encode(
+			map[string]any{
+				"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": reply}}},
+			},
+		)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	for i, word := range strings.SplitAfter(reply, " ") {
+		event := map[string]string{"delta": word}
+		if i == strings.Count(reply, " ") {
+			event["finishReason"] = "stop"
+		}
+		data, _ := json.Marshal(event)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		w.(http.Flusher).Flush()
+	}
+	io.WriteString(w, "data: [DONE]\n\n")
+}
+
+func embeddings(w http.ResponseWriter, r *http.Request) {
+	var req api.EmbeddingRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	var resp api.EmbeddingResponse
+	for i, input := range req.Input {
+		vec := make([]float32, EmbeddingDimensions)
+		for _, word := range strings.Fields(strings.ToLower(input)) {
+			h := fnv.New32a()
+			h.Write([]byte(strings.Trim(word, ".,;:!?")))
+			vec[h.Sum32()%EmbeddingDimensions]++
+		}
+		resp.Data = append(resp.Data, api.Embedding{Index: i, Embedding: vec})
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,135 @@
+// Command rag answers a question about the text files of a folder: the files are split into chunks,
+// the chunks closest to the question are found by their embeddings and given to the model as context.
+//
+//	TOKEN=... go run ./examples/rag -dir ./docs "How do I configure retries?"
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	api "github.com/j178/opencat-api"
+)
+
+type chunk struct {
+	source string
+	text   string
+	vec    []float32
+}
+
+// index splits the text files under dir into paragraphs and embeds them.
+func index(ctx context.Context, c *api.Client, dir string) ([]chunk, error) {
+	var chunks []chunk
+	err := filepath.WalkDir(
+		dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			switch filepath.Ext(path) {
+			case ".txt", ".md":
+			default:
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			for _, p := range strings.Split(string(data), "\n\n") {
+				if p = strings.TrimSpace(p); p != "" {
+					chunks = append(chunks, chunk{source: path, text: p})
+				}
+			}
+			return nil
+		},
+	)
+	if err != nil || len(chunks) == 0 {
+		return nil, err
+	}
+
+	texts := make([]string, len(chunks))
+	for i, ch := range chunks {
+		texts[i] = ch.text
+	}
+	resp, err := c.EmbedAll(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range resp.Data {
+		chunks[e.Index].vec = e.Embedding
+	}
+	return chunks, nil
+}
+
+func similarity(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// answer answers a question with the k chunks closest to it as context.
+func answer(ctx context.Context, c *api.Client, model api.ChatModel, chunks []chunk, question string, k int) (string, error) {
+	resp, err := c.EmbedAll(ctx, []string{question})
+	if err != nil {
+		return "", err
+	}
+	q := resp.Data[0].Embedding
+	sort.SliceStable(chunks, func(i, j int) bool { return similarity(chunks[i].vec, q) > similarity(chunks[j].vec, q) })
+
+	var prompt strings.Builder
+	prompt.WriteString("Answer the question with the following excerpts, citing their sources.\n\n")
+	for _, ch := range chunks[:min(k, len(chunks))] {
+		fmt.Fprintf(&prompt, "Source: %s\n%s\n\n", ch.source, ch.text)
+	}
+	prompt.WriteString("Question: " + question)
+
+	reply, err := c.Chat(
+		ctx, api.ChatRequest{
+			Model:    model,
+			Messages: []api.Message{{Role: api.RoleUser, Content: prompt.String()}},
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	return reply.Choices[0].Message.Content, nil
+}
+
+func main() {
+	dir := flag.String("dir", ".", "folder of .txt and .md files")
+	model := flag.String("model", string(api.ChatModelGPT4), "chat model")
+	k := flag.Int("k", 3, "number of excerpts given to the model")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatal("usage: rag [-dir folder] question")
+	}
+
+	ctx := context.Background()
+	c := api.NewClient(os.Getenv("TOKEN"))
+	chunks, err := index(ctx, c, *dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(chunks) == 0 {
+		log.Fatalf("no text files in %s", *dir)
+	}
+	reply, err := answer(ctx, c, api.ChatModel(*model), chunks, flag.Arg(0), *k)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(reply)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	api "github.com/j178/opencat-api"
+	"github.com/j178/opencat-api/examples/internal/fake"
+)
+
+func TestRAG(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cats.md":     "Cats sleep most of the day.\n\nCats purr when they are happy.",
+		"rockets.txt": "Rockets burn fuel to climb to orbit.",
+		"notes.json":  `{"ignored": true}`,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := fake.NewClient(t)
+	ctx := context.Background()
+
+	chunks, err := index(ctx, c, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks, want 3", len(chunks))
+	}
+
+	reply, err := answer(ctx, c, api.ChatModelGPT4, chunks, "Why do cats purr?", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fake model echoes the prompt, which has the closest excerpt only.
+	if !strings.Contains(reply, "Source: "+filepath.Join(dir, "cats.md")+"\nCats purr when they are happy.") ||
+		strings.Contains(reply, "Rockets") || strings.Contains(reply, "sleep") {
+		t.Errorf("unexpected prompt %q", reply)
+	}
+}
@@ -0,0 +1,81 @@
+// Command summarize summarizes text files concurrently, printing a summary per file in the order given.
+//
+//	TOKEN=... go run ./examples/summarize -concurrency 4 reports/*.txt
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	api "github.com/j178/opencat-api"
+)
+
+type summary struct {
+	path string
+	text string
+	err  error
+}
+
+// summarize summarizes the files at paths, at most concurrency at a time.
+// A file that fails doesn't stop the others, its error is in its summary.
+func summarize(ctx context.Context, c *api.Client, model api.ChatModel, paths []string, concurrency int) []summary {
+	summaries := make([]summary, len(paths))
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			s := &summaries[i]
+			s.path = path
+			data, err := os.ReadFile(path)
+			if err != nil {
+				s.err = err
+				return
+			}
+			resp, err := c.Chat(
+				ctx, api.ChatRequest{
+					Model: model,
+					Messages: []api.Message{
+						{Role: api.RoleSystem, Content: "Summarize the text in three sentences at most."},
+						{Role: api.RoleUser, Content: string(data)},
+					},
+				},
+			)
+			if err != nil {
+				s.err = err
+				return
+			}
+			s.text = resp.Choices[0].Message.Content
+		}(i, path)
+	}
+	wg.Wait()
+	return summaries
+}
+
+func main() {
+	model := flag.String("model", string(api.ChatModelGPT3Dot5Turbo), "chat model")
+	concurrency := flag.Int("concurrency", 4, "number of files summarized at once")
+	flag.Parse()
+
+	c := api.NewClient(os.Getenv("TOKEN"))
+	failed := false
+	for _, s := range summarize(context.Background(), c, api.ChatModel(*model), flag.Args(), *concurrency) {
+		if s.err != nil {
+			log.Printf("%s: %v", s.path, s.err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s\n%s\n\n", s.path, s.text)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
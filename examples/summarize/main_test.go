@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	api "github.com/j178/opencat-api"
+	"github.com/j178/opencat-api/examples/internal/fake"
+)
+
+func TestSummarize(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < 5; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("%d.txt", i))
+		err := os.WriteFile(path, []byte(fmt.Sprintf("Report %d.", i)), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	paths = append(paths, filepath.Join(dir, "missing.txt"))
+
+	summaries := summarize(context.Background(), fake.NewClient(t), api.ChatModelGPT4, paths, 2)
+	for i, s := range summaries[:5] {
+		if s.err != nil || s.path != paths[i] || s.text != fake.Reply(fmt.Sprintf("Report %d.", i)) {
+			t.Errorf("unexpected summary %+v", s)
+		}
+	}
+	if s := summaries[5]; s.err == nil {
+		t.Errorf("expected an error for a missing file, got %+v", s)
+	}
+}
@@ -0,0 +1,83 @@
+// Command voice is a turn of a voice assistant: it transcribes a recorded question, asks the model,
+// and writes the spoken reply to a file.
+//
+//	TOKEN=... go run ./examples/voice -in question.m4a -out reply.mp3
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	api "github.com/j178/opencat-api"
+)
+
+// assistant answers recorded questions, keeping the conversation between them.
+type assistant struct {
+	c     *api.Client
+	conv  *api.Conversation
+	voice string
+}
+
+func newAssistant(c *api.Client, model api.ChatModel, voice string) *assistant {
+	conv := api.NewConversation(c, model)
+	conv.SetSystemPrompt("You are a voice assistant. Answer briefly, in plain sentences that read well aloud.")
+	return &assistant{c: c, conv: conv, voice: voice}
+}
+
+// reply transcribes the question in audio, named filename, and writes the spoken reply to w.
+// It returns the question and the reply as text.
+func (a *assistant) reply(ctx context.Context, audio io.Reader, filename string, w io.Writer) (string, string, error) {
+	question, err := a.c.Transcribe(
+		ctx, api.TranscriptionRequest{
+			Model:    api.TranscriptionModelWhisper1,
+			Audio:    audio,
+			Filename: filename,
+		},
+	)
+	if err != nil {
+		return "", "", err
+	}
+	reply, err := a.conv.Ask(ctx, question.Text)
+	if err != nil {
+		return question.Text, "", err
+	}
+	_, err = a.c.SpeechTo(
+		ctx, api.SpeechRequest{
+			Input: reply,
+			Voice: a.voice,
+			Model: api.SpeechModelTTS1,
+		}, w,
+	)
+	return question.Text, reply, err
+}
+
+func main() {
+	in := flag.String("in", "question.m4a", "recorded question")
+	out := flag.String("out", "reply.mp3", "spoken reply")
+	model := flag.String("model", string(api.ChatModelGPT4), "chat model")
+	voice := flag.String("voice", "alloy", "voice of the reply")
+	flag.Parse()
+
+	audio, err := os.Open(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer audio.Close()
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	a := newAssistant(api.NewClient(os.Getenv("TOKEN")), api.ChatModel(*model), *voice)
+	question, reply, err := a.reply(context.Background(), audio, filepath.Base(*in), f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("> %s\n%s\n", question, reply)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	api "github.com/j178/opencat-api"
+	"github.com/j178/opencat-api/examples/internal/fake"
+)
+
+func TestVoice(t *testing.T) {
+	a := newAssistant(fake.NewClient(t), api.ChatModelGPT4, "alloy")
+
+	// The fake server transcribes audio as its content.
+	for _, q := range []string{"What time is it?", "And tomorrow?"} {
+		var speech bytes.Buffer
+		question, reply, err := a.reply(context.Background(), strings.NewReader(q), "question.m4a", &speech)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if question != q || reply != fake.Reply(q) || speech.String() != reply {
+			t.Errorf("unexpected turn %q, %q, %q", question, reply, speech.String())
+		}
+	}
+	if n := len(a.conv.History()); n != 4 {
+		t.Errorf("got %d messages in the history, want 4", n)
+	}
+}
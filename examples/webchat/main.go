@@ -0,0 +1,80 @@
+// Command webchat serves a chat page whose replies are streamed to the browser as Server-Sent Events.
+//
+//	TOKEN=... go run ./examples/webchat -addr localhost:8080
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	api "github.com/j178/opencat-api"
+)
+
+const page = `<!doctype html>
+<title>Chat</title>
+<div id="log"></div>
+<form id="form"><input id="input" autofocus><button>Send</button></form>
+<script>
+const messages = [];
+form.onsubmit = async (e) => {
+  e.preventDefault();
+  messages.push({role: "user", content: input.value});
+  log.append(Object.assign(document.createElement("p"), {textContent: input.value}));
+  input.value = "";
+  const reply = log.appendChild(document.createElement("p"));
+  const resp = await fetch("/chat", {method: "POST", body: JSON.stringify({messages})});
+  const reader = resp.body.pipeThrough(new TextDecoderStream()).getReader();
+  let buf = "";
+  for (;;) {
+    const {value, done} = await reader.read();
+    if (done) break;
+    buf += value;
+    const events = buf.split("\n\n");
+    buf = events.pop();
+    for (const event of events) {
+      const data = event.match(/^event: delta\ndata: (.*)$/m);
+      if (data) reply.textContent += JSON.parse(data[1]).content || "";
+    }
+  }
+  messages.push({role: "assistant", content: reply.textContent});
+};
+</script>
+`
+
+// newHandler serves the page and relays the chats it sends. The browser only sends the messages,
+// the model is chosen by the server.
+func newHandler(c *api.Client, model api.ChatModel) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"/", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			io.WriteString(w, page)
+		},
+	)
+	mux.Handle(
+		"/chat", c.SSEHandler(
+			func(r *http.Request) (api.ChatRequest, error) {
+				var body struct {
+					Messages []api.Message `json:"messages"`
+				}
+				err := json.NewDecoder(r.Body).Decode(&body)
+				return api.ChatRequest{Model: model, Messages: body.Messages}, err
+			},
+		),
+	)
+	return mux
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	model := flag.String("model", string(api.ChatModelGPT3Dot5Turbo), "chat model")
+	flag.Parse()
+
+	c := api.NewClient(os.Getenv("TOKEN"))
+	log.Printf("listening on http://%s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, newHandler(c, api.ChatModel(*model))))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	api "github.com/j178/opencat-api"
+	"github.com/j178/opencat-api/examples/internal/fake"
+)
+
+func TestWebChat(t *testing.T) {
+	srv := httptest.NewServer(newHandler(fake.NewClient(t), api.ChatModelGPT4))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `fetch("/chat"`) {
+		t.Errorf("unexpected page %s", body)
+	}
+
+	resp, err = http.Post(srv.URL+"/chat", "application/json", strings.NewReader(`{"messages": [{"role": "user", "content": "Hi there"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	var reply string
+	for _, event := range strings.Split(string(body), "\n\n") {
+		if data, ok := strings.CutPrefix(event, "event: delta\ndata: "); ok {
+			var delta api.RelayDelta
+			err = json.Unmarshal([]byte(data), &delta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reply += delta.Content
+		}
+	}
+	if reply != fake.Reply("Hi there") || !strings.HasSuffix(string(body), "event: done\ndata: {}\n\n") {
+		t.Errorf("unexpected stream %q", body)
+	}
+}
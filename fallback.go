@@ -0,0 +1,70 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+func validateFallbacks(fallbacks map[ChatModel][]ChatModel, aliases map[ChatModel]ModelAlias) error {
+	for model, chain := range fallbacks {
+		key := fmt.Sprintf("fallbacks.%s", model)
+		if _, ok := aliases[model]; ok {
+			return &ConfigError{key, fmt.Errorf("fallbacks are set for models, %s is an alias", model)}
+		}
+		for i, fallback := range chain {
+			if fallback == "" || fallback == model {
+				return &ConfigError{fmt.Sprintf("%s[%d]", key, i), fmt.Errorf("invalid fallback %q for %s", fallback, model)}
+			}
+		}
+	}
+	return nil
+}
+
+// canFallBack reports whether a chat request that failed with err may succeed with another model:
+// the provider is down, overloaded or unreachable. Limits of the tenant apply to every model.
+func canFallBack(ctx context.Context, err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		category := apiErr.Category()
+		return category == ErrorServer || category == ErrorRateLimited
+	}
+	var tenantLimit *ErrTenantLimit
+	return ctx.Err() == nil && !errors.As(err, &tenantLimit) && CategorizeError(err) == ErrorNetwork
+}
+
+// chatWithFallbacks sends a prepared chat request, and if it fails because of its model, sends it again
+// with each fallback of the model in turn, publishing a FallbackEvent, see Config.Fallbacks.
+// The error of the last model is returned.
+func (c *Client) chatWithFallbacks(ctx context.Context, chat ChatRequest) (ChatResponse, error) {
+	resp, err := c.chatPrepared(ctx, chat)
+	cfg := c.config()
+	failed := chat.Model
+	for _, model := range cfg.Fallbacks[chat.Model] {
+		if err == nil || !canFallBack(ctx, err) {
+			break
+		}
+		fallback := chat
+		fallback.Model = model
+		fallback.Provider = ""
+		cfg.resolveAlias(&fallback)
+		fallback.Model = c.checkDeprecated(fallback.Model)
+		if fallback.validate() != nil {
+			// The model can't take the request, such as multiple choices for Claude.
+			continue
+		}
+		session, _ := SessionFromContext(ctx)
+		c.events.Publish(
+			FallbackEvent{
+				Time:     c.clock.Now(),
+				Session:  session,
+				Model:    failed,
+				Fallback: fallback.Model,
+				Cause:    err,
+			},
+		)
+		resp, err = c.chatPrepared(ctx, fallback)
+		failed = fallback.Model
+	}
+	return resp, err
+}
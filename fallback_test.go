@@ -0,0 +1,98 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestChatFallbacks(t *testing.T) {
+	var models []ChatModel
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				Model ChatModel `json:"model"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			models = append(models, body.Model)
+			switch body.Model {
+			case ChatModelGPT4:
+				w.WriteHeader(http.StatusServiceUnavailable)
+			case ChatModelGPT4Turbo:
+				w.WriteHeader(http.StatusTooManyRequests)
+			case ChatModelGPT3Dot5Turbo:
+				io.WriteString(w, `{"choices": [{"message": {"role": "assistant", "content": "Hi"}}]}`)
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		},
+	)
+	c.updateConfig(
+		func(cfg *Config) {
+			cfg.Aliases = map[ChatModel]ModelAlias{"fast": {Model: ChatModelGPT3Dot5Turbo}}
+			cfg.Fallbacks = map[ChatModel][]ChatModel{
+				ChatModelGPT4:    {ChatModelGPT4Turbo, "fast"},
+				ChatModelGPT432K: {ChatModelGPT4},
+			}
+		},
+	)
+	var fallbacks []FallbackEvent
+	unsubscribe := c.Events().Subscribe(
+		func(e Event) {
+			if f, ok := e.(FallbackEvent); ok {
+				fallbacks = append(fallbacks, f)
+			}
+		},
+	)
+	defer unsubscribe()
+	messages := []Message{{Role: RoleUser, Content: "Hello"}}
+
+	resp, err := c.Chat(context.Background(), ChatRequest{Model: ChatModelGPT4, Messages: messages})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Choices[0].Message.Content != "Hi" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	want := []ChatModel{ChatModelGPT4, ChatModelGPT4Turbo, ChatModelGPT3Dot5Turbo}
+	if len(models) != len(want) || models[0] != want[0] || models[1] != want[1] || models[2] != want[2] {
+		t.Errorf("expected requests for %v, got %v", want, models)
+	}
+	var apiErr *APIError
+	if len(fallbacks) != 2 || fallbacks[0].Model != ChatModelGPT4 || fallbacks[0].Fallback != ChatModelGPT4Turbo ||
+		fallbacks[1].Model != ChatModelGPT4Turbo || fallbacks[1].Fallback != ChatModelGPT3Dot5Turbo ||
+		!errors.As(fallbacks[1].Cause, &apiErr) || apiErr.HTTPStatusCode != http.StatusTooManyRequests {
+		t.Errorf("unexpected fallback events %+v", fallbacks)
+	}
+
+	// A request the model rejects isn't sent to the fallbacks.
+	models = nil
+	_, err = c.Chat(context.Background(), ChatRequest{Model: ChatModelGPT432K, Messages: messages})
+	if !errors.As(err, &apiErr) || apiErr.HTTPStatusCode != http.StatusBadRequest || len(models) != 1 {
+		t.Errorf("expected the bad request of %s only, got %v after %v", ChatModelGPT432K, err, models)
+	}
+}
+
+func TestValidateFallbacks(t *testing.T) {
+	for key, fallbacks := range map[string]map[ChatModel][]ChatModel{
+		"fallbacks.fast":     {"fast": {ChatModelGPT4}},
+		"fallbacks.gpt-4[1]": {ChatModelGPT4: {ChatModelClaude2, ChatModelGPT4}},
+		"fallbacks.gpt-4[0]": {ChatModelGPT4: {""}},
+	} {
+		c := NewClient("token")
+		err := c.ApplyConfig(
+			Config{
+				Token:     "token",
+				Aliases:   map[ChatModel]ModelAlias{"fast": {Model: ChatModelGPT3Dot5Turbo}},
+				Fallbacks: fallbacks,
+			},
+		)
+		var cfgErr *ConfigError
+		if !errors.As(err, &cfgErr) || cfgErr.Key != key {
+			t.Errorf("%v: expected an error for %s, got %v", fallbacks, key, err)
+		}
+	}
+}
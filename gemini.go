@@ -0,0 +1,80 @@
+package opencat_api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GeminiParams are options of Gemini models, see ChatRequest.Gemini.
+type GeminiParams struct {
+	// SafetySettings change how strictly the content of a category is blocked.
+	// Categories not listed keep the default threshold.
+	SafetySettings []SafetySetting `json:"safetySettings,omitempty"`
+	// CandidateCount is the number of replies to generate.
+	CandidateCount int `json:"candidateCount,omitempty"`
+	// TopK samples each token from the K most likely ones.
+	TopK int `json:"topK,omitempty"`
+}
+
+// HarmCategory is a category of content blocked by the safety filter of Gemini.
+type HarmCategory string
+
+const (
+	HarmCategoryHarassment       HarmCategory = "HARM_CATEGORY_HARASSMENT"
+	HarmCategoryHateSpeech       HarmCategory = "HARM_CATEGORY_HATE_SPEECH"
+	HarmCategorySexuallyExplicit HarmCategory = "HARM_CATEGORY_SEXUALLY_EXPLICIT"
+	HarmCategoryDangerousContent HarmCategory = "HARM_CATEGORY_DANGEROUS_CONTENT"
+)
+
+// HarmBlockThreshold is the probability of harm from which content is blocked.
+type HarmBlockThreshold string
+
+const (
+	HarmBlockNone           HarmBlockThreshold = "BLOCK_NONE"
+	HarmBlockOnlyHigh       HarmBlockThreshold = "BLOCK_ONLY_HIGH"
+	HarmBlockMediumAndAbove HarmBlockThreshold = "BLOCK_MEDIUM_AND_ABOVE"
+	HarmBlockLowAndAbove    HarmBlockThreshold = "BLOCK_LOW_AND_ABOVE"
+)
+
+type SafetySetting struct {
+	Category  HarmCategory       `json:"category"`
+	Threshold HarmBlockThreshold `json:"threshold"`
+}
+
+func (p *GeminiParams) validate(model ChatModel, provider Provider) error {
+	if provider != ProviderGoogle {
+		return fmt.Errorf("Gemini options are not supported by %s", model)
+	}
+	if p.CandidateCount < 0 || p.TopK < 0 {
+		return errors.New("Gemini candidate count and top K must not be negative")
+	}
+	return nil
+}
+
+// ErrSafetyBlocked is returned by Chat when the safety filter of the model blocked the reply,
+// see GeminiParams.SafetySettings.
+type ErrSafetyBlocked struct {
+	Model ChatModel
+	// Reason is the finish reason reported by the model.
+	Reason string
+}
+
+func (e *ErrSafetyBlocked) Error() string {
+	return fmt.Sprintf("reply of %s blocked by the safety filter (%s)", e.Model, e.Reason)
+}
+
+// checkSafetyBlocked returns an *ErrSafetyBlocked if every choice of a Gemini response was blocked,
+// instead of returning empty replies.
+func checkSafetyBlocked(chat ChatRequest, resp ChatResponse) error {
+	if chat.provider() != ProviderGoogle || len(resp.Choices) == 0 {
+		return nil
+	}
+	for _, choice := range resp.Choices {
+		reason := strings.ToUpper(choice.FinishReason)
+		if choice.Message.Content != "" || (reason != "SAFETY" && reason != "BLOCKLIST" && reason != "CONTENT_FILTER") {
+			return nil
+		}
+	}
+	return &ErrSafetyBlocked{Model: chat.Model, Reason: resp.Choices[0].FinishReason}
+}
@@ -0,0 +1,42 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestChatGeminiSafety(t *testing.T) {
+	var body map[string]any
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": ""}, "finish_reason": "SAFETY"}]}`)
+		},
+	)
+
+	chat := ChatRequest{
+		Model:    ChatModelGEMINIPro,
+		Messages: []Message{{Role: RoleUser, Content: "hi"}},
+		Gemini: &GeminiParams{
+			SafetySettings: []SafetySetting{{HarmCategoryDangerousContent, HarmBlockOnlyHigh}},
+			TopK:           40,
+		},
+	}
+	_, err := c.Chat(context.Background(), chat)
+	if CategorizeError(err) != ErrorContentFiltered {
+		t.Errorf("expected a content filter error, got %v", err)
+	}
+	gemini, _ := body["gemini"].(map[string]any)
+	if gemini["topK"] != float64(40) || len(gemini["safetySettings"].([]any)) != 1 {
+		t.Errorf("Gemini options not sent: %v", body)
+	}
+
+	chat.Model = ChatModelGPT4
+	_, err = c.Chat(context.Background(), chat)
+	if err == nil {
+		t.Error("expected an error for Gemini options on another model")
+	}
+}
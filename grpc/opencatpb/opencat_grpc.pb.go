@@ -0,0 +1,349 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: opencat.proto
+
+package opencatpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	OpenCat_Chat_FullMethodName       = "/opencat.v1.OpenCat/Chat"
+	OpenCat_StreamChat_FullMethodName = "/opencat.v1.OpenCat/StreamChat"
+	OpenCat_Image_FullMethodName      = "/opencat.v1.OpenCat/Image"
+	OpenCat_Speech_FullMethodName     = "/opencat.v1.OpenCat/Speech"
+	OpenCat_Usage_FullMethodName      = "/opencat.v1.OpenCat/Usage"
+)
+
+// OpenCatClient is the client API for OpenCat service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type OpenCatClient interface {
+	// Chat generates a reply to a list of messages.
+	Chat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (*ChatResponse, error)
+	// StreamChat generates a reply to a list of messages and streams it as it is generated.
+	StreamChat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (OpenCat_StreamChatClient, error)
+	// Image generates images from a prompt.
+	Image(ctx context.Context, in *ImageRequest, opts ...grpc.CallOption) (*ImageResponse, error)
+	// Speech synthesizes speech and streams the audio as it is received.
+	Speech(ctx context.Context, in *SpeechRequest, opts ...grpc.CallOption) (OpenCat_SpeechClient, error)
+	// Usage streams the usage of each product of the account.
+	Usage(ctx context.Context, in *UsageRequest, opts ...grpc.CallOption) (OpenCat_UsageClient, error)
+}
+
+type openCatClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewOpenCatClient(cc grpc.ClientConnInterface) OpenCatClient {
+	return &openCatClient{cc}
+}
+
+func (c *openCatClient) Chat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (*ChatResponse, error) {
+	out := new(ChatResponse)
+	err := c.cc.Invoke(ctx, OpenCat_Chat_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *openCatClient) StreamChat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (OpenCat_StreamChatClient, error) {
+	stream, err := c.cc.NewStream(ctx, &OpenCat_ServiceDesc.Streams[0], OpenCat_StreamChat_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &openCatStreamChatClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type OpenCat_StreamChatClient interface {
+	Recv() (*ChatDelta, error)
+	grpc.ClientStream
+}
+
+type openCatStreamChatClient struct {
+	grpc.ClientStream
+}
+
+func (x *openCatStreamChatClient) Recv() (*ChatDelta, error) {
+	m := new(ChatDelta)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *openCatClient) Image(ctx context.Context, in *ImageRequest, opts ...grpc.CallOption) (*ImageResponse, error) {
+	out := new(ImageResponse)
+	err := c.cc.Invoke(ctx, OpenCat_Image_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *openCatClient) Speech(ctx context.Context, in *SpeechRequest, opts ...grpc.CallOption) (OpenCat_SpeechClient, error) {
+	stream, err := c.cc.NewStream(ctx, &OpenCat_ServiceDesc.Streams[1], OpenCat_Speech_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &openCatSpeechClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type OpenCat_SpeechClient interface {
+	Recv() (*SpeechChunk, error)
+	grpc.ClientStream
+}
+
+type openCatSpeechClient struct {
+	grpc.ClientStream
+}
+
+func (x *openCatSpeechClient) Recv() (*SpeechChunk, error) {
+	m := new(SpeechChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *openCatClient) Usage(ctx context.Context, in *UsageRequest, opts ...grpc.CallOption) (OpenCat_UsageClient, error) {
+	stream, err := c.cc.NewStream(ctx, &OpenCat_ServiceDesc.Streams[2], OpenCat_Usage_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &openCatUsageClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type OpenCat_UsageClient interface {
+	Recv() (*ProductUsage, error)
+	grpc.ClientStream
+}
+
+type openCatUsageClient struct {
+	grpc.ClientStream
+}
+
+func (x *openCatUsageClient) Recv() (*ProductUsage, error) {
+	m := new(ProductUsage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// OpenCatServer is the server API for OpenCat service.
+// All implementations must embed UnimplementedOpenCatServer
+// for forward compatibility
+type OpenCatServer interface {
+	// Chat generates a reply to a list of messages.
+	Chat(context.Context, *ChatRequest) (*ChatResponse, error)
+	// StreamChat generates a reply to a list of messages and streams it as it is generated.
+	StreamChat(*ChatRequest, OpenCat_StreamChatServer) error
+	// Image generates images from a prompt.
+	Image(context.Context, *ImageRequest) (*ImageResponse, error)
+	// Speech synthesizes speech and streams the audio as it is received.
+	Speech(*SpeechRequest, OpenCat_SpeechServer) error
+	// Usage streams the usage of each product of the account.
+	Usage(*UsageRequest, OpenCat_UsageServer) error
+	mustEmbedUnimplementedOpenCatServer()
+}
+
+// UnimplementedOpenCatServer must be embedded to have forward compatible implementations.
+type UnimplementedOpenCatServer struct {
+}
+
+func (UnimplementedOpenCatServer) Chat(context.Context, *ChatRequest) (*ChatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Chat not implemented")
+}
+func (UnimplementedOpenCatServer) StreamChat(*ChatRequest, OpenCat_StreamChatServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamChat not implemented")
+}
+func (UnimplementedOpenCatServer) Image(context.Context, *ImageRequest) (*ImageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Image not implemented")
+}
+func (UnimplementedOpenCatServer) Speech(*SpeechRequest, OpenCat_SpeechServer) error {
+	return status.Errorf(codes.Unimplemented, "method Speech not implemented")
+}
+func (UnimplementedOpenCatServer) Usage(*UsageRequest, OpenCat_UsageServer) error {
+	return status.Errorf(codes.Unimplemented, "method Usage not implemented")
+}
+func (UnimplementedOpenCatServer) mustEmbedUnimplementedOpenCatServer() {}
+
+// UnsafeOpenCatServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to OpenCatServer will
+// result in compilation errors.
+type UnsafeOpenCatServer interface {
+	mustEmbedUnimplementedOpenCatServer()
+}
+
+func RegisterOpenCatServer(s grpc.ServiceRegistrar, srv OpenCatServer) {
+	s.RegisterService(&OpenCat_ServiceDesc, srv)
+}
+
+func _OpenCat_Chat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OpenCatServer).Chat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OpenCat_Chat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OpenCatServer).Chat(ctx, req.(*ChatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OpenCat_StreamChat_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ChatRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OpenCatServer).StreamChat(m, &openCatStreamChatServer{stream})
+}
+
+type OpenCat_StreamChatServer interface {
+	Send(*ChatDelta) error
+	grpc.ServerStream
+}
+
+type openCatStreamChatServer struct {
+	grpc.ServerStream
+}
+
+func (x *openCatStreamChatServer) Send(m *ChatDelta) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _OpenCat_Image_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OpenCatServer).Image(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OpenCat_Image_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OpenCatServer).Image(ctx, req.(*ImageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OpenCat_Speech_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SpeechRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OpenCatServer).Speech(m, &openCatSpeechServer{stream})
+}
+
+type OpenCat_SpeechServer interface {
+	Send(*SpeechChunk) error
+	grpc.ServerStream
+}
+
+type openCatSpeechServer struct {
+	grpc.ServerStream
+}
+
+func (x *openCatSpeechServer) Send(m *SpeechChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _OpenCat_Usage_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(UsageRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OpenCatServer).Usage(m, &openCatUsageServer{stream})
+}
+
+type OpenCat_UsageServer interface {
+	Send(*ProductUsage) error
+	grpc.ServerStream
+}
+
+type openCatUsageServer struct {
+	grpc.ServerStream
+}
+
+func (x *openCatUsageServer) Send(m *ProductUsage) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// OpenCat_ServiceDesc is the grpc.ServiceDesc for OpenCat service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var OpenCat_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "opencat.v1.OpenCat",
+	HandlerType: (*OpenCatServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Chat",
+			Handler:    _OpenCat_Chat_Handler,
+		},
+		{
+			MethodName: "Image",
+			Handler:    _OpenCat_Image_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamChat",
+			Handler:       _OpenCat_StreamChat_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Speech",
+			Handler:       _OpenCat_Speech_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Usage",
+			Handler:       _OpenCat_Usage_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "opencat.proto",
+}
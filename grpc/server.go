@@ -0,0 +1,275 @@
+// Package grpc serves a client of the OpenCat API over gRPC, see opencat.proto, so services written in
+// other languages can share one Go sidecar, with the same authorization and limits for all of them.
+//
+// It is a module of its own, so the opencat_api package doesn't depend on gRPC.
+package grpc
+
+//go:generate protoc --go_out=opencatpb --go_opt=paths=source_relative --go-grpc_out=opencatpb --go-grpc_opt=paths=source_relative opencat.proto
+
+import (
+	"context"
+	"io"
+
+	api "github.com/j178/opencat-api"
+	"github.com/j178/opencat-api/grpc/opencatpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements the OpenCat service with a client.
+type Server struct {
+	opencatpb.UnimplementedOpenCatServer
+
+	// Authorize is called before every call, to check its metadata, such as a bearer token, see
+	// metadata.FromIncomingContext. It returns the tenant the call is made for, whose limits apply if
+	// the client has a TenantLimiter, see api.WithTenantLimiter, or "" for none.
+	// If it returns an error, the call fails with codes.Unauthenticated. If nil, every call is accepted.
+	Authorize func(ctx context.Context) (tenant string, err error)
+	// Options are applied to every chat request.
+	Options []api.RequestOption
+
+	client *api.Client
+}
+
+// NewServer returns a server sending the calls it gets with c.
+func NewServer(c *api.Client) *Server {
+	return &Server{client: c}
+}
+
+// Register registers the OpenCat service of s on g.
+func (s *Server) Register(g *grpc.Server) {
+	opencatpb.RegisterOpenCatServer(g, s)
+}
+
+// authorize returns the context to send the requests of a call with.
+func (s *Server) authorize(ctx context.Context) (context.Context, error) {
+	if s.Authorize == nil {
+		return ctx, nil
+	}
+	tenant, err := s.Authorize(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if tenant != "" {
+		ctx = api.WithTenant(ctx, tenant)
+	}
+	return ctx, nil
+}
+
+// statusOf maps an error of the client to a gRPC status error.
+func statusOf(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	code := codes.Unknown
+	switch api.CategorizeError(err) {
+	case api.ErrorQuotaExceeded, api.ErrorRateLimited:
+		code = codes.ResourceExhausted
+	case api.ErrorContentFiltered, api.ErrorContextTooLong:
+		code = codes.InvalidArgument
+	case api.ErrorServer, api.ErrorNetwork:
+		code = codes.Unavailable
+	case api.ErrorCanceled:
+		code = codes.Canceled
+	case api.ErrorInvalidKey:
+		// The key of the sidecar, not of the caller.
+		code = codes.Internal
+	}
+	return status.Error(code, err.Error())
+}
+
+func chatRequest(req *opencatpb.ChatRequest) (api.ChatRequest, error) {
+	chat := api.ChatRequest{
+		Model:       api.ChatModel(req.Model),
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   int(req.MaxTokens),
+		N:           int(req.N),
+		Stop:        req.Stop,
+		Language:    req.Language,
+	}
+	for _, msg := range req.Messages {
+		m := api.Message{Role: api.Role(msg.Role), Content: msg.Content, ToolCallID: msg.ToolCallId}
+		for _, img := range msg.Images {
+			var image api.Image
+			switch source := img.Source.(type) {
+			case *opencatpb.Image_Data:
+				image = api.NewImageFromBytes(source.Data)
+			case *opencatpb.Image_Url:
+				image = api.NewImageURL(source.Url)
+			default:
+				return api.ChatRequest{}, status.Error(codes.InvalidArgument, "image without data or URL")
+			}
+			if img.MimeType != "" {
+				image = image.WithMIMEType(img.MimeType)
+			}
+			m.Images = append(m.Images, image)
+		}
+		chat.Messages = append(chat.Messages, m)
+	}
+	return chat, nil
+}
+
+func (s *Server) Chat(ctx context.Context, req *opencatpb.ChatRequest) (*opencatpb.ChatResponse, error) {
+	ctx, err := s.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	chat, err := chatRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Chat(ctx, chat, s.Options...)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	out := &opencatpb.ChatResponse{
+		Id:    resp.ID,
+		Model: resp.Model,
+		Usage: &opencatpb.TokenUsage{
+			PromptTokens:     int32(resp.Usage.PromptTokens),
+			CompletionTokens: int32(resp.Usage.CompletionTokens),
+			TotalTokens:      int32(resp.Usage.TotalTokens),
+		},
+	}
+	for _, choice := range resp.Choices {
+		out.Choices = append(
+			out.Choices, &opencatpb.ChatChoice{
+				Index:        int32(choice.Index),
+				Message:      &opencatpb.Message{Role: string(choice.Message.Role), Content: choice.Message.Content},
+				FinishReason: choice.FinishReason,
+			},
+		)
+	}
+	return out, nil
+}
+
+func (s *Server) StreamChat(req *opencatpb.ChatRequest, stream opencatpb.OpenCat_StreamChatServer) error {
+	ctx, err := s.authorize(stream.Context())
+	if err != nil {
+		return err
+	}
+	chat, err := chatRequest(req)
+	if err != nil {
+		return err
+	}
+	chat.Stream = true
+
+	// Stop generating once the caller is gone.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var sendErr error
+	err = s.client.StreamChatDeltas(
+		ctx, chat, func(delta api.ChatDelta) {
+			if sendErr != nil || delta.Content == "" && delta.FinishReason == "" {
+				return
+			}
+			sendErr = stream.Send(
+				&opencatpb.ChatDelta{
+					Index: int32(delta.Index), Content: delta.Content, FinishReason: delta.FinishReason,
+				},
+			)
+			if sendErr != nil {
+				cancel()
+			}
+		},
+		s.Options...,
+	)
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		return statusOf(err)
+	}
+	return nil
+}
+
+func (s *Server) Image(ctx context.Context, req *opencatpb.ImageRequest) (*opencatpb.ImageResponse, error) {
+	ctx, err := s.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	images, err := s.client.Image(
+		ctx, api.ImageRequest{
+			Model:          api.ImageModel(req.Model),
+			Prompt:         req.Prompt,
+			NegativePrompt: req.NegativePrompt,
+			Width:          int(req.Width),
+			Height:         int(req.Height),
+			Num:            int(req.Num),
+			Seed:           req.Seed,
+			ResponseFormat: api.ImageFormatData,
+		},
+	)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	out := &opencatpb.ImageResponse{}
+	for _, img := range images {
+		out.Images = append(
+			out.Images, &opencatpb.GeneratedImage{Data: img.Data, RevisedPrompt: img.RevisedPrompt, Seed: img.Seed},
+		)
+	}
+	return out, nil
+}
+
+// speechChunkSize is the most audio sent in a message of Speech.
+const speechChunkSize = 32 << 10
+
+func (s *Server) Speech(req *opencatpb.SpeechRequest, stream opencatpb.OpenCat_SpeechServer) error {
+	ctx, err := s.authorize(stream.Context())
+	if err != nil {
+		return err
+	}
+	audio, err := s.client.Speech(
+		ctx, api.SpeechRequest{
+			Input:          req.Input,
+			Voice:          req.Voice,
+			Model:          api.SpeechModel(req.Model),
+			ResponseFormat: api.SpeechFormat(req.ResponseFormat),
+			Speed:          req.Speed,
+		},
+	)
+	if err != nil {
+		return statusOf(err)
+	}
+	defer audio.Close()
+
+	buf := make([]byte, speechChunkSize)
+	for {
+		n, err := io.ReadFull(audio, buf)
+		if n > 0 {
+			sendErr := stream.Send(&opencatpb.SpeechChunk{Audio: buf[:n]})
+			if sendErr != nil {
+				return sendErr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return statusOf(err)
+		}
+	}
+}
+
+func (s *Server) Usage(_ *opencatpb.UsageRequest, stream opencatpb.OpenCat_UsageServer) error {
+	ctx, err := s.authorize(stream.Context())
+	if err != nil {
+		return err
+	}
+	usages, err := s.client.Usage(ctx)
+	if err != nil {
+		return statusOf(err)
+	}
+	for _, u := range usages {
+		err = stream.Send(
+			&opencatpb.ProductUsage{Id: u.ID, Limit: int32(u.Limit), Product: u.Product, Usage: u.Usage},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	api "github.com/j178/opencat-api"
+	"github.com/j178/opencat-api/grpc/opencatpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestServer serves s over an in-memory connection and returns a client of it.
+func newTestServer(t *testing.T, s *Server) opencatpb.OpenCatClient {
+	lis := bufconn.Listen(1 << 20)
+	g := grpc.NewServer()
+	s.Register(g)
+	go g.Serve(lis)
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.Dial(
+		"bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return opencatpb.NewOpenCatClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/v1/audio/speech":
+					io.WriteString(w, strings.Repeat("a", speechChunkSize+10))
+				case strings.HasPrefix(r.URL.Path, "/1/images"):
+					io.WriteString(w, `{"data": [{"b64_json": "b25l", "revised_prompt": "a kitten"}]}`)
+				case strings.HasSuffix(r.URL.Path, "/usage"):
+					io.WriteString(w, `{"data": [{"id": "1", "limit": 100, "product": "chat", "usage": {"gpt-4": 30}}]}`)
+				case strings.Contains(readBody(r), `"stream":true`):
+					w.Header().Set("Content-Type", "text/event-stream")
+					fmt.Fprint(
+						w, "data: {\"choices\": [{\"delta\": {\"content\": \"Hel\"}}]}\n\n"+
+							"data: {\"choices\": [{\"delta\": {\"content\": \"lo\"}}]}\n\ndata: [DONE]\n\n",
+					)
+				default:
+					io.WriteString(
+						w, `{"id": "chat-1", "choices": [{"message": {"role": "assistant", "content": "Hello"}}],`+
+							`"usage": {"prompt_tokens": 5, "completion_tokens": 1, "total_tokens": 6}}`,
+					)
+				}
+			},
+		),
+	)
+	defer srv.Close()
+	c := api.NewClient(
+		"token", api.WithTenantLimiter(api.NewTenantLimiter(api.TenantLimits{RequestsPerMinute: 5})),
+	)
+	err := c.ApplyConfig(api.Config{Token: "token", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer(c)
+	s.Authorize = func(ctx context.Context) (string, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if auth := md.Get("authorization"); len(auth) == 1 && strings.HasPrefix(auth[0], "Bearer ") {
+			return strings.TrimPrefix(auth[0], "Bearer "), nil
+		}
+		return "", errors.New("missing token")
+	}
+	client := newTestServer(t, s)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer acme")
+	req := &opencatpb.ChatRequest{
+		Model:    string(api.ChatModelGPT4),
+		Messages: []*opencatpb.Message{{Role: "user", Content: "Hi"}},
+	}
+
+	_, err = client.Chat(context.Background(), req)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated without a token, got %v", err)
+	}
+
+	resp, err := client.Chat(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Id != "chat-1" || resp.Choices[0].Message.Content != "Hello" || resp.Usage.TotalTokens != 6 {
+		t.Errorf("unexpected response %v", resp)
+	}
+
+	stream, err := client.StreamChat(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reply string
+	for {
+		delta, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply += delta.Content
+	}
+	if reply != "Hello" {
+		t.Errorf("streamed %q", reply)
+	}
+
+	images, err := client.Image(ctx, &opencatpb.ImageRequest{Model: "dall-e-3", Prompt: "a cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images.Images) != 1 || string(images.Images[0].Data) != "one" || images.Images[0].RevisedPrompt != "a kitten" {
+		t.Errorf("unexpected images %v", images)
+	}
+
+	speech, err := client.Speech(ctx, &opencatpb.SpeechRequest{Input: "Hello", Voice: "alloy", Model: "tts-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks, size int
+	for {
+		chunk, err := speech.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks++
+		size += len(chunk.Audio)
+	}
+	if chunks != 2 || size != speechChunkSize+10 {
+		t.Errorf("got %d bytes of audio in %d chunks", size, chunks)
+	}
+
+	usage, err := client.Usage(ctx, &opencatpb.UsageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := usage.Recv()
+	if err != nil || u.Product != "chat" || u.Usage["gpt-4"] != 30 {
+		t.Errorf("unexpected usage %v, %v", u, err)
+	}
+
+	// The limits of the tenant returned by Authorize apply: it made 5 requests in the last minute.
+	_, err = client.Chat(ctx, req)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted over the tenant limit, got %v", err)
+	}
+}
+
+func readBody(r *http.Request) string {
+	data, _ := io.ReadAll(r.Body)
+	return string(data)
+}
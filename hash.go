@@ -0,0 +1,97 @@
+package opencat_api
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"strings"
+)
+
+// contentHash hashes a sequence of fields, each prefixed by its length so they can't run into each other.
+type contentHash struct {
+	h hash.Hash
+}
+
+func newContentHash() contentHash {
+	return contentHash{sha256.New()}
+}
+
+func (c contentHash) field(b []byte) {
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+	c.h.Write(n[:])
+	c.h.Write(b)
+}
+
+func (c contentHash) text(s string) {
+	c.field([]byte(strings.Join(strings.Fields(s), " ")))
+}
+
+// toolCalls hashes the names and arguments of calls. Arguments are compared as JSON values when valid,
+// so their formatting and key order don't matter. Call IDs, which are random, are left out.
+func (c contentHash) toolCalls(calls []ToolCall) {
+	for _, call := range calls {
+		c.field([]byte(call.Function.Name))
+		var args any
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err == nil {
+			canonical, _ := json.Marshal(args)
+			c.field(canonical)
+		} else {
+			c.text(call.Function.Arguments)
+		}
+	}
+}
+
+func (c contentHash) sum() string {
+	return hex.EncodeToString(c.h.Sum(nil))
+}
+
+// Hash returns a stable hash of the content of the message, for deduplicating stored messages and
+// grouping repeated answers. Messages that differ only in whitespace, in the formatting of tool call
+// arguments or in tool call IDs have the same hash, and a reply hashes the same as a ResponseMessage.
+// Images read from a reader are left out, since reading them would consume them.
+func (m Message) Hash() string {
+	c := newContentHash()
+	c.field([]byte(m.Role))
+	c.text(m.Text())
+	c.toolCalls(m.ToolCalls)
+	for _, img := range m.images() {
+		switch {
+		case img.url != "":
+			c.field([]byte(img.url))
+		case img.data != nil:
+			c.field(img.data)
+		}
+	}
+	return c.sum()
+}
+
+// Hash returns a stable hash of the content of the message, see Message.Hash.
+func (m ResponseMessage) Hash() string {
+	c := newContentHash()
+	c.field([]byte(m.Role))
+	c.text(m.Content)
+	c.toolCalls(m.ToolCalls)
+	for _, p := range m.Parts {
+		switch {
+		case p.Type == PartText:
+		case p.URL != "":
+			c.field([]byte(p.URL))
+		default:
+			c.field(p.Data)
+		}
+	}
+	return c.sum()
+}
+
+// Hash returns a stable hash of the messages of the choices, see Message.Hash. The ID, creation time,
+// model and usage of the response are left out, so identical generations have the same hash.
+func (r ChatResponse) Hash() string {
+	c := newContentHash()
+	for _, choice := range r.Choices {
+		c.field([]byte(choice.Message.Hash()))
+	}
+	return c.sum()
+}
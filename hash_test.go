@@ -0,0 +1,43 @@
+package opencat_api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	a := Message{
+		Role:      RoleAssistant,
+		Content:   "Hello,\n  world!",
+		ToolCalls: []ToolCall{{ID: "call_1", Function: FunctionCall{Name: "f", Arguments: `{"a": 1, "b": 2}`}}},
+	}
+	b := Message{
+		Role:      RoleAssistant,
+		Content:   " Hello, world! ",
+		ToolCalls: []ToolCall{{ID: "call_2", Function: FunctionCall{Name: "f", Arguments: `{"b":2,"a":1}`}}},
+	}
+	if a.Hash() != b.Hash() {
+		t.Error("messages differing in whitespace, argument formatting and call IDs hash differently")
+	}
+	if a.Hash() == (Message{Role: RoleUser, Content: a.Content, ToolCalls: a.ToolCalls}).Hash() {
+		t.Error("role not hashed")
+	}
+	c := Message{Content: "a", ToolCalls: []ToolCall{{Function: FunctionCall{Name: "b"}}}}
+	if (Message{Content: "ab"}).Hash() == c.Hash() {
+		t.Error("fields run into each other")
+	}
+
+	var r1, r2 ChatResponse
+	_ = json.Unmarshal(
+		[]byte(`{"id": "1", "created": 1, "choices": [{"message": {"role": "assistant", "content": "Hi there"}}]}`), &r1,
+	)
+	_ = json.Unmarshal(
+		[]byte(`{"id": "2", "created": 2, "choices": [{"message": {"role": "assistant", "content": "Hi\nthere"}}]}`), &r2,
+	)
+	if r1.Hash() != r2.Hash() {
+		t.Error("identical generations hash differently")
+	}
+	if r1.Choices[0].Message.Hash() != (Message{Role: RoleAssistant, Content: "Hi there"}).Hash() {
+		t.Error("reply hashes differently from the message stored in history")
+	}
+}
@@ -0,0 +1,179 @@
+package opencat_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// HealthState is the health of a model, derived from its success rate over the last 10 minutes.
+// It is not a circuit breaker state: the client sends requests to failing models all the same,
+// it is up to the caller to avoid them, such as with RankModels or Config.Fallbacks. Changes are published
+// as a HealthEvent.
+type HealthState string
+
+const (
+	HealthOK       HealthState = "ok"
+	HealthDegraded HealthState = "degraded"
+	// HealthFailing means most calls to the model fail, it should be avoided until it recovers.
+	HealthFailing HealthState = "failing"
+)
+
+// healthStateOf maps a success rate to a state: below 95% a model is degraded, below 50% failing.
+func healthStateOf(successRate float64) HealthState {
+	switch {
+	case successRate < 0.5:
+		return HealthFailing
+	case successRate < 0.95:
+		return HealthDegraded
+	default:
+		return HealthOK
+	}
+}
+
+// ModelHealth is the health of a model over the last 10 minutes.
+type ModelHealth struct {
+	Model    ChatModel `json:"model"`
+	Provider Provider  `json:"provider"`
+	// State is the health of the model, see HealthState.
+	State     HealthState `json:"health"`
+	Requests  int         `json:"requests"`
+	Errors    int         `json:"errors"`
+	ErrorRate float64     `json:"error_rate"`
+	// Latencies are in seconds.
+	LatencyP50 float64 `json:"latency_p50"`
+	LatencyP95 float64 `json:"latency_p95"`
+
+	// latencySum is the total latency of the successful requests, in seconds.
+	latencySum float64
+}
+
+// Health is the operational state of a client.
+type Health struct {
+	Models []ModelHealth `json:"models"`
+	// Usage is the fraction of its limit used by each product, as of the last call to Usage.
+	Usage map[string]float64 `json:"usage"`
+}
+
+type usageRatios struct {
+	mu     sync.Mutex
+	ratios map[string]float64
+}
+
+func (u *usageRatios) set(ratios map[string]float64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.ratios = ratios
+}
+
+func (u *usageRatios) get() map[string]float64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return maps.Clone(u.ratios)
+}
+
+// Health returns the health of the models called recently and the usage last reported by the API.
+func (c *Client) Health() Health {
+	h := Health{Models: []ModelHealth{}, Usage: c.usage.get()}
+	if h.Usage == nil {
+		h.Usage = map[string]float64{}
+	}
+	for _, st := range c.Stats() {
+		h.Models = append(
+			h.Models, ModelHealth{
+				Model:      st.Model,
+				Provider:   st.Provider,
+				State:      healthStateOf(st.SuccessRate),
+				Requests:   st.Requests,
+				Errors:     st.Errors,
+				ErrorRate:  1 - st.SuccessRate,
+				LatencyP50: st.LatencyP50.Seconds(),
+				LatencyP95: st.LatencyP95.Seconds(),
+				latencySum: st.latencySum.Seconds(),
+			},
+		)
+	}
+	return h
+}
+
+// HealthHandler returns a handler serving the health of the client, to be mounted on an existing server.
+// It serves JSON, or the Prometheus text format when the format=prometheus query parameter is set
+// or the request accepts text/plain but not JSON.
+func (c *Client) HealthHandler() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			h := c.Health()
+			accept := r.Header.Get("Accept")
+			if r.URL.Query().Get("format") == "prometheus" ||
+				strings.Contains(accept, "text/plain") && !strings.Contains(accept, "application/json") {
+				w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+				h.writePrometheus(w)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(h)
+		},
+	)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (h Health) writePrometheus(w io.Writer) {
+	metric := func(name, typ, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	gauge := func(name, help string) {
+		metric(name, "gauge", help)
+	}
+	model := func(m ModelHealth) string {
+		return fmt.Sprintf(`model="%s",provider="%s"`, labelEscaper.Replace(string(m.Model)), m.Provider)
+	}
+
+	gauge("opencat_model_requests", "Chat requests to the model in the last 10 minutes.")
+	for _, m := range h.Models {
+		fmt.Fprintf(w, "opencat_model_requests{%s} %d\n", model(m), m.Requests)
+	}
+	gauge("opencat_model_errors", "Failed chat requests to the model in the last 10 minutes.")
+	for _, m := range h.Models {
+		fmt.Fprintf(w, "opencat_model_errors{%s} %d\n", model(m), m.Errors)
+	}
+	gauge("opencat_model_error_rate", "Fraction of the chat requests to the model that failed in the last 10 minutes.")
+	for _, m := range h.Models {
+		fmt.Fprintf(w, "opencat_model_error_rate{%s} %g\n", model(m), m.ErrorRate)
+	}
+	gauge("opencat_model_health", "Health of the model from its error rate in the last 10 minutes, 1 for the current one.")
+	for _, m := range h.Models {
+		for _, state := range []HealthState{HealthOK, HealthDegraded, HealthFailing} {
+			value := 0
+			if m.State == state {
+				value = 1
+			}
+			fmt.Fprintf(w, "opencat_model_health{%s,health=\"%s\"} %d\n", model(m), state, value)
+		}
+	}
+	metric(
+		"opencat_model_latency_seconds", "summary",
+		"Latency of the successful chat requests to the model in the last 10 minutes.",
+	)
+	for _, m := range h.Models {
+		fmt.Fprintf(w, "opencat_model_latency_seconds{%s,quantile=\"0.5\"} %g\n", model(m), m.LatencyP50)
+		fmt.Fprintf(w, "opencat_model_latency_seconds{%s,quantile=\"0.95\"} %g\n", model(m), m.LatencyP95)
+		fmt.Fprintf(w, "opencat_model_latency_seconds_sum{%s} %g\n", model(m), m.latencySum)
+		fmt.Fprintf(w, "opencat_model_latency_seconds_count{%s} %d\n", model(m), m.Requests-m.Errors)
+	}
+
+	products := make([]string, 0, len(h.Usage))
+	for product := range h.Usage {
+		products = append(products, product)
+	}
+	sort.Strings(products)
+	gauge("opencat_usage_ratio", "Fraction of its limit used by the product.")
+	for _, product := range products {
+		fmt.Fprintf(w, "opencat_usage_ratio{product=\"%s\"} %g\n", labelEscaper.Replace(product), h.Usage[product])
+	}
+}
@@ -0,0 +1,87 @@
+package opencat_api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthHandler(t *testing.T) {
+	c := NewClient("token")
+	start := time.Now()
+	c.recordCall(ChatModelGPT4, start, 0, nil)
+	c.recordCall(ChatModelClaude2, start, 0, nil)
+	c.recordCall(ChatModelClaude2, start, 0, &APIError{HTTPStatusCode: 503})
+	c.checkUsage([]Usage{{Product: "chat", Limit: 100, Usage: map[string]float32{"gpt-4": 30, "claude-2": 20}}})
+
+	rec := httptest.NewRecorder()
+	c.HealthHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/health", nil))
+	var h Health
+	err := json.Unmarshal(rec.Body.Bytes(), &h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Models) != 2 || h.Models[0].State != HealthDegraded || h.Models[0].ErrorRate != 0.5 ||
+		h.Models[1].State != HealthOK {
+		t.Errorf("unexpected models: %+v", h.Models)
+	}
+	if h.Usage["chat"] != 0.5 {
+		t.Errorf("unexpected usage: %v", h.Usage)
+	}
+
+	rec = httptest.NewRecorder()
+	c.HealthHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/health?format=prometheus", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		`opencat_model_errors{model="claude-2.1",provider="anthropic"} 1`,
+		`opencat_model_health{model="claude-2.1",provider="anthropic",health="degraded"} 1`,
+		"# TYPE opencat_model_latency_seconds summary",
+		`opencat_model_latency_seconds_count{model="claude-2.1",provider="anthropic"} 1`,
+		`opencat_usage_ratio{product="chat"} 0.5`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %s in:\n%s", line, body)
+		}
+	}
+}
+
+func TestHealthEvents(t *testing.T) {
+	c := NewClient("token")
+	var events []HealthEvent
+	c.Events().Subscribe(
+		func(e Event) {
+			if h, ok := e.(HealthEvent); ok {
+				events = append(events, h)
+			}
+		},
+	)
+	start := time.Now()
+	c.recordCall(ChatModelGPT4, start, 0, nil)
+	c.recordCall(ChatModelGPT4, start, 0, &APIError{HTTPStatusCode: 503})
+	c.recordCall(ChatModelGPT4, start, 0, &APIError{HTTPStatusCode: 503})
+	c.recordCall(ChatModelGPT4, start, 0, &APIError{HTTPStatusCode: 503})
+	// Rejected requests don't count.
+	c.recordCall(ChatModelGPT4, start, 0, &APIError{HTTPStatusCode: 400})
+	for i := 0; i < 4; i++ {
+		c.recordCall(ChatModelGPT4, start, 0, nil)
+	}
+
+	want := []struct{ from, to HealthState }{
+		{HealthOK, HealthDegraded},
+		{HealthDegraded, HealthFailing},
+		{HealthFailing, HealthDegraded},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	for i, w := range want {
+		if e := events[i]; e.Model != ChatModelGPT4 || e.From != w.from || e.To != w.to {
+			t.Errorf("event %d: expected %s to %s, got %+v", i, w.from, w.to, e)
+		}
+	}
+	if rate := events[1].SuccessRate; rate != 1.0/3 {
+		t.Errorf("expected a success rate of 1/3 when failing, got %v", rate)
+	}
+}
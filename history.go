@@ -0,0 +1,125 @@
+package opencat_api
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// HistoryEntry is a message of a conversation history with what is needed to sync it across devices:
+// an ID unique within the conversation, assigned by the app, when the message was added, and the time it was
+// last changed.
+type HistoryEntry struct {
+	ID string
+	// Created is when the message was added to the conversation. It orders the history and never changes.
+	Created time.Time
+	// Time is when the entry was last changed, by an edit or a deletion.
+	Time    time.Time
+	Message Message
+	// Deleted marks a removed message. Keep such tombstones, so the removal is merged into other copies.
+	Deleted bool
+}
+
+// HistoryDiff is the difference between two copies of a history, see DiffHistories.
+type HistoryDiff struct {
+	// Added are the entries only in the second copy.
+	Added []HistoryEntry
+	// Removed are the entries only in the first copy.
+	Removed []HistoryEntry
+	// Changed are the entries of the second copy whose content or deletion differs from the first copy.
+	Changed []HistoryEntry
+}
+
+// Empty reports whether the copies are the same.
+func (d HistoryDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffHistories compares two copies of a history, such as the local copy and the copy synced by the
+// backend, by entry ID. Messages are compared by content, see Message.Hash.
+// Entries are listed in the order of the copy they come from.
+func DiffHistories(a, b []HistoryEntry) HistoryDiff {
+	byID := make(map[string]HistoryEntry, len(a))
+	for _, e := range a {
+		byID[e.ID] = e
+	}
+	var diff HistoryDiff
+	inB := make(map[string]bool, len(b))
+	for _, e := range b {
+		inB[e.ID] = true
+		old, ok := byID[e.ID]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, e)
+		case old.Deleted != e.Deleted || old.Message.Hash() != e.Message.Hash():
+			diff.Changed = append(diff.Changed, e)
+		}
+	}
+	for _, e := range a {
+		if !inB[e.ID] {
+			diff.Removed = append(diff.Removed, e)
+		}
+	}
+	return diff
+}
+
+// MergeHistories merges copies of a history: all entries are kept, and when copies have different versions
+// of an entry, the one changed last wins. The result is ordered by creation time, then by ID, so editing
+// or deleting a message doesn't move it.
+// Merging is deterministic: the order of the copies and of their entries doesn't matter, so every
+// device merging the same copies gets the same history.
+func MergeHistories(copies ...[]HistoryEntry) []HistoryEntry {
+	byID := map[string]HistoryEntry{}
+	for _, entries := range copies {
+		for _, e := range entries {
+			current, ok := byID[e.ID]
+			if !ok || newerEntry(e, current) {
+				byID[e.ID] = e
+			}
+		}
+	}
+	merged := make([]HistoryEntry, 0, len(byID))
+	for _, e := range byID {
+		merged = append(merged, e)
+	}
+	sort.Slice(
+		merged, func(i, j int) bool {
+			if !merged[i].Created.Equal(merged[j].Created) {
+				return merged[i].Created.Before(merged[j].Created)
+			}
+			return merged[i].ID < merged[j].ID
+		},
+	)
+	return merged
+}
+
+// newerEntry reports whether a wins over b, two versions of the same entry.
+func newerEntry(a, b HistoryEntry) bool {
+	if !a.Time.Equal(b.Time) {
+		return a.Time.After(b.Time)
+	}
+	// Changed at the same time: a deletion wins, then the greater hash, so the choice doesn't depend on order.
+	if a.Deleted != b.Deleted {
+		return a.Deleted
+	}
+	return a.Message.Hash() > b.Message.Hash()
+}
+
+// HistoryMessages returns the messages of the entries that aren't deleted, for Conversation.SetHistory.
+func HistoryMessages(entries []HistoryEntry) []Message {
+	var messages []Message
+	for _, e := range entries {
+		if !e.Deleted {
+			messages = append(messages, e.Message)
+		}
+	}
+	return messages
+}
+
+// SetHistory replaces the questions and replies so far, such as with a history synced from another device.
+// The system prompt is kept.
+func (conv *Conversation) SetHistory(messages []Message) {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	conv.history = slices.Clone(messages)
+}
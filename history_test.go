@@ -0,0 +1,62 @@
+package opencat_api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeHistories(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := func(id string, created, minute int, role Role, content string) HistoryEntry {
+		return HistoryEntry{
+			ID:      id,
+			Created: t0.Add(time.Duration(created) * time.Minute),
+			Time:    t0.Add(time.Duration(minute) * time.Minute),
+			Message: Message{Role: role, Content: content},
+		}
+	}
+	local := []HistoryEntry{
+		entry("1", 0, 0, RoleUser, "Hi"),
+		entry("2", 1, 1, RoleAssistant, "Hello!"),
+		entry("3", 2, 2, RoleUser, "Tell me a joke"),
+	}
+	remote := []HistoryEntry{
+		entry("1", 0, 0, RoleUser, "Hi"),
+		entry("2", 1, 5, RoleAssistant, "Hello, how can I help?"),
+		entry("4", 3, 3, RoleUser, "From my phone"),
+	}
+	deleted := entry("3", 2, 4, RoleUser, "Tell me a joke")
+	deleted.Deleted = true
+	remote = append(remote, deleted)
+
+	diff := DiffHistories(local, remote)
+	if len(diff.Added) != 1 || diff.Added[0].ID != "4" || len(diff.Changed) != 2 || len(diff.Removed) != 0 {
+		t.Errorf("unexpected diff: %+v", diff)
+	}
+	if !DiffHistories(local, local).Empty() {
+		t.Error("a history differs from itself")
+	}
+
+	merged := MergeHistories(local, remote)
+	if !reflect.DeepEqual(merged, MergeHistories(remote, local)) {
+		t.Error("merge depends on the order of the copies")
+	}
+	var ids []string
+	for _, e := range merged {
+		ids = append(ids, e.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"1", "2", "3", "4"}) {
+		t.Errorf("merged ids = %v", ids)
+	}
+	messages := HistoryMessages(merged)
+	if len(messages) != 3 || messages[1].Content != "Hello, how can I help?" {
+		t.Errorf("unexpected messages: %+v", messages)
+	}
+
+	conv := NewConversation(NewClient("token"), ChatModelGPT4)
+	conv.SetHistory(messages)
+	if n := len(conv.History()); n != 3 {
+		t.Errorf("history has %d messages, want 3", n)
+	}
+}
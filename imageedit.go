@@ -0,0 +1,272 @@
+package opencat_api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+)
+
+// ImageEditRequest edits an image following a prompt, see ImageEdit.
+type ImageEditRequest struct {
+	// Model is ImageModelDallE2 if empty. ImageModelStableDiffusionXL inpaints images too.
+	Model ImageModel
+	// Image is a PNG image of less than 4 MB, square for DALL-E. It can't be an image URL.
+	// Without a Mask, its transparent areas are edited.
+	Image Image
+	// Mask is an optional PNG image of the size of Image, whose transparent areas are edited by DALL-E,
+	// and whose white areas are edited by Stable Diffusion XL.
+	Mask *Image
+	// Prompt describes the edited image.
+	Prompt string
+	// Size is the width and height of the images edited by DALL-E: 256, 512 or 1024. Zero means 1024.
+	// Stable Diffusion XL keeps the size of Image.
+	Size int
+	// Num is the number of edited images, between 1 and 10. Zero means 1.
+	Num int
+	// ResponseFormat is ImageFormatData if empty.
+	ResponseFormat ImageFormat
+	// StableDiffusionXL are the parameters of Stable Diffusion XL.
+	StableDiffusionXL StableDiffusionXLParams
+}
+
+func (r ImageEditRequest) validate() error {
+	switch r.Model {
+	case "", ImageModelDallE2:
+	case ImageModelStableDiffusionXL:
+		if r.Size != 0 {
+			return errors.New("Stable Diffusion XL keeps the size of the image, size must be zero")
+		}
+		err := r.StableDiffusionXL.validate()
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("model %s can't edit images, use %s or %s", r.Model, ImageModelDallE2, ImageModelStableDiffusionXL)
+	}
+	if r.Prompt == "" {
+		return errors.New("image edit request needs a prompt")
+	}
+	if r.Image.url != "" || r.Mask != nil && r.Mask.url != "" {
+		return errors.New("image URLs can't be edited, the image must be uploaded")
+	}
+	if r.Image.r == nil && len(r.Image.data) == 0 {
+		return errors.New("image edit request needs an image")
+	}
+	return validateImageOutput(r.Size, r.Num, r.ResponseFormat)
+}
+
+// validateImageOutput validates the size, number and format of images edited or varied by DALL-E 2.
+func validateImageOutput(size, num int, format ImageFormat) error {
+	switch size {
+	case 0, 256, 512, 1024:
+	default:
+		return fmt.Errorf("size must be 256, 512 or 1024, got %d", size)
+	}
+	if num < 0 || num > 10 {
+		return fmt.Errorf("num must be between 1 and 10, got %d", num)
+	}
+	return format.validate()
+}
+
+// writeImageOutput writes the model, size, number and format of images as fields of form,
+// defaulting to 1 image of 1024x1024. Stable Diffusion XL images have no size.
+func writeImageOutput(form *multipart.Writer, model ImageModel, size, num int, format ImageFormat) error {
+	if size == 0 {
+		size = 1024
+	}
+	if num == 0 {
+		num = 1
+	}
+	fields := [][2]string{
+		{"model", string(model)},
+		{"n", strconv.Itoa(num)},
+	}
+	if model != ImageModelStableDiffusionXL {
+		fields = append(fields, [2]string{"size", fmt.Sprintf("%dx%d", size, size)})
+	}
+	if format != "" {
+		fields = append(fields, [2]string{"response_format", string(format)})
+	}
+	return writeFields(form, fields)
+}
+
+// writeFields writes fields to form, in order.
+func writeFields(form *multipart.Writer, fields [][2]string) error {
+	for _, field := range fields {
+		err := form.WriteField(field[0], field[1])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFields writes the parameters set in p as fields of form, with where the areas to inpaint are:
+// white in the mask, or transparent in the image if there is no mask.
+func (p StableDiffusionXLParams) writeFields(form *multipart.Writer, masked bool) error {
+	maskSource := "INIT_IMAGE_ALPHA"
+	if masked {
+		maskSource = "MASK_IMAGE_WHITE"
+	}
+	fields := [][2]string{{"mask_source", maskSource}}
+	if p.Steps != 0 {
+		fields = append(fields, [2]string{"steps", strconv.Itoa(p.Steps)})
+	}
+	if p.Sampler != "" {
+		fields = append(fields, [2]string{"sampler", string(p.Sampler)})
+	}
+	if p.StylePreset != "" {
+		fields = append(fields, [2]string{"style_preset", string(p.StylePreset)})
+	}
+	if p.Scale != 0 {
+		fields = append(fields, [2]string{"scale", strconv.Itoa(p.Scale)})
+	}
+	if p.Seed != 0 {
+		fields = append(fields, [2]string{"seed", strconv.FormatUint(uint64(p.Seed), 10)})
+	}
+	return writeFields(form, fields)
+}
+
+// writeImageFile writes an image as a PNG file field of form.
+func writeImageFile(form *multipart.Writer, field string, img Image) error {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename="%s.png"`, field, field))
+	mimeType := img.mimeType
+	if mimeType == "" {
+		mimeType = "image/png"
+	}
+	h.Set("Content-Type", mimeType)
+	part, err := form.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	r := img.r
+	if r == nil {
+		r = bytes.NewReader(img.data)
+	}
+	_, err = io.Copy(part, r)
+	return err
+}
+
+// ImageEdit edits an image following a prompt, in its transparent areas or those of a mask, which is known
+// as inpainting, and returns the edited images like Image.
+func (c *Client) ImageEdit(ctx context.Context, edit ImageEditRequest) ([]GeneratedImage, error) {
+	err := edit.validate()
+	if err != nil {
+		return nil, err
+	}
+	model := edit.Model
+	if model == "" {
+		model = ImageModelDallE2
+	}
+
+	u, err := newUpload(
+		func(form *multipart.Writer) error {
+			err := writeImageFile(form, "image", edit.Image)
+			if err != nil {
+				return err
+			}
+			if edit.Mask != nil {
+				err = writeImageFile(form, "mask", *edit.Mask)
+				if err != nil {
+					return err
+				}
+			}
+			err = form.WriteField("prompt", edit.Prompt)
+			if err != nil {
+				return err
+			}
+			if model == ImageModelStableDiffusionXL {
+				err = edit.StableDiffusionXL.writeFields(form, edit.Mask != nil)
+				if err != nil {
+					return err
+				}
+			}
+			return writeImageOutput(form, model, edit.Size, edit.Num, edit.ResponseFormat)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer u.Close()
+	return c.uploadImage(ctx, "/1/images/edits", model, u)
+}
+
+// uploadImage sends an image upload and returns the images of the response.
+func (c *Client) uploadImage(ctx context.Context, path string, model ImageModel, u *upload) ([]GeneratedImage, error) {
+	req, err := c.newUploadRequest(ctx, path, u)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req, string(model))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewAPIError(resp)
+	}
+	return decodeImages(resp)
+}
+
+// ImageVariationRequest asks for variations of an image, see ImageVariations.
+type ImageVariationRequest struct {
+	// Model is ImageModelDallE2 if empty, the only model that varies images.
+	Model ImageModel
+	// Image is a square PNG image of less than 4 MB. It can't be an image URL.
+	Image Image
+	// Size is the width and height of the variations: 256, 512 or 1024. Zero means 1024.
+	Size int
+	// Num is the number of variations, between 1 and 10. Zero means 1.
+	Num int
+	// ResponseFormat is ImageFormatData if empty.
+	ResponseFormat ImageFormat
+}
+
+func (r ImageVariationRequest) validate() error {
+	if r.Model != "" && r.Model != ImageModelDallE2 {
+		return fmt.Errorf("model %s can't vary images, use %s", r.Model, ImageModelDallE2)
+	}
+	if r.Image.url != "" {
+		return errors.New("image URLs can't be varied, the image must be uploaded")
+	}
+	if r.Image.r == nil && len(r.Image.data) == 0 {
+		return errors.New("image variation request needs an image")
+	}
+	return validateImageOutput(r.Size, r.Num, r.ResponseFormat)
+}
+
+// ImageVariations returns variations of an image, like Image.
+func (c *Client) ImageVariations(ctx context.Context, variation ImageVariationRequest) ([]GeneratedImage, error) {
+	err := variation.validate()
+	if err != nil {
+		return nil, err
+	}
+	model := variation.Model
+	if model == "" {
+		model = ImageModelDallE2
+	}
+
+	u, err := newUpload(
+		func(form *multipart.Writer) error {
+			err := writeImageFile(form, "image", variation.Image)
+			if err != nil {
+				return err
+			}
+			return writeImageOutput(form, model, variation.Size, variation.Num, variation.ResponseFormat)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer u.Close()
+	return c.uploadImage(ctx, "/1/images/variations", model, u)
+}
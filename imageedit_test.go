@@ -0,0 +1,384 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImageEdit(t *testing.T) {
+	var path string
+	var fields map[string]string
+	files := map[string]string{}
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			err := r.ParseMultipartForm(1 << 20)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fields = map[string]string{}
+			for name, values := range r.MultipartForm.Value {
+				fields[name] = values[0]
+			}
+			for name, headers := range r.MultipartForm.File {
+				f, _ := headers[0].Open()
+				data, _ := io.ReadAll(f)
+				files[name] = headers[0].Header.Get("Content-Type") + ":" + string(data)
+			}
+			io.WriteString(w, `{"image_data": ["ZWRpdGVk"]}`)
+		},
+	)
+
+	mask := NewImageFromBytes([]byte("mask"))
+	imgs, err := c.ImageEdit(
+		context.Background(), ImageEditRequest{
+			Image:  NewImage(strings.NewReader("image")),
+			Mask:   &mask,
+			Prompt: "add a hat",
+			Size:   512,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imgs) != 1 || string(imgs[0].Data) != "edited" {
+		t.Errorf("unexpected images %+v", imgs)
+	}
+	if path != "/1/images/edits" {
+		t.Errorf("unexpected path %s", path)
+	}
+	if fields["model"] != "dall-e-2" || fields["prompt"] != "add a hat" || fields["n"] != "1" || fields["size"] != "512x512" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	if files["image"] != "image/png:image" || files["mask"] != "image/png:mask" {
+		t.Errorf("unexpected files %v", files)
+	}
+
+	for _, edit := range []ImageEditRequest{
+		{Image: NewImageFromBytes([]byte("image"))},
+		{Image: NewImageURL("https://example.com/a.png"), Prompt: "add a hat"},
+		{Image: NewImageFromBytes([]byte("image")), Prompt: "add a hat", Model: ImageModelDallE3},
+		{Image: NewImageFromBytes([]byte("image")), Prompt: "add a hat", Size: 300},
+	} {
+		_, err = c.ImageEdit(context.Background(), edit)
+		if err == nil {
+			t.Errorf("expected an error for %+v", edit)
+		}
+	}
+}
+
+func TestImageVariations(t *testing.T) {
+	var path string
+	var fields map[string]string
+	var image string
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			err := r.ParseMultipartForm(1 << 20)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fields = map[string]string{}
+			for name, values := range r.MultipartForm.Value {
+				fields[name] = values[0]
+			}
+			file, _, _ := r.FormFile("image")
+			data, _ := io.ReadAll(file)
+			image = string(data)
+			io.WriteString(w, `{"image_data": ["b25l", "dHdv"]}`)
+		},
+	)
+
+	imgs, err := c.ImageVariations(
+		context.Background(), ImageVariationRequest{Image: NewImageFromBytes([]byte("image")), Size: 256, Num: 2},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imgs) != 2 || string(imgs[0].Data) != "one" || string(imgs[1].Data) != "two" {
+		t.Errorf("unexpected images %+v", imgs)
+	}
+	if path != "/1/images/variations" || image != "image" {
+		t.Errorf("unexpected upload of %q to %s", image, path)
+	}
+	if fields["model"] != "dall-e-2" || fields["n"] != "2" || fields["size"] != "256x256" || fields["prompt"] != "" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+
+	_, err = c.ImageVariations(context.Background(), ImageVariationRequest{Image: NewImageFromBytes([]byte("image")), Num: 11})
+	if err == nil {
+		t.Error("expected an error for too many variations")
+	}
+}
+
+func TestImageResponseFormat(t *testing.T) {
+	var format string
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				ResponseFormat string `json:"response_format"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			format = req.ResponseFormat
+			io.WriteString(
+				w, `{"data": [{"url": "https://example.com/1.png", "revised_prompt": "A fluffy kitten in a wicker basket"}]}`,
+			)
+		},
+	)
+
+	imgs, err := c.Image(
+		context.Background(),
+		ImageRequest{Model: ImageModelDallE3, Prompt: "a kitten in a basket", ResponseFormat: ImageFormatURL},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []GeneratedImage{{URL: "https://example.com/1.png", RevisedPrompt: "A fluffy kitten in a wicker basket"}}
+	if !reflect.DeepEqual(imgs, want) || format != "url" {
+		t.Errorf("unexpected images %+v in format %q", imgs, format)
+	}
+
+	_, err = c.Image(context.Background(), ImageRequest{Model: ImageModelDallE3, Prompt: "a kitten", ResponseFormat: "png"})
+	if err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestImageMetadata(t *testing.T) {
+	var body string
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, body)
+		},
+	)
+
+	tests := []struct {
+		body string
+		want []GeneratedImage
+	}{
+		{
+			`{"data": [{"b64_json": "b25l", "revised_prompt": "A kitten", "content_filter_results": ` +
+				`{"violence": {"filtered": true, "severity": "medium"}, "hate": {"filtered": false, "severity": "safe"}}}]}`,
+			[]GeneratedImage{
+				{Data: []byte("one"), RevisedPrompt: "A kitten", Filtered: true, FilterCategories: []string{"violence"}},
+			},
+		},
+		{
+			`{"artifacts": [{"base64": "b25l", "seed": 42, "finishReason": "SUCCESS"}, ` +
+				`{"base64": "dHdv", "seed": 43, "finishReason": "CONTENT_FILTERED"}]}`,
+			[]GeneratedImage{{Data: []byte("one"), Seed: 42}, {Data: []byte("two"), Seed: 43, Filtered: true}},
+		},
+	}
+	for _, tt := range tests {
+		body = tt.body
+		imgs, err := c.Image(context.Background(), ImageRequest{Model: ImageModelDallE3, Prompt: "a kitten"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(imgs, tt.want) {
+			t.Errorf("got %+v, want %+v", imgs, tt.want)
+		}
+	}
+}
+
+func TestImageSize(t *testing.T) {
+	var size string
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var req ImageRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			size = fmt.Sprintf("%dx%d", req.Width, req.Height)
+			io.WriteString(w, `{"image_data": ["b25l"]}`)
+		},
+	)
+
+	_, err := c.Image(context.Background(), ImageRequest{Model: ImageModelDallE3, Prompt: "a kitten"})
+	if err != nil || size != "1024x1024" {
+		t.Errorf("default size %s, %v", size, err)
+	}
+	_, err = c.Image(context.Background(), ImageRequest{Model: ImageModelDallE3, Prompt: "a kitten", Width: 1792, Height: 1024})
+	if err != nil || size != "1792x1024" {
+		t.Errorf("size %s, %v", size, err)
+	}
+
+	for _, req := range []ImageRequest{
+		{Model: ImageModelDallE3, Prompt: "a kitten", Width: 512, Height: 512},
+		{Model: ImageModelDallE2, Prompt: "a kitten", Width: 1792, Height: 1024},
+		{Model: ImageModelDallE3, Prompt: "a kitten", Num: 2},
+		{Model: ImageModelDallE3, Prompt: "a kitten", DallE: DallEParams{Quality: "ultra"}},
+		{Model: ImageModelDallE3},
+	} {
+		_, err = c.Image(context.Background(), req)
+		if err == nil {
+			t.Errorf("expected an error for %+v", req)
+		}
+	}
+	_, err = c.Image(context.Background(), ImageRequest{Model: ImageModelDallE3, Prompt: "a kitten", Width: 800, Height: 600})
+	if err == nil || !strings.Contains(err.Error(), "1024x1024, 1792x1024, 1024x1792") {
+		t.Errorf("error doesn't list the supported sizes: %v", err)
+	}
+}
+
+func TestStableDiffusionXLParams(t *testing.T) {
+	valid := StableDiffusionXLParams{
+		Steps: 30, Sampler: SDXLSamplerEulerAncestral, StylePreset: SDXLStylePhotographic, Scale: 7, Seed: 42,
+	}
+	body, err := json.Marshal(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"steps":30,"sampler":"K_EULER_ANCESTRAL","style_preset":"photographic","scale":7,"seed":42}`
+	if string(body) != want {
+		t.Errorf("got %s, want %s", body, want)
+	}
+
+	for _, p := range []StableDiffusionXLParams{valid, {}} {
+		err = ImageRequest{Model: ImageModelStableDiffusionXL, Prompt: "a kitten", StableDiffusionXL: p}.validate()
+		if err != nil {
+			t.Errorf("%+v: %v", p, err)
+		}
+	}
+	for _, p := range []StableDiffusionXLParams{
+		{Steps: 5},
+		{Steps: 100},
+		{Scale: 36},
+		{Sampler: "euler"},
+		{StylePreset: "watercolor"},
+	} {
+		err = ImageRequest{Model: ImageModelStableDiffusionXL, Prompt: "a kitten", StableDiffusionXL: p}.validate()
+		if err == nil {
+			t.Errorf("expected an error for %+v", p)
+		}
+	}
+}
+
+func TestImageSeed(t *testing.T) {
+	var seeds [2]uint32
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var req ImageRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			seeds = [2]uint32{req.Seed, req.StableDiffusionXL.Seed}
+			io.WriteString(w, `{"image_data": ["b25l"]}`)
+		},
+	)
+
+	imgs, err := c.Image(
+		context.Background(),
+		ImageRequest{Model: ImageModelStableDiffusionXL, Prompt: "a kitten", Width: 1024, Height: 1024, Seed: 42},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seeds != [2]uint32{42, 42} || imgs[0].Seed != 42 {
+		t.Errorf("seed not sent or returned: sent %v, got %d", seeds, imgs[0].Seed)
+	}
+
+	for _, req := range []ImageRequest{
+		{Model: ImageModelDallE3, Prompt: "a kitten", Seed: 42},
+		{Model: ImageModelStableDiffusionXL, Prompt: "a kitten", Seed: 42, StableDiffusionXL: StableDiffusionXLParams{Seed: 7}},
+	} {
+		_, err = c.Image(context.Background(), req)
+		if err == nil {
+			t.Errorf("expected an error for %+v", req)
+		}
+	}
+}
+
+func TestImageInitImage(t *testing.T) {
+	var body map[string]any
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			io.WriteString(w, `{"image_data": ["b25l"]}`)
+		},
+	)
+
+	init := NewImage(strings.NewReader("image")).WithMIMEType("image/png")
+	_, err := c.Image(
+		context.Background(), ImageRequest{
+			Model: ImageModelStableDiffusionXL, Prompt: "as a watercolor", Width: 1024, Height: 1024,
+			InitImage: &init, Strength: 0.6,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body["init_image"] != "data:image/png;base64,aW1hZ2U=" || body["image_strength"] != 0.6 {
+		t.Errorf("init image not sent: %v, %v", body["init_image"], body["image_strength"])
+	}
+
+	url := NewImageURL("https://example.com/a.png")
+	for _, req := range []ImageRequest{
+		{Model: ImageModelDallE3, Prompt: "a kitten", InitImage: &init},
+		{Model: ImageModelStableDiffusionXL, Prompt: "a kitten", InitImage: &url},
+		{Model: ImageModelStableDiffusionXL, Prompt: "a kitten", Strength: 0.5},
+		{Model: ImageModelStableDiffusionXL, Prompt: "a kitten", InitImage: &init, Strength: 1.5},
+	} {
+		_, err = c.Image(context.Background(), req)
+		if err == nil {
+			t.Errorf("expected an error for %+v", req)
+		}
+	}
+}
+
+func TestImageEditStableDiffusionXL(t *testing.T) {
+	var fields map[string]string
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			err := r.ParseMultipartForm(1 << 20)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fields = map[string]string{}
+			for name, values := range r.MultipartForm.Value {
+				fields[name] = values[0]
+			}
+			io.WriteString(w, `{"artifacts": [{"base64": "ZWRpdGVk", "seed": 42, "finishReason": "SUCCESS"}]}`)
+		},
+	)
+
+	mask := NewImageFromBytes([]byte("mask"))
+	edit := ImageEditRequest{
+		Model:             ImageModelStableDiffusionXL,
+		Image:             NewImageFromBytes([]byte("image")),
+		Mask:              &mask,
+		Prompt:            "add a hat",
+		StableDiffusionXL: StableDiffusionXLParams{Steps: 30, Sampler: SDXLSamplerEuler, Seed: 42},
+	}
+	imgs, err := c.ImageEdit(context.Background(), edit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imgs) != 1 || string(imgs[0].Data) != "edited" || imgs[0].Seed != 42 {
+		t.Errorf("unexpected images %+v", imgs)
+	}
+	want := map[string]string{
+		"model": "stable_diffusion_xl", "prompt": "add a hat", "n": "1", "mask_source": "MASK_IMAGE_WHITE",
+		"steps": "30", "sampler": "K_EULER", "seed": "42",
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("got fields %v, want %v", fields, want)
+	}
+
+	edit.Mask = nil
+	_, err = c.ImageEdit(context.Background(), edit)
+	if err != nil || fields["mask_source"] != "INIT_IMAGE_ALPHA" {
+		t.Errorf("unexpected mask source %q, %v", fields["mask_source"], err)
+	}
+
+	edit.Size = 512
+	_, err = c.ImageEdit(context.Background(), edit)
+	if err == nil {
+		t.Error("expected an error for a size")
+	}
+}
@@ -0,0 +1,227 @@
+package opencat_api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// imageJobRetention is how long a finished image job can still be polled.
+const imageJobRetention = time.Hour
+
+// ImageJobState is the state of an image job, see StartImage.
+type ImageJobState string
+
+const (
+	ImageJobRunning ImageJobState = "running"
+	ImageJobDone    ImageJobState = "done"
+	ImageJobFailed  ImageJobState = "failed"
+	// ImageJobCanceled means the job was stopped by CancelImage.
+	ImageJobCanceled ImageJobState = "canceled"
+)
+
+// ImageJobStatus is the progress of an image job.
+type ImageJobStatus struct {
+	ID    string
+	State ImageJobState
+	// Done is the number of images generated so far, out of Total.
+	Done, Total int
+	// Images are the images generated so far, all of them once the job is done.
+	Images []GeneratedImage
+	// Err is why the job failed or was canceled.
+	Err      error
+	Started  time.Time
+	Finished time.Time
+}
+
+type imageJobOptions struct {
+	progress func(ImageJobStatus)
+}
+
+// ImageJobOption adjusts an image job.
+type ImageJobOption func(*imageJobOptions)
+
+// WithImageProgress calls fn with the status of the job when it starts, after each request, and when it ends,
+// so a UI can show the progress. fn is called from the goroutine of the job.
+func WithImageProgress(fn func(ImageJobStatus)) ImageJobOption {
+	return func(o *imageJobOptions) {
+		o.progress = fn
+	}
+}
+
+type imageJob struct {
+	status ImageJobStatus
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// imageJobs are the image jobs of a client.
+type imageJobs struct {
+	mu   sync.Mutex
+	jobs map[string]*imageJob
+}
+
+// add registers a new job, forgetting the jobs finished more than imageJobRetention ago.
+func (j *imageJobs) add(job *imageJob, now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.jobs == nil {
+		j.jobs = map[string]*imageJob{}
+	}
+	for id, old := range j.jobs {
+		if old.status.State != ImageJobRunning && now.Sub(old.status.Finished) > imageJobRetention {
+			delete(j.jobs, id)
+		}
+	}
+	j.jobs[job.status.ID] = job
+}
+
+func (j *imageJobs) get(id string) (*imageJob, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job, ok := j.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown image job %s", id)
+	}
+	return job, nil
+}
+
+// update changes the status of a job and returns a copy of it.
+func (j *imageJobs) update(job *imageJob, fn func(status *ImageJobStatus)) ImageJobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(&job.status)
+	status := job.status
+	status.Images = append([]GeneratedImage(nil), status.Images...)
+	return status
+}
+
+// imagesPerRequest is the number of images a model generates in one request, 0 if it is not limited.
+func imagesPerRequest(model ImageModel) int {
+	if model == ImageModelDallE3 {
+		return 1
+	}
+	return 0
+}
+
+// StartImage starts generating images in the background and returns the ID of the job, to follow it with
+// PollImage or WaitImage. OpenCat generates images synchronously, so the job is run by the client: images
+// a model can't generate in one request, like several images of DALL-E 3, are generated by a request each,
+// and the progress is reported as they finish. The job outlives ctx, which usually belongs to the request
+// that started it, and keeps its values; stop it with CancelImage.
+//
+// Finished jobs can be polled for an hour.
+func (c *Client) StartImage(ctx context.Context, image ImageRequest, opts ...ImageJobOption) (string, error) {
+	var o imageJobOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	total := max(image.Num, 1)
+	perRequest := imagesPerRequest(image.Model)
+	if perRequest == 0 {
+		perRequest = total
+	}
+	// Validate the requests before starting, so mistakes are returned right away.
+	first := image
+	first.Num = min(total, perRequest)
+	if _, ok := imageSizes[first.Model]; ok && first.Width == 0 && first.Height == 0 {
+		first.Width, first.Height = 1024, 1024
+	}
+	err := first.validate()
+	if err != nil {
+		return "", err
+	}
+
+	id := newSessionID()
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	job := &imageJob{
+		status: ImageJobStatus{ID: id, State: ImageJobRunning, Total: total, Started: c.clock.Now()},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	c.imageJobs.add(job, job.status.Started)
+	progress := func(status ImageJobStatus) {
+		if o.progress != nil {
+			o.progress(status)
+		}
+	}
+	progress(c.imageJobs.update(job, func(*ImageJobStatus) {}))
+
+	go func() {
+		defer close(job.done)
+		defer cancel()
+		for done := 0; done < total; {
+			req := image
+			req.Num = min(total-done, perRequest)
+			images, err := c.Image(ctx, req)
+			if err == nil && len(images) == 0 {
+				err = fmt.Errorf("no images generated by %s", image.Model)
+			}
+			if err != nil {
+				state := ImageJobFailed
+				if ctx.Err() != nil {
+					state, err = ImageJobCanceled, ctx.Err()
+				}
+				progress(
+					c.imageJobs.update(
+						job, func(status *ImageJobStatus) {
+							status.State, status.Err, status.Finished = state, err, c.clock.Now()
+						},
+					),
+				)
+				return
+			}
+			// Models may generate fewer images than asked, the next request asks for the rest.
+			done += len(images)
+			progress(
+				c.imageJobs.update(
+					job, func(status *ImageJobStatus) {
+						status.Images = append(status.Images, images...)
+						status.Done = min(done, total)
+						if done >= total {
+							status.State, status.Finished = ImageJobDone, c.clock.Now()
+						}
+					},
+				),
+			)
+		}
+	}()
+	return id, nil
+}
+
+// CancelImage stops an image job started with StartImage. The images generated so far are kept,
+// the job ends in ImageJobCanceled unless it already finished.
+func (c *Client) CancelImage(id string) error {
+	job, err := c.imageJobs.get(id)
+	if err != nil {
+		return err
+	}
+	job.cancel()
+	return nil
+}
+
+// PollImage returns the status of an image job started with StartImage.
+func (c *Client) PollImage(id string) (ImageJobStatus, error) {
+	job, err := c.imageJobs.get(id)
+	if err != nil {
+		return ImageJobStatus{}, err
+	}
+	return c.imageJobs.update(job, func(*ImageJobStatus) {}), nil
+}
+
+// WaitImage waits for an image job started with StartImage to finish and returns its images,
+// or the error it failed with.
+func (c *Client) WaitImage(ctx context.Context, id string) ([]GeneratedImage, error) {
+	job, err := c.imageJobs.get(id)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-job.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	status := c.imageJobs.update(job, func(*ImageJobStatus) {})
+	return status.Images, status.Err
+}
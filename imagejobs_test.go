@@ -0,0 +1,135 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestImageJob(t *testing.T) {
+	var mu sync.Mutex
+	var requests []int
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var req ImageRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			mu.Lock()
+			requests = append(requests, req.Num)
+			n := len(requests)
+			mu.Unlock()
+			if req.Prompt == "fail" && n == 2 {
+				http.Error(w, `{"error": {"message": "content policy violation"}}`, http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, `{"data": [{"b64_json": "b25l", "revised_prompt": "image %d"}]}`, n)
+		},
+	)
+
+	var progress []string
+	id, err := c.StartImage(
+		context.Background(), ImageRequest{Model: ImageModelDallE3, Prompt: "a kitten", Num: 3},
+		WithImageProgress(
+			func(status ImageJobStatus) {
+				progress = append(progress, fmt.Sprintf("%s %d/%d", status.State, status.Done, status.Total))
+			},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgs, err := c.WaitImage(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imgs) != 3 || imgs[2].RevisedPrompt != "image 3" || fmt.Sprint(requests) != "[1 1 1]" {
+		t.Errorf("unexpected images %+v from requests %v", imgs, requests)
+	}
+	if want := "[running 0/3 running 1/3 running 2/3 done 3/3]"; fmt.Sprint(progress) != want {
+		t.Errorf("got progress %v, want %s", progress, want)
+	}
+	status, err := c.PollImage(id)
+	if err != nil || status.State != ImageJobDone || len(status.Images) != 3 || status.Finished.IsZero() {
+		t.Errorf("unexpected status %+v, %v", status, err)
+	}
+
+	requests = nil
+	id, err = c.StartImage(context.Background(), ImageRequest{Model: ImageModelDallE3, Prompt: "fail", Num: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.WaitImage(context.Background(), id)
+	status, _ = c.PollImage(id)
+	if err == nil || status.State != ImageJobFailed || status.Done != 1 || len(status.Images) != 1 {
+		t.Errorf("unexpected status %+v after %v", status, err)
+	}
+
+	_, err = c.StartImage(context.Background(), ImageRequest{Model: ImageModelDallE3, Prompt: "a kitten", Width: 10, Height: 10})
+	if err == nil {
+		t.Error("expected an error for an invalid request")
+	}
+	_, err = c.PollImage("missing")
+	if err == nil {
+		t.Error("expected an error for an unknown job")
+	}
+}
+
+func TestImageJobLifecycle(t *testing.T) {
+	var mu sync.Mutex
+	var requests []int
+	block := make(chan struct{})
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var req ImageRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			mu.Lock()
+			requests = append(requests, req.Num)
+			mu.Unlock()
+			if req.Prompt == "slow" {
+				select {
+				case <-block:
+				case <-r.Context().Done():
+				}
+				return
+			}
+			// One image at a time, whatever the number asked.
+			fmt.Fprint(w, `{"data": [{"b64_json": "b25l"}]}`)
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	id, err := c.StartImage(ctx, ImageRequest{Model: ImageModelDallE2, Prompt: "a kitten", Num: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The job outlives the context it was started with.
+	cancel()
+	imgs, err := c.WaitImage(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imgs) != 3 || fmt.Sprint(requests) != "[3 2 1]" {
+		t.Errorf("unexpected images %+v from requests %v", imgs, requests)
+	}
+
+	id, err = c.StartImage(context.Background(), ImageRequest{Model: ImageModelDallE2, Prompt: "slow", Num: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.CancelImage(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.WaitImage(context.Background(), id)
+	status, _ := c.PollImage(id)
+	if !errors.Is(err, context.Canceled) || status.State != ImageJobCanceled {
+		t.Errorf("unexpected status %+v after %v", status, err)
+	}
+	close(block)
+	if c.CancelImage("missing") == nil {
+		t.Error("expected an error for an unknown job")
+	}
+}
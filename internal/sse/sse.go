@@ -0,0 +1,140 @@
+// Package sse implements a decoder for the text/event-stream format.
+// https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a single dispatched server-sent event.
+type Event struct {
+	// ID is the last event ID seen on the stream when this event was dispatched.
+	ID string
+	// Event is the event type, "message" if the stream did not set one.
+	Event string
+	// Data is the event payload, multiple data lines are joined with "\n".
+	Data string
+}
+
+// Decoder reads events from an event stream.
+type Decoder struct {
+	r       *bufio.Reader
+	started bool
+	lastID  string
+	retry   time.Duration
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Retry returns the reconnection time last sent by the server, or 0 if none.
+func (d *Decoder) Retry() time.Duration {
+	return d.retry
+}
+
+// Next returns the next event in the stream.
+// It returns io.EOF when the stream ends, an incomplete trailing event is discarded.
+func (d *Decoder) Next() (Event, error) {
+	var (
+		data      bytes.Buffer
+		eventType string
+	)
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return Event{}, err
+		}
+
+		if len(line) == 0 {
+			if data.Len() == 0 {
+				eventType = ""
+				continue
+			}
+			if eventType == "" {
+				eventType = "message"
+			}
+			return Event{
+				ID:    d.lastID,
+				Event: eventType,
+				Data:  strings.TrimSuffix(data.String(), "\n"),
+			}, nil
+		}
+
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value := line, []byte(nil)
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], line[i+1:]
+			value = bytes.TrimPrefix(value, []byte(" "))
+		}
+
+		switch string(field) {
+		case "event":
+			eventType = string(value)
+		case "data":
+			data.Write(value)
+			data.WriteByte('\n')
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				d.lastID = string(value)
+			}
+		case "retry":
+			if !isDigits(value) {
+				continue
+			}
+			if ms, err := strconv.ParseUint(string(value), 10, 32); err == nil {
+				d.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// readLine reads a line terminated by "\r\n", "\n" or "\r", without the terminator.
+func (d *Decoder) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			// A line not terminated by EOL is never processed.
+			return nil, err
+		}
+		if !d.started {
+			d.started = true
+			// Skip a leading UTF-8 BOM.
+			if b == 0xEF {
+				bom, _ := d.r.Peek(2)
+				if bytes.Equal(bom, []byte{0xBB, 0xBF}) {
+					_, _ = d.r.Discard(2)
+					continue
+				}
+			}
+		}
+		switch b {
+		case '\n':
+			return line, nil
+		case '\r':
+			if next, err := d.r.Peek(1); err == nil && next[0] == '\n' {
+				_, _ = d.r.Discard(1)
+			}
+			return line, nil
+		}
+		line = append(line, b)
+	}
+}
+
+func isDigits(b []byte) bool {
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return len(b) > 0
+}
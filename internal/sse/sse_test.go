@@ -0,0 +1,140 @@
+package sse
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decodeAll(t testing.TB, s string) []Event {
+	dec := NewDecoder(strings.NewReader(s))
+	var events []Event
+	for {
+		ev, err := dec.Next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Fatal(err)
+			}
+			return events
+		}
+		events = append(events, ev)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Event
+	}{
+		{
+			name:  "single",
+			input: "data: {\"delta\":\"hi\"}\n\n",
+			want:  []Event{{Event: "message", Data: `{"delta":"hi"}`}},
+		},
+		{
+			name:  "comments",
+			input: ": keepalive\n\ndata: a\n: ping\n\n",
+			want:  []Event{{Event: "message", Data: "a"}},
+		},
+		{
+			name:  "multi-line data",
+			input: "data: {\ndata: \"a\": 1\ndata: }\n\n",
+			want:  []Event{{Event: "message", Data: "{\n\"a\": 1\n}"}},
+		},
+		{
+			name:  "event and id",
+			input: "event: completion\nid: 1\ndata: x\n\nevent: ping\ndata: {}\n\ndata: y\n\n",
+			want: []Event{
+				{ID: "1", Event: "completion", Data: "x"},
+				{ID: "1", Event: "ping", Data: "{}"},
+				{ID: "1", Event: "message", Data: "y"},
+			},
+		},
+		{
+			name:  "line endings",
+			input: "data: a\r\n\r\ndata: b\r\rdata: c\n\n",
+			want: []Event{
+				{Event: "message", Data: "a"},
+				{Event: "message", Data: "b"},
+				{Event: "message", Data: "c"},
+			},
+		},
+		{
+			name:  "no space and empty field",
+			input: "data:a\ndata\n\n",
+			want:  []Event{{Event: "message", Data: "a\n"}},
+		},
+		{
+			name:  "event without data is not dispatched",
+			input: "event: ping\n\ndata: a\n\n",
+			want:  []Event{{Event: "message", Data: "a"}},
+		},
+		{
+			name:  "incomplete trailing event",
+			input: "data: a\n\ndata: b\n",
+			want:  []Event{{Event: "message", Data: "a"}},
+		},
+		{
+			name:  "bom",
+			input: "\xEF\xBB\xBFdata: a\n\n",
+			want:  []Event{{Event: "message", Data: "a"}},
+		},
+		{
+			name:  "id with null is ignored",
+			input: "id: 1\ndata: a\n\nid: 2\x003\ndata: b\n\n",
+			want: []Event{
+				{ID: "1", Event: "message", Data: "a"},
+				{ID: "1", Event: "message", Data: "b"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got := decodeAll(t, tt.input)
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestDecoderRetry(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("retry: 1500\ndata: a\n\nretry: 1x\ndata: b\n\n"))
+	for i := 0; i < 2; i++ {
+		if _, err := dec.Next(); err != nil {
+			t.Fatal(err)
+		}
+		if got := dec.Retry(); got != 1500*time.Millisecond {
+			t.Errorf("retry = %v, want 1.5s", got)
+		}
+	}
+}
+
+func FuzzDecoder(f *testing.F) {
+	f.Add("data: a\n\n")
+	f.Add(": keepalive\nevent: completion\ndata: {\"completion\":\"x\"}\n\n")
+	f.Add("data: a\ndata: b\r\n\r\nid: 1\rretry: 10\r\r")
+	f.Add("\xEF\xBB\xBFdata\n\n")
+	f.Fuzz(
+		func(t *testing.T, input string) {
+			events := decodeAll(t, input)
+			// Normalizing line endings must not change the result.
+			normalized := strings.ReplaceAll(input, "\r\n", "\n")
+			normalized = strings.ReplaceAll(normalized, "\r", "\n")
+			if got := decodeAll(t, normalized); !reflect.DeepEqual(got, events) {
+				t.Errorf("CRLF and LF streams decoded differently: %q vs %q", events, got)
+			}
+			for _, ev := range events {
+				if ev.Event == "" {
+					t.Errorf("event type not defaulted: %q", ev)
+				}
+			}
+		},
+	)
+}
@@ -0,0 +1,420 @@
+// Package toml decodes the subset of TOML used by configuration files: tables, arrays of tables,
+// dotted and quoted keys, strings, integers, floats, booleans, arrays and inline tables.
+// https://toml.io/en/v1.0.0
+//
+// Multi-line strings and dates are not supported.
+package toml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SyntaxError is an invalid or unsupported document.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Unmarshal decodes a document. Tables are map[string]any, arrays []any, integers int64 and floats float64.
+func Unmarshal(data []byte) (map[string]any, error) {
+	p := &parser{data: data, line: 1, defined: map[string]bool{}}
+	root := map[string]any{}
+	current := root
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return root, nil
+		}
+		var err error
+		if p.peek() == '[' {
+			current, err = p.table(root)
+		} else {
+			err = p.keyValue(current)
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		p.skipComment()
+		if !p.eof() && p.peek() != '\n' && p.peek() != '\r' {
+			return nil, p.errorf("expected a new line, got %q", p.peek())
+		}
+	}
+}
+
+type parser struct {
+	data []byte
+	pos  int
+	line int
+	// defined are the tables defined by a header, by their path.
+	defined map[string]bool
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &SyntaxError{Line: p.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+// peek returns the next byte, or 0 at the end.
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.data[p.pos]
+}
+
+func (p *parser) consume(c byte) bool {
+	if p.peek() != c {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+func (p *parser) skipSpace() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+func (p *parser) skipComment() {
+	if p.peek() != '#' {
+		return
+	}
+	for !p.eof() && p.peek() != '\n' {
+		p.pos++
+	}
+}
+
+// skipBlank skips spaces, comments and new lines.
+func (p *parser) skipBlank() {
+	for {
+		p.skipSpace()
+		p.skipComment()
+		switch p.peek() {
+		case '\n':
+			p.line++
+		case '\r':
+		default:
+			return
+		}
+		p.pos++
+	}
+}
+
+// table parses a table header and returns the table it starts.
+func (p *parser) table(root map[string]any) (map[string]any, error) {
+	p.pos++
+	array := p.consume('[')
+	keys, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	if !p.consume(']') || array && !p.consume(']') {
+		return nil, p.errorf("unterminated table header")
+	}
+	parent, err := p.descend(root, keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+	name := keys[len(keys)-1]
+	path := strings.Join(keys, ".")
+	if array {
+		tables, ok := parent[name].([]any)
+		if _, exists := parent[name]; exists && !ok {
+			return nil, p.errorf("%s is not an array of tables", path)
+		}
+		t := map[string]any{}
+		parent[name] = append(tables, t)
+		return t, nil
+	}
+	if p.defined[path] {
+		return nil, p.errorf("table %s is defined twice", path)
+	}
+	p.defined[path] = true
+	switch v := parent[name].(type) {
+	case nil:
+		t := map[string]any{}
+		parent[name] = t
+		return t, nil
+	case map[string]any:
+		return v, nil
+	default:
+		return nil, p.errorf("%s is not a table", path)
+	}
+}
+
+// descend returns the table at the path of keys under t, creating the missing ones.
+// An array of tables stands for its last table.
+func (p *parser) descend(t map[string]any, keys []string) (map[string]any, error) {
+	for _, k := range keys {
+		switch v := t[k].(type) {
+		case nil:
+			next := map[string]any{}
+			t[k] = next
+			t = next
+		case map[string]any:
+			t = v
+		case []any:
+			last, ok := any(nil), false
+			if len(v) > 0 {
+				last = v[len(v)-1]
+			}
+			if t, ok = last.(map[string]any); !ok {
+				return nil, p.errorf("%s is not a table", k)
+			}
+		default:
+			return nil, p.errorf("%s is not a table", k)
+		}
+	}
+	return t, nil
+}
+
+// keyValue parses a key/value pair into t.
+func (p *parser) keyValue(t map[string]any) error {
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	if !p.consume('=') {
+		return p.errorf("expected = after key %s", strings.Join(keys, "."))
+	}
+	p.skipSpace()
+	v, err := p.value()
+	if err != nil {
+		return err
+	}
+	t, err = p.descend(t, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	name := keys[len(keys)-1]
+	if _, ok := t[name]; ok {
+		return p.errorf("duplicate key %s", strings.Join(keys, "."))
+	}
+	t[name] = v
+	return nil
+}
+
+func isBare(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-'
+}
+
+// key parses a dotted key, and the spaces around it.
+func (p *parser) key() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		var k string
+		var err error
+		switch p.peek() {
+		case '"':
+			k, err = p.basicString()
+		case '\'':
+			k, err = p.literalString()
+		default:
+			start := p.pos
+			for isBare(p.peek()) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, p.errorf("expected a key, got %q", p.peek())
+			}
+			k = string(p.data[start:p.pos])
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+		p.skipSpace()
+		if !p.consume('.') {
+			return keys, nil
+		}
+	}
+}
+
+func (p *parser) hasPrefix(s string) bool {
+	return strings.HasPrefix(string(p.data[p.pos:]), s)
+}
+
+func (p *parser) value() (any, error) {
+	switch c := p.peek(); {
+	case p.hasPrefix(`"""`), p.hasPrefix(`'''`):
+		return nil, p.errorf("multi-line strings are not supported")
+	case c == '"':
+		return p.basicString()
+	case c == '\'':
+		return p.literalString()
+	case c == '[':
+		return p.array()
+	case c == '{':
+		return p.inlineTable()
+	}
+
+	start := p.pos
+	for isBare(p.peek()) || strings.IndexByte("+.:", p.peek()) >= 0 {
+		p.pos++
+	}
+	s := string(p.data[start:p.pos])
+	switch s {
+	case "":
+		return nil, p.errorf("expected a value, got %q", p.peek())
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "inf", "+inf", "-inf", "nan", "+nan", "-nan":
+		return nil, p.errorf("%s is not supported", s)
+	}
+	if isDate(s) {
+		return nil, p.errorf("dates are not supported")
+	}
+	n := strings.ReplaceAll(s, "_", "")
+	digits := strings.TrimLeft(n, "+-")
+	if len(digits) > 2 && digits[0] == '0' && strings.IndexByte("xob", digits[1]) >= 0 {
+		if i, err := strconv.ParseInt(n, 0, 64); err == nil {
+			return i, nil
+		}
+		return nil, p.errorf("invalid number %s", s)
+	}
+	if len(digits) > 1 && digits[0] == '0' && '0' <= digits[1] && digits[1] <= '9' {
+		return nil, p.errorf("invalid number %s: leading zeros are not allowed", s)
+	}
+	if i, err := strconv.ParseInt(n, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(n, 64); err == nil {
+		return f, nil
+	}
+	return nil, p.errorf("invalid value %s", s)
+}
+
+// isDate reports whether a bare value is a date or time: it has a colon, or a dash that isn't a sign.
+func isDate(s string) bool {
+	for i := 1; i < len(s); i++ {
+		if s[i] == ':' || s[i] == '-' && s[i-1] != 'e' && s[i-1] != 'E' {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) basicString() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			e := p.peek()
+			p.pos++
+			switch e {
+			case 'b':
+				b.WriteByte('\b')
+			case 't':
+				b.WriteByte('\t')
+			case 'n':
+				b.WriteByte('\n')
+			case 'f':
+				b.WriteByte('\f')
+			case 'r':
+				b.WriteByte('\r')
+			case '"', '\\':
+				b.WriteByte(e)
+			case 'u', 'U':
+				size := 4
+				if e == 'U' {
+					size = 8
+				}
+				if p.pos+size > len(p.data) {
+					return "", p.errorf("unterminated string")
+				}
+				r, err := strconv.ParseUint(string(p.data[p.pos:p.pos+size]), 16, 32)
+				if err != nil {
+					return "", p.errorf("invalid escape \\%c%s", e, p.data[p.pos:p.pos+size])
+				}
+				p.pos += size
+				b.WriteRune(rune(r))
+			default:
+				return "", p.errorf("invalid escape \\%c", e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+func (p *parser) literalString() (string, error) {
+	p.pos++
+	start := p.pos
+	for p.peek() != '\'' {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		p.pos++
+	}
+	p.pos++
+	return string(p.data[start : p.pos-1]), nil
+}
+
+// array parses an array, which may span lines.
+func (p *parser) array() ([]any, error) {
+	p.pos++
+	arr := []any{}
+	for {
+		p.skipBlank()
+		if p.consume(']') {
+			return arr, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+		p.skipBlank()
+		if p.consume(']') {
+			return arr, nil
+		}
+		if !p.consume(',') {
+			return nil, p.errorf("expected , or ] in array, got %q", p.peek())
+		}
+	}
+}
+
+// inlineTable parses an inline table, which must be on a single line.
+func (p *parser) inlineTable() (map[string]any, error) {
+	p.pos++
+	t := map[string]any{}
+	p.skipSpace()
+	if p.consume('}') {
+		return t, nil
+	}
+	for {
+		err := p.keyValue(t)
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.consume('}') {
+			return t, nil
+		}
+		if !p.consume(',') {
+			return nil, p.errorf("expected , or } in inline table, got %q", p.peek())
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package toml
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshal(t *testing.T) {
+	doc := `
+# The API.
+token = "sk-\"1\"\u00e9"
+base_url = 'https://api.opencat.app' # literal
+timeout = "60s"
+max_retries = 3
+ratio = 1_000.5e-1
+hex = 0xff
+enabled = true
+auth.header = "api-key"
+
+[endpoints.image]
+timeout = "120s"
+rate_limit = 0.5
+
+[endpoints."chat"]
+burst = -2
+
+[fallbacks]
+gpt-4 = [
+  "gpt-3.5-turbo", # cheaper
+  "claude-2",
+]
+
+[[backends]]
+base_url = "https://a.example.com"
+
+[[backends]]
+base_url = "https://b.example.com"
+headers = { x-id = "b", retries = 1 }
+`
+	got, err := Unmarshal([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"token":       "sk-\"1\"é",
+		"base_url":    "https://api.opencat.app",
+		"timeout":     "60s",
+		"max_retries": int64(3),
+		"ratio":       100.05,
+		"hex":         int64(255),
+		"enabled":     true,
+		"auth":        map[string]any{"header": "api-key"},
+		"endpoints": map[string]any{
+			"image": map[string]any{"timeout": "120s", "rate_limit": 0.5},
+			"chat":  map[string]any{"burst": int64(-2)},
+		},
+		"fallbacks": map[string]any{"gpt-4": []any{"gpt-3.5-turbo", "claude-2"}},
+		"backends": []any{
+			map[string]any{"base_url": "https://a.example.com"},
+			map[string]any{
+				"base_url": "https://b.example.com",
+				"headers":  map[string]any{"x-id": "b", "retries": int64(1)},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for doc, line := range map[string]int{
+		"a = 1\na = 2":               2,
+		"[a]\nb = 1\n[a]":            3,
+		"a = 1\nb = \"unterminated":  2,
+		"a = 1979-05-27":             1,
+		"\n\na = \"\"\"multi\"\"\"":  3,
+		"a = [1, 2":                  1,
+		"a = 1 b = 2":                1,
+		"a = 012":                    1,
+		"a = 1\n[a.b]":               2,
+		"a = {b = 1,\nc = 2}":        1,
+		"a = \"\\x\"":                1,
+		"[[a]]\nb = 1\n[a]\n":        3,
+		"a.b = 1\n[x]\ny = 2\nz = -": 4,
+	} {
+		_, err := Unmarshal([]byte(doc))
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%q: expected a syntax error, got %v", doc, err)
+			continue
+		}
+		if syntaxErr.Line != line {
+			t.Errorf("%q: expected an error on line %d, got %v", doc, line, err)
+		}
+	}
+}
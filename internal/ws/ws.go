@@ -0,0 +1,333 @@
+// Package ws implements the WebSocket protocol for text and binary messages.
+// https://datatracker.ietf.org/doc/html/rfc6455
+package ws
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxMessageSize is the maximum size of a received message, larger messages close the connection.
+const MaxMessageSize = 1 << 20
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// Close codes.
+const (
+	CloseNormal        = 1000
+	CloseProtocolError = 1002
+	CloseTooBig        = 1009
+)
+
+var errTooBig = errors.New("ws: message too big")
+
+// Conn is a WebSocket connection. Reads must not be concurrent, writes can be.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	// client connections mask the frames they send.
+	client bool
+
+	wmu          sync.Mutex
+	writeTimeout time.Duration
+	closeOnce    sync.Once
+}
+
+// acceptKey returns the Sec-WebSocket-Accept header answering key.
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Upgrade upgrades an HTTP request to a WebSocket connection.
+// If the request is not a WebSocket handshake, it replies with 400 Bad Request and returns an error.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") || r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		http.Error(w, "not a websocket handshake", http.StatusBadRequest)
+		return nil, errors.New("ws: not a websocket handshake")
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, err
+	}
+	_, _ = fmt.Fprintf(
+		rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key),
+	)
+	err = rw.Flush()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, br: rw.Reader}, nil
+}
+
+// Dial opens a client connection to a ws:// URL.
+func Dial(ctx context.Context, rawURL string, header http.Header) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ws" {
+		return nil, fmt.Errorf("ws: unsupported scheme %q", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "80")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	u.Scheme = "http"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	err = req.Write(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		resp.Body.Close()
+		conn.Close()
+		return nil, fmt.Errorf("ws: handshake failed with status %s", resp.Status)
+	}
+	return &Conn{conn: conn, br: br, client: true}, nil
+}
+
+// Read returns the next text or binary message, answering pings meanwhile.
+// It returns io.EOF when the peer closes the connection.
+func (c *Conn) Read() ([]byte, error) {
+	var msg []byte
+	started := false
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			if errors.Is(err, errTooBig) {
+				c.close(CloseTooBig, "message too big")
+			}
+			return nil, err
+		}
+		switch op {
+		case opPing:
+			err = c.writeFrame(opPong, payload)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.close(CloseNormal, "")
+			return nil, io.EOF
+		case opText, opBinary:
+			if started {
+				c.close(CloseProtocolError, "expected a continuation frame")
+				return nil, errors.New("ws: expected a continuation frame")
+			}
+			started = true
+		case opContinuation:
+			if !started {
+				c.close(CloseProtocolError, "unexpected continuation frame")
+				return nil, errors.New("ws: unexpected continuation frame")
+			}
+		default:
+			c.close(CloseProtocolError, "unknown opcode")
+			return nil, fmt.Errorf("ws: unknown opcode %d", op)
+		}
+		if len(msg)+len(payload) > MaxMessageSize {
+			c.close(CloseTooBig, "message too big")
+			return nil, errTooBig
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+func (c *Conn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var head [2]byte
+	_, err = io.ReadFull(c.br, head[:])
+	if err != nil {
+		return
+	}
+	fin, op = head[0]&0x80 != 0, head[0]&0x0f
+	masked := head[1]&0x80 != 0
+	if masked == c.client {
+		// Clients must mask their frames, servers must not.
+		err = errors.New("ws: invalid frame masking")
+		c.close(CloseProtocolError, "invalid frame masking")
+		return
+	}
+
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		_, err = io.ReadFull(c.br, ext[:])
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, err = io.ReadFull(c.br, ext[:])
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if err != nil {
+		return
+	}
+	if n > MaxMessageSize {
+		err = errTooBig
+		return
+	}
+
+	var mask [4]byte
+	if masked {
+		_, err = io.ReadFull(c.br, mask[:])
+		if err != nil {
+			return
+		}
+	}
+	payload = make([]byte, n)
+	_, err = io.ReadFull(c.br, payload)
+	if err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// SetWriteTimeout makes writes fail once they take longer than d, such as when the peer stops reading.
+// Zero means no timeout. The connection must then be closed.
+func (c *Conn) SetWriteTimeout(d time.Duration) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.writeTimeout = d
+}
+
+// Write sends a text message.
+func (c *Conn) Write(msg []byte) error {
+	return c.writeFrame(opText, msg)
+}
+
+// Ping sends a ping, to keep the connection alive through proxies.
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	frame := []byte{0x80 | op, 0}
+	n := len(payload)
+	switch {
+	case n < 126:
+		frame[1] = byte(n)
+	case n <= 0xffff:
+		frame[1] = 126
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame[1] = 127
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	if c.client {
+		frame[1] |= 0x80
+		var mask [4]byte
+		_, _ = rand.Read(mask[:])
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		for i := range frame[start:] {
+			frame[start+i] ^= mask[i%4]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.writeTimeout > 0 {
+		err := c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+		if err != nil {
+			return err
+		}
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close sends a normal close frame and closes the connection.
+func (c *Conn) Close() error {
+	return c.close(CloseNormal, "")
+}
+
+func (c *Conn) close(code int, reason string) error {
+	err := net.ErrClosed
+	c.closeOnce.Do(
+		func() {
+			payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+			_ = c.writeFrame(opClose, append(payload, reason...))
+			err = c.conn.Close()
+		},
+	)
+	return err
+}
@@ -0,0 +1,132 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func echoServer(t testing.TB) *httptest.Server {
+	return httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				conn, err := Upgrade(w, r)
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				for {
+					msg, err := conn.Read()
+					if err != nil {
+						return
+					}
+					_ = conn.Write(msg)
+				}
+			},
+		),
+	)
+}
+
+func TestEcho(t *testing.T) {
+	srv := echoServer(t)
+	defer srv.Close()
+	conn, err := Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Lengths encoded in 7 bits, 16 bits and 64 bits.
+	for _, n := range []int{5, 300, 70000} {
+		msg := bytes.Repeat([]byte("a"), n)
+		err = conn.Write(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = conn.Ping()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := conn.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, msg) {
+			t.Errorf("got %d bytes, want %d", len(got), n)
+		}
+	}
+}
+
+func TestFragmentsAndClose(t *testing.T) {
+	srv := echoServer(t)
+	defer srv.Close()
+	conn, err := Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, frame := range []struct {
+		fin bool
+		op  byte
+		s   string
+	}{{false, opText, "hel"}, {false, opContinuation, "lo"}, {true, opContinuation, "!"}} {
+		head := frame.op
+		if frame.fin {
+			head |= 0x80
+		}
+		// Masked with a zero key.
+		_, err = conn.conn.Write(append([]byte{head, 0x80 | byte(len(frame.s)), 0, 0, 0, 0}, frame.s...))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := conn.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello!" {
+		t.Errorf("got %q", got)
+	}
+
+	err = conn.writeFrame(opClose, []byte{0x03, 0xe8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Read()
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF after close, got %v", err)
+	}
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	srv := echoServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	// The peer of a pipe never reads.
+	a, b := net.Pipe()
+	defer b.Close()
+	conn := &Conn{conn: a}
+	conn.SetWriteTimeout(10 * time.Millisecond)
+	err := conn.Write([]byte("hello"))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
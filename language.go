@@ -0,0 +1,161 @@
+package opencat_api
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// WithLanguage asks the model to reply in the language of the BCP 47 tag lang, like fr or zh-CN.
+// Replies detected to be in another language are asked again once, see ChatRequest.Language.
+func WithLanguage(lang string) RequestOption {
+	return func(r *ChatRequest) {
+		r.Language = lang
+	}
+}
+
+var languageNames = map[string]string{
+	"ar": "Arabic",
+	"de": "German",
+	"el": "Greek",
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"he": "Hebrew",
+	"hi": "Hindi",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"nl": "Dutch",
+	"pt": "Portuguese",
+	"ru": "Russian",
+	"th": "Thai",
+	"zh": "Chinese",
+}
+
+// baseLanguage returns the language subtag of a BCP 47 tag: zh-CN is zh.
+func baseLanguage(lang string) string {
+	base, _, _ := strings.Cut(strings.ReplaceAll(lang, "_", "-"), "-")
+	return strings.ToLower(base)
+}
+
+// languageRequirement returns the instruction to reply in the language of the BCP 47 tag lang,
+// and the check of replies, which accepts the ones whose language can't be detected.
+func languageRequirement(lang string) (instruction string, check func(content string) error) {
+	want := baseLanguage(lang)
+	name := languageNames[want]
+	if name == "" {
+		name = "the language with the tag " + lang
+	}
+	instruction = fmt.Sprintf("Always reply in %s, whatever the language of the messages.", name)
+	return instruction, func(content string) error {
+		got := DetectLanguage(content)
+		if got == "" || got == want {
+			return nil
+		}
+		return fmt.Errorf("the reply is in %s instead of %s", languageNames[got], name)
+	}
+}
+
+var (
+	codeBlockRe = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+	wordRe      = regexp.MustCompile(`\p{L}+`)
+)
+
+// stopwords are frequent words of languages written in the Latin script, which tell them apart.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "of", "to", "in", "that", "it", "you", "with", "for", "this", "was"},
+	"fr": {"le", "la", "les", "et", "est", "des", "une", "un", "que", "pour", "dans", "vous", "pas", "avec"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "mit", "sie", "ich", "auf", "den"},
+	"es": {"el", "los", "las", "y", "es", "que", "una", "por", "para", "con", "del", "como", "pero", "está"},
+	"it": {"il", "di", "che", "è", "gli", "della", "per", "una", "sono", "con", "non", "anche", "questo", "nel"},
+	"pt": {"o", "os", "as", "e", "é", "que", "uma", "não", "para", "com", "do", "da", "em", "você"},
+	"nl": {"de", "het", "een", "en", "is", "van", "niet", "dat", "op", "met", "voor", "zijn", "ik", "je"},
+}
+
+var stopwordSet = func() map[string]map[string]bool {
+	sets := map[string]map[string]bool{}
+	for lang, words := range stopwords {
+		sets[lang] = map[string]bool{}
+		for _, w := range words {
+			sets[lang][w] = true
+		}
+	}
+	return sets
+}()
+
+// DetectLanguage guesses the language of text, as a BCP 47 language subtag like en or zh.
+// It tells languages apart by script, and languages written in the Latin script by their frequent words.
+// It returns "" if the text is too short, mostly code, or in a language it doesn't know.
+func DetectLanguage(text string) string {
+	text = codeBlockRe.ReplaceAllString(text, " ")
+
+	var han, kana, hangul, latin int
+	scripts := map[string]int{}
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.Is(unicode.Cyrillic, r):
+			scripts["ru"]++
+		case unicode.Is(unicode.Arabic, r):
+			scripts["ar"]++
+		case unicode.Is(unicode.Devanagari, r):
+			scripts["hi"]++
+		case unicode.Is(unicode.Thai, r):
+			scripts["th"]++
+		case unicode.Is(unicode.Greek, r):
+			scripts["el"]++
+		case unicode.Is(unicode.Hebrew, r):
+			scripts["he"]++
+		}
+	}
+	// Japanese mixes kanji with kana. An ideogram or syllable carries about as much as a few Latin letters,
+	// so terms in English don't outweigh the text around them.
+	if kana > 0 && kana*5 >= han+kana {
+		scripts["ja"] = (han + kana) * 3
+	} else {
+		scripts["zh"] = han * 3
+	}
+	scripts["ko"] = hangul * 3
+
+	best, count := "", 0
+	for lang, n := range scripts {
+		if n > count || n == count && lang < best {
+			best, count = lang, n
+		}
+	}
+	if latin > count {
+		best, count = latinLanguage(text), latin
+	}
+	if count < 10 {
+		return ""
+	}
+	return best
+}
+
+// latinLanguage returns the language written in the Latin script with the most frequent words in text.
+func latinLanguage(text string) string {
+	hits := map[string]int{}
+	for _, word := range wordRe.FindAllString(strings.ToLower(text), -1) {
+		for lang, set := range stopwordSet {
+			if set[word] {
+				hits[lang]++
+			}
+		}
+	}
+	best, count := "", 0
+	for lang, n := range hits {
+		if n > count || n == count && lang < best {
+			best, count = lang, n
+		}
+	}
+	return best
+}
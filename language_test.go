@@ -0,0 +1,66 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"The weather is nice today, and the sun is shining.", "en"},
+		{"Le temps est beau aujourd'hui et le soleil brille pour tout le monde.", "fr"},
+		{"Das Wetter ist heute schön und die Sonne scheint.", "de"},
+		{"今天天气很好，我们使用 Kubernetes 部署服务。", "zh"},
+		{"今日はいい天気ですね。散歩に行きましょう。", "ja"},
+		{"오늘은 날씨가 정말 좋네요. 산책하러 갈까요?", "ko"},
+		{"Сегодня хорошая погода, пойдём гулять.", "ru"},
+		{"OK", ""},
+		{"```go\nfunc main() { fmt.Println(\"the and is\") }\n```", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectLanguage(tt.text); got != tt.want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestChatLanguage(t *testing.T) {
+	var requests [][]Message
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var body struct{ Messages []Message }
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			requests = append(requests, body.Messages)
+			reply := "The capital of France is Paris, and it is a big city."
+			if len(requests) > 1 {
+				reply = "La capitale de la France est Paris, et c'est une grande ville."
+			}
+			fmt.Fprintf(w, `{"choices": [{"message": {"role": "assistant", "content": %q}}]}`, reply)
+		},
+	)
+
+	resp, err := c.Chat(
+		context.Background(),
+		ChatRequest{Model: ChatModelGPT4, Messages: []Message{User("What is the capital of France?")}},
+		WithLanguage("fr-FR"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 || !strings.HasPrefix(resp.Choices[0].Message.Content, "La capitale") {
+		t.Fatalf("got %q after %d requests", resp.Choices[0].Message.Content, len(requests))
+	}
+	if first := requests[0]; len(first) != 2 || !strings.Contains(first[1].Content, "French") {
+		t.Errorf("no language instruction in %+v", first)
+	}
+	if retry := requests[1]; !strings.Contains(retry[len(retry)-1].Content, "English instead of French") {
+		t.Errorf("no correction in %+v", retry)
+	}
+}
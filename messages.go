@@ -0,0 +1,142 @@
+package opencat_api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MessageOption adds to a message of a MessageBuilder.
+type MessageOption func(*Message)
+
+// WithImage attaches an image to a user message.
+func WithImage(r io.Reader) MessageOption {
+	return func(msg *Message) {
+		msg.Images = append(msg.Images, NewImage(r))
+	}
+}
+
+// WithImages attaches images to a user message, for example with a detail level set by Image.WithDetail.
+func WithImages(images ...Image) MessageOption {
+	return func(msg *Message) {
+		msg.Images = append(msg.Images, images...)
+	}
+}
+
+// WithParts appends content parts to a message, to interleave text with images or audio.
+func WithParts(parts ...ContentPart) MessageOption {
+	return func(msg *Message) {
+		msg.Parts = append(msg.Parts, parts...)
+	}
+}
+
+// WithImageURL attaches a remote image to a user message, see NewImageURL.
+func WithImageURL(url string) MessageOption {
+	return func(msg *Message) {
+		msg.Images = append(msg.Images, NewImageURL(url))
+	}
+}
+
+// System returns a system message.
+func System(text string) Message {
+	return Message{Role: RoleSystem, Content: text}
+}
+
+// User returns a user message, with parts after the text to attach images or audio.
+func User(text string, parts ...ContentPart) Message {
+	return Message{Role: RoleUser, Content: text, Parts: parts}
+}
+
+// Assistant returns an assistant message, such as a previous reply, or the start of the reply for
+// models that continue it, like Claude.
+func Assistant(text string) Message {
+	return Message{Role: RoleAssistant, Content: text}
+}
+
+// ToolResult returns the result of the tool call with the given ID.
+func ToolResult(callID, content string) Message {
+	return Message{Role: RoleTool, ToolCallID: callID, Content: content}
+}
+
+// MessageBuilder builds a list of messages:
+//
+//	messages, err := NewMessages().
+//		System("You are a helpful assistant.").
+//		User("What is in this picture?", WithImage(f)).
+//		Build()
+//
+// The role of a message is chosen by the method or constructor used to add it, see System and User,
+// so it can't be misspelled.
+type MessageBuilder struct {
+	messages []Message
+}
+
+func NewMessages() *MessageBuilder {
+	return &MessageBuilder{}
+}
+
+// Add appends messages made with System, User, Assistant or ToolResult.
+func (b *MessageBuilder) Add(msgs ...Message) *MessageBuilder {
+	b.messages = append(b.messages, msgs...)
+	return b
+}
+
+func (b *MessageBuilder) add(msg Message, opts []MessageOption) *MessageBuilder {
+	for _, opt := range opts {
+		opt(&msg)
+	}
+	return b.Add(msg)
+}
+
+func (b *MessageBuilder) System(content string, opts ...MessageOption) *MessageBuilder {
+	return b.add(System(content), opts)
+}
+
+func (b *MessageBuilder) User(content string, opts ...MessageOption) *MessageBuilder {
+	return b.add(User(content), opts)
+}
+
+func (b *MessageBuilder) Assistant(content string, opts ...MessageOption) *MessageBuilder {
+	return b.add(Assistant(content), opts)
+}
+
+// Build returns the messages, or an error if a message has an unknown role or no content, or an image
+// is attached to a message that is not from the user.
+func (b *MessageBuilder) Build() ([]Message, error) {
+	var errs []error
+	for i, msg := range b.messages {
+		switch msg.Role {
+		case RoleSystem, RoleUser, RoleAssistant, RoleTool:
+		default:
+			errs = append(errs, fmt.Errorf("message %d has unknown role %q", i, msg.Role))
+		}
+		if strings.TrimSpace(msg.Text()) == "" && len(msg.images()) == 0 && len(msg.Parts) == 0 {
+			errs = append(errs, fmt.Errorf("message %d (%s) is empty", i, msg.Role))
+		}
+		if len(msg.images()) > 0 && msg.Role != RoleUser {
+			errs = append(errs, fmt.Errorf("message %d (%s) has images, only user messages can", i, msg.Role))
+		}
+	}
+	if len(b.messages) == 0 {
+		errs = append(errs, errors.New("no messages"))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return b.messages, nil
+}
+
+// BuildFor is like Build, and also checks that model accepts images if there are any.
+func (b *MessageBuilder) BuildFor(model ChatModel) ([]Message, error) {
+	messages, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range messages {
+		if len(msg.images()) > 0 && !supportsVision(model) {
+			return nil, fmt.Errorf("%s does not accept images", model)
+		}
+	}
+	return messages, nil
+}
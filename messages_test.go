@@ -0,0 +1,151 @@
+package opencat_api
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMessageBuilder(t *testing.T) {
+	messages, err := NewMessages().
+		System("You are a helpful assistant.").
+		User("What is in this picture?", WithImage(strings.NewReader("image"))).
+		Assistant("A cat.").
+		BuildFor(ChatModelGPT4VisionPreview)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 3 || len(messages[1].Images) != 1 || messages[2].Role != RoleAssistant {
+		t.Errorf("got %+v", messages)
+	}
+
+	_, err = NewMessages().User("Describe it.", WithImage(strings.NewReader("image"))).BuildFor(ChatModelGPT4)
+	if err == nil {
+		t.Errorf("expected error for images with a model without vision")
+	}
+	_, err = NewMessages().System(" ").Assistant("", WithImage(strings.NewReader("image"))).Build()
+	if err == nil || !strings.Contains(err.Error(), "message 0 (system) is empty") ||
+		!strings.Contains(err.Error(), "only user messages") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestMessageConstructors(t *testing.T) {
+	messages, err := NewMessages().
+		Add(
+			System("Be brief."),
+			User("What is this?", ImagePart(NewImageURL("https://example.com/cat.png"))),
+			Assistant("A cat."),
+			ToolResult("call_1", "sunny"),
+		).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles := []Role{RoleSystem, RoleUser, RoleAssistant, RoleTool}
+	for i, msg := range messages {
+		if msg.Role != roles[i] {
+			t.Errorf("message %d: role = %s, want %s", i, msg.Role, roles[i])
+		}
+	}
+	if len(messages[1].images()) != 1 || messages[3].ToolCallID != "call_1" {
+		t.Errorf("got %+v", messages)
+	}
+
+	_, err = NewMessages().Add(Message{Role: "usr", Content: "hi"}).Build()
+	if err == nil || !strings.Contains(err.Error(), `unknown role "usr"`) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestImageJSON(t *testing.T) {
+	msg := Message{
+		Role:    RoleUser,
+		Content: "Compare these.",
+		Images:  []Image{NewImage(strings.NewReader("abc")), NewImageURL("https://example.com/cat.png")},
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `"images":["data:image/jpeg;base64,YWJj","https://example.com/cat.png"]`
+	if !strings.Contains(string(data), want) {
+		t.Errorf("got %s, want images %s", data, want)
+	}
+}
+
+func TestImageMIMEType(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 600)
+	tests := []struct {
+		image Image
+		want  string
+	}{
+		{NewImage(strings.NewReader(png)), "data:image/png;base64,"},
+		{NewImage(strings.NewReader("GIF89a...")), "data:image/gif;base64,"},
+		{NewImage(strings.NewReader("not an image")), "data:image/jpeg;base64,"},
+		{NewImage(strings.NewReader(png)).WithMIMEType("image/x-custom"), "data:image/x-custom;base64,"},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(&tt.image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var uri string
+		_ = json.Unmarshal(data, &uri)
+		if !strings.HasPrefix(uri, tt.want) {
+			t.Errorf("got %.40s, want prefix %s", uri, tt.want)
+		}
+	}
+	// The sniffed bytes are still encoded.
+	img := NewImage(strings.NewReader(png))
+	data, _ := json.Marshal(&img)
+	if len(data) < len(png)*4/3 {
+		t.Errorf("image truncated to %d bytes", len(data))
+	}
+}
+
+func TestImageFromBytes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dot.gif")
+	err := os.WriteFile(path, []byte("GIF89a\x01\x00\x01\x00"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := NewImageFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Unlike reader-backed images, it can be sent again.
+	first, err := json.Marshal(&img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := json.Marshal(&img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) != string(second) || !strings.HasPrefix(string(first), `"data:image/gif;base64,`) {
+		t.Errorf("got %s then %s", first, second)
+	}
+}
+
+func TestImageDetail(t *testing.T) {
+	messages, err := NewMessages().
+		User("Compare.", WithImages(NewImageURL("https://example.com/a.png").WithDetail(ImageDetailLow))).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(messages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `"images":[{"url":"https://example.com/a.png","detail":"low"}]`
+	if !strings.Contains(string(data), want) {
+		t.Errorf("got %s, want images %s", data, want)
+	}
+	if n := estimateTokens("", messages); n > 100 {
+		t.Errorf("low detail image estimated at %d tokens", n)
+	}
+}
@@ -0,0 +1,177 @@
+// Package mobile is a facade of the client for iOS and Android apps, to be bound with gomobile:
+//
+//	gomobile bind -target=ios,android github.com/j178/opencat-api/mobile
+//
+// Its exported API only uses types gomobile can bind: strings, numbers, byte slices, errors, pointers to
+// structs and interfaces. Lists are built with methods, and streaming uses a callback interface
+// implemented by the app. Blocking methods must not be called from the main thread.
+package mobile
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	api "github.com/j178/opencat-api"
+)
+
+type Client struct {
+	c *api.Client
+}
+
+func NewClient(token string) *Client {
+	return &Client{c: api.NewClient(token)}
+}
+
+// SetBaseURL sends requests to another endpoint, such as a self-hosted gateway.
+func (c *Client) SetBaseURL(url string) error {
+	cfg := c.c.Config()
+	cfg.BaseURL = url
+	return c.c.ApplyConfig(cfg)
+}
+
+// SetTimeout limits the duration of each request, in seconds. Zero means no limit.
+func (c *Client) SetTimeout(seconds int64) error {
+	cfg := c.c.Config()
+	cfg.Timeout = time.Duration(seconds) * time.Second
+	return c.c.ApplyConfig(cfg)
+}
+
+// Messages is a list of chat messages.
+type Messages struct {
+	messages []api.Message
+}
+
+func NewMessages() *Messages {
+	return &Messages{}
+}
+
+// Add appends a message. role is system, user or assistant.
+func (m *Messages) Add(role, text string) {
+	m.messages = append(m.messages, api.Message{Role: api.Role(role), Content: text})
+}
+
+// AddImage appends a message with an image, for vision models.
+func (m *Messages) AddImage(role, text string, image []byte) {
+	m.messages = append(
+		m.messages,
+		api.Message{Role: api.Role(role), Content: text, Images: []api.Image{api.NewImageFromBytes(image)}},
+	)
+}
+
+func (m *Messages) Len() int {
+	return len(m.messages)
+}
+
+// ChatOptions are the optional parameters of a chat request. Zero values mean the model's default.
+type ChatOptions struct {
+	Temperature float64
+	MaxTokens   int
+}
+
+func NewChatOptions() *ChatOptions {
+	return &ChatOptions{}
+}
+
+func (c *Client) request(model string, messages *Messages, opts *ChatOptions) api.ChatRequest {
+	chat := api.ChatRequest{Model: api.ChatModel(model), Messages: messages.messages}
+	if opts != nil {
+		chat.Temperature = opts.Temperature
+		chat.MaxTokens = opts.MaxTokens
+	}
+	return chat
+}
+
+// Reply is the reply of a chat request.
+type Reply struct {
+	Content          string
+	FinishReason     string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Chat sends a chat request and waits for the reply.
+func (c *Client) Chat(model string, messages *Messages, opts *ChatOptions) (*Reply, error) {
+	resp, err := c.c.Chat(context.Background(), c.request(model, messages, opts))
+	if err != nil {
+		return nil, err
+	}
+	reply := &Reply{PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens}
+	if len(resp.Choices) > 0 {
+		reply.Content = resp.Choices[0].Message.Content
+		reply.FinishReason = resp.Choices[0].FinishReason
+	}
+	return reply, nil
+}
+
+// StreamHandler receives a streamed reply. Its methods are called from a background thread.
+type StreamHandler interface {
+	// OnDelta is called with each piece of the reply.
+	OnDelta(delta string)
+	// OnComplete is called with the whole reply once it is complete.
+	OnComplete(reply string)
+	// OnError is called instead of OnComplete if the request fails or is canceled, with a message for users
+	// in the language of lang, see StreamChat, and the category of the error, like rate_limited.
+	OnError(message, category string)
+}
+
+// Task is a request running in the background.
+type Task struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Cancel stops the request. The handler gets OnError with the canceled category, unless the request completed.
+func (t *Task) Cancel() {
+	t.once.Do(t.cancel)
+}
+
+// Wait blocks until the handler got OnComplete or OnError.
+func (t *Task) Wait() {
+	<-t.done
+}
+
+// StreamChat sends a chat request in the background and streams the reply to handler.
+// lang is the language of error messages, like en or zh-CN.
+func (c *Client) StreamChat(
+	model string,
+	messages *Messages,
+	opts *ChatOptions,
+	lang string,
+	handler StreamHandler,
+) *Task {
+	ctx, cancel := context.WithCancel(context.Background())
+	task := &Task{cancel: cancel, done: make(chan struct{})}
+	chat := c.request(model, messages, opts)
+	chat.Stream = true
+	go func() {
+		defer close(task.done)
+		defer cancel()
+		var reply string
+		err := c.c.StreamChat(
+			ctx, chat, func(delta string, done bool) {
+				reply += delta
+				if delta != "" {
+					handler.OnDelta(delta)
+				}
+			},
+		)
+		if err != nil {
+			handler.OnError(api.LocalizeError(err, lang), string(api.CategorizeError(err)))
+			return
+		}
+		handler.OnComplete(reply)
+	}()
+	return task
+}
+
+// ErrorMessage returns a message describing err for users, in the language of lang, like en or zh-CN.
+func ErrorMessage(err error, lang string) string {
+	return api.LocalizeError(err, lang)
+}
+
+// ErrorCategory returns the category of err, like invalid_key or rate_limited.
+func ErrorCategory(err error) string {
+	return string(api.CategorizeError(err))
+}
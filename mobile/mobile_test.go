@@ -0,0 +1,71 @@
+package mobile
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type recorder struct {
+	deltas   []string
+	reply    string
+	category string
+}
+
+func (r *recorder) OnDelta(delta string)             { r.deltas = append(r.deltas, delta) }
+func (r *recorder) OnComplete(reply string)          { r.reply = reply }
+func (r *recorder) OnError(message, category string) { r.category = category }
+
+func TestStreamChat(t *testing.T) {
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, "data: {\"delta\":\"Hel\"}\n\ndata: {\"delta\":\"lo\"}\n\n")
+			},
+		),
+	)
+	defer srv.Close()
+	c := NewClient("token")
+	err := c.SetBaseURL(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := NewMessages()
+	messages.Add("user", "hi")
+	var r recorder
+	c.StreamChat("gpt-4", messages, nil, "en", &r).Wait()
+	if r.reply != "Hello" || len(r.deltas) != 2 || r.category != "" {
+		t.Errorf("unexpected stream: %+v", r)
+	}
+
+	err = c.SetBaseURL("ftp://example.com")
+	if err == nil {
+		t.Error("expected an error for an invalid base URL")
+	}
+}
+
+func TestStreamChatCancel(t *testing.T) {
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+		),
+	)
+	defer srv.Close()
+	c := NewClient("token")
+	_ = c.SetBaseURL(srv.URL)
+
+	messages := NewMessages()
+	messages.Add("user", "hi")
+	var r recorder
+	task := c.StreamChat("gpt-4", messages, NewChatOptions(), "en", &r)
+	task.Cancel()
+	task.Wait()
+	if r.category != "canceled" {
+		t.Errorf("category = %q, want canceled", r.category)
+	}
+}
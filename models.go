@@ -0,0 +1,211 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ModelInfo describes a chat model, see RegisterModel.
+type ModelInfo struct {
+	Name     ChatModel
+	Provider Provider
+	// ContextWindow is the maximum number of tokens, prompt and reply, the model accepts.
+	ContextWindow int
+	// Vision, Tools and Streaming report whether the model accepts images, can call tools and can stream replies.
+	Vision    bool
+	Tools     bool
+	Streaming bool
+	// JSONMode reports whether the model supports ResponseFormatJSON.
+	JSONMode bool
+	// Price is zero if unknown.
+	Price Price
+}
+
+var (
+	modelsMu sync.RWMutex
+	models   = map[ChatModel]ModelInfo{}
+)
+
+func init() {
+	for _, info := range []ModelInfo{
+		{ChatModelGPT3Dot5Turbo, ProviderOpenAI, 4096, false, true, true, true, Price{0.001, 0.002, "USD"}},
+		{ChatModelGPT3Dot5Turbo16K, ProviderOpenAI, 16385, false, true, true, false, Price{0.003, 0.004, "USD"}},
+		{ChatModelGPT4, ProviderOpenAI, 8192, false, true, true, false, Price{0.03, 0.06, "USD"}},
+		{ChatModelGPT432K, ProviderOpenAI, 32768, false, true, true, false, Price{0.06, 0.12, "USD"}},
+		{ChatModelGPT4Turbo, ProviderOpenAI, 128000, false, true, true, true, Price{0.01, 0.03, "USD"}},
+		{ChatModelGPT4VisionPreview, ProviderOpenAI, 128000, true, false, true, false, Price{0.01, 0.03, "USD"}},
+		{ChatModelClaudeInstant1, ProviderAnthropic, 100000, false, true, true, false, Price{0.0008, 0.0024, "USD"}},
+		{ChatModelClaude2, ProviderAnthropic, 200000, false, true, true, false, Price{0.008, 0.024, "USD"}},
+		{ChatModelGEMINIPro, ProviderGoogle, 32768, false, true, true, false, Price{0.00025, 0.0005, "USD"}},
+		{ChatModelGEMINIProVision, ProviderGoogle, 16384, true, false, true, false, Price{0.00025, 0.0005, "USD"}},
+		{ChatModelERNIEBot, ProviderBaidu, 5120, false, false, true, false, Price{0.012, 0.012, "CNY"}},
+		{ChatModelERNIEBotTurbo, ProviderBaidu, 7168, false, false, true, false, Price{0.008, 0.008, "CNY"}},
+		{ChatModelERNIEBot4, ProviderBaidu, 5120, false, false, true, false, Price{0.12, 0.12, "CNY"}},
+		{ChatModelQWENTurbo, ProviderAlibaba, 8192, false, false, true, false, Price{0.008, 0.008, "CNY"}},
+		{ChatModelQWENPlus, ProviderAlibaba, 32768, false, false, true, false, Price{0.02, 0.02, "CNY"}},
+		{ChatModelSparkDeskV1, ProviderIFlytek, 4096, false, false, true, false, Price{0.018, 0.018, "CNY"}},
+		{ChatModelSparkDeskV2, ProviderIFlytek, 8192, false, false, true, false, Price{0.036, 0.036, "CNY"}},
+		{ChatModelSparkDeskV3, ProviderIFlytek, 8192, false, false, true, false, Price{0.036, 0.036, "CNY"}},
+	} {
+		models[info.Name] = info
+	}
+}
+
+// RegisterModel adds a model to the registry, or replaces its information, so models the server supports
+// before this package does are routed to the right provider, and their context window and price are known.
+// An empty Provider is guessed from the name.
+func RegisterModel(info ModelInfo) {
+	if info.Provider == "" {
+		info.Provider = guessProvider(info.Name)
+	}
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+	models[info.Name] = info
+}
+
+// ModelInfoOf returns the information of model. Versioned names like gpt-4-0613 get the information
+// of their base model.
+func ModelInfoOf(model ChatModel) (ModelInfo, bool) {
+	modelsMu.RLock()
+	defer modelsMu.RUnlock()
+	return modelInfoLocked(model)
+}
+
+func modelInfoLocked(model ChatModel) (ModelInfo, bool) {
+	if info, ok := models[model]; ok {
+		return info, true
+	}
+	var best ModelInfo
+	for m, info := range models {
+		if strings.HasPrefix(string(model), string(m)+"-") && len(m) > len(best.Name) {
+			best = info
+		}
+	}
+	return best, best.Name != ""
+}
+
+// Models returns the registered models, sorted by name.
+func Models() []ModelInfo {
+	modelsMu.RLock()
+	defer modelsMu.RUnlock()
+	list := make([]ModelInfo, 0, len(models))
+	for _, info := range models {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Capabilities are the features a model supports, see Client.Capabilities.
+type Capabilities struct {
+	// Known is false for models missing from the registry, whose capabilities are assumed.
+	Known     bool
+	Streaming bool
+	Vision    bool
+	Tools     bool
+	JSONMode  bool
+	// ContextWindow is the maximum number of tokens, prompt and reply.
+	ContextWindow int
+}
+
+// Capabilities reports what model supports, from the registry, see RegisterModel. An empty model means
+// the default model of the client. Unknown models are assumed to stream, with a small context window,
+// and to support nothing else.
+func (c *Client) Capabilities(model ChatModel) Capabilities {
+	if model == "" {
+		model = c.config().DefaultModel
+	}
+	info, ok := ModelInfoOf(model)
+	if !ok {
+		return Capabilities{Streaming: true, ContextWindow: defaultContextWindow}
+	}
+	return Capabilities{
+		Known:         true,
+		Streaming:     info.Streaming,
+		Vision:        info.Vision,
+		Tools:         info.Tools,
+		JSONMode:      info.JSONMode,
+		ContextWindow: contextWindow(model),
+	}
+}
+
+// providerOf returns the provider of a model, from the registry, or guessed from its name if it isn't registered.
+func providerOf(model ChatModel) Provider {
+	if info, ok := ModelInfoOf(model); ok {
+		return info.Provider
+	}
+	return guessProvider(model)
+}
+
+// guessProvider guesses the provider of a model from its name.
+func guessProvider(model ChatModel) Provider {
+	m := string(model)
+	switch {
+	case strings.HasPrefix(m, "claude"):
+		return ProviderAnthropic
+	case strings.HasPrefix(m, "gemini"):
+		return ProviderGoogle
+	case strings.HasPrefix(m, "ERNIE"):
+		return ProviderBaidu
+	case strings.HasPrefix(m, "qwen"):
+		return ProviderAlibaba
+	case strings.HasPrefix(m, "SparkDesk"):
+		return ProviderIFlytek
+	default:
+		return ProviderOpenAI
+	}
+}
+
+// supportsVision reports whether the model accepts images in messages.
+func supportsVision(model ChatModel) bool {
+	info, ok := ModelInfoOf(model)
+	return ok && info.Vision
+}
+
+// Models lists the chat models the server offers, to populate a model picker. Models in the registry
+// get their information from it, with the name the server uses; others get a provider guessed from
+// their name and are assumed to stream.
+func (c *Client) Models(ctx context.Context) ([]ModelInfo, error) {
+	req, err := c.newRequest(ctx, "GET", "/1/models", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, NewAPIError(resp)
+	}
+
+	var data struct {
+		Data []struct {
+			ID            ChatModel `json:"id"`
+			ContextWindow int       `json:"context_window"`
+		} `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]ModelInfo, 0, len(data.Data))
+	for _, m := range data.Data {
+		info, ok := ModelInfoOf(m.ID)
+		if !ok {
+			info = ModelInfo{Provider: guessProvider(m.ID), Streaming: true}
+		}
+		info.Name = m.ID
+		if m.ContextWindow > 0 {
+			info.ContextWindow = m.ContextWindow
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
@@ -0,0 +1,98 @@
+package opencat_api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestRegisterModel(t *testing.T) {
+	RegisterModel(
+		ModelInfo{
+			Name:          "claude-next",
+			ContextWindow: 500000,
+			Vision:        true,
+			Tools:         true,
+			Streaming:     true,
+			Price:         Price{0.002, 0.01, "USD"},
+		},
+	)
+	RegisterModel(ModelInfo{Name: "acme-chat", Provider: ProviderAnthropic, ContextWindow: 1000})
+
+	if p := providerOf("claude-next"); p != ProviderAnthropic {
+		t.Errorf("provider of claude-next = %s", p)
+	}
+	if p := providerOf("acme-chat-2024"); p != ProviderAnthropic {
+		t.Errorf("provider of a versioned registered model = %s, want the registered one", p)
+	}
+	if p := providerOf("unknown-model"); p != ProviderOpenAI {
+		t.Errorf("provider of an unregistered model = %s", p)
+	}
+	if !supportsVision("claude-next") || supportsVision(ChatModelGPT4) {
+		t.Error("vision support not taken from the registry")
+	}
+	if n := contextWindow("acme-chat"); n != 1000 {
+		t.Errorf("context window = %d", n)
+	}
+	if _, ok := PriceOf("acme-chat"); ok {
+		t.Error("model registered without a price has a price")
+	}
+
+	SetPrice("gpt-4-0125", Price{0.01, 0.03, "USD"})
+	if n := contextWindow("gpt-4-0125"); n != 8192 {
+		t.Errorf("setting the price of a versioned model lost the context window of its base model: %d", n)
+	}
+	found := false
+	for _, info := range Models() {
+		found = found || info.Name == "claude-next"
+	}
+	if !found {
+		t.Error("registered model not listed")
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	c := NewClient("token")
+	caps := c.Capabilities(ChatModelGPT4VisionPreview)
+	if !caps.Known || !caps.Vision || caps.Tools || !caps.Streaming || caps.ContextWindow != 128000 {
+		t.Errorf("unexpected capabilities of %s: %+v", ChatModelGPT4VisionPreview, caps)
+	}
+	if caps := c.Capabilities("gpt-4-1106-preview-2024"); !caps.Known || !caps.JSONMode {
+		t.Errorf("versioned model should have the capabilities of its base model: %+v", caps)
+	}
+	if caps := c.Capabilities("unknown-model"); caps.Known || caps.Vision || !caps.Streaming {
+		t.Errorf("unexpected capabilities of an unknown model: %+v", caps)
+	}
+
+	c.updateConfig(func(cfg *Config) { cfg.DefaultModel = ChatModelGEMINIProVision })
+	if caps := c.Capabilities(""); !caps.Vision {
+		t.Errorf("capabilities of the default model: %+v", caps)
+	}
+}
+
+func TestListModels(t *testing.T) {
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/1/models" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, `{"data": [{"id": "gpt-4-0613"}, {"id": "qwen-max", "context_window": 8000}]}`)
+		},
+	)
+
+	models, err := c.Models(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 {
+		t.Fatalf("got %d models, want 2", len(models))
+	}
+	if gpt4 := models[0]; gpt4.Name != "gpt-4-0613" || !gpt4.Tools || gpt4.ContextWindow != 8192 {
+		t.Errorf("unexpected registered model: %+v", gpt4)
+	}
+	if qwen := models[1]; qwen.Provider != ProviderAlibaba || !qwen.Streaming || qwen.ContextWindow != 8000 {
+		t.Errorf("unexpected unknown model: %+v", qwen)
+	}
+}
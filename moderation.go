@@ -0,0 +1,144 @@
+package opencat_api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ModerationCategories are the categories of harmful content checked by Moderate.
+type ModerationCategories[T bool | float64] struct {
+	Hate                  T `json:"hate"`
+	HateThreatening       T `json:"hate/threatening"`
+	Harassment            T `json:"harassment"`
+	HarassmentThreatening T `json:"harassment/threatening"`
+	SelfHarm              T `json:"self-harm"`
+	SelfHarmIntent        T `json:"self-harm/intent"`
+	SelfHarmInstructions  T `json:"self-harm/instructions"`
+	Sexual                T `json:"sexual"`
+	SexualMinors          T `json:"sexual/minors"`
+	Violence              T `json:"violence"`
+	ViolenceGraphic       T `json:"violence/graphic"`
+}
+
+// ModerationResult is the verdict on one input.
+type ModerationResult struct {
+	// Flagged is true if the input is harmful in any category.
+	Flagged bool `json:"flagged"`
+	// Categories tells in which categories the input is harmful.
+	Categories ModerationCategories[bool] `json:"categories"`
+	// CategoryScores are the confidence, from 0 to 1, that the input is harmful in each category.
+	CategoryScores ModerationCategories[float64] `json:"category_scores"`
+}
+
+// FlaggedCategories returns the names of the categories the input is flagged in, like self-harm/intent.
+func (r ModerationResult) FlaggedCategories() []string {
+	var flagged []string
+	v, t := reflect.ValueOf(r.Categories), reflect.TypeOf(r.Categories)
+	for i := 0; i < t.NumField(); i++ {
+		if v.Field(i).Bool() {
+			flagged = append(flagged, t.Field(i).Tag.Get("json"))
+		}
+	}
+	return flagged
+}
+
+// Moderate checks whether texts are harmful, with a result per text, in order.
+// It is free, so user input can be screened before chat tokens are spent on it, see WithModeration.
+func (c *Client) Moderate(ctx context.Context, input []string) ([]ModerationResult, error) {
+	if len(input) == 0 {
+		return nil, errors.New("moderation request has no input")
+	}
+	body, err := json.Marshal(map[string]any{"input": input})
+	if err != nil {
+		return nil, err
+	}
+	req, err := c.newRequest(ctx, "POST", "/v1/moderations", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, NewAPIError(resp)
+	}
+
+	var data struct {
+		Results []ModerationResult `json:"results"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data.Results) != len(input) {
+		return nil, fmt.Errorf("got %d moderation results for %d inputs", len(data.Results), len(input))
+	}
+	return data.Results, nil
+}
+
+// ErrModerationFlagged is returned by Chat and StreamChat when moderation flagged the request,
+// see WithModeration. The request is not sent.
+type ErrModerationFlagged struct {
+	// Categories are the categories the input is flagged in.
+	Categories []string
+}
+
+func (e *ErrModerationFlagged) Error() string {
+	return fmt.Sprintf("request flagged by moderation (%s)", strings.Join(e.Categories, ", "))
+}
+
+// WithModeration makes Chat and StreamChat screen the new user input of each request with Moderate,
+// and fail with *ErrModerationFlagged instead of sending a harmful request to the model.
+// The new input is the text of the user messages after the last assistant message.
+func WithModeration() ClientOption {
+	return func(c *Client) {
+		c.moderation = true
+	}
+}
+
+// screen moderates the new user input of chat if WithModeration is set.
+func (c *Client) screen(ctx context.Context, chat ChatRequest) error {
+	if !c.moderation {
+		return nil
+	}
+	var input []string
+	for i := len(chat.Messages) - 1; i >= 0 && chat.Messages[i].Role != RoleAssistant; i-- {
+		if msg := chat.Messages[i]; msg.Role == RoleUser && msg.Text() != "" {
+			input = append(input, msg.Text())
+		}
+	}
+	if len(input) == 0 {
+		return nil
+	}
+	results, err := c.Moderate(ctx, input)
+	if err != nil {
+		return fmt.Errorf("moderate request: %w", err)
+	}
+	flagged := false
+	var categories []string
+	for _, r := range results {
+		if !r.Flagged {
+			continue
+		}
+		flagged = true
+		for _, category := range r.FlaggedCategories() {
+			if !slices.Contains(categories, category) {
+				categories = append(categories, category)
+			}
+		}
+	}
+	if flagged {
+		return &ErrModerationFlagged{Categories: categories}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestModerate(t *testing.T) {
+	var inputs [][]string
+	chats := 0
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/moderations" {
+				chats++
+				io.WriteString(w, `{"choices": [{"message": {"role": "assistant", "content": "Hi"}}]}`)
+				return
+			}
+			var body struct {
+				Input []string `json:"input"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			inputs = append(inputs, body.Input)
+			var results []string
+			for _, input := range body.Input {
+				if strings.Contains(input, "hurt") {
+					results = append(
+						results, `{"flagged": true, "categories": {"violence": true, "self-harm/intent": true},`+
+							`"category_scores": {"violence": 0.9, "self-harm/intent": 0.7}}`,
+					)
+				} else {
+					results = append(results, `{"flagged": false, "category_scores": {"violence": 0.01}}`)
+				}
+			}
+			io.WriteString(w, `{"results": [`+strings.Join(results, ",")+`]}`)
+		},
+		WithModeration(),
+	)
+
+	results, err := c.Moderate(context.Background(), []string{"hello", "I will hurt them"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Flagged || !results[1].Flagged {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if results[1].CategoryScores.Violence != 0.9 || !results[1].Categories.SelfHarmIntent {
+		t.Errorf("unexpected scores: %+v", results[1])
+	}
+	if got := results[1].FlaggedCategories(); !slices.Equal(got, []string{"self-harm/intent", "violence"}) {
+		t.Errorf("unexpected flagged categories: %v", got)
+	}
+
+	// Only the messages after the last reply are screened.
+	inputs = nil
+	_, err = c.Chat(
+		context.Background(), ChatRequest{
+			Model: ChatModelGPT3Dot5Turbo,
+			Messages: []Message{
+				{Role: RoleUser, Content: "I will hurt them"},
+				{Role: RoleAssistant, Content: "Please don't."},
+				{Role: RoleUser, Content: "Fine, hello"},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 1 || !slices.Equal(inputs[0], []string{"Fine, hello"}) || chats != 1 {
+		t.Errorf("unexpected moderation inputs %v or chats %d", inputs, chats)
+	}
+
+	_, err = c.Chat(
+		context.Background(), ChatRequest{
+			Model:    ChatModelGPT3Dot5Turbo,
+			Messages: []Message{{Role: RoleUser, Content: "I will hurt them"}},
+		},
+	)
+	var flagged *ErrModerationFlagged
+	if !errors.As(err, &flagged) || !slices.Contains(flagged.Categories, "violence") {
+		t.Fatalf("expected a moderation error, got %v", err)
+	}
+	if CategorizeError(err) != ErrorContentFiltered {
+		t.Errorf("unexpected category %q", CategorizeError(err))
+	}
+	if chats != 1 {
+		t.Errorf("flagged request was sent")
+	}
+}
@@ -0,0 +1,151 @@
+package opencat_api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// ResponseMessage is the message of a chat response choice.
+type ResponseMessage struct {
+	// Content is the text of the message. Images and audio returned as separate content parts are not in it.
+	Content   string     `json:"content"`
+	Role      Role       `json:"role"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// Parts is the content split into text, images and audio, in order.
+	// Images embedded as markdown links in a text reply are parts too.
+	Parts []ResponsePart `json:"-"`
+}
+
+// PartType is the kind of a ResponsePart.
+type PartType string
+
+const (
+	PartText  PartType = "text"
+	PartImage PartType = "image"
+	PartAudio PartType = "audio"
+)
+
+// ResponsePart is a piece of a multi-modal reply.
+type ResponsePart struct {
+	Type PartType
+	// Text is the text of a text part, the alt text of an image or the transcript of audio.
+	Text string
+	// URL is where an image can be downloaded from, empty if its Data was returned inline.
+	URL string
+	// Data and MIMEType are the content of inline images and audio.
+	Data     []byte
+	MIMEType string
+}
+
+func (m *ResponseMessage) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Content   json.RawMessage `json:"content"`
+		Role      Role            `json:"role"`
+		ToolCalls []ToolCall      `json:"tool_calls"`
+		Audio     *struct {
+			Data       string `json:"data"`
+			Transcript string `json:"transcript"`
+		} `json:"audio"`
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	*m = ResponseMessage{Role: raw.Role, ToolCalls: raw.ToolCalls}
+
+	content := strings.TrimSpace(string(raw.Content))
+	switch {
+	case content == "" || content == "null":
+	case strings.HasPrefix(content, "["):
+		var parts []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			ImageURL struct {
+				URL string `json:"url"`
+			} `json:"image_url"`
+			Audio struct {
+				Data   string `json:"data"`
+				Format string `json:"format"`
+			} `json:"audio"`
+		}
+		err = json.Unmarshal(raw.Content, &parts)
+		if err != nil {
+			return err
+		}
+		var text strings.Builder
+		for _, p := range parts {
+			switch p.Type {
+			case "text":
+				text.WriteString(p.Text)
+				m.Parts = append(m.Parts, textParts(p.Text)...)
+			case "image_url", "image":
+				m.Parts = append(m.Parts, urlPart(PartImage, "", p.ImageURL.URL))
+			case "audio", "output_audio":
+				audio, err := base64.StdEncoding.DecodeString(p.Audio.Data)
+				if err != nil {
+					return err
+				}
+				part := ResponsePart{Type: PartAudio, Data: audio}
+				if p.Audio.Format != "" {
+					part.MIMEType = "audio/" + p.Audio.Format
+				}
+				m.Parts = append(m.Parts, part)
+			}
+		}
+		m.Content = text.String()
+	default:
+		err = json.Unmarshal(raw.Content, &m.Content)
+		if err != nil {
+			return err
+		}
+		m.Parts = textParts(m.Content)
+	}
+
+	if raw.Audio != nil {
+		audio, err := base64.StdEncoding.DecodeString(raw.Audio.Data)
+		if err != nil {
+			return err
+		}
+		m.Parts = append(m.Parts, ResponsePart{Type: PartAudio, Text: raw.Audio.Transcript, Data: audio})
+	}
+	return nil
+}
+
+var markdownImage = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)\)`)
+
+// textParts splits text into text parts and the images it embeds as markdown links.
+func textParts(text string) []ResponsePart {
+	var parts []ResponsePart
+	addText := func(s string) {
+		if strings.TrimSpace(s) != "" {
+			parts = append(parts, ResponsePart{Type: PartText, Text: s})
+		}
+	}
+	last := 0
+	for _, m := range markdownImage.FindAllStringSubmatchIndex(text, -1) {
+		addText(text[last:m[0]])
+		parts = append(parts, urlPart(PartImage, text[m[2]:m[3]], text[m[4]:m[5]]))
+		last = m[1]
+	}
+	addText(text[last:])
+	return parts
+}
+
+// urlPart returns a part for url, decoding data URIs.
+func urlPart(typ PartType, text, url string) ResponsePart {
+	part := ResponsePart{Type: typ, Text: text, URL: url}
+	header, payload, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !strings.HasPrefix(url, "data:") || !ok || !strings.HasSuffix(header, ";base64") {
+		return part
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return part
+	}
+	part.URL = ""
+	part.Data = data
+	part.MIMEType = strings.TrimSuffix(header, ";base64")
+	return part
+}
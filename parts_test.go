@@ -0,0 +1,49 @@
+package opencat_api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestResponseParts(t *testing.T) {
+	tests := []struct {
+		json    string
+		content string
+		parts   []ResponsePart
+	}{
+		{
+			`{"role": "assistant", "content": "Here it is: ![a cat](https://example.com/cat.png) Enjoy!"}`,
+			"Here it is: ![a cat](https://example.com/cat.png) Enjoy!",
+			[]ResponsePart{
+				{Type: PartText, Text: "Here it is: "},
+				{Type: PartImage, Text: "a cat", URL: "https://example.com/cat.png"},
+				{Type: PartText, Text: " Enjoy!"},
+			},
+		},
+		{
+			`{"role": "assistant", "content": [{"type": "text", "text": "A dot:"},
+			{"type": "image_url", "image_url": {"url": "data:image/png;base64,AQID"}}]}`,
+			"A dot:",
+			[]ResponsePart{
+				{Type: PartText, Text: "A dot:"},
+				{Type: PartImage, Data: []byte{1, 2, 3}, MIMEType: "image/png"},
+			},
+		},
+		{
+			`{"role": "assistant", "content": null, "audio": {"data": "AQID", "transcript": "hi"}}`,
+			"",
+			[]ResponsePart{{Type: PartAudio, Text: "hi", Data: []byte{1, 2, 3}}},
+		},
+	}
+	for _, tt := range tests {
+		var msg ResponseMessage
+		err := json.Unmarshal([]byte(tt.json), &msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Content != tt.content || !reflect.DeepEqual(msg.Parts, tt.parts) {
+			t.Errorf("got %q %+v, want %q %+v", msg.Content, msg.Parts, tt.content, tt.parts)
+		}
+	}
+}
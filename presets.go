@@ -0,0 +1,58 @@
+package opencat_api
+
+// Preset is a named set of generation parameters, translated to suitable values for each provider.
+type Preset string
+
+var (
+	// PresetPrecise favors focused, deterministic answers, e.g. for extraction and coding.
+	PresetPrecise Preset = "precise"
+	// PresetBalanced is a middle ground suitable for general chat.
+	PresetBalanced Preset = "balanced"
+	// PresetCreative favors varied, imaginative answers, e.g. for brainstorming and fiction.
+	PresetCreative Preset = "creative"
+)
+
+type presetParams struct {
+	temperature float64
+}
+
+// Temperature ranges differ: OpenAI and Qwen accept [0, 2], the others [0, 1],
+// and ERNIE and SparkDesk reject 0.
+var presets = map[Preset]map[Provider]presetParams{
+	PresetPrecise: {
+		ProviderOpenAI:    {temperature: 0.1},
+		ProviderAnthropic: {temperature: 0.1},
+		ProviderGoogle:    {temperature: 0.1},
+		ProviderBaidu:     {temperature: 0.1},
+		ProviderAlibaba:   {temperature: 0.1},
+		ProviderIFlytek:   {temperature: 0.1},
+	},
+	PresetBalanced: {
+		ProviderOpenAI:    {temperature: 0.7},
+		ProviderAnthropic: {temperature: 0.5},
+		ProviderGoogle:    {temperature: 0.5},
+		ProviderBaidu:     {temperature: 0.8},
+		ProviderAlibaba:   {temperature: 0.8},
+		ProviderIFlytek:   {temperature: 0.5},
+	},
+	PresetCreative: {
+		ProviderOpenAI:    {temperature: 1.2},
+		ProviderAnthropic: {temperature: 1},
+		ProviderGoogle:    {temperature: 0.9},
+		ProviderBaidu:     {temperature: 1},
+		ProviderAlibaba:   {temperature: 1.3},
+		ProviderIFlytek:   {temperature: 0.9},
+	},
+}
+
+// WithPreset applies the parameters of a preset for the provider of the request model,
+// overriding the request's own values.
+func WithPreset(p Preset) RequestOption {
+	return func(r *ChatRequest) {
+		params, ok := presets[p][r.provider()]
+		if !ok {
+			return
+		}
+		r.Temperature = params.temperature
+	}
+}
@@ -0,0 +1,146 @@
+// Package prompt renders chat messages from named text/template templates.
+//
+// A template is split into messages by role actions: {{system}}, {{user}} and {{assistant}} start a message
+// with that role, and {{role "tool"}} one with any role. Text before the first role action is a user message.
+// For example:
+//
+//	{{system}}You are a translator into {{.Language}}.
+//	{{user}}{{template "_context" .}}Translate: {{.Text}}
+//
+// Templates share one namespace, so partials defined in one file, with {{define}} or as a file of their own,
+// can be used by all the others. Variables missing from a map are an error instead of "<no value>".
+package prompt
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+
+	api "github.com/j178/opencat-api"
+)
+
+// Registry holds named templates.
+type Registry struct {
+	mu   sync.RWMutex
+	tmpl *template.Template
+}
+
+func NewRegistry() *Registry {
+	return &Registry{tmpl: template.New("").Funcs(roleFuncs("")).Option("missingkey=error")}
+}
+
+// Parse adds a template with the given name.
+func (r *Registry) Parse(name, text string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err := r.tmpl.New(name).Parse(text)
+	if err != nil {
+		return fmt.Errorf("parse template %s: %w", name, err)
+	}
+	return nil
+}
+
+// ParseFS adds the templates of the files of fsys matching patterns, see fs.Glob.
+// Each template is named after its file, without directory and extension: prompts/summarize.tmpl is "summarize".
+func (r *Registry) ParseFS(fsys fs.FS, patterns ...string) error {
+	for _, pattern := range patterns {
+		files, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("pattern %q matches no files", pattern)
+		}
+		for _, file := range files {
+			data, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return err
+			}
+			name := strings.TrimSuffix(path.Base(file), path.Ext(file))
+			err = r.Parse(name, string(data))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Render executes the named template with vars and splits the output into messages.
+// Messages are trimmed of surrounding whitespace, and empty ones are left out.
+func (r *Registry) Render(name string, vars any) ([]api.Message, error) {
+	r.mu.RLock()
+	tmpl, err := r.tmpl.Clone()
+	r.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if tmpl.Lookup(name) == nil {
+		return nil, fmt.Errorf("template %s not found", name)
+	}
+
+	// The markers are unique per call, so variables can't inject a role.
+	nonce := make([]byte, 8)
+	_, _ = rand.Read(nonce)
+	marker := "\x00" + hex.EncodeToString(nonce) + ":"
+	tmpl.Funcs(roleFuncs(marker))
+
+	var out strings.Builder
+	err = tmpl.ExecuteTemplate(&out, name, vars)
+	if err != nil {
+		return nil, err
+	}
+	return splitMessages(out.String(), marker)
+}
+
+func roleFuncs(marker string) template.FuncMap {
+	role := func(role string) string { return marker + role + "\x00" }
+	return template.FuncMap{
+		"role":      role,
+		"system":    func() string { return role(string(api.RoleSystem)) },
+		"user":      func() string { return role(string(api.RoleUser)) },
+		"assistant": func() string { return role(string(api.RoleAssistant)) },
+	}
+}
+
+func splitMessages(s, marker string) ([]api.Message, error) {
+	var messages []api.Message
+	add := func(role api.Role, content string) {
+		content = strings.TrimSpace(content)
+		if content != "" {
+			messages = append(messages, api.Message{Role: role, Content: content})
+		}
+	}
+
+	parts := strings.Split(s, marker)
+	add(api.RoleUser, parts[0])
+	for _, part := range parts[1:] {
+		role, content, ok := strings.Cut(part, "\x00")
+		if !ok {
+			return nil, errors.New("malformed role marker")
+		}
+		add(api.Role(role), content)
+	}
+	return messages, nil
+}
+
+// Template renders one template of a registry with variables of type T.
+type Template[T any] struct {
+	registry *Registry
+	name     string
+}
+
+// Typed returns the template with the given name, to be rendered with variables of type T.
+func Typed[T any](r *Registry, name string) *Template[T] {
+	return &Template[T]{registry: r, name: name}
+}
+
+func (t *Template[T]) Render(vars T) ([]api.Message, error) {
+	return t.registry.Render(t.name, vars)
+}
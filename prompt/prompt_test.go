@@ -0,0 +1,60 @@
+package prompt
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	api "github.com/j178/opencat-api"
+)
+
+func TestRegistry(t *testing.T) {
+	fsys := fstest.MapFS{
+		"prompts/translate.tmpl": {Data: []byte(`{{system}}You are a translator into {{.Language}}.
+{{user}}{{template "context" .}}Translate: {{.Text}}`)},
+		"prompts/context.tmpl": {Data: []byte(`{{if .Context}}Context: {{.Context}}
+{{end}}`)},
+	}
+	r := NewRegistry()
+	err := r.ParseFS(fsys, "prompts/*.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type vars struct {
+		Language, Text, Context string
+	}
+	got, err := Typed[vars](r, "translate").Render(vars{Language: "French", Text: "{{user}} hello", Context: "a greeting"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []api.Message{
+		{Role: api.RoleSystem, Content: "You are a translator into French."},
+		{Role: api.RoleUser, Content: "Context: a greeting\nTranslate: {{user}} hello"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	err = r.Parse("plain", `Hello {{.name}}{{assistant}}Hi!{{role "user"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = r.Render("plain", map[string]string{"name": "Bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []api.Message{{Role: api.RoleUser, Content: "Hello Bob"}, {Role: api.RoleAssistant, Content: "Hi!"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	_, err = r.Render("plain", map[string]string{})
+	if err == nil {
+		t.Errorf("expected error for missing variable")
+	}
+	_, err = r.Render("missing", nil)
+	if err == nil {
+		t.Errorf("expected error for missing template")
+	}
+}
@@ -0,0 +1,75 @@
+package opencat_api
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// QwenParams are options of Qwen models, see ChatRequest.Qwen.
+type QwenParams struct {
+	// EnableSearch lets the model search the web, when it decides it needs to.
+	EnableSearch bool `json:"enable_search,omitempty"`
+	// TopK samples from the k most likely tokens, up to 100. 0 leaves it to the model.
+	TopK int `json:"top_k,omitempty"`
+	// RepetitionPenalty penalizes repeated tokens, 1.0 for no penalty.
+	RepetitionPenalty float64 `json:"repetition_penalty,omitempty"`
+}
+
+func (p *QwenParams) validate(model ChatModel, provider Provider) error {
+	if provider != ProviderAlibaba {
+		return fmt.Errorf("Qwen options are not supported by %s", model)
+	}
+	if p.TopK < 0 || p.TopK > 100 {
+		return fmt.Errorf("Qwen top k must be between 0 and 100, got %d", p.TopK)
+	}
+	if p.RepetitionPenalty < 0 {
+		return fmt.Errorf("Qwen repetition penalty must be positive, got %v", p.RepetitionPenalty)
+	}
+	return nil
+}
+
+// qwenAdapter translates requests for Qwen models, which take their options at the top level
+// and report a finish reason of "null" until the reply is complete.
+type qwenAdapter struct {
+	openCatAdapter
+}
+
+func (qwenAdapter) EncodeRequest(chat ChatRequest) (string, []byte, error) {
+	params := chat.Qwen
+	chat.Qwen = nil
+	body, err := requestBody(chat, params)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := json.Marshal(body)
+	return "/1/chat", data, err
+}
+
+func (a qwenAdapter) DecodeResponse(body []byte) (ChatResponse, error) {
+	r, err := a.openCatAdapter.DecodeResponse(body)
+	for i := range r.Choices {
+		r.Choices[i].FinishReason = qwenFinishReason(r.Choices[i].FinishReason)
+	}
+	return r, err
+}
+
+func (a qwenAdapter) DecodeStreamEvent(data []byte) ([]ChatDelta, error) {
+	deltas, err := a.openCatAdapter.DecodeStreamEvent(data)
+	for i := range deltas {
+		deltas[i].FinishReason = qwenFinishReason(deltas[i].FinishReason)
+	}
+	return deltas, err
+}
+
+// qwenFinishReason maps the finish reasons of Qwen to the ones of the other providers.
+func qwenFinishReason(reason string) string {
+	switch reason {
+	case "null":
+		// Not finished yet.
+		return ""
+	case "function_call":
+		return "tool_calls"
+	default:
+		return reason
+	}
+}
@@ -0,0 +1,56 @@
+package opencat_api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestQwen(t *testing.T) {
+	chat := ChatRequest{
+		Model:    ChatModelQWENPlus,
+		Messages: []Message{User("今天的新闻？")},
+		Qwen:     &QwenParams{EnableSearch: true, TopK: 50, RepetitionPenalty: 1.1},
+	}
+	if err := chat.validate(); err != nil {
+		t.Fatal(err)
+	}
+	adapter := adapterFor(&Config{}, chat)
+	_, data, err := adapter.EncodeRequest(chat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]any
+	err = json.Unmarshal(data, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body["enable_search"] != true || body["top_k"] != float64(50) || body["repetition_penalty"] != 1.1 ||
+		body["qwen"] != nil {
+		t.Errorf("unexpected request: %s", data)
+	}
+
+	deltas, err := adapter.DecodeStreamEvent([]byte(`{"choices": [{"delta": {"content": "今"}, "finish_reason": "null"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deltas) != 1 || deltas[0].Content != "今" || deltas[0].FinishReason != "" {
+		t.Errorf("unexpected deltas: %+v", deltas)
+	}
+	resp, err := adapter.DecodeResponse([]byte(`{"choices": [{"message": {"content": "今"}, "finish_reason": "stop"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Choices[0].FinishReason != "stop" {
+		t.Errorf("unexpected finish reason: %q", resp.Choices[0].FinishReason)
+	}
+
+	chat.Qwen.TopK = 101
+	if err := chat.validate(); err == nil {
+		t.Error("expected an error for a top k over 100")
+	}
+	chat.Qwen.TopK = 0
+	chat.Model = ChatModelGPT4
+	if err := chat.validate(); err == nil {
+		t.Error("expected an error for Qwen options on another model")
+	}
+}
@@ -0,0 +1,336 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/j178/opencat-api/internal/ws"
+)
+
+// RelayDelta is a delta of a relayed stream, as sent to the browser.
+type RelayDelta struct {
+	Index        int             `json:"index"`
+	Content      string          `json:"content,omitempty"`
+	ToolCalls    []ToolCallDelta `json:"tool_calls,omitempty"`
+	FinishReason string          `json:"finish_reason,omitempty"`
+}
+
+func newRelayDelta(delta ChatDelta) RelayDelta {
+	return RelayDelta{
+		Index:        delta.Index,
+		Content:      delta.Content,
+		ToolCalls:    delta.ToolCalls,
+		FinishReason: delta.FinishReason,
+	}
+}
+
+// RelayError is the error that ended a relayed stream, as sent to the browser.
+type RelayError struct {
+	Error    string        `json:"error"`
+	Category ErrorCategory `json:"category"`
+}
+
+func newRelayError(err error) RelayError {
+	return RelayError{Error: err.Error(), Category: CategorizeError(err)}
+}
+
+// DecodeChatRequest decodes the body of r as a ChatRequest.
+// It lets the browser choose the model and every parameter, so it should only be used behind authentication;
+// otherwise build the request on the server from the parts the browser may set.
+func DecodeChatRequest(r *http.Request) (ChatRequest, error) {
+	var chat ChatRequest
+	err := json.NewDecoder(r.Body).Decode(&chat)
+	return chat, err
+}
+
+// SSEHandler relays chat streams to browsers as Server-Sent Events, see Client.SSEHandler.
+//
+// Each delta is sent as a delta event whose data is a RelayDelta. The stream ends with a done event,
+// or an error event whose data is a RelayError. Comments are sent every Heartbeat to keep proxies from
+// closing idle connections while the model thinks. When the browser disconnects, the upstream request is canceled.
+type SSEHandler struct {
+	// NewRequest returns the chat request to send for an HTTP request, or an error to reply with 400 Bad Request.
+	NewRequest func(r *http.Request) (ChatRequest, error)
+	// Options are applied to every request.
+	Options []RequestOption
+	// Heartbeat is 15 seconds if 0.
+	Heartbeat time.Duration
+
+	client *Client
+}
+
+// SSEHandler returns a handler relaying the stream of the chat request returned by newRequest.
+// If newRequest is nil, the request is decoded from the body with DecodeChatRequest.
+func (c *Client) SSEHandler(newRequest func(r *http.Request) (ChatRequest, error), opts ...RequestOption) *SSEHandler {
+	if newRequest == nil {
+		newRequest = DecodeChatRequest
+	}
+	return &SSEHandler{NewRequest: newRequest, Options: opts, client: c}
+}
+
+func (h *SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	chat, err := h.NewRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	chat.Stream = true
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Disables the buffering of nginx.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	var mu sync.Mutex
+	write := func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, format, args...)
+		_ = rc.Flush()
+	}
+	event := func(name string, v any) {
+		data, _ := json.Marshal(v)
+		write("event: %s\ndata: %s\n\n", name, data)
+	}
+
+	heartbeat := h.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	// The writer must not be used after ServeHTTP returns.
+	defer wg.Wait()
+	defer close(done)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-ticker.C:
+				write(": ping\n\n")
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// The request context is canceled when the browser disconnects, which cancels the upstream stream.
+	err = h.client.StreamChatDeltas(
+		r.Context(), chat, func(delta ChatDelta) {
+			event("delta", newRelayDelta(delta))
+		}, h.Options...,
+	)
+	if r.Context().Err() != nil {
+		return
+	}
+	if err != nil {
+		event("error", newRelayError(err))
+		return
+	}
+	write("event: done\ndata: {}\n\n")
+}
+
+// RelayRequest is a chat request sent by a browser over a WebSocket.
+type RelayRequest struct {
+	// ID is chosen by the browser to match the messages of the reply, so requests can stream at once.
+	ID      string      `json:"id"`
+	Request ChatRequest `json:"request"`
+}
+
+// RelayMessage is a message sent to a browser over a WebSocket.
+// Type is delta, done or error, with the Delta or the Error set accordingly.
+type RelayMessage struct {
+	ID    string      `json:"id"`
+	Type  string      `json:"type"`
+	Delta *RelayDelta `json:"delta,omitempty"`
+	Error *RelayError `json:"error,omitempty"`
+}
+
+// WebSocketHandler relays chat streams to browsers over WebSockets, see Client.WebSocketHandler.
+//
+// Browsers send RelayRequest messages and get RelayMessage messages back: the deltas of each request,
+// then a done or an error message. Closing the connection cancels the requests still streaming.
+// Pings are sent every Heartbeat to keep proxies from closing idle connections.
+type WebSocketHandler struct {
+	// CheckOrigin reports whether the page a browser connects from, in the Origin header, may open a connection.
+	// Browsers don't apply CORS to WebSockets, so without it any site could connect with the cookies of the user.
+	// If it returns false, the handshake is refused with 403 Forbidden. If nil, only connections without an Origin,
+	// which don't come from browsers, and from the host of the handler are accepted.
+	CheckOrigin func(r *http.Request) bool
+	// Authorize is called before the connection is upgraded, to check a cookie or a token.
+	// If it returns an error, the handshake is refused with 401 Unauthorized. If nil, every connection is accepted.
+	Authorize func(r *http.Request) error
+	// NewRequest returns the chat request to send for a request sent by the browser on the connection opened by r.
+	// If it returns an error, the browser gets an error message. If nil, requests are sent as is, see DecodeChatRequest.
+	NewRequest func(r *http.Request, chat ChatRequest) (ChatRequest, error)
+	// Options are applied to every request.
+	Options []RequestOption
+	// RateLimit is the maximum number of requests per second on a connection, requests over the limit wait
+	// for their turn. Burst is the number of requests that can be sent at once before RateLimit applies.
+	// If RateLimit is 0, requests are not limited.
+	RateLimit float64
+	Burst     int
+	// MaxConcurrent is the maximum number of requests streaming at once on a connection, 4 if 0.
+	// Reading the next requests waits until one of them completes.
+	MaxConcurrent int
+	// Heartbeat is 15 seconds if 0.
+	Heartbeat time.Duration
+	// WriteTimeout is how long a message can take to be sent to the browser, 10 seconds if 0.
+	// Browsers that stop reading are disconnected.
+	WriteTimeout time.Duration
+
+	client *Client
+}
+
+// WebSocketHandler returns a handler relaying chat streams over WebSockets, to be mounted on a path
+// like /ws/chat of an existing server.
+func (c *Client) WebSocketHandler(opts ...RequestOption) *WebSocketHandler {
+	return &WebSocketHandler{Options: opts, client: c}
+}
+
+// sameOrigin reports whether r doesn't come from a browser, or comes from a page of the host it is sent to.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	checkOrigin := h.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	if h.Authorize != nil {
+		err := h.Authorize(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+	conn, err := ws.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	writeTimeout := h.WriteTimeout
+	if writeTimeout <= 0 {
+		writeTimeout = 10 * time.Second
+	}
+	conn.SetWriteTimeout(writeTimeout)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	// The request context is not canceled when a hijacked connection closes,
+	// ctx is canceled when the browser closes it instead.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	defer cancel()
+	send := func(msg RelayMessage) {
+		data, _ := json.Marshal(msg)
+		err := conn.Write(data)
+		if err != nil {
+			// Closing the connection stops reading it, which cancels the requests.
+			conn.Close()
+		}
+	}
+
+	heartbeat := h.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = conn.Ping()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	maxConcurrent := h.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = 4
+	}
+	streams := make(chan struct{}, maxConcurrent)
+	var limiter rateLimiter
+	for {
+		data, err := conn.Read()
+		if err != nil {
+			return
+		}
+		var req RelayRequest
+		err = json.Unmarshal(data, &req)
+		if err == nil && h.NewRequest != nil {
+			req.Request, err = h.NewRequest(r, req.Request)
+		}
+		if err != nil {
+			e := newRelayError(err)
+			send(RelayMessage{ID: req.ID, Type: "error", Error: &e})
+			continue
+		}
+		if h.RateLimit > 0 {
+			// Reading the next requests waits too, so a connection can't queue up requests.
+			err = limiter.wait(ctx, h.client.clock, h.RateLimit, h.Burst)
+			if err != nil {
+				return
+			}
+		}
+
+		select {
+		case streams <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-streams }()
+			h.relay(ctx, req, send)
+		}()
+	}
+}
+
+// relay sends the reply of a request to the browser.
+func (h *WebSocketHandler) relay(ctx context.Context, req RelayRequest, send func(RelayMessage)) {
+	chat := req.Request
+	chat.Stream = true
+	err := h.client.StreamChatDeltas(
+		ctx, chat, func(delta ChatDelta) {
+			d := newRelayDelta(delta)
+			send(RelayMessage{ID: req.ID, Type: "delta", Delta: &d})
+		}, h.Options...,
+	)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		e := newRelayError(err)
+		send(RelayMessage{ID: req.ID, Type: "error", Error: &e})
+		return
+	}
+	send(RelayMessage{ID: req.ID, Type: "done"})
+}
@@ -0,0 +1,276 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/j178/opencat-api/internal/ws"
+)
+
+func TestSSEHandler(t *testing.T) {
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"delta\":\"Hel\"}\n\n")
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+			io.WriteString(w, "data: {\"delta\":\"lo\",\"finishReason\":\"stop\"}\n\ndata: [DONE]\n\n")
+		},
+	)
+
+	h := c.SSEHandler(nil, WithModel(ChatModelGPT4))
+	h.Heartbeat = 10 * time.Millisecond
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"messages": [{"role": "user", "content": "Hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	got := string(body)
+	for _, want := range []string{
+		"event: delta\ndata: {\"index\":0,\"content\":\"Hel\"}\n\n",
+		": ping\n\n",
+		"event: delta\ndata: {\"index\":0,\"content\":\"lo\",\"finish_reason\":\"stop\"}\n\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in %q", want, got)
+		}
+	}
+	if !strings.HasSuffix(got, "event: done\ndata: {}\n\n") {
+		t.Errorf("stream not done: %q", got)
+	}
+
+	resp, err = http.Post(srv.URL, "application/json", strings.NewReader(`{oops`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
+
+func TestSSEHandlerDisconnect(t *testing.T) {
+	canceled := make(chan struct{})
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"delta\":\"Hel\"}\n\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			close(canceled)
+		},
+	)
+	srv := httptest.NewServer(c.SSEHandler(nil, WithModel(ChatModelGPT4)))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(
+		ctx, "POST", srv.URL, strings.NewReader(`{"messages": [{"role": "user", "content": "Hi"}]}`),
+	)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	_, err = resp.Body.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	resp.Body.Close()
+
+	select {
+	case <-canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream request not canceled")
+	}
+}
+
+func TestWebSocketHandler(t *testing.T) {
+	var models []string
+	canceled := make(chan struct{})
+	clock := NewFakeClock(time.Now())
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				Model    string    `json:"model"`
+				Messages []Message `json:"messages"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			models = append(models, body.Model)
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"delta\":\"Hel\"}\n\n")
+			w.(http.Flusher).Flush()
+			if body.Messages[0].Content == "hang" {
+				<-r.Context().Done()
+				close(canceled)
+				return
+			}
+			io.WriteString(w, "data: {\"delta\":\"lo\",\"finishReason\":\"stop\"}\n\ndata: [DONE]\n\n")
+		},
+		WithClock(clock),
+	)
+
+	h := c.WebSocketHandler()
+	h.Authorize = func(r *http.Request) error {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			return errors.New("unauthorized")
+		}
+		return nil
+	}
+	h.NewRequest = func(r *http.Request, chat ChatRequest) (ChatRequest, error) {
+		chat.Model = ChatModelGPT4
+		return chat, nil
+	}
+	h.RateLimit = 1
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	_, err := ws.Dial(context.Background(), url, nil)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected an unauthorized handshake, got %v", err)
+	}
+	_, err = ws.Dial(
+		context.Background(), url,
+		http.Header{"Authorization": {"Bearer secret"}, "Origin": {"https://attacker.example"}},
+	)
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("expected a cross-origin handshake to be refused, got %v", err)
+	}
+	conn, err := ws.Dial(
+		context.Background(), url, http.Header{"Authorization": {"Bearer secret"}, "Origin": {srv.URL}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	read := func() RelayMessage {
+		data, err := conn.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg RelayMessage
+		err = json.Unmarshal(data, &msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	for _, id := range []string{"a", "b"} {
+		err = conn.Write([]byte(`{"id": "` + id + `", "request": {"messages": [{"role": "user", "content": "Hi"}]}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var content string
+		for msg := read(); msg.Type != "done"; msg = read() {
+			if msg.ID != id || msg.Type != "delta" {
+				t.Fatalf("unexpected message %+v", msg)
+			}
+			content += msg.Delta.Content
+		}
+		if content != "Hello" {
+			t.Errorf("unexpected content %q", content)
+		}
+	}
+	if len(models) != 2 || models[0] != string(ChatModelGPT4) {
+		t.Errorf("unexpected models %v", models)
+	}
+	if len(clock.Sleeps()) != 1 {
+		t.Errorf("second request not rate limited: %v", clock.Sleeps())
+	}
+
+	err = conn.Write([]byte(`{oops`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := read(); msg.Type != "error" || msg.Error == nil {
+		t.Errorf("expected an error message, got %+v", msg)
+	}
+
+	err = conn.Write([]byte(`{"id": "c", "request": {"messages": [{"role": "user", "content": "hang"}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := read(); msg.ID != "c" || msg.Type != "delta" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+	conn.Close()
+	select {
+	case <-canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream request not canceled")
+	}
+}
+
+func TestWebSocketHandlerConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	var streaming, most int
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			streaming++
+			most = max(most, streaming)
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				streaming--
+				mu.Unlock()
+			}()
+			time.Sleep(20 * time.Millisecond)
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"delta\":\"Hi\",\"finishReason\":\"stop\"}\n\ndata: [DONE]\n\n")
+		},
+	)
+	h := c.WebSocketHandler(WithModel(ChatModelGPT4))
+	h.MaxConcurrent = 2
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	conn, err := ws.Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 6; i++ {
+		err = conn.Write(
+			[]byte(`{"id": "` + strconv.Itoa(i) + `", "request": {"messages": [{"role": "user", "content": "Hi"}]}}`),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for done := 0; done < 6; {
+		data, err := conn.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg RelayMessage
+		_ = json.Unmarshal(data, &msg)
+		if msg.Type == "done" {
+			done++
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if most != 2 {
+		t.Errorf("%d requests streamed at once, want 2", most)
+	}
+}
@@ -0,0 +1,163 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// DiffOp is the kind of a DiffSpan.
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = "equal"
+	DiffDelete DiffOp = "delete"
+	DiffInsert DiffOp = "insert"
+)
+
+// DiffSpan is a run of text that is in both replies, only in the original one (deleted),
+// or only in the replayed one (inserted).
+type DiffSpan struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+}
+
+// ReplayTurn compares the original reply of a turn with the reply of the replay.
+type ReplayTurn struct {
+	// Index is the index of the original reply in the conversation.
+	Index int `json:"index"`
+	// Prompt is the text of the last user message before the reply.
+	Prompt   string          `json:"prompt"`
+	Original Message         `json:"original"`
+	Replayed ResponseMessage `json:"replayed"`
+	// Same reports whether the replies have the same content, see Message.Hash.
+	Same bool `json:"same"`
+	// Diff is a word by word diff of the text of the replies.
+	Diff []DiffSpan `json:"diff,omitempty"`
+	// Error is the error of the replay of the turn, if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// ReplayReport is the result of Replay, with a turn per assistant message of the conversation.
+type ReplayReport struct {
+	Model ChatModel    `json:"model"`
+	Turns []ReplayTurn `json:"turns"`
+}
+
+// Changed returns the turns whose replies differ or failed.
+func (r ReplayReport) Changed() []ReplayTurn {
+	var changed []ReplayTurn
+	for _, turn := range r.Turns {
+		if !turn.Same || turn.Error != "" {
+			changed = append(changed, turn)
+		}
+	}
+	return changed
+}
+
+// Replay sends a stored conversation again turn by turn, with opts like WithModel or WithTemperature,
+// to find which replies changed after a model update. Each assistant message is asked again with the
+// original messages before it, so a changed reply doesn't change the following turns.
+// Turns that fail are reported and the replay goes on; it stops only if ctx is done.
+// Messages must not use images created with NewImage, which can be sent once.
+func (c *Client) Replay(ctx context.Context, messages []Message, opts ...RequestOption) (ReplayReport, error) {
+	var report ReplayReport
+	for i, msg := range messages {
+		if msg.Role != RoleAssistant {
+			continue
+		}
+		turn := ReplayTurn{Index: i, Original: msg}
+		for j := i - 1; j >= 0; j-- {
+			if messages[j].Role == RoleUser {
+				turn.Prompt = messages[j].Text()
+				break
+			}
+		}
+
+		chat, err := c.prepareChat(ChatRequest{Messages: messages[:i:i]}, opts)
+		if err == nil {
+			report.Model = chat.Model
+			var resp ChatResponse
+			resp, err = c.chatPrepared(ctx, chat)
+			if err == nil && len(resp.Choices) == 0 {
+				err = errors.New("response has no choices")
+			}
+			if err == nil {
+				turn.Replayed = resp.Choices[0].Message
+			}
+		}
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		if err != nil {
+			turn.Error = err.Error()
+		} else {
+			turn.Same = msg.Hash() == turn.Replayed.Hash()
+			if !turn.Same {
+				turn.Diff = diffWords(msg.Text(), turn.Replayed.Content)
+			}
+		}
+		report.Turns = append(report.Turns, turn)
+	}
+	return report, nil
+}
+
+var wordSpanRe = regexp.MustCompile(`\S+\s*`)
+
+// maxDiffWords bounds the size of the table of diffWords, longer replies are diffed as a whole.
+const maxDiffWords = 2000
+
+// diffWords returns the diff of a and b, word by word, with the whitespace following each word.
+func diffWords(a, b string) []DiffSpan {
+	wa, wb := wordSpanRe.FindAllString(a, -1), wordSpanRe.FindAllString(b, -1)
+	if len(wa) > maxDiffWords || len(wb) > maxDiffWords {
+		return appendSpan(appendSpan(nil, DiffDelete, a), DiffInsert, b)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of wa[i:] and wb[j:].
+	lcs := make([][]int, len(wa)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(wb)+1)
+	}
+	for i := len(wa) - 1; i >= 0; i-- {
+		for j := len(wb) - 1; j >= 0; j-- {
+			if strings.TrimSpace(wa[i]) == strings.TrimSpace(wb[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var spans []DiffSpan
+	i, j := 0, 0
+	for i < len(wa) && j < len(wb) {
+		switch {
+		case strings.TrimSpace(wa[i]) == strings.TrimSpace(wb[j]):
+			spans = appendSpan(spans, DiffEqual, wb[j])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			spans = appendSpan(spans, DiffDelete, wa[i])
+			i++
+		default:
+			spans = appendSpan(spans, DiffInsert, wb[j])
+			j++
+		}
+	}
+	spans = appendSpan(spans, DiffDelete, strings.Join(wa[i:], ""))
+	return appendSpan(spans, DiffInsert, strings.Join(wb[j:], ""))
+}
+
+// appendSpan appends text to the last span if it has the same op, or as a new span.
+func appendSpan(spans []DiffSpan, op DiffOp, text string) []DiffSpan {
+	if text == "" {
+		return spans
+	}
+	if n := len(spans); n > 0 && spans[n-1].Op == op {
+		spans[n-1].Text += text
+		return spans
+	}
+	return append(spans, DiffSpan{Op: op, Text: text})
+}
@@ -0,0 +1,81 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestDiffWords(t *testing.T) {
+	got := diffWords("The cat sat on the mat.", "The dog sat on the  mat.")
+	want := []DiffSpan{
+		{DiffEqual, "The "},
+		{DiffDelete, "cat "},
+		{DiffInsert, "dog "},
+		{DiffEqual, "sat on the  mat."},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffWords = %+v, want %+v", got, want)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var models []string
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				Model    string
+				Messages []Message
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			models = append(models, body.Model)
+			reply := "4"
+			if len(body.Messages) > 2 {
+				reply = "Paris is the capital of France."
+			}
+			fmt.Fprintf(w, `{"choices": [{"message": {"role": "assistant", "content": %q}}]}`, reply)
+		},
+	)
+
+	report, err := c.Replay(
+		context.Background(),
+		[]Message{
+			User("2+2?"),
+			Assistant("4"),
+			User("Capital of France?"),
+			Assistant("The capital of France is Paris."),
+		},
+		WithModel(ChatModelGPT4Turbo),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 || models[0] != string(ChatModelGPT4Turbo) || report.Model != ChatModelGPT4Turbo {
+		t.Errorf("replayed with %v", models)
+	}
+	if len(report.Turns) != 2 || !report.Turns[0].Same || report.Turns[1].Same {
+		t.Fatalf("unexpected turns: %+v", report.Turns)
+	}
+	changed := report.Changed()
+	if len(changed) != 1 || changed[0].Index != 3 || changed[0].Prompt != "Capital of France?" ||
+		changed[0].Diff[0] != (DiffSpan{DiffDelete, "The "}) {
+		t.Errorf("unexpected changed turns: %+v", changed)
+	}
+
+	// Each turn is prepared once, so a deprecated model is warned about once per turn.
+	const deprecated ChatModel = "gpt-4-replay"
+	restoreDeprecations(t)
+	Deprecate(deprecated, Deprecation{Replacement: ChatModelGPT4})
+	warnings := 0
+	c.OnWarning(func(WarningEvent) { warnings++ })
+	_, err = c.Replay(context.Background(), []Message{User("2+2?"), Assistant("4")}, WithModel(deprecated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warnings != 1 {
+		t.Errorf("%d warnings for a turn, want 1", warnings)
+	}
+}
@@ -0,0 +1,64 @@
+package opencat_api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+)
+
+// WithMaxRetries makes the client retry requests up to n times when they fail with a network error,
+// are rate limited or hit a server error. Retries back off exponentially, or as the server asks with Retry-After.
+func WithMaxRetries(n int) ClientOption {
+	return func(c *Client) {
+		c.configOption(func(cfg *Config) { cfg.MaxRetries = n })
+	}
+}
+
+// OnRetry calls fn before every retry of the client, see RetryEvent. Call the returned function to stop.
+func (c *Client) OnRetry(fn func(RetryEvent)) (unsubscribe func()) {
+	return c.events.Subscribe(
+		func(e Event) {
+			if r, ok := e.(RetryEvent); ok {
+				fn(r)
+			}
+		},
+	)
+}
+
+type retryKey struct{}
+
+// withRetry marks the requests made with ctx as retries of an earlier attempt, for spend tracking.
+func withRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey{}, true)
+}
+
+func isRetry(ctx context.Context) bool {
+	retry, _ := ctx.Value(retryKey{}).(bool)
+	return retry
+}
+
+// shouldRetry reports whether a request that got resp or err may succeed if sent again.
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		// Timeouts of a single attempt are retried, the caller's are not.
+		return ctx.Err() == nil
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 &&
+		resp.StatusCode != http.StatusNotImplemented
+}
+
+// retryDelay returns how long to wait before the given retry, starting at 1.
+func retryDelay(retry int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			return min(time.Duration(secs)*time.Second, retryMaxDelay)
+		}
+	}
+	return min(retryBaseDelay<<(retry-1), retryMaxDelay)
+}
@@ -0,0 +1,67 @@
+package opencat_api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRetry(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.Header().Set("Retry-After", "0")
+				http.Error(w, "slow down", http.StatusTooManyRequests)
+				return
+			}
+			fmt.Fprint(
+				w, `{"id": "1", "model": "gpt-4", "choices": [{"message": {"role": "assistant", "content": "hi"}}],
+				"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}}`,
+			)
+		},
+		WithMaxRetries(2),
+	)
+	var retries []RetryEvent
+	c.OnRetry(func(e RetryEvent) { retries = append(retries, e) })
+
+	resp, err := c.Chat(
+		context.Background(),
+		ChatRequest{Model: ChatModelGPT4, Messages: []Message{{Role: RoleUser, Content: "hello"}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Choices[0].Message.Content != "hi" || calls.Load() != 2 {
+		t.Errorf("got %q after %d calls", resp.Choices[0].Message.Content, calls.Load())
+	}
+	if len(retries) != 1 || retries[0].Attempt != 2 || retries[0].Delay != 0 || CategorizeError(retries[0].Cause) != ErrorRateLimited {
+		t.Errorf("retries = %+v", retries)
+	}
+
+	spend := c.Spend()
+	if spend.Organic.Requests != 1 || spend.Retries.Requests != 1 {
+		t.Errorf("requests: organic %d, retries %d", spend.Organic.Requests, spend.Retries.Requests)
+	}
+	if spend.Organic.PromptTokens != 10 || spend.Organic.CompletionTokens != 5 || spend.Organic.Cost["USD"] == 0 {
+		t.Errorf("organic spend = %+v", spend.Organic)
+	}
+
+	_, err = c.chatValidated(
+		context.Background(),
+		func() ChatRequest {
+			return ChatRequest{Model: ChatModelGPT4, Messages: []Message{{Role: RoleUser, Content: "hello"}}}
+		},
+		func(string) error { return fmt.Errorf("not good") },
+		1,
+	)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	spend = c.Spend()
+	if spend.Retries.PromptTokens != 10 || spend.Organic.PromptTokens != 20 {
+		t.Errorf("spend after validation retry = %+v", spend)
+	}
+}
@@ -0,0 +1,114 @@
+package opencat_api
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// imageExtension returns the file extension of an image from its first bytes, .png if unknown.
+func imageExtension(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return ".png"
+	case bytes.HasPrefix(head, []byte("\xff\xd8\xff")):
+		return ".jpg"
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		return ".webp"
+	case bytes.HasPrefix(head, []byte("GIF8")):
+		return ".gif"
+	default:
+		return ".png"
+	}
+}
+
+// audioExtension returns the file extension of audio from its first bytes, empty if unknown, like raw PCM.
+func audioExtension(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("ID3")):
+		return ".mp3"
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return ".opus"
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return ".flac"
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return ".wav"
+	case len(head) >= 2 && head[0] == 0xff && head[1]&0xf6 == 0xf0:
+		// An ADTS frame header, with the layer bits of AAC.
+		return ".aac"
+	case len(head) >= 2 && head[0] == 0xff && head[1]&0xe0 == 0xe0:
+		// An MPEG audio frame header without an ID3 tag.
+		return ".mp3"
+	default:
+		return ""
+	}
+}
+
+// writeFileAtomic writes r to path through a temporary file in the same directory renamed over path,
+// so path never holds a partial file. The directories of path are created.
+func writeFileAtomic(path string, r io.Reader) error {
+	dir := filepath.Dir(path)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// SaveImages writes the data of images to dir, created if missing, as image-1.png, image-2.jpg and so on,
+// with the extension of the format of each image. Files are written atomically, so they are never partial.
+// It returns the paths of the files. Images without data, returned as URLs, can't be saved.
+func SaveImages(dir string, images []GeneratedImage) ([]string, error) {
+	for i, img := range images {
+		if len(img.Data) == 0 {
+			return nil, fmt.Errorf("image %d has no data", i+1)
+		}
+	}
+	paths := make([]string, len(images))
+	for i, img := range images {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("image-%d%s", i+1, imageExtension(img.Data)))
+		err := writeFileAtomic(paths[i], bytes.NewReader(img.Data))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// SaveAudio writes audio, such as the stream of Speech, to path, creating its directory. If path has no
+// extension, the extension of the format of the audio is added. The file is written atomically, so it is
+// never partial, even if reading audio fails. It returns the path of the file.
+func SaveAudio(path string, audio io.Reader) (string, error) {
+	r := bufio.NewReader(audio)
+	head, err := r.Peek(12)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if filepath.Ext(path) == "" {
+		path += audioExtension(head)
+	}
+	return path, writeFileAtomic(path, r)
+}
@@ -0,0 +1,92 @@
+package opencat_api
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSaveImages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "gallery")
+	images := []GeneratedImage{
+		{Data: []byte("\x89PNG\r\n\x1a\nimage")},
+		{Data: []byte("\xff\xd8\xff\xe0image")},
+		{Data: []byte("RIFF\x00\x00\x00\x00WEBPimage")},
+	}
+	paths, err := SaveImages(dir, images)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(dir, "image-1.png"), filepath.Join(dir, "image-2.jpg"), filepath.Join(dir, "image-3.webp"),
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("got paths %v, want %v", paths, want)
+	}
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != string(images[i].Data) {
+			t.Errorf("%s: %q, %v", path, data, err)
+		}
+	}
+
+	_, err = SaveImages(dir, []GeneratedImage{{URL: "https://example.com/1.png"}})
+	if err == nil {
+		t.Error("expected an error for an image without data")
+	}
+}
+
+func TestSaveAudio(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "audio")
+	tests := []struct {
+		path  string
+		audio string
+		want  string
+	}{
+		{"speech", "ID3\x04\x00audio", "speech.mp3"},
+		{"speech", "OggSaudio", "speech.opus"},
+		{"speech", "RIFF\x00\x00\x00\x00WAVEfmt ", "speech.wav"},
+		{"speech", "\xff\xf1audio", "speech.aac"},
+		{"speech", "\xff\xfbaudio", "speech.mp3"},
+		{"speech.pcm", "\x00\x01", "speech.pcm"},
+		{"raw", "\x00\x01", "raw"},
+	}
+	for _, tt := range tests {
+		path, err := SaveAudio(filepath.Join(dir, tt.path), strings.NewReader(tt.audio))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != filepath.Join(dir, tt.want) {
+			t.Errorf("%q saved to %s, want %s", tt.audio, path, tt.want)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != tt.audio {
+			t.Errorf("%s: %q, %v", path, data, err)
+		}
+	}
+
+	// A failed read leaves neither a partial file nor a temporary one.
+	failing := io.MultiReader(strings.NewReader("ID3audio"), &errReader{errors.New("connection reset")})
+	_, err := SaveAudio(filepath.Join(dir, "failed"), failing)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "failed") || strings.HasPrefix(e.Name(), ".") {
+			t.Errorf("file %s left after a failed save", e.Name())
+		}
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,195 @@
+// Package scrub replaces personal data and secrets in recorded fixtures and debug dumps with stable fakes,
+// so reproduction cases can be shared without leaking customer data.
+//
+// Fakes are derived from a keyed hash of the original value: the same value gets the same fake everywhere
+// it appears, in every file scrubbed with the same key, so relationships in the data are preserved.
+package scrub
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rule replaces the matches of a pattern. Fake returns the replacement of a match, given a hash of it.
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Fake    func(match, hash string) string
+}
+
+var (
+	// RuleBearer drops bearer tokens, secrets are not faked.
+	RuleBearer = Rule{
+		"bearer", regexp.MustCompile(`(?i)\bBearer\s+[A-Za-z0-9._~+/=-]+`),
+		func(_, _ string) string { return "Bearer REDACTED" },
+	}
+	// RuleAPIKey drops API keys like sk-....
+	RuleAPIKey = Rule{
+		"api_key", regexp.MustCompile(`\b(?:sk|pk|rk)-[A-Za-z0-9_-]{16,}`),
+		func(_, _ string) string { return "sk-REDACTED" },
+	}
+	RuleEmail = Rule{
+		"email", regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+		func(_, hash string) string { return "user-" + hash[:8] + "@example.com" },
+	}
+	RuleUUID = Rule{
+		"uuid", regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`),
+		func(_, hash string) string {
+			return hash[:8] + "-" + hash[8:12] + "-" + hash[12:16] + "-" + hash[16:20] + "-" + hash[20:32]
+		},
+	}
+	// RuleID fakes prefixed identifiers like chatcmpl-8abc..., keeping the prefix.
+	RuleID = Rule{
+		"id", regexp.MustCompile(`\b(chatcmpl|cmpl|msg|call|file|req|user|org)[-_][A-Za-z0-9]{8,}\b`),
+		func(match, hash string) string {
+			i := strings.IndexAny(match, "-_")
+			return match[:i+1] + hash[:24]
+		},
+	}
+	// RulePhone fakes phone numbers: up to 15 digits after a +, or digits grouped like 415 555 0123
+	// or (415) 555-0123, so timestamps, amounts and other runs of digits are left alone.
+	RulePhone = Rule{
+		"phone", regexp.MustCompile(
+			`\+\d{7,15}\b|(?:\+\d{1,3}[ -]?)?(?:\(\d{1,4}\)|\b\d{2,4})[ -]\d{3,4}[ -]\d{4}\b`,
+		),
+		func(_, hash string) string { return fmt.Sprintf("+1 555 %07d", hexToInt(hash[:6])%10000000) },
+	}
+	// RuleIPv4 fakes IP addresses with addresses reserved for documentation.
+	RuleIPv4 = Rule{
+		"ipv4", regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`),
+		func(_, hash string) string { return fmt.Sprintf("192.0.2.%d", hexToInt(hash[:2])%254+1) },
+	}
+)
+
+// DefaultRules are the rules of a Scrubber created by New, applied in order.
+var DefaultRules = []Rule{RuleBearer, RuleAPIKey, RuleEmail, RuleUUID, RuleID, RulePhone, RuleIPv4}
+
+// sensitiveKeys are JSON object keys whose string values are replaced entirely.
+// "name" is not one of them, since it is mostly used for tools and functions.
+var sensitiveKeys = map[string]string{
+	"username":      "name",
+	"user":          "name",
+	"full_name":     "name",
+	"author":        "name",
+	"token":         "secret",
+	"api_key":       "secret",
+	"password":      "secret",
+	"authorization": "secret",
+	"secret":        "secret",
+}
+
+// Scrubber replaces sensitive data. It is not safe for concurrent use while rules or names are added.
+type Scrubber struct {
+	key   []byte
+	rules []Rule
+	names []string
+}
+
+// New returns a scrubber with the default rules. Fakes depend on key: use the same key for files
+// that must stay consistent with each other, and keep it private so fakes can't be reversed by guessing.
+func New(key string) *Scrubber {
+	return &Scrubber{key: []byte(key), rules: append([]Rule(nil), DefaultRules...)}
+}
+
+// AddRule adds a rule, applied after the others.
+func (s *Scrubber) AddRule(rule Rule) {
+	s.rules = append(s.rules, rule)
+}
+
+// AddNames makes the scrubber replace the given names, like customer or employee names found in the data,
+// wherever they appear in text. Names can't be reliably detected otherwise.
+func (s *Scrubber) AddNames(names ...string) {
+	s.names = append(s.names, names...)
+	// Replace longer names first, so "Ann Lee" wins over "Ann".
+	sort.SliceStable(s.names, func(i, j int) bool { return len(s.names[i]) > len(s.names[j]) })
+}
+
+func (s *Scrubber) hash(kind, value string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(kind + "\x00" + value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Scrubber) fakeName(name string) string {
+	return "Person-" + s.hash("name", name)[:6]
+}
+
+// String scrubs text.
+func (s *Scrubber) String(text string) string {
+	for _, name := range s.names {
+		if name != "" {
+			text = strings.ReplaceAll(text, name, s.fakeName(name))
+		}
+	}
+	for _, rule := range s.rules {
+		text = rule.Pattern.ReplaceAllStringFunc(
+			text, func(match string) string {
+				return rule.Fake(match, s.hash(rule.Name, match))
+			},
+		)
+	}
+	return text
+}
+
+// JSON scrubs a JSON document: string values are scrubbed like text, and the values of keys like
+// user, token or password are replaced entirely. Numbers and the structure of the document are kept.
+func (s *Scrubber) JSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	err := dec.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(s.value("", v), "", "  ")
+}
+
+func (s *Scrubber) value(key string, v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			v[k] = s.value(strings.ToLower(k), child)
+		}
+		return v
+	case []any:
+		for i, child := range v {
+			v[i] = s.value(key, child)
+		}
+		return v
+	case string:
+		switch sensitiveKeys[key] {
+		case "name":
+			if v == "" {
+				return v
+			}
+			return s.fakeName(v)
+		case "secret":
+			return "REDACTED"
+		}
+		// Strings may hold nested JSON, like the body of a recorded request.
+		if trimmed := strings.TrimSpace(v); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			if nested, err := s.JSON([]byte(trimmed)); err == nil {
+				var compact bytes.Buffer
+				if json.Compact(&compact, nested) == nil {
+					return compact.String()
+				}
+			}
+		}
+		return s.String(v)
+	default:
+		return v
+	}
+}
+
+func hexToInt(h string) int {
+	n, _ := strconv.ParseUint(h, 16, 64)
+	return int(n)
+}
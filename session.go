@@ -0,0 +1,69 @@
+package opencat_api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+// Backend is an additional API endpoint or account requests can be routed to, see Config.Backends.
+type Backend struct {
+	BaseURL string
+	// Token defaults to the token of the configuration.
+	Token string
+}
+
+type sessionKey struct{}
+
+// WithSession returns a context whose requests belong to the session id, such as a conversation.
+// When several backends are configured, all the requests of a session are sent to the same one,
+// so they benefit from server-side caches and behave consistently.
+func WithSession(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, id)
+}
+
+// SessionFromContext returns the session set by WithSession, if any.
+func SessionFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(sessionKey{}).(string)
+	return id, ok && id != ""
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// backend returns the backend a request should be sent to. Requests without a session use the main one.
+// Sessions are assigned with rendezvous hashing, so adding or removing a backend only moves
+// the sessions of that backend.
+func (cfg *Config) backend(ctx context.Context) Backend {
+	main := Backend{BaseURL: cfg.BaseURL, Token: cfg.Token}
+	session, ok := SessionFromContext(ctx)
+	if !ok || len(cfg.Backends) == 0 {
+		return main
+	}
+
+	best, bestScore := main, sessionScore(session, main)
+	for _, b := range cfg.Backends {
+		if b.Token == "" {
+			b.Token = cfg.Token
+		}
+		if score := sessionScore(session, b); score > bestScore {
+			best, bestScore = b, score
+		}
+	}
+	return best
+}
+
+func sessionScore(session string, b Backend) uint64 {
+	h := sha256.New()
+	h.Write([]byte(b.BaseURL))
+	h.Write([]byte{0})
+	h.Write([]byte(b.Token))
+	h.Write([]byte{0})
+	h.Write([]byte(session))
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
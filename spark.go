@@ -0,0 +1,74 @@
+package opencat_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// sparkDomains are the domains of the SparkDesk versions, which the API selects the model by.
+var sparkDomains = map[ChatModel]string{
+	ChatModelSparkDeskV1: "general",
+	ChatModelSparkDeskV2: "generalv2",
+	ChatModelSparkDeskV3: "generalv3",
+}
+
+// SparkParams are options of SparkDesk (讯飞星火) models, see ChatRequest.Spark.
+type SparkParams struct {
+	// Domain selects the model version, like generalv3. It defaults to the domain of the requested model.
+	Domain string `json:"domain,omitempty"`
+	// TopK samples from the k most likely tokens, from 1 to 6. 0 leaves it to the model, which uses 4.
+	TopK int `json:"top_k,omitempty"`
+	// ChatID identifies the conversation, for abuse monitoring.
+	ChatID string `json:"chat_id,omitempty"`
+	// UserID identifies the end user, up to 32 characters.
+	UserID string `json:"uid,omitempty"`
+}
+
+func (p *SparkParams) validate(model ChatModel, provider Provider) error {
+	if provider != ProviderIFlytek {
+		return fmt.Errorf("SparkDesk options are not supported by %s", model)
+	}
+	if p.Domain != "" && !strings.HasPrefix(p.Domain, "general") {
+		return fmt.Errorf("unknown SparkDesk domain %q", p.Domain)
+	}
+	if p.TopK < 0 || p.TopK > 6 {
+		return fmt.Errorf("SparkDesk top k must be between 1 and 6, got %d", p.TopK)
+	}
+	if len(p.UserID) > 32 {
+		return fmt.Errorf("SparkDesk user ID must be at most 32 characters, got %d", len(p.UserID))
+	}
+	return nil
+}
+
+// sparkAdapter translates requests for SparkDesk models, which select the model by domain,
+// take their options at the top level and reject values OpenAI accepts, like a temperature over 1.
+type sparkAdapter struct {
+	openCatAdapter
+}
+
+func (sparkAdapter) EncodeRequest(chat ChatRequest) (string, []byte, error) {
+	if chat.Temperature > 1 {
+		return "", nil, fmt.Errorf("temperature of %s must not be over 1, got %v", chat.Model, chat.Temperature)
+	}
+	if window := contextWindow(chat.Model); chat.MaxTokens > window {
+		return "", nil, fmt.Errorf("max tokens of %s must not be over %d, got %d", chat.Model, window, chat.MaxTokens)
+	}
+	params := SparkParams{}
+	if chat.Spark != nil {
+		params = *chat.Spark
+	}
+	if params.Domain == "" {
+		if info, ok := ModelInfoOf(chat.Model); ok {
+			params.Domain = sparkDomains[info.Name]
+		}
+	}
+	chat.Spark = nil
+
+	body, err := requestBody(chat, params)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := json.Marshal(body)
+	return "/1/chat", data, err
+}
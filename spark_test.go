@@ -0,0 +1,44 @@
+package opencat_api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSparkRequest(t *testing.T) {
+	chat := ChatRequest{
+		Model:       ChatModelSparkDeskV3,
+		Temperature: 0.5,
+		Messages:    []Message{User("你好")},
+		Spark:       &SparkParams{TopK: 3, UserID: "u1"},
+	}
+	if err := chat.validate(); err != nil {
+		t.Fatal(err)
+	}
+	_, data, err := adapterFor(&Config{}, chat).EncodeRequest(chat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]any
+	err = json.Unmarshal(data, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body["domain"] != "generalv3" || body["top_k"] != float64(3) || body["uid"] != "u1" || body["spark"] != nil {
+		t.Errorf("unexpected request: %s", data)
+	}
+
+	chat.Spark.TopK = 7
+	if err := chat.validate(); err == nil {
+		t.Error("expected an error for a top k over 6")
+	}
+	chat.Spark = nil
+	chat.Temperature = 1.5
+	if _, _, err := (sparkAdapter{}).EncodeRequest(chat); err == nil {
+		t.Error("expected an error for a temperature over 1")
+	}
+	chat.Temperature, chat.Model, chat.MaxTokens = 0, ChatModelSparkDeskV1, 8192
+	if _, _, err := (sparkAdapter{}).EncodeRequest(chat); err == nil {
+		t.Error("expected an error for max tokens over the context window")
+	}
+}
@@ -0,0 +1,297 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// firstWrite is an http.ResponseWriter that reports its first write.
+type firstWrite struct {
+	*httptest.ResponseRecorder
+	written chan struct{}
+}
+
+func (w *firstWrite) Write(p []byte) (int, error) {
+	if w.Body.Len() == 0 {
+		close(w.written)
+	}
+	return w.ResponseRecorder.Write(p)
+}
+
+func TestSpeechTo(t *testing.T) {
+	w := &firstWrite{httptest.NewRecorder(), make(chan struct{})}
+	c := newTestClient(
+		t, func(rw http.ResponseWriter, r *http.Request) {
+			rw.Write([]byte("ID3 first frame "))
+			rw.(http.Flusher).Flush()
+			// The first frame must reach the writer before the rest of the audio is generated.
+			<-w.written
+			rw.Write([]byte("second frame"))
+		},
+	)
+
+	n, err := c.SpeechTo(context.Background(), SpeechRequest{Model: SpeechModelTTS1, Input: "hi"}, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Body.String(); got != "ID3 first frame second frame" || n != int64(len(got)) {
+		t.Errorf("unexpected audio %q (%d bytes)", got, n)
+	}
+	if !w.Flushed {
+		t.Error("writer not flushed")
+	}
+}
+
+func TestSpeechFormat(t *testing.T) {
+	var body, outputFormat string
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			data, _ := io.ReadAll(r.Body)
+			body = string(data)
+			outputFormat = r.Header.Get("X-Microsoft-OutputFormat")
+			w.Write([]byte("OggS"))
+		},
+	)
+	speak := func(speech SpeechRequest) error {
+		r, err := c.Speech(context.Background(), speech)
+		if err == nil {
+			r.Close()
+		}
+		return err
+	}
+
+	err := speak(SpeechRequest{Model: SpeechModelTTS1, Input: "hi", ResponseFormat: SpeechOpus, Speed: 1.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body, `"response_format":"opus","speed":1.5`) {
+		t.Errorf("unexpected body %s", body)
+	}
+
+	err = speak(
+		SpeechRequest{
+			Model: SpeechModelAzure, Input: "hi", Voice: "en-US-JennyNeural", ResponseFormat: SpeechWAV, Speed: 0.5,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outputFormat != "riff-24khz-16bit-mono-pcm" || !strings.Contains(body, `<prosody rate="0.5">hi</prosody>`) {
+		t.Errorf("unexpected output format %s or body %s", outputFormat, body)
+	}
+
+	for _, speech := range []SpeechRequest{
+		{Model: SpeechModelTTS1, Input: "hi", ResponseFormat: "ogg"},
+		{Model: SpeechModelTTS1, Input: "hi", Speed: 5},
+		{Model: SpeechModelAzure, Input: "hi", ResponseFormat: SpeechFLAC},
+		{Model: SpeechModelAzure, Input: "hi", Speed: 3},
+	} {
+		if speak(speech) == nil {
+			t.Errorf("expected an error for %+v", speech)
+		}
+	}
+}
+
+func TestAzureSpeechConfig(t *testing.T) {
+	var body string
+	var header http.Header
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				body, header = string(data), r.Header
+				w.Write([]byte("ID3"))
+			},
+		),
+	)
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(
+		path, []byte(`{"token": "token", "base_url": "`+srv.URL+`", "azure_speech": {"region": "westus"}}`), 0644,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient("token")
+	err = c.ApplyConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	speak := func(speech SpeechRequest) {
+		t.Helper()
+		speech.Model, speech.Input = SpeechModelAzure, "你好"
+		r, err := c.Speech(context.Background(), speech)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Close()
+	}
+
+	speak(SpeechRequest{Voice: "zh-CN-XiaoxiaoNeural"})
+	if !strings.Contains(body, `xml:lang="zh-CN"`) || header.Get("X-Region") != "westus" ||
+		header.Get("X-Microsoft-OutputFormat") != "audio-16khz-128kbitrate-mono-mp3" {
+		t.Errorf("unexpected request %v %s", header, body)
+	}
+
+	WithAzureSpeech(AzureSpeechConfig{OutputFormat: "audio-48khz-192kbitrate-mono-mp3"})(c)
+	speak(SpeechRequest{Voice: "zh-CN-XiaoxiaoNeural", Azure: AzureSpeechConfig{Region: "japaneast", Language: "ja-JP"}})
+	if !strings.Contains(body, `xml:lang="ja-JP"`) || header.Get("X-Region") != "japaneast" ||
+		header.Get("X-Microsoft-OutputFormat") != "audio-48khz-192kbitrate-mono-mp3" {
+		t.Errorf("unexpected request %v %s", header, body)
+	}
+
+	speak(SpeechRequest{Voice: "en-GB-SoniaNeural", ResponseFormat: SpeechOpus})
+	if !strings.Contains(body, `xml:lang="en-GB"`) || header.Get("X-Region") != "eastasia" ||
+		header.Get("X-Microsoft-OutputFormat") != "ogg-24khz-16bit-mono-opus" {
+		t.Errorf("unexpected request %v %s", header, body)
+	}
+}
+
+func TestSpeechSSML(t *testing.T) {
+	var body string
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			data, _ := io.ReadAll(r.Body)
+			body = string(data)
+			w.Write([]byte("ID3"))
+		},
+	)
+
+	ssml := `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="en-US">` +
+		`<voice name="en-US-JennyNeural">Hello<break time="500ms"/></voice>` +
+		`<voice name="en-US-GuyNeural">Hi</voice></speak>`
+	r, err := c.Speech(context.Background(), SpeechRequest{Model: SpeechModelAzure, SSML: ssml})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if body != ssml {
+		t.Errorf("SSML not sent as is: %s", body)
+	}
+
+	for _, speech := range []SpeechRequest{
+		{Model: SpeechModelTTS1, SSML: ssml},
+		{Model: SpeechModelAzure, SSML: ssml, Input: "Hello"},
+		{Model: SpeechModelAzure, SSML: "<speak><voice>Hello</speak>"},
+		{Model: SpeechModelAzure, SSML: "<voice>Hello</voice>"},
+	} {
+		_, err = c.Speech(context.Background(), speech)
+		if err == nil {
+			t.Errorf("expected an error for %+v", speech)
+		}
+	}
+}
+
+func TestStreamSpeech(t *testing.T) {
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var speech SpeechRequest
+			_ = json.NewDecoder(r.Body).Decode(&speech)
+			io.WriteString(w, "<"+speech.Input)
+			w.(http.Flusher).Flush()
+			time.Sleep(20 * time.Millisecond)
+			io.WriteString(w, ">")
+		},
+	)
+	speech := SpeechRequest{Model: SpeechModelTTS1, Input: "Hello there! How are you? 我很好。Bye"}
+
+	var chunks []SpeechChunk
+	err := c.StreamSpeech(
+		context.Background(), speech, func(chunk SpeechChunk) error {
+			chunks = append(chunks, chunk)
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for i, chunk := range chunks {
+		if chunk.Sentence != i || !chunk.Final {
+			t.Errorf("unexpected chunk %+v", chunk)
+		}
+		got = append(got, chunk.Text+"="+string(chunk.Audio))
+	}
+	want := []string{"Hello there!=<Hello there!>", "How are you?=<How are you?>", "我很好。=<我很好。>", "Bye=<Bye>"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// With a max buffering delay, the audio is passed on as it arrives.
+	chunks = nil
+	err = c.StreamSpeech(
+		context.Background(), SpeechRequest{Model: SpeechModelTTS1, Input: "Hi."}, func(chunk SpeechChunk) error {
+			chunks = append(chunks, chunk)
+			return nil
+		},
+		WithMaxBufferDelay(time.Nanosecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 2 || string(chunks[0].Audio) != "<Hi." || chunks[0].Final || !chunks[len(chunks)-1].Final ||
+		chunks[len(chunks)-1].Elapsed < chunks[0].Elapsed {
+		t.Errorf("unexpected chunks %+v", chunks)
+	}
+
+	stop := errors.New("stop")
+	err = c.StreamSpeech(
+		context.Background(), speech, func(chunk SpeechChunk) error {
+			return stop
+		},
+	)
+	if !errors.Is(err, stop) {
+		t.Errorf("expected the error of fn, got %v", err)
+	}
+}
+
+func TestSpeechAll(t *testing.T) {
+	got := groupSentences([]string{"One two.", "Three.", "A much longer sentence here.", "Supercalifragilistic."}, 12)
+	want := []string{"One two.", "Three.", "A much", "longer", "sentence", "here.", "Supercalifra", "gilistic."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var speech SpeechRequest
+			_ = json.NewDecoder(r.Body).Decode(&speech)
+			io.WriteString(w, "["+speech.Input+"]")
+		},
+	)
+
+	audio, err := c.SpeechAll(
+		context.Background(), SpeechRequest{Model: SpeechModelTTS1, Input: "First one. Second one! Third one?"},
+		WithMaxChars(22), WithLookahead(3),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(audio)
+	audio.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "[First one. Second one!][Third one?]" {
+		t.Errorf("unexpected audio %q", data)
+	}
+
+	_, err = c.SpeechAll(context.Background(), SpeechRequest{Model: SpeechModelTTS1, Input: "Hi.", ResponseFormat: SpeechWAV})
+	if err == nil {
+		t.Error("expected an error for WAV")
+	}
+}
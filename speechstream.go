@@ -0,0 +1,282 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpeechChunk is a piece of the audio of StreamSpeech.
+type SpeechChunk struct {
+	// Sentence is the index of the sentence the audio belongs to, and Text the sentence,
+	// or several sentences with WithMaxChars.
+	Sentence int
+	Text     string
+	Audio    []byte
+	// Final is set on the last chunk of a sentence, whose audio may be empty.
+	Final bool
+	// Elapsed is the time from the call to StreamSpeech to when the chunk was ready.
+	Elapsed time.Duration
+}
+
+type speechStreamOptions struct {
+	maxDelay  time.Duration
+	lookahead int
+	maxChars  int
+}
+
+// SpeechStreamOption adjusts how StreamSpeech synthesizes speech.
+type SpeechStreamOption func(*speechStreamOptions)
+
+// WithMaxBufferDelay makes StreamSpeech pass on the audio of a sentence as it arrives, at most d after
+// the previous chunk, instead of once the sentence is complete.
+func WithMaxBufferDelay(d time.Duration) SpeechStreamOption {
+	return func(o *speechStreamOptions) {
+		o.maxDelay = d
+	}
+}
+
+// WithLookahead sets the number of sentences StreamSpeech synthesizes ahead of the one being passed on, 2 by default.
+func WithLookahead(n int) SpeechStreamOption {
+	return func(o *speechStreamOptions) {
+		o.lookahead = n
+	}
+}
+
+// WithMaxChars groups consecutive sentences into requests of up to n characters, so fewer requests are sent.
+// Sentences longer than n are split between words. StreamSpeech sends a request per sentence by default,
+// SpeechAll requests of up to 4096 characters, the limit of OpenAI.
+func WithMaxChars(n int) SpeechStreamOption {
+	return func(o *speechStreamOptions) {
+		o.maxChars = n
+	}
+}
+
+// splitSentences splits text after the punctuation ending its sentences.
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for _, loc := range sentenceEndRe.FindAllStringIndex(text, -1) {
+		if s := strings.TrimSpace(text[start:loc[1]]); s != "" {
+			sentences = append(sentences, s)
+		}
+		start = loc[1]
+	}
+	if s := strings.TrimSpace(text[start:]); s != "" {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
+
+// groupSentences groups consecutive sentences into texts of up to maxChars characters, if maxChars is positive.
+func groupSentences(sentences []string, maxChars int) []string {
+	if maxChars <= 0 {
+		return sentences
+	}
+	var texts []string
+	var text []rune
+	for _, sentence := range sentences {
+		s := []rune(sentence)
+		if len(text) > 0 && len(text)+1+len(s) > maxChars {
+			texts = append(texts, string(text))
+			text = nil
+		}
+		if len(text) > 0 {
+			text = append(text, ' ')
+		}
+		text = append(text, s...)
+		for len(text) > maxChars {
+			// Split the long sentence at the last space that fits, or anywhere without one.
+			cut := maxChars
+			for i := maxChars; i > 0; i-- {
+				if text[i] == ' ' {
+					cut = i
+					break
+				}
+			}
+			texts = append(texts, strings.TrimSpace(string(text[:cut])))
+			text = []rune(strings.TrimSpace(string(text[cut:])))
+		}
+	}
+	if len(text) > 0 {
+		texts = append(texts, string(text))
+	}
+	return texts
+}
+
+// StreamSpeech synthesizes the input sentence by sentence and passes the audio to fn in order, so playback
+// can start once the first sentence is ready instead of the whole input. Following sentences are synthesized
+// while the previous ones are passed on, see WithLookahead. If fn returns an error, StreamSpeech stops with it.
+//
+// Each sentence is a separate audio file: MP3, Opus and PCM can be played back to back,
+// while WAV has a header per sentence. The input can't be SSML.
+func (c *Client) StreamSpeech(
+	ctx context.Context,
+	speech SpeechRequest,
+	fn func(chunk SpeechChunk) error,
+	opts ...SpeechStreamOption,
+) error {
+	o := speechStreamOptions{lookahead: 2}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	texts, err := speechTexts(speech, o)
+	if err != nil {
+		return err
+	}
+	return c.streamSpeech(ctx, speech, texts, fn, o)
+}
+
+// speechTexts validates speech and splits its input into the texts of the requests.
+func speechTexts(speech SpeechRequest, o speechStreamOptions) ([]string, error) {
+	if speech.SSML != "" {
+		return nil, errors.New("SSML can't be split into sentences")
+	}
+	err := speech.validate()
+	if err != nil {
+		return nil, err
+	}
+	texts := groupSentences(splitSentences(speech.Input), o.maxChars)
+	if len(texts) == 0 {
+		return nil, errors.New("speech request has no input")
+	}
+	return texts, nil
+}
+
+func (c *Client) streamSpeech(
+	ctx context.Context,
+	speech SpeechRequest,
+	sentences []string,
+	fn func(chunk SpeechChunk) error,
+	o speechStreamOptions,
+) error {
+	start := c.clock.Now()
+
+	type result struct {
+		audio io.ReadCloser
+		err   error
+	}
+	results := make([]chan result, len(sentences))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+		// Close the audio synthesized ahead but not passed on.
+		for _, ch := range results {
+			select {
+			case r := <-ch:
+				if r.audio != nil {
+					r.audio.Close()
+				}
+			default:
+			}
+		}
+	}()
+
+	sem := make(chan struct{}, max(o.lookahead, 0)+1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i, sentence := range sentences {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			wg.Add(1)
+			go func(i int, sentence string) {
+				defer wg.Done()
+				req := speech
+				req.Input = sentence
+				audio, err := c.Speech(ctx, req)
+				results[i] <- result{audio, err}
+			}(i, sentence)
+		}
+	}()
+
+	for i, sentence := range sentences {
+		var r result
+		select {
+		case r = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if r.err != nil {
+			return r.err
+		}
+		err := c.passSpeech(r.audio, o.maxDelay, start, SpeechChunk{Sentence: i, Text: sentence}, fn)
+		r.audio.Close()
+		if err != nil {
+			return err
+		}
+		<-sem
+	}
+	return nil
+}
+
+// passSpeech reads the audio of a sentence and passes it to fn, in chunks if maxDelay is set.
+func (c *Client) passSpeech(
+	audio io.Reader,
+	maxDelay time.Duration,
+	start time.Time,
+	chunk SpeechChunk,
+	fn func(chunk SpeechChunk) error,
+) error {
+	var pending []byte
+	last := c.clock.Now()
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := audio.Read(buf)
+		pending = append(pending, buf[:n]...)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		now := c.clock.Now()
+		final := err == io.EOF
+		if final || maxDelay > 0 && len(pending) > 0 && now.Sub(last) >= maxDelay {
+			chunk.Audio, chunk.Final, chunk.Elapsed = pending, final, now.Sub(start)
+			err := fn(chunk)
+			if err != nil || final {
+				return err
+			}
+			pending, last = nil, now
+		}
+	}
+}
+
+// SpeechAll synthesizes input longer than a request accepts: it is split between sentences into requests,
+// see WithMaxChars and WithLookahead, whose audio is joined in order into the returned stream.
+// The format must be one whose files can be joined: MP3, Opus, AAC or PCM. Caller must close the stream.
+func (c *Client) SpeechAll(ctx context.Context, speech SpeechRequest, opts ...SpeechStreamOption) (io.ReadCloser, error) {
+	o := speechStreamOptions{lookahead: 2, maxChars: 4096}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if speech.ResponseFormat == SpeechWAV || speech.ResponseFormat == SpeechFLAC {
+		return nil, fmt.Errorf("speech in %s can't be joined, use %s instead", speech.ResponseFormat, SpeechPCM)
+	}
+	texts, err := speechTexts(speech, o)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		err := c.streamSpeech(
+			ctx, speech, texts, func(chunk SpeechChunk) error {
+				_, err := pw.Write(chunk.Audio)
+				return err
+			}, o,
+		)
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
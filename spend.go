@@ -0,0 +1,88 @@
+package opencat_api
+
+import (
+	"context"
+	"maps"
+	"sync"
+)
+
+// SpendTotals adds up requests, tokens and cost.
+type SpendTotals struct {
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	// Cost is the amount spent per currency, for models with a known price.
+	Cost map[string]float64
+}
+
+// Spend is what the chat requests of a client used, with the traffic caused by retries counted apart,
+// so retry amplification can be told from organic traffic.
+// Retries include requests sent again after a failure, reconnected streams and replies that were
+// rejected and asked again. Tokens of models that don't report usage are estimated.
+type Spend struct {
+	Organic SpendTotals
+	Retries SpendTotals
+}
+
+type spendTracker struct {
+	mu    sync.Mutex
+	spend Spend
+}
+
+func (s *spendTracker) totals(retry bool) *SpendTotals {
+	if retry {
+		return &s.spend.Retries
+	}
+	return &s.spend.Organic
+}
+
+func (s *spendTracker) addRequest(retry bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.totals(retry).Requests++
+}
+
+func (s *spendTracker) addTokens(retry bool, model ChatModel, prompt, completion int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.totals(retry)
+	t.PromptTokens += prompt
+	t.CompletionTokens += completion
+	if price, ok := PriceOf(model); ok {
+		if t.Cost == nil {
+			t.Cost = map[string]float64{}
+		}
+		cost := price.cost(prompt, completion)
+		t.Cost[cost.Currency] += cost.Amount
+	}
+}
+
+// recordTokens adds the tokens used by a chat request made with ctx.
+// When the provider didn't report usage, it is estimated from the messages and the reply.
+func (c *Client) recordTokens(ctx context.Context, chat ChatRequest, usage TokenUsage, reply string) {
+	if usage.TotalTokens == 0 {
+		usage.PromptTokens = estimateTokens(chat.provider(), chat.Messages)
+		usage.CompletionTokens = estimateTextTokens(chat.provider(), reply)
+	}
+	c.addTokens(ctx, chat.Model, usage.PromptTokens, usage.CompletionTokens)
+}
+
+// addTokens adds tokens used by a request made with ctx to the spend of the client and of the tenant.
+func (c *Client) addTokens(ctx context.Context, model ChatModel, prompt, completion int) {
+	c.spend.addTokens(isRetry(ctx), model, prompt, completion)
+	if tenant, ok := TenantFromContext(ctx); ok && c.tenants != nil {
+		if e := c.tenants.addTokens(tenant, model, prompt, completion, c.clock.Now()); e != nil {
+			c.events.Publish(*e)
+		}
+	}
+}
+
+// Spend returns what the client has used so far.
+func (c *Client) Spend() Spend {
+	c.spend.mu.Lock()
+	defer c.spend.mu.Unlock()
+	s := c.spend.spend
+	s.Organic.Cost = maps.Clone(s.Organic.Cost)
+	s.Retries.Cost = maps.Clone(s.Retries.Cost)
+	return s
+}
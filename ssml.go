@@ -0,0 +1,118 @@
+package opencat_api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSML builds an SSML document for Azure speech, escaping the text and attributes, see SpeechRequest.SSML:
+//
+//	ssml := NewSSML("en-US", "en-US-JennyNeural").
+//		Say("Hello!").
+//		Break(500 * time.Millisecond).
+//		Style("cheerful", "Nice to meet you.").
+//		Voice("en-US-GuyNeural").
+//		Prosody(Prosody{Rate: 0.8, Pitch: "-10%"}, "Likewise.").
+//		String()
+//
+// https://learn.microsoft.com/en-us/azure/ai-services/speech-service/speech-synthesis-markup
+type SSML struct {
+	language string
+	voices   []ssmlVoice
+}
+
+type ssmlVoice struct {
+	name string
+	body strings.Builder
+}
+
+// NewSSML returns a document in language, like en-US, whose speech starts with voice.
+func NewSSML(language, voice string) *SSML {
+	s := &SSML{language: language}
+	return s.Voice(voice)
+}
+
+// Voice switches to another voice for the following speech.
+func (s *SSML) Voice(name string) *SSML {
+	s.voices = append(s.voices, ssmlVoice{name: name})
+	return s
+}
+
+func (s *SSML) write(format string, args ...any) *SSML {
+	fmt.Fprintf(&s.voices[len(s.voices)-1].body, format, args...)
+	return s
+}
+
+// Say speaks text.
+func (s *SSML) Say(text string) *SSML {
+	return s.write("%s", escapeXML(text))
+}
+
+// Break pauses for d, up to 5 seconds.
+func (s *SSML) Break(d time.Duration) *SSML {
+	return s.write(`<break time="%dms"/>`, d.Milliseconds())
+}
+
+// Style speaks text in a speaking style of the voice, like cheerful or sad.
+// Styles are only supported by some neural voices.
+func (s *SSML) Style(style, text string) *SSML {
+	return s.write(`<mstts:express-as style="%s">%s</mstts:express-as>`, escapeXML(style), escapeXML(text))
+}
+
+// Prosody sets how text is spoken.
+type Prosody struct {
+	// Rate is a multiple of the normal rate, between 0.5 and 2. 0 means the normal rate.
+	Rate float64
+	// Pitch and Volume are relative changes like +10% or -2st, or levels like high or soft. Empty means unchanged.
+	Pitch  string
+	Volume string
+}
+
+// Prosody speaks text with the rate, pitch and volume of p.
+func (s *SSML) Prosody(p Prosody, text string) *SSML {
+	var attrs strings.Builder
+	if p.Rate != 0 {
+		fmt.Fprintf(&attrs, ` rate="%s"`, strconv.FormatFloat(p.Rate, 'f', -1, 64))
+	}
+	if p.Pitch != "" {
+		fmt.Fprintf(&attrs, ` pitch="%s"`, escapeXML(p.Pitch))
+	}
+	if p.Volume != "" {
+		fmt.Fprintf(&attrs, ` volume="%s"`, escapeXML(p.Volume))
+	}
+	return s.write(`<prosody%s>%s</prosody>`, attrs.String(), escapeXML(text))
+}
+
+// Phoneme speaks text as the phonetic pronunciation ph, written in alphabet, like ipa or sapi.
+func (s *SSML) Phoneme(alphabet, ph, text string) *SSML {
+	return s.write(
+		`<phoneme alphabet="%s" ph="%s">%s</phoneme>`, escapeXML(alphabet), escapeXML(ph), escapeXML(text),
+	)
+}
+
+// String returns the document.
+func (s *SSML) String() string {
+	var b strings.Builder
+	fmt.Fprintf(
+		&b, `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" `+
+			`xmlns:mstts="https://www.w3.org/2001/mstts" xml:lang="%s">`, escapeXML(s.language),
+	)
+	for _, v := range s.voices {
+		if v.body.Len() > 0 {
+			fmt.Fprintf(&b, `<voice name="%s">%s</voice>`, escapeXML(v.name), v.body.String())
+		}
+	}
+	b.WriteString("</speak>")
+	return b.String()
+}
+
+// escapeXML escapes text for XML content and quoted attributes,
+// replacing characters that are not allowed in XML.
+func escapeXML(text string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
@@ -0,0 +1,35 @@
+package opencat_api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSSML(t *testing.T) {
+	ssml := NewSSML("en-US", "en-US-JennyNeural").
+		Say(`Tom & "Jerry" <3`).
+		Break(500*time.Millisecond).
+		Style("cheerful", "Nice to meet you.").
+		Voice("en-US-GuyNeural").
+		Prosody(Prosody{Rate: 0.8, Pitch: "-10%"}, "Likewise.").
+		Phoneme("ipa", "təˈmeɪtoʊ", "tomato").
+		String()
+	want := `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" ` +
+		`xmlns:mstts="https://www.w3.org/2001/mstts" xml:lang="en-US">` +
+		`<voice name="en-US-JennyNeural">Tom &amp; &#34;Jerry&#34; &lt;3<break time="500ms"/>` +
+		`<mstts:express-as style="cheerful">Nice to meet you.</mstts:express-as></voice>` +
+		`<voice name="en-US-GuyNeural"><prosody rate="0.8" pitch="-10%">Likewise.</prosody>` +
+		`<phoneme alphabet="ipa" ph="təˈmeɪtoʊ">tomato</phoneme></voice></speak>`
+	if ssml != want {
+		t.Errorf("got  %s\nwant %s", ssml, want)
+	}
+	if err := validateSSML(ssml); err != nil {
+		t.Error(err)
+	}
+
+	// Characters not allowed in XML are replaced.
+	ssml = NewSSML("en-US", `a"b`).Say("bell\x07").String()
+	if err := validateSSML(ssml); err != nil {
+		t.Errorf("invalid document %s: %v", ssml, err)
+	}
+}
@@ -0,0 +1,215 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// statsSamples is the number of recent calls kept per model.
+	statsSamples = 256
+	// statsWindow is how long a call counts towards the statistics.
+	statsWindow = 10 * time.Minute
+)
+
+// ModelStats summarizes the recent chat calls to a model.
+type ModelStats struct {
+	Model    ChatModel
+	Provider Provider
+	Requests int
+	Errors   int
+	// SuccessRate is the fraction of requests that succeeded, 1 if there were none.
+	SuccessRate float64
+	// LatencyP50 and LatencyP95 are percentiles of the duration of successful calls, until the last token for streams.
+	LatencyP50 time.Duration
+	LatencyP95 time.Duration
+	// TTFTP50 and TTFTP95 are percentiles of the time to the first token of successful streams.
+	TTFTP50 time.Duration
+	TTFTP95 time.Duration
+
+	// latencySum is the total duration of successful calls.
+	latencySum time.Duration
+}
+
+type callSample struct {
+	at      time.Time
+	ok      bool
+	latency time.Duration
+	// ttft is the time to first token, 0 for calls that are not streamed.
+	ttft time.Duration
+}
+
+type modelSamples struct {
+	samples []callSample
+	next    int
+	// state is the health of the model after the last call.
+	state HealthState
+}
+
+// successRate returns the fraction of the calls since the start of the window that succeeded, 1 if there were none.
+func (m *modelSamples) successRate(now time.Time) float64 {
+	requests, errs := 0, 0
+	for _, sample := range m.samples {
+		if now.Sub(sample.at) > statsWindow {
+			continue
+		}
+		requests++
+		if !sample.ok {
+			errs++
+		}
+	}
+	if requests == 0 {
+		return 1
+	}
+	return float64(requests-errs) / float64(requests)
+}
+
+type scoreboard struct {
+	mu     sync.Mutex
+	models map[ChatModel]*modelSamples
+}
+
+// record adds a call to model, and returns the health of the model before and after it.
+func (s *scoreboard) record(model ChatModel, sample callSample, now time.Time) (from, to HealthState, successRate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.models == nil {
+		s.models = map[ChatModel]*modelSamples{}
+	}
+	m := s.models[model]
+	if m == nil {
+		m = &modelSamples{state: HealthOK}
+		s.models[model] = m
+	}
+	if len(m.samples) < statsSamples {
+		m.samples = append(m.samples, sample)
+	} else {
+		m.samples[m.next] = sample
+		m.next = (m.next + 1) % statsSamples
+	}
+	from = m.state
+	successRate = m.successRate(now)
+	m.state = healthStateOf(successRate)
+	return from, m.state, successRate
+}
+
+func (s *scoreboard) stats(now time.Time) []ModelStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var all []ModelStats
+	for model, m := range s.models {
+		st := ModelStats{Model: model, Provider: providerOf(model), SuccessRate: 1}
+		var latencies, ttfts []time.Duration
+		for _, sample := range m.samples {
+			if now.Sub(sample.at) > statsWindow {
+				continue
+			}
+			st.Requests++
+			if !sample.ok {
+				st.Errors++
+				continue
+			}
+			latencies = append(latencies, sample.latency)
+			st.latencySum += sample.latency
+			if sample.ttft > 0 {
+				ttfts = append(ttfts, sample.ttft)
+			}
+		}
+		if st.Requests == 0 {
+			continue
+		}
+		st.SuccessRate = float64(st.Requests-st.Errors) / float64(st.Requests)
+		st.LatencyP50, st.LatencyP95 = percentile(latencies, 0.5), percentile(latencies, 0.95)
+		st.TTFTP50, st.TTFTP95 = percentile(ttfts, 0.5), percentile(ttfts, 0.95)
+		all = append(all, st)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Model < all[j].Model })
+	return all
+}
+
+func percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	slices.Sort(durations)
+	return durations[int(p*float64(len(durations)-1)+0.5)]
+}
+
+// recordCall adds the outcome of a chat call to the statistics, and publishes a HealthEvent if it changes
+// the health of the model. Calls canceled by the caller and rejected requests say nothing about the model
+// and are ignored.
+func (c *Client) recordCall(model ChatModel, start time.Time, ttft time.Duration, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode >= 400 && apiErr.HTTPStatusCode < 500 &&
+		apiErr.HTTPStatusCode != 429 {
+		return
+	}
+	now := c.clock.Now()
+	from, to, successRate := c.scoreboard.record(
+		model, callSample{
+			at:      start,
+			ok:      err == nil,
+			latency: now.Sub(start),
+			ttft:    ttft,
+		},
+		now,
+	)
+	if from != to {
+		c.events.Publish(
+			HealthEvent{
+				Time:        now,
+				Model:       model,
+				Provider:    providerOf(model),
+				From:        from,
+				To:          to,
+				SuccessRate: successRate,
+			},
+		)
+	}
+}
+
+// Stats returns the statistics of the chat calls made in the last 10 minutes, per model.
+func (c *Client) Stats() []ModelStats {
+	return c.scoreboard.stats(c.clock.Now())
+}
+
+// RankModels orders models from the healthiest to the least healthy, by recent success rate and then
+// by median latency. Models without recent calls are ranked as healthy, after the ones known to work well.
+// It can be used to pick which model to try first.
+func (c *Client) RankModels(models []ChatModel) []ChatModel {
+	byModel := map[ChatModel]ModelStats{}
+	for _, st := range c.Stats() {
+		byModel[st.Model] = st
+	}
+
+	ranked := slices.Clone(models)
+	sort.SliceStable(
+		ranked, func(i, j int) bool {
+			a, aok := byModel[ranked[i]]
+			b, bok := byModel[ranked[j]]
+			if !aok || !bok {
+				// Unknown models go after known good ones, and before known bad ones.
+				if !aok && !bok {
+					return false
+				}
+				if aok {
+					return a.SuccessRate >= 0.99
+				}
+				return b.SuccessRate < 0.99
+			}
+			if a.SuccessRate != b.SuccessRate {
+				return a.SuccessRate > b.SuccessRate
+			}
+			return a.LatencyP50 < b.LatencyP50
+		},
+	)
+	return ranked
+}
@@ -0,0 +1,47 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	c := NewClient("token")
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		c.recordCall(ChatModelGPT4, start.Add(-time.Duration(i+1)*time.Second), time.Duration(i+1)*time.Millisecond, nil)
+	}
+	c.recordCall(ChatModelClaude2, start, 0, nil)
+	c.recordCall(ChatModelClaude2, start, 0, &APIError{HTTPStatusCode: 503})
+	// Not the model's fault.
+	c.recordCall(ChatModelClaude2, start, 0, &APIError{HTTPStatusCode: 400})
+	c.recordCall(ChatModelClaude2, start, 0, context.Canceled)
+	c.recordCall(ChatModelGEMINIPro, start, 0, errors.New("connection reset"))
+
+	stats := c.Stats()
+	if len(stats) != 3 {
+		t.Fatalf("got stats for %d models, want 3", len(stats))
+	}
+	claude, gemini, gpt4 := stats[0], stats[1], stats[2]
+	if claude.Requests != 2 || claude.Errors != 1 || claude.SuccessRate != 0.5 {
+		t.Errorf("unexpected claude stats: %+v", claude)
+	}
+	if gemini.SuccessRate != 0 {
+		t.Errorf("unexpected gemini stats: %+v", gemini)
+	}
+	if gpt4.Requests != 10 || gpt4.TTFTP50 != 6*time.Millisecond || gpt4.TTFTP95 != 10*time.Millisecond {
+		t.Errorf("unexpected gpt-4 stats: %+v", gpt4)
+	}
+	if gpt4.LatencyP50 < 5*time.Second || gpt4.LatencyP95 < gpt4.LatencyP50 {
+		t.Errorf("unexpected gpt-4 latency: %+v", gpt4)
+	}
+
+	got := c.RankModels([]ChatModel{ChatModelGEMINIPro, ChatModelClaude2, ChatModelQWENPlus, ChatModelGPT4})
+	want := []ChatModel{ChatModelGPT4, ChatModelQWENPlus, ChatModelClaude2, ChatModelGEMINIPro}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RankModels = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,149 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ChatInto asks the model to reply with JSON matching the schema of T, and decodes the reply into a T.
+// The JSON schema is derived from T by reflection: field names follow the json struct tags, fields tagged
+// omitempty are optional and a `description:"..."` tag documents a field for the model.
+// If the reply can't be decoded, the model is shown the error and asked once more.
+func ChatInto[T any](ctx context.Context, c *Client, req ChatRequest) (T, error) {
+	var v T
+	schema, err := json.Marshal(jsonSchema(reflect.TypeOf(&v).Elem()))
+	if err != nil {
+		return v, err
+	}
+
+	instruction := Message{
+		Role: RoleSystem,
+		Content: fmt.Sprintf(
+			"Reply with JSON only, no prose and no code fences. The JSON must match this JSON schema:\n%s",
+			schema,
+		),
+	}
+	messages := []Message{instruction}
+	for _, msg := range req.Messages {
+		// The messages are sent again on every retry.
+		msg, err = msg.replayable()
+		if err != nil {
+			return v, err
+		}
+		messages = append(messages, msg)
+	}
+
+	_, err = c.chatValidated(
+		ctx,
+		func() ChatRequest {
+			r := req
+			r.Messages = messages
+			return r
+		},
+		func(content string) error {
+			var zero T
+			v = zero
+			return decodeJSON(content, &v)
+		},
+		1,
+	)
+	return v, err
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// jsonSchema describes how encoding/json encodes values of type t.
+func jsonSchema(t reflect.Type) map[string]any {
+	return jsonSchemaOf(t, map[reflect.Type]bool{})
+}
+
+func jsonSchemaOf(t reflect.Type, seen map[reflect.Type]bool) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	if reflect.PointerTo(t).Implements(reflect.TypeOf((*json.Marshaler)(nil)).Elem()) {
+		// Custom encoding, nothing to say about its shape.
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": jsonSchemaOf(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchemaOf(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			// Recursive type, stop here.
+			return map[string]any{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		properties := map[string]any{}
+		required := []string{}
+		addStructFields(t, properties, &required, seen)
+		return map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	default:
+		return map[string]any{}
+	}
+}
+
+func addStructFields(t reflect.Type, properties map[string]any, required *[]string, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addStructFields(ft, properties, required, seen)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		schema := jsonSchemaOf(f.Type, seen)
+		if desc := f.Tag.Get("description"); desc != "" {
+			schema["description"] = desc
+		}
+		properties[name] = schema
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			*required = append(*required, name)
+		}
+	}
+}
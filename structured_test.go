@@ -0,0 +1,118 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONSchema(t *testing.T) {
+	type node struct {
+		Name     string  `json:"name" description:"node name"`
+		Children []*node `json:"children,omitempty"`
+	}
+	type embedded struct {
+		ID int64 `json:"id"`
+	}
+	type doc struct {
+		embedded
+		Title   string            `json:"title"`
+		Score   float64           `json:"score,omitempty"`
+		Tags    map[string]bool   `json:"tags"`
+		Created time.Time         `json:"created"`
+		Root    node              `json:"root"`
+		Ignored string            `json:"-"`
+		Extra   map[string]string `json:",omitempty"`
+		private int
+	}
+
+	got, err := json.Marshal(jsonSchema(reflect.TypeOf(doc{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"additionalProperties":false,"properties":{` +
+		`"Extra":{"additionalProperties":{"type":"string"},"type":"object"},` +
+		`"created":{"format":"date-time","type":"string"},` +
+		`"id":{"type":"integer"},` +
+		`"root":{"additionalProperties":false,"properties":{` +
+		`"children":{"items":{"type":"object"},"type":"array"},` +
+		`"name":{"description":"node name","type":"string"}},"required":["name"],"type":"object"},` +
+		`"score":{"type":"number"},` +
+		`"tags":{"additionalProperties":{"type":"boolean"},"type":"object"},` +
+		`"title":{"type":"string"}},` +
+		`"required":["id","title","tags","created","root"],"type":"object"}`
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestChatInto(t *testing.T) {
+	c := client()
+	type city struct {
+		Name       string `json:"name"`
+		Country    string `json:"country"`
+		Population int    `json:"population" description:"approximate number of inhabitants"`
+	}
+	cities, err := ChatInto[[]city](
+		context.Background(), c, ChatRequest{
+			Model:     ChatModelGPT4Turbo,
+			MaxTokens: 1024,
+			Messages: []Message{
+				{
+					Role:    RoleUser,
+					Content: "List the three largest cities in China.",
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("cities: %+v", cities)
+}
+
+func TestChatIntoReplaysImages(t *testing.T) {
+	var images []string
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				Messages []struct {
+					Images []string `json:"images"`
+				} `json:"messages"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			images = append(images, body.Messages[1].Images...)
+			content := `"not JSON"`
+			if len(images) > 1 {
+				content = `"{\"name\": \"cat\"}"`
+			}
+			fmt.Fprintf(w, `{"choices": [{"message": {"role": "assistant", "content": %s}}]}`, content)
+		},
+	)
+
+	type animal struct {
+		Name string `json:"name"`
+	}
+	got, err := ChatInto[animal](
+		context.Background(), c, ChatRequest{
+			Model: ChatModelGPT4VisionPreview,
+			Messages: []Message{
+				{
+					Role: RoleUser, Content: "What animal is this?",
+					Images: []Image{NewImage(strings.NewReader("\x89PNG\r\n\x1a\n"))},
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "cat" || len(images) != 2 || images[0] == "" || images[1] != images[0] {
+		t.Errorf("image not sent again on the retry: %q, got %+v", images, got)
+	}
+}
@@ -0,0 +1,208 @@
+package opencat_api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type tenantKey struct{}
+
+// WithTenant returns a context whose requests are made on behalf of the tenant id, such as a customer,
+// and are subject to the limits of the client's TenantLimiter, see WithTenantLimiter.
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// TenantFromContext returns the tenant set by WithTenant, if any.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok && id != ""
+}
+
+// TenantLimits are the limits of one tenant. Zero values mean no limit.
+type TenantLimits struct {
+	RequestsPerMinute int
+	// TokensPerMinute limits the prompt and completion tokens used in the last minute.
+	// A request is rejected once the limit is reached, so the request that crosses it still completes.
+	TokensPerMinute int
+	// Budget caps the total cost of the requests, counting models priced in Budget.Currency, see PriceOf.
+	// An empty currency is USD.
+	Budget Cost
+}
+
+func (l TenantLimits) withDefaults() TenantLimits {
+	if l.Budget.Currency == "" {
+		l.Budget.Currency = "USD"
+	}
+	return l
+}
+
+// TenantLimit is the limit an ErrTenantLimit was raised for.
+type TenantLimit string
+
+const (
+	TenantLimitRequests TenantLimit = "requests_per_minute"
+	TenantLimitTokens   TenantLimit = "tokens_per_minute"
+	TenantLimitBudget   TenantLimit = "budget"
+)
+
+// ErrTenantLimit is returned, without sending the request, when a tenant has reached one of its limits.
+type ErrTenantLimit struct {
+	Tenant string
+	Limit  TenantLimit
+	// RetryAfter is when the request would be allowed again, zero for an exhausted budget.
+	RetryAfter time.Duration
+}
+
+func (e *ErrTenantLimit) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("tenant %s reached its %s limit, retry after %v", e.Tenant, e.Limit, e.RetryAfter)
+	}
+	return fmt.Sprintf("tenant %s reached its %s limit", e.Tenant, e.Limit)
+}
+
+// TenantLimiter isolates tenants sharing a client, so one of them can't use up the rate limits or the
+// quota of the account. Its limits apply on top of the limits of Config.Endpoints.
+type TenantLimiter struct {
+	mu       sync.Mutex
+	defaults TenantLimits
+	limits   map[string]TenantLimits
+	tenants  map[string]*tenantUsage
+}
+
+type tenantUsage struct {
+	requests []time.Time
+	tokens   []tokenUse
+	spent    float64
+}
+
+type tokenUse struct {
+	time time.Time
+	n    int
+}
+
+// NewTenantLimiter returns a limiter applying defaults to tenants without limits of their own.
+func NewTenantLimiter(defaults TenantLimits) *TenantLimiter {
+	return &TenantLimiter{
+		defaults: defaults.withDefaults(),
+		limits:   map[string]TenantLimits{},
+		tenants:  map[string]*tenantUsage{},
+	}
+}
+
+// WithTenantLimiter makes the client enforce the limits of l on requests made with WithTenant.
+func WithTenantLimiter(l *TenantLimiter) ClientOption {
+	return func(c *Client) {
+		c.tenants = l
+	}
+}
+
+// SetLimits sets the limits of a tenant, instead of the defaults.
+func (l *TenantLimiter) SetLimits(tenant string, limits TenantLimits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits[tenant] = limits.withDefaults()
+}
+
+// Spent returns the cost of the requests of a tenant counted against its budget.
+func (l *TenantLimiter) Spent(tenant string) Cost {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limits := l.limitsOf(tenant)
+	return Cost{Amount: l.usage(tenant).spent, Currency: limits.Budget.Currency}
+}
+
+// ResetBudget starts counting the budget of a tenant from zero, such as at the start of a billing period.
+func (l *TenantLimiter) ResetBudget(tenant string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.usage(tenant).spent = 0
+}
+
+func (l *TenantLimiter) limitsOf(tenant string) TenantLimits {
+	if limits, ok := l.limits[tenant]; ok {
+		return limits
+	}
+	return l.defaults
+}
+
+func (l *TenantLimiter) usage(tenant string) *tenantUsage {
+	u := l.tenants[tenant]
+	if u == nil {
+		u = &tenantUsage{}
+		l.tenants[tenant] = u
+	}
+	return u
+}
+
+// allow counts a request of tenant at now, or returns an *ErrTenantLimit if a limit is reached.
+func (l *TenantLimiter) allow(tenant string, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limits, u := l.limitsOf(tenant), l.usage(tenant)
+
+	windowStart := now.Add(-time.Minute)
+	for len(u.requests) > 0 && !u.requests[0].After(windowStart) {
+		u.requests = u.requests[1:]
+	}
+	for len(u.tokens) > 0 && !u.tokens[0].time.After(windowStart) {
+		u.tokens = u.tokens[1:]
+	}
+
+	if limits.Budget.Amount > 0 && u.spent >= limits.Budget.Amount {
+		return &ErrTenantLimit{Tenant: tenant, Limit: TenantLimitBudget}
+	}
+	if limits.RequestsPerMinute > 0 && len(u.requests) >= limits.RequestsPerMinute {
+		// Allowed again when the oldest request of the window leaves it.
+		return &ErrTenantLimit{
+			Tenant: tenant, Limit: TenantLimitRequests, RetryAfter: u.requests[0].Sub(windowStart),
+		}
+	}
+	if limits.TokensPerMinute > 0 {
+		used := 0
+		for _, t := range u.tokens {
+			used += t.n
+		}
+		if used >= limits.TokensPerMinute {
+			// Allowed again when enough tokens leave the window.
+			for _, t := range u.tokens {
+				used -= t.n
+				if used < limits.TokensPerMinute {
+					return &ErrTenantLimit{
+						Tenant: tenant, Limit: TenantLimitTokens, RetryAfter: t.time.Sub(windowStart),
+					}
+				}
+			}
+		}
+	}
+	u.requests = append(u.requests, now)
+	return nil
+}
+
+// addTokens counts tokens used by tenant at now against its limits. It returns the event to publish if
+// the budget of the tenant crossed a threshold, nil otherwise.
+func (l *TenantLimiter) addTokens(tenant string, model ChatModel, prompt, completion int, now time.Time) *BudgetEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limits, u := l.limitsOf(tenant), l.usage(tenant)
+	u.tokens = append(u.tokens, tokenUse{now, prompt + completion})
+	price, ok := PriceOf(model)
+	if !ok || price.Currency != limits.Budget.Currency {
+		return nil
+	}
+	before := u.spent
+	u.spent += price.cost(prompt, completion).Amount
+	threshold := crossedThreshold(before, u.spent, limits.Budget.Amount)
+	if threshold == 0 {
+		return nil
+	}
+	return &BudgetEvent{
+		Time:      now,
+		Tenant:    tenant,
+		Threshold: threshold,
+		Spent:     Cost{Amount: u.spent, Currency: limits.Budget.Currency},
+		Budget:    limits.Budget,
+	}
+}
@@ -0,0 +1,94 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTenantLimiter(t *testing.T) {
+	l := NewTenantLimiter(TenantLimits{RequestsPerMinute: 2, TokensPerMinute: 1000})
+	l.SetLimits("vip", TenantLimits{Budget: Cost{Amount: 0.05, Currency: "USD"}})
+	now := time.Now()
+
+	var limitErr *ErrTenantLimit
+	for i := 0; i < 2; i++ {
+		if err := l.allow("a", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := l.allow("a", now.Add(10*time.Second))
+	if !errors.As(err, &limitErr) || limitErr.Limit != TenantLimitRequests || limitErr.RetryAfter != 50*time.Second {
+		t.Errorf("expected the request limit, got %v", err)
+	}
+	if err := l.allow("b", now); err != nil {
+		t.Errorf("tenants are not isolated: %v", err)
+	}
+
+	later := now.Add(time.Minute)
+	if err := l.allow("a", later); err != nil {
+		t.Fatalf("request limit not reset after a minute: %v", err)
+	}
+	l.addTokens("a", ChatModelGPT4, 800, 300, later)
+	err = l.allow("a", later.Add(time.Second))
+	if !errors.As(err, &limitErr) || limitErr.Limit != TenantLimitTokens {
+		t.Errorf("expected the token limit, got %v", err)
+	}
+
+	// 1000 prompt and 500 completion tokens of GPT-4 cost 0.06 USD.
+	e := l.addTokens("vip", ChatModelGPT4, 1000, 500, now)
+	if e == nil || e.Tenant != "vip" || e.Threshold != 1 || e.Budget.Amount != 0.05 {
+		t.Errorf("unexpected budget event %+v", e)
+	}
+	if spent := l.Spent("vip"); spent.Amount < 0.059 || spent.Currency != "USD" {
+		t.Errorf("spent = %+v", spent)
+	}
+	err = l.allow("vip", now)
+	if !errors.As(err, &limitErr) || limitErr.Limit != TenantLimitBudget || CategorizeError(err) != ErrorQuotaExceeded {
+		t.Errorf("expected the budget limit, got %v", err)
+	}
+	l.ResetBudget("vip")
+	if err := l.allow("vip", now); err != nil {
+		t.Errorf("budget not reset: %v", err)
+	}
+
+	// A budget without a currency is in USD, rather than counting no model.
+	l.SetLimits("usd", TenantLimits{Budget: Cost{Amount: 0.05}})
+	l.addTokens("usd", ChatModelGPT4, 1000, 500, now)
+	err = l.allow("usd", now)
+	if !errors.As(err, &limitErr) || limitErr.Limit != TenantLimitBudget {
+		t.Errorf("expected the budget limit without a currency, got %v", err)
+	}
+}
+
+func TestChatTenantLimit(t *testing.T) {
+	requests := 0
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`)
+		},
+		WithTenantLimiter(NewTenantLimiter(TenantLimits{RequestsPerMinute: 1})),
+	)
+
+	chat := ChatRequest{Model: ChatModelGPT3Dot5Turbo, Messages: []Message{{Role: RoleUser, Content: "hi"}}}
+	ctx := WithTenant(context.Background(), "customer-1")
+	_, err := c.Chat(ctx, chat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Chat(ctx, chat)
+	if CategorizeError(err) != ErrorRateLimited {
+		t.Errorf("expected a rate limit error, got %v", err)
+	}
+	_, err = c.Chat(context.Background(), chat)
+	if err != nil {
+		t.Errorf("request without tenant limited: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("server got %d requests, want 2", requests)
+	}
+}
@@ -0,0 +1,176 @@
+package opencat_api
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// Tokenizer is a byte pair encoding compatible with tiktoken's cl100k_base, the encoding of the GPT-3.5 and GPT-4 models.
+type Tokenizer struct {
+	ranks map[string]int
+}
+
+// LoadTokenizer reads the ranks of a tiktoken encoding file, such as
+// https://openaipublic.blob.core.windows.net/encodings/cl100k_base.tiktoken,
+// made of lines with a base64 encoded token and its rank.
+// The file is not bundled with this package to keep it small.
+func LoadTokenizer(r io.Reader) (*Tokenizer, error) {
+	t := &Tokenizer{ranks: map[string]int{}}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		token, rank, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing rank", line)
+		}
+		b, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		n, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		t.ranks[string(b)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for b := 0; b < 256; b++ {
+		if _, ok := t.ranks[string([]byte{byte(b)})]; !ok {
+			return nil, fmt.Errorf("missing token for byte %d", b)
+		}
+	}
+	return t, nil
+}
+
+// Encode returns the tokens of text.
+func (t *Tokenizer) Encode(text string) []int {
+	var tokens []int
+	for _, piece := range splitPieces(text) {
+		if rank, ok := t.ranks[piece]; ok {
+			tokens = append(tokens, rank)
+			continue
+		}
+		for _, part := range t.bytePairMerge(piece) {
+			tokens = append(tokens, t.ranks[part])
+		}
+	}
+	return tokens
+}
+
+// Count returns the number of tokens of text.
+func (t *Tokenizer) Count(text string) int {
+	return len(t.Encode(text))
+}
+
+// bytePairMerge splits piece into single bytes and repeatedly merges the adjacent pair with the lowest rank.
+func (t *Tokenizer) bytePairMerge(piece string) []string {
+	parts := make([]string, len(piece))
+	for i := range parts {
+		parts[i] = piece[i : i+1]
+	}
+	for len(parts) > 1 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i < len(parts)-1; i++ {
+			if rank, ok := t.ranks[parts[i]+parts[i+1]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+	return parts
+}
+
+// splitPieces splits text like the cl100k_base pattern, before byte pair encoding:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+//
+// The pattern can't be used with the regexp package, which has no lookahead.
+func splitPieces(text string) []string {
+	var pieces []string
+	rs := []rune(text)
+	for i := 0; i < len(rs); {
+		n := matchPiece(rs[i:])
+		pieces = append(pieces, string(rs[i:i+n]))
+		i += n
+	}
+	return pieces
+}
+
+func matchPiece(rs []rune) int {
+	isLetter := unicode.IsLetter
+	isNumber := unicode.IsNumber
+	isSpace := unicode.IsSpace
+	isNewline := func(r rune) bool { return r == '\r' || r == '\n' }
+	isPunct := func(r rune) bool { return !isSpace(r) && !isLetter(r) && !isNumber(r) }
+	count := func(rs []rune, f func(rune) bool) int {
+		n := 0
+		for n < len(rs) && f(rs[n]) {
+			n++
+		}
+		return n
+	}
+
+	// Contractions.
+	if rs[0] == '\'' && len(rs) > 1 {
+		for _, suffix := range []string{"s", "t", "re", "ve", "m", "ll", "d"} {
+			if len(rs) > len(suffix) && strings.EqualFold(string(rs[1:1+len(suffix)]), suffix) {
+				return 1 + len(suffix)
+			}
+		}
+	}
+	// Words, with an optional leading non-letter like a space.
+	if isLetter(rs[0]) {
+		return count(rs, isLetter)
+	}
+	if !isNewline(rs[0]) && !isNumber(rs[0]) && len(rs) > 1 && isLetter(rs[1]) {
+		return 1 + count(rs[1:], isLetter)
+	}
+	// Numbers, up to 3 digits.
+	if isNumber(rs[0]) {
+		return min(count(rs, isNumber), 3)
+	}
+	// Punctuation, with an optional leading space and trailing newlines.
+	start := 0
+	if rs[0] == ' ' && len(rs) > 1 && isPunct(rs[1]) {
+		start = 1
+	}
+	if isPunct(rs[start]) {
+		n := start + count(rs[start:], isPunct)
+		return n + count(rs[n:], isNewline)
+	}
+	// Whitespace.
+	n := count(rs, isSpace)
+	for j := n - 1; j >= 0; j-- {
+		if isNewline(rs[j]) {
+			return j + 1
+		}
+	}
+	if n < len(rs) && n > 1 {
+		// Leave the last space to the following word.
+		return n - 1
+	}
+	return n
+}
+
+var tokenizer atomic.Pointer[Tokenizer]
+
+// SetTokenizer makes Tokens count the tokens of OpenAI models exactly with t, see LoadTokenizer.
+func SetTokenizer(t *Tokenizer) {
+	tokenizer.Store(t)
+}
@@ -0,0 +1,62 @@
+package opencat_api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitPieces(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello world", []string{"Hello", " world"}},
+		{"I'm  fine\n\nok  ", []string{"I", "'m", " ", " fine", "\n\n", "ok", "  "}},
+		{"12345", []string{"123", "45"}},
+		{"wait... what?!\n", []string{"wait", "...", " what", "?!\n"}},
+		{"你好，世界", []string{"你好", "，世界"}},
+	}
+	for _, tt := range tests {
+		got := splitPieces(tt.text)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPieces(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizer(t *testing.T) {
+	var ranks strings.Builder
+	for b := 0; b < 256; b++ {
+		fmt.Fprintf(&ranks, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(b)}), b)
+	}
+	for i, token := range []string{"lo", "he", "hel", "hello", " w"} {
+		fmt.Fprintf(&ranks, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), 256+i)
+	}
+	tok, err := LoadTokenizer(strings.NewReader(ranks.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// "hello" is a token, " world" merges " w" and leaves the other bytes.
+	got := tok.Encode("hello world")
+	want := []int{259, 260, 'o', 'r', 'l', 'd'}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Encode = %v, want %v", got, want)
+	}
+	// "helo" merges "lo" first, since it has the lowest rank, then "he".
+	got = tok.Encode("helo")
+	want = []int{257, 256}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Encode = %v, want %v", got, want)
+	}
+
+	SetTokenizer(tok)
+	defer SetTokenizer(nil)
+	n, err := Tokens(ChatModelGPT4, []Message{{Role: RoleUser, Content: "hello world"}})
+	if err != nil || n != 3+3+4+6 {
+		t.Errorf("Tokens = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,142 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"math"
+	"unicode"
+)
+
+// defaultContextWindow is assumed for models missing from the registry, see RegisterModel.
+const defaultContextWindow = 4096
+
+func contextWindow(model ChatModel) int {
+	if info, ok := ModelInfoOf(model); ok && info.ContextWindow > 0 {
+		return info.ContextWindow
+	}
+	return defaultContextWindow
+}
+
+const (
+	// messageOverheadTokens accounts for the role and separators of each message.
+	messageOverheadTokens = 4
+	// imageTokens is the cost of an image at high detail, for a typical 1024x1024 image.
+	imageTokens = 765
+	// lowDetailImageTokens is the fixed cost of an image at low detail.
+	lowDetailImageTokens = 85
+)
+
+func estimateImageTokens(images []Image) int {
+	n := 0
+	for _, img := range images {
+		if img.detail == ImageDetailLow {
+			n += lowDetailImageTokens
+		} else {
+			n += imageTokens
+		}
+	}
+	return n
+}
+
+// tokenRates describe how the tokenizer of a provider splits text, for estimates.
+type tokenRates struct {
+	// asciiChars is the number of ASCII characters per token.
+	asciiChars float64
+	// cjkTokens is the number of tokens per CJK character. Tokenizers trained mostly on Chinese text
+	// merge common words into one token, so it is below 1 for them.
+	cjkTokens float64
+}
+
+// defaultTokenRates are those of the tokenizers of OpenAI: about 4 characters of English per token,
+// and about one token per CJK character.
+var defaultTokenRates = tokenRates{asciiChars: 4, cjkTokens: 1}
+
+var providerTokenRates = map[Provider]tokenRates{
+	// About 3.5 characters of English per token.
+	ProviderAnthropic: {asciiChars: 3.5, cjkTokens: 1},
+	// About 1.5 to 1.8 Chinese characters per token.
+	ProviderAlibaba: {asciiChars: 4, cjkTokens: 0.6},
+	// About 1.5 Chinese characters per token.
+	ProviderIFlytek: {asciiChars: 4, cjkTokens: 0.67},
+	// Baidu estimates a token per Chinese character and 1.3 tokens per English word.
+	ProviderBaidu: {asciiChars: 4.6, cjkTokens: 1},
+}
+
+func tokenRatesOf(provider Provider) tokenRates {
+	if rates, ok := providerTokenRates[provider]; ok {
+		return rates
+	}
+	return defaultTokenRates
+}
+
+// estimateTokens roughly estimates the prompt tokens of messages for the tokenizer of provider.
+// An empty provider gets the rates of OpenAI.
+func estimateTokens(provider Provider, messages []Message) int {
+	n := 3 // every reply is primed with a few tokens
+	for _, msg := range messages {
+		n += messageOverheadTokens + estimateTextTokens(provider, msg.Text()) + estimateImageTokens(msg.images())
+		for _, call := range msg.ToolCalls {
+			n += estimateTextTokens(provider, call.Function.Name) + estimateTextTokens(provider, call.Function.Arguments)
+		}
+	}
+	return n
+}
+
+// estimateTextTokens estimates the tokens of s, counting ASCII and CJK characters at the rates of provider,
+// and other characters as a token each.
+func estimateTextTokens(provider Provider, s string) int {
+	var ascii, cjk, other int
+	for _, r := range s {
+		switch {
+		case r < unicode.MaxASCII:
+			ascii++
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			cjk++
+		default:
+			other++
+		}
+	}
+	rates := tokenRatesOf(provider)
+	return int(math.Ceil(float64(ascii)/rates.asciiChars)) + int(math.Ceil(float64(cjk)*rates.cjkTokens)) + other
+}
+
+type tokenProviderKey struct{}
+
+// withTokenProvider returns a context telling truncation policies the provider to estimate tokens for.
+func withTokenProvider(ctx context.Context, provider Provider) context.Context {
+	return context.WithValue(ctx, tokenProviderKey{}, provider)
+}
+
+func tokenProvider(ctx context.Context) Provider {
+	p, _ := ctx.Value(tokenProviderKey{}).(Provider)
+	return p
+}
+
+// Tokens counts the prompt tokens of a chat request to model with messages, to check that it fits in
+// the context window or to choose MaxTokens. For OpenAI models, the count is exact once a tokenizer is set
+// with SetTokenizer, except for images and tool calls. Otherwise it is an estimate, usually within 10%,
+// that accounts for providers whose tokenizers fit more Chinese text in a token, like Qwen and SparkDesk.
+func Tokens(model ChatModel, messages []Message) (int, error) {
+	if model == "" {
+		return 0, errors.New("model is empty")
+	}
+	return countTokens(providerOf(model), messages), nil
+}
+
+// countTokens is Tokens for the tokenizer of provider, such as the provider a request is routed to.
+func countTokens(provider Provider, messages []Message) int {
+	t := tokenizer.Load()
+	if t == nil || provider != ProviderOpenAI {
+		return estimateTokens(provider, messages)
+	}
+
+	// See https://github.com/openai/openai-cookbook/blob/main/examples/How_to_count_tokens_with_tiktoken.ipynb
+	n := 3
+	for _, msg := range messages {
+		n += 3 + t.Count(string(msg.Role)) + t.Count(msg.Text()) + estimateImageTokens(msg.images())
+		for _, call := range msg.ToolCalls {
+			n += t.Count(call.Function.Name) + t.Count(call.Function.Arguments)
+		}
+	}
+	return n
+}
@@ -0,0 +1,48 @@
+package opencat_api
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokensByProvider(t *testing.T) {
+	chinese := strings.Repeat("你好世界", 25)
+	tests := []struct {
+		provider Provider
+		text     string
+		want     int
+	}{
+		{ProviderOpenAI, "Hello, world", 3},
+		{ProviderAnthropic, "Hello, world", 4},
+		{ProviderOpenAI, chinese, 100},
+		{ProviderAlibaba, chinese, 60},
+		{ProviderIFlytek, chinese, 67},
+		{ProviderBaidu, chinese, 100},
+		{ProviderBaidu, strings.Repeat("word ", 20), 22},
+		{"", "Привет", 6},
+	}
+	for _, tt := range tests {
+		if got := estimateTextTokens(tt.provider, tt.text); got != tt.want {
+			t.Errorf("estimateTextTokens(%s, %.12q) = %d, want %d", tt.provider, tt.text, got, tt.want)
+		}
+	}
+
+	messages := []Message{User(chinese)}
+	gpt, _ := Tokens(ChatModelGPT4, messages)
+	qwen, _ := Tokens(ChatModelQWENPlus, messages)
+	if qwen >= gpt {
+		t.Errorf("Qwen estimated at %d tokens, GPT-4 at %d", qwen, gpt)
+	}
+
+	// Truncation counts tokens like the model does: 100 Chinese characters fit in 80 tokens for Qwen only.
+	history := []Message{User(chinese), Assistant("好"), User("继续")}
+	fitted, err := FitContext(context.Background(), ChatModelQWENPlus, history, 32768-90, TruncateDropOldest)
+	if err != nil || len(fitted) != 3 {
+		t.Errorf("Qwen history truncated to %d messages: %v", len(fitted), err)
+	}
+	fitted, err = FitContext(context.Background(), ChatModelGPT4, history, 8192-90, TruncateDropOldest)
+	if err != nil || len(fitted) == 3 {
+		t.Errorf("GPT-4 history not truncated: %d messages, %v", len(fitted), err)
+	}
+}
@@ -0,0 +1,139 @@
+package opencat_api
+
+import (
+	"sync"
+)
+
+// ToolCallEvent is an update of a streamed tool call, see ToolCallStream.
+type ToolCallEvent struct {
+	// Choice is the index of the choice the call belongs to.
+	Choice int
+	// ID and Name are those of the call, known from its first fragment.
+	ID   string
+	Name string
+	// Fragment is the piece of the arguments received with this update, empty for the first and last ones.
+	Fragment string
+	// Arguments are the arguments received so far, or all of them if Complete.
+	Arguments string
+	// Complete is set on the last event of a call, once the model moved on to another call or finished the choice.
+	// The arguments can then be parsed, and the call prepared while the rest of the stream arrives.
+	Complete bool
+}
+
+// ToolCallStream accumulates the tool calls of a stream per choice and call, and reports each fragment of
+// their arguments and when they are complete. Its Add method is passed to StreamChatDeltas, or called
+// from the function passed to it:
+//
+//	calls := NewToolCallStream(func(e ToolCallEvent) { ... })
+//	err := c.StreamChatDeltas(ctx, chat, calls.Add)
+//	calls.Close()
+type ToolCallStream struct {
+	fn func(ToolCallEvent)
+
+	mu      sync.Mutex
+	choices map[int]*streamedCalls
+}
+
+// streamedCalls are the tool calls of a choice. Calls are streamed one after the other,
+// so only the last one can be incomplete.
+type streamedCalls struct {
+	calls []ToolCall
+	// indexes maps the index of a call in the deltas to its position in calls.
+	indexes  map[int]int
+	complete bool
+}
+
+// NewToolCallStream returns a ToolCallStream calling fn, which may be nil, on every update.
+func NewToolCallStream(fn func(ToolCallEvent)) *ToolCallStream {
+	return &ToolCallStream{fn: fn, choices: map[int]*streamedCalls{}}
+}
+
+// Add adds the tool call fragments and the finish reason of a delta.
+func (s *ToolCallStream) Add(delta ChatDelta) {
+	s.mu.Lock()
+	var events []ToolCallEvent
+	choice := s.choices[delta.Index]
+	if choice == nil && len(delta.ToolCalls) > 0 {
+		choice = &streamedCalls{indexes: map[int]int{}}
+		s.choices[delta.Index] = choice
+	}
+	for _, fragment := range delta.ToolCalls {
+		i, ok := choice.indexes[fragment.Index]
+		if !ok {
+			events = s.complete(events, delta.Index)
+			choice.calls = append(
+				choice.calls, ToolCall{ID: fragment.ID, Type: fragment.Type, Function: FunctionCall{Name: fragment.Function.Name}},
+			)
+			i = len(choice.calls) - 1
+			choice.indexes[fragment.Index] = i
+			choice.complete = false
+		}
+		call := &choice.calls[i]
+		call.Function.Arguments += fragment.Function.Arguments
+		events = append(
+			events, ToolCallEvent{
+				Choice:    delta.Index,
+				ID:        call.ID,
+				Name:      call.Function.Name,
+				Fragment:  fragment.Function.Arguments,
+				Arguments: call.Function.Arguments,
+			},
+		)
+	}
+	if delta.FinishReason != "" {
+		events = s.complete(events, delta.Index)
+	}
+	s.mu.Unlock()
+
+	s.emit(events)
+}
+
+// Close completes the last calls of the choices that didn't finish, like when the stream was interrupted.
+func (s *ToolCallStream) Close() {
+	s.mu.Lock()
+	var events []ToolCallEvent
+	for i := range s.choices {
+		events = s.complete(events, i)
+	}
+	s.mu.Unlock()
+
+	s.emit(events)
+}
+
+// complete appends the completion of the last call of a choice to events, if it isn't complete yet.
+func (s *ToolCallStream) complete(events []ToolCallEvent, index int) []ToolCallEvent {
+	choice := s.choices[index]
+	if choice == nil || choice.complete || len(choice.calls) == 0 {
+		return events
+	}
+	choice.complete = true
+	call := choice.calls[len(choice.calls)-1]
+	return append(
+		events, ToolCallEvent{
+			Choice:    index,
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+			Complete:  true,
+		},
+	)
+}
+
+func (s *ToolCallStream) emit(events []ToolCallEvent) {
+	if s.fn == nil {
+		return
+	}
+	for _, e := range events {
+		s.fn(e)
+	}
+}
+
+// Calls returns the tool calls of a choice received so far.
+func (s *ToolCallStream) Calls(choice int) []ToolCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c := s.choices[choice]; c != nil {
+		return append([]ToolCall(nil), c.calls...)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package opencat_api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestToolCallStream(t *testing.T) {
+	var events []ToolCallEvent
+	calls := NewToolCallStream(
+		func(e ToolCallEvent) {
+			events = append(events, e)
+		},
+	)
+	for _, data := range []string{
+		`{"choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[` +
+			`{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[` +
+			`{"index":1,"id":"call_2","type":"function","function":{"name":"get_time","arguments":"{}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	} {
+		deltas, err := parseStreamEvent([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, delta := range deltas {
+			calls.Add(delta)
+		}
+	}
+	calls.Close()
+
+	want := []ToolCallEvent{
+		{ID: "call_1", Name: "get_weather"},
+		{ID: "call_1", Name: "get_weather", Fragment: `{"city":`, Arguments: `{"city":`},
+		{ID: "call_1", Name: "get_weather", Fragment: `"Paris"}`, Arguments: `{"city":"Paris"}`},
+		{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Paris"}`, Complete: true},
+		{ID: "call_2", Name: "get_time", Fragment: `{}`, Arguments: `{}`},
+		{ID: "call_2", Name: "get_time", Arguments: `{}`, Complete: true},
+	}
+	if !reflect.DeepEqual(events, want) {
+		got, _ := json.MarshalIndent(events, "", "  ")
+		t.Errorf("unexpected events: %s", got)
+	}
+	if got := calls.Calls(0); len(got) != 2 || got[0].Function.Arguments != `{"city":"Paris"}` || got[1].ID != "call_2" {
+		t.Errorf("unexpected calls: %+v", got)
+	}
+
+	// An interrupted stream is completed by Close.
+	events = nil
+	calls = NewToolCallStream(
+		func(e ToolCallEvent) {
+			events = append(events, e)
+		},
+	)
+	calls.Add(ChatDelta{Index: 1, ToolCalls: []ToolCallDelta{{ID: "call_3", Function: FunctionCall{Name: "f", Arguments: "{"}}}})
+	calls.Close()
+	if len(events) != 2 || !events[1].Complete || events[1].Choice != 1 || events[1].Arguments != "{" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
@@ -0,0 +1,147 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strconv"
+)
+
+type TranscriptionModel string
+
+const (
+	TranscriptionModelWhisper1 TranscriptionModel = "whisper-1"
+)
+
+// TranscriptionFormat is the format of a transcription.
+type TranscriptionFormat string
+
+const (
+	TranscriptionJSON TranscriptionFormat = "json"
+	// TranscriptionVerboseJSON adds the language, the duration and timed segments.
+	TranscriptionVerboseJSON TranscriptionFormat = "verbose_json"
+	TranscriptionText        TranscriptionFormat = "text"
+	// TranscriptionSRT and TranscriptionVTT are subtitles.
+	TranscriptionSRT TranscriptionFormat = "srt"
+	TranscriptionVTT TranscriptionFormat = "vtt"
+)
+
+type TranscriptionRequest struct {
+	Model TranscriptionModel
+	// Audio is read once, when the request is sent. Files are limited to 25 MB.
+	Audio io.Reader
+	// Filename is the name of the audio file, whose extension, like .mp3 or .m4a, tells its format.
+	Filename string
+	// Language is the ISO 639-1 code of the language of the audio, like en. It is detected if empty,
+	// but giving it improves accuracy and latency.
+	Language string
+	// Prompt is text that guides the style, or continues a previous segment of the audio.
+	Prompt string
+	// Temperature is between 0 and 1.
+	Temperature float64
+	// ResponseFormat is TranscriptionJSON if empty.
+	ResponseFormat TranscriptionFormat
+}
+
+func (r TranscriptionRequest) validate() error {
+	if r.Audio == nil || r.Filename == "" {
+		return errors.New("transcription request needs audio and its file name")
+	}
+	if r.Temperature < 0 || r.Temperature > 1 {
+		return fmt.Errorf("temperature must be between 0 and 1, got %v", r.Temperature)
+	}
+	return nil
+}
+
+type TranscriptionSegment struct {
+	ID int `json:"id"`
+	// Start and End are in seconds.
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+type Transcription struct {
+	// Text is the transcribed text, or the subtitles in the SRT and VTT formats.
+	Text string `json:"text"`
+	// Language, Duration, in seconds, and Segments are only returned in the verbose JSON format.
+	Language string                 `json:"language,omitempty"`
+	Duration float64                `json:"duration,omitempty"`
+	Segments []TranscriptionSegment `json:"segments,omitempty"`
+}
+
+// Transcribe transcribes audio into text in the language of the audio.
+func (c *Client) Transcribe(ctx context.Context, transcription TranscriptionRequest) (Transcription, error) {
+	err := transcription.validate()
+	if err != nil {
+		return Transcription{}, err
+	}
+	format := transcription.ResponseFormat
+	if format == "" {
+		format = TranscriptionJSON
+	}
+
+	u, err := newUpload(
+		func(form *multipart.Writer) error {
+			file, err := form.CreateFormFile("file", transcription.Filename)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(file, transcription.Audio)
+			if err != nil {
+				return err
+			}
+			fields := map[string]string{
+				"model":           string(transcription.Model),
+				"language":        transcription.Language,
+				"prompt":          transcription.Prompt,
+				"response_format": string(format),
+			}
+			if transcription.Temperature != 0 {
+				fields["temperature"] = strconv.FormatFloat(transcription.Temperature, 'f', -1, 64)
+			}
+			for name, value := range fields {
+				if value == "" {
+					continue
+				}
+				err = form.WriteField(name, value)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return Transcription{}, err
+	}
+	defer u.Close()
+
+	req, err := c.newUploadRequest(ctx, "/v1/audio/transcriptions", u)
+	if err != nil {
+		return Transcription{}, err
+	}
+
+	resp, err := c.do(req, string(transcription.Model))
+	if err != nil {
+		return Transcription{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return Transcription{}, NewAPIError(resp)
+	}
+
+	switch format {
+	case TranscriptionJSON, TranscriptionVerboseJSON:
+		var t Transcription
+		err = json.NewDecoder(resp.Body).Decode(&t)
+		return t, err
+	default:
+		text, err := io.ReadAll(resp.Body)
+		return Transcription{Text: string(text)}, err
+	}
+}
@@ -0,0 +1,74 @@
+package opencat_api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTranscribe(t *testing.T) {
+	var fields map[string]string
+	var audio string
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			err := r.ParseMultipartForm(1 << 20)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fields = map[string]string{}
+			for name, values := range r.MultipartForm.Value {
+				fields[name] = values[0]
+			}
+			file, header, _ := r.FormFile("file")
+			data, _ := io.ReadAll(file)
+			audio = header.Filename + ":" + string(data)
+			if fields["response_format"] == "srt" {
+				io.WriteString(w, "1\n00:00:00,000 --> 00:00:01,000\nHello\n")
+				return
+			}
+			io.WriteString(
+				w, `{"text": "Hello", "language": "english", "duration": 1.5,`+
+					`"segments": [{"id": 0, "start": 0, "end": 1.5, "text": "Hello"}]}`,
+			)
+		},
+	)
+
+	tr, err := c.Transcribe(
+		context.Background(), TranscriptionRequest{
+			Model:          TranscriptionModelWhisper1,
+			Audio:          strings.NewReader("ID3..."),
+			Filename:       "hello.mp3",
+			Language:       "en",
+			Temperature:    0.2,
+			ResponseFormat: TranscriptionVerboseJSON,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if audio != "hello.mp3:ID3..." || fields["model"] != "whisper-1" || fields["language"] != "en" ||
+		fields["temperature"] != "0.2" || fields["response_format"] != "verbose_json" {
+		t.Errorf("unexpected form: %s %v", audio, fields)
+	}
+	if tr.Text != "Hello" || tr.Duration != 1.5 || len(tr.Segments) != 1 || tr.Segments[0].End != 1.5 {
+		t.Errorf("unexpected transcription: %+v", tr)
+	}
+
+	tr, err = c.Transcribe(
+		context.Background(), TranscriptionRequest{
+			Model:          TranscriptionModelWhisper1,
+			Audio:          strings.NewReader("ID3..."),
+			Filename:       "hello.mp3",
+			ResponseFormat: TranscriptionSRT,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(tr.Text, "Hello\n") {
+		t.Errorf("unexpected subtitles: %q", tr.Text)
+	}
+}
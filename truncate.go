@@ -0,0 +1,152 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrContextTooLong is returned when messages can't be shortened enough to fit the context window.
+var ErrContextTooLong = errors.New("messages do not fit in the context window")
+
+// defaultReplyTokens is reserved for the reply when a request doesn't set MaxTokens.
+const defaultReplyTokens = 1024
+
+// TruncationPolicy shortens a list of messages so its estimated size fits in budget tokens.
+// The last message, usually the new question, must be kept.
+type TruncationPolicy interface {
+	Truncate(ctx context.Context, messages []Message, budget int) ([]Message, error)
+}
+
+type truncationFunc func(ctx context.Context, messages []Message, budget int) ([]Message, error)
+
+func (f truncationFunc) Truncate(ctx context.Context, messages []Message, budget int) ([]Message, error) {
+	return f(ctx, messages, budget)
+}
+
+var (
+	// TruncateDropOldest drops the oldest messages, including system messages.
+	TruncateDropOldest TruncationPolicy = truncationFunc(
+		func(ctx context.Context, messages []Message, budget int) ([]Message, error) {
+			return dropOldest(tokenProvider(ctx), messages, budget, false)
+		},
+	)
+	// TruncateKeepSystem drops the oldest messages, but keeps all system messages.
+	TruncateKeepSystem TruncationPolicy = truncationFunc(
+		func(ctx context.Context, messages []Message, budget int) ([]Message, error) {
+			return dropOldest(tokenProvider(ctx), messages, budget, true)
+		},
+	)
+)
+
+func dropOldest(provider Provider, messages []Message, budget int, keepSystem bool) ([]Message, error) {
+	var system, rest []Message
+	for _, msg := range messages {
+		if keepSystem && msg.Role == RoleSystem {
+			system = append(system, msg)
+		} else {
+			rest = append(rest, msg)
+		}
+	}
+
+	for len(rest) > 0 {
+		kept := append(system[:len(system):len(system)], rest...)
+		if estimateTokens(provider, kept) <= budget {
+			return kept, nil
+		}
+		if len(rest) == 1 {
+			break
+		}
+		rest = rest[1:]
+		// Don't start with a reply or a tool result whose question was dropped.
+		for len(rest) > 1 && rest[0].Role != RoleUser && rest[0].Role != RoleSystem {
+			rest = rest[1:]
+		}
+	}
+	return nil, ErrContextTooLong
+}
+
+// TruncateSummarize replaces the oldest messages with a summary written by model,
+// keeping system messages and the most recent turns verbatim.
+// If summarizing is not enough, the oldest messages are dropped.
+func TruncateSummarize(c *Client, model ChatModel) TruncationPolicy {
+	return truncationFunc(
+		func(ctx context.Context, messages []Message, budget int) ([]Message, error) {
+			if estimateTokens(tokenProvider(ctx), messages) <= budget {
+				return messages, nil
+			}
+
+			var system, rest []Message
+			for _, msg := range messages {
+				if msg.Role == RoleSystem {
+					system = append(system, msg)
+				} else {
+					rest = append(rest, msg)
+				}
+			}
+			// Summarize the older half, keeping the question and at least one exchange before it.
+			split := min(len(rest)/2, len(rest)-3)
+			for split > 0 && rest[split].Role != RoleUser {
+				split--
+			}
+			if split <= 0 {
+				return dropOldest(tokenProvider(ctx), messages, budget, true)
+			}
+
+			summary, err := summarize(ctx, c, model, rest[:split])
+			if err != nil {
+				return nil, fmt.Errorf("summarize history: %w", err)
+			}
+			summarized := append(system, Message{Role: RoleSystem, Content: summary})
+			summarized = append(summarized, rest[split:]...)
+			return dropOldest(tokenProvider(ctx), summarized, budget, true)
+		},
+	)
+}
+
+const summarizePrompt = "Summarize the conversation above for your own future reference, in the language of the conversation. " +
+	"Keep names, facts, decisions and open questions, leave out pleasantries. Reply with the summary only."
+
+func summarize(ctx context.Context, c *Client, model ChatModel, messages []Message) (string, error) {
+	var transcript strings.Builder
+	for _, msg := range messages {
+		transcript.WriteString(string(msg.Role))
+		transcript.WriteString(": ")
+		transcript.WriteString(msg.Text())
+		transcript.WriteString("\n\n")
+	}
+	resp, err := c.Chat(
+		ctx, ChatRequest{
+			Model:     model,
+			MaxTokens: defaultReplyTokens,
+			Messages: []Message{
+				{Role: RoleUser, Content: transcript.String() + summarizePrompt},
+			},
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("response has no choices")
+	}
+	return "Summary of the earlier conversation: " + resp.Choices[0].Message.Content, nil
+}
+
+// FitContext shortens messages with policy so that a request to model, with room for maxTokens
+// of reply, fits in the model's context window. A maxTokens of 0 reserves a default amount.
+func FitContext(ctx context.Context, model ChatModel, messages []Message, maxTokens int, policy TruncationPolicy) ([]Message, error) {
+	if maxTokens <= 0 {
+		maxTokens = defaultReplyTokens
+	}
+	budget := contextWindow(model) - maxTokens
+	n, err := Tokens(model, messages)
+	if err != nil {
+		return nil, err
+	}
+	if n <= budget {
+		return messages, nil
+	}
+	return policy.Truncate(withTokenProvider(ctx, providerOf(model)), messages, budget)
+}
@@ -0,0 +1,66 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTruncate(t *testing.T) {
+	long := strings.Repeat("word ", 400) // ~500 tokens
+	messages := []Message{
+		{Role: RoleSystem, Content: "You are a helpful assistant."},
+		{Role: RoleUser, Content: long},
+		{Role: RoleAssistant, Content: long},
+		{Role: RoleUser, Content: long},
+		{Role: RoleAssistant, Content: "ok"},
+		{Role: RoleUser, Content: "question"},
+	}
+	ctx := context.Background()
+
+	got, err := TruncateKeepSystem.Truncate(ctx, messages, 700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 || got[0].Role != RoleSystem || got[1].Content != messages[3].Content {
+		t.Errorf("keep system: got %d messages starting with %v", len(got), got[0].Role)
+	}
+
+	got, err = TruncateDropOldest.Truncate(ctx, messages, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The assistant reply is dropped along with its question.
+	if len(got) != 1 || got[0].Content != "question" {
+		t.Errorf("drop oldest: got %+v", got)
+	}
+
+	_, err = TruncateDropOldest.Truncate(ctx, []Message{{Role: RoleUser, Content: long}}, 100)
+	if !errors.Is(err, ErrContextTooLong) {
+		t.Errorf("expected ErrContextTooLong, got %v", err)
+	}
+
+	got, err = FitContext(ctx, ChatModelClaude2, messages, 0, TruncateDropOldest)
+	if err != nil || len(got) != len(messages) {
+		t.Errorf("messages that fit should be kept, got %d, %v", len(got), err)
+	}
+}
+
+func TestTruncateSummarize(t *testing.T) {
+	c := client()
+	conv := NewConversation(c, ChatModelGPT3Dot5Turbo)
+	conv.SetTruncation(TruncateSummarize(c, ChatModelGPT3Dot5Turbo))
+	filler := strings.Repeat("This is some filler text to make the conversation long. ", 100)
+	for _, q := range []string{"My name is Alice. " + filler, "I live in Paris. " + filler, filler, filler} {
+		_, err := conv.Ask(context.Background(), q+" Reply with OK.")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	reply, err := conv.Ask(context.Background(), "What is my name and where do I live?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("reply: %s, history: %d messages", reply, len(conv.History()))
+}
@@ -0,0 +1,123 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Turn is a question of a conversation whose reply is being streamed, see Conversation.StartStream.
+// It maps "stop generating" to Abort and "regenerate" to Restart.
+type Turn struct {
+	conv *Conversation
+	text string
+	fn   func(delta string, done bool)
+	opts []RequestOption
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	done    chan struct{}
+	aborted bool
+	reply   string
+	err     error
+	// added reports whether the question and reply were added to the history,
+	// end is the length of the history right after, to tell whether the turn is still the last one.
+	added bool
+	end   int
+}
+
+// StartStream asks a question like AskStream, but in the background. fn is called from another goroutine.
+func (conv *Conversation) StartStream(
+	ctx context.Context,
+	text string,
+	fn func(delta string, done bool),
+	opts ...RequestOption,
+) *Turn {
+	t := &Turn{conv: conv, text: text, fn: fn, opts: opts}
+	t.start(ctx)
+	return t
+}
+
+func (t *Turn) start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	t.mu.Lock()
+	t.cancel, t.done = cancel, done
+	t.aborted, t.reply, t.err, t.added = false, "", nil, false
+	t.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		defer cancel()
+
+		t.conv.mu.Lock()
+		reply, added, err := t.conv.askStream(ctx, t.text, t.fn, t.opts)
+		end := len(t.conv.history)
+		t.conv.unlock()
+
+		t.mu.Lock()
+		t.reply, t.added, t.end, t.err = reply, added, end, err
+		t.mu.Unlock()
+	}()
+}
+
+// Wait waits for the reply to complete and returns it.
+// After Abort, it returns the partial reply and no error.
+func (t *Turn) Wait() (string, error) {
+	t.mu.Lock()
+	done := t.done
+	t.mu.Unlock()
+	<-done
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.aborted && errors.Is(t.err, context.Canceled) {
+		return t.reply, nil
+	}
+	return t.reply, t.err
+}
+
+// Aborted reports whether the reply was cut short by Abort.
+func (t *Turn) Aborted() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.aborted && errors.Is(t.err, context.Canceled)
+}
+
+// Abort stops generating the reply and waits for the stream to be closed.
+// The partial reply is kept in the history. It does nothing if the reply is complete.
+func (t *Turn) Abort() {
+	t.mu.Lock()
+	cancel, done := t.cancel, t.done
+	select {
+	case <-done:
+	default:
+		t.aborted = true
+	}
+	t.mu.Unlock()
+
+	cancel()
+	<-done
+}
+
+// Restart aborts the reply if it is still being generated, removes the question and reply from the history
+// and asks the question again. It fails if other questions were asked since.
+func (t *Turn) Restart(ctx context.Context) error {
+	t.Abort()
+
+	t.conv.mu.Lock()
+	t.mu.Lock()
+	if t.added {
+		if len(t.conv.history) != t.end {
+			t.mu.Unlock()
+			t.conv.mu.Unlock()
+			return errors.New("turn is no longer the last of the conversation")
+		}
+		t.conv.history = t.conv.history[:t.end-2]
+	}
+	t.mu.Unlock()
+	t.conv.mu.Unlock()
+
+	t.start(ctx)
+	return nil
+}
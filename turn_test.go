@@ -0,0 +1,62 @@
+package opencat_api
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTurnAbortRestart(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			if calls.Add(1) == 1 {
+				w.Write([]byte("data: {\"delta\":\"Once upon\"}\n\n"))
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+				return
+			}
+			w.Write([]byte("data: {\"delta\":\"Hello\"}\n\ndata: [DONE]\n\n"))
+		},
+	)
+
+	conv := NewConversation(c, ChatModelGPT3Dot5Turbo)
+	started := make(chan struct{}, 1)
+	turn := conv.StartStream(
+		context.Background(), "Tell me a story", func(delta string, done bool) {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+		},
+	)
+	<-started
+	turn.Abort()
+	reply, err := turn.Wait()
+	if err != nil || reply != "Once upon" || !turn.Aborted() {
+		t.Fatalf("after abort: %q, %v", reply, err)
+	}
+	if h := conv.History(); len(h) != 2 || h[1].Content != "Once upon" {
+		t.Errorf("partial reply not kept: %+v", h)
+	}
+
+	err = turn.Restart(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err = turn.Wait()
+	if err != nil || reply != "Hello" || turn.Aborted() {
+		t.Fatalf("after restart: %q, %v", reply, err)
+	}
+	if h := conv.History(); len(h) != 2 || h[1].Content != "Hello" {
+		t.Errorf("history after restart: %+v", h)
+	}
+
+	// Another question was asked since.
+	conv.history = append(conv.history, Message{Role: RoleUser, Content: "later"}, Message{Role: RoleAssistant})
+	if err := turn.Restart(context.Background()); err == nil {
+		t.Errorf("restarting a turn that is not the last should fail")
+	}
+}
@@ -0,0 +1,95 @@
+package opencat_api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+)
+
+// uploadMemoryLimit is the size over which multipart forms are spooled to a temporary file instead of memory.
+var uploadMemoryLimit = 8 << 20
+
+// upload is a multipart form that can be read any number of times, so the request sending it can be retried.
+// It must be closed to remove its temporary file.
+type upload struct {
+	contentType string
+	buf         bytes.Buffer
+	file        *os.File
+	size        int64
+}
+
+// newUpload builds a multipart form with build.
+func newUpload(build func(form *multipart.Writer) error) (*upload, error) {
+	u := &upload{}
+	form := multipart.NewWriter(u)
+	u.contentType = form.FormDataContentType()
+	err := build(form)
+	if err == nil {
+		err = form.Close()
+	}
+	if err != nil {
+		u.Close()
+		return nil, err
+	}
+	return u, nil
+}
+
+// Write writes to memory, then to a temporary file once the form grows over uploadMemoryLimit.
+func (u *upload) Write(p []byte) (int, error) {
+	if u.file == nil && u.buf.Len()+len(p) > uploadMemoryLimit {
+		f, err := os.CreateTemp("", "opencat-upload-*")
+		if err != nil {
+			return 0, err
+		}
+		u.file = f
+		_, err = f.Write(u.buf.Bytes())
+		if err != nil {
+			return 0, err
+		}
+		u.buf = bytes.Buffer{}
+	}
+	u.size += int64(len(p))
+	if u.file != nil {
+		return u.file.Write(p)
+	}
+	return u.buf.Write(p)
+}
+
+// body returns a new reader of the form from its start.
+func (u *upload) body() (io.ReadCloser, error) {
+	if u.file != nil {
+		return io.NopCloser(io.NewSectionReader(u.file, 0, u.size)), nil
+	}
+	return io.NopCloser(bytes.NewReader(u.buf.Bytes())), nil
+}
+
+func (u *upload) Close() error {
+	if u.file == nil {
+		return nil
+	}
+	err := u.file.Close()
+	if rmErr := os.Remove(u.file.Name()); err == nil {
+		err = rmErr
+	}
+	u.file = nil
+	return err
+}
+
+// newUploadRequest creates a POST request of a multipart form, whose body is rewound for retries.
+func (c *Client) newUploadRequest(ctx context.Context, path string, u *upload) (*http.Request, error) {
+	req, err := c.newRequest(ctx, "POST", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", u.contentType)
+	req.ContentLength = u.size
+	req.GetBody = u.body
+	req.Body, err = u.body()
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
@@ -0,0 +1,64 @@
+package opencat_api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadRetry(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	defer func(limit int) { uploadMemoryLimit = limit }(uploadMemoryLimit)
+	uploadMemoryLimit = 16
+
+	var attempts, spooled int
+	var audios []string
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if entries, _ := os.ReadDir(tmp); len(entries) == 1 {
+				spooled++
+			}
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			audios = append(audios, string(data))
+			if attempts == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			io.WriteString(w, `{"text": "Hello"}`)
+		},
+		WithClock(NewFakeClock(time.Now())), WithMaxRetries(1),
+	)
+
+	audio := strings.Repeat("ID3...", 10)
+	tr, err := c.Transcribe(
+		context.Background(), TranscriptionRequest{
+			Model:    TranscriptionModelWhisper1,
+			Audio:    strings.NewReader(audio),
+			Filename: "hello.mp3",
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Text != "Hello" || len(audios) != 2 || audios[0] != audio || audios[1] != audio {
+		t.Errorf("unexpected transcription %q after uploads %q", tr.Text, audios)
+	}
+	if spooled != 2 {
+		t.Errorf("upload spooled to a file %d times, want 2", spooled)
+	}
+	entries, err := os.ReadDir(tmp)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("temporary files left: %v, %v", entries, err)
+	}
+}
@@ -0,0 +1,55 @@
+package opencat_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// chatValidated sends a chat request and checks the reply with validate.
+// When the reply is rejected, the model is shown the problem and asked to fix it, up to retries more times.
+// newRequest is called for every attempt, so it must not use images created with NewImage, which can be sent once.
+func (c *Client) chatValidated(
+	ctx context.Context,
+	newRequest func() ChatRequest,
+	validate func(content string) error,
+	retries int,
+) (ChatResponse, error) {
+	var corrections []Message
+	for attempt := 0; ; attempt++ {
+		req, reqCtx := newRequest(), ctx
+		if attempt > 0 {
+			reqCtx = withRetry(ctx)
+		}
+		req.Messages = append(req.Messages, corrections...)
+		resp, err := c.Chat(reqCtx, req)
+		if err != nil {
+			return resp, err
+		}
+		if len(resp.Choices) == 0 {
+			return resp, errors.New("response has no choices")
+		}
+
+		content := resp.Choices[0].Message.Content
+		err = validate(content)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= retries {
+			return resp, fmt.Errorf("invalid response after %d attempts: %w", attempt+1, err)
+		}
+		c.events.Publish(
+			RetryEvent{
+				Time:    c.clock.Now(),
+				Model:   string(req.Model),
+				Attempt: attempt + 2,
+				Cause:   err,
+			},
+		)
+		corrections = append(
+			corrections,
+			Message{Role: RoleAssistant, Content: content},
+			Message{Role: RoleUser, Content: fmt.Sprintf("That does not meet the requirements: %v. Please try again.", err)},
+		)
+	}
+}
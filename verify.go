@@ -0,0 +1,114 @@
+package opencat_api
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrTruncatedResponse is returned when a downloaded payload, like an image or audio, is shorter than
+// its Content-Length or doesn't match the checksum sent by the server in a header or trailer.
+type ErrTruncatedResponse struct {
+	// Want is the expected length, -1 if unknown, and Got the length received.
+	Want int64
+	Got  int64
+	// Algorithm is the checksum that didn't match, empty if the length is wrong.
+	Algorithm string
+}
+
+func (e *ErrTruncatedResponse) Error() string {
+	if e.Algorithm != "" {
+		return fmt.Sprintf("response corrupted: %s checksum mismatch after %d bytes", e.Algorithm, e.Got)
+	}
+	return fmt.Sprintf("response truncated: got %d of %d bytes", e.Got, e.Want)
+}
+
+// digestAlgorithms are the checksums of Content-Digest (RFC 9530) and Digest (RFC 3230) that are verified.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+	"md5":     md5.New,
+}
+
+// verifiedBody checks the length and checksums of a response body once it is read to the end.
+type verifiedBody struct {
+	io.ReadCloser
+	resp   *http.Response
+	n      int64
+	hashes map[string]hash.Hash
+	err    error
+}
+
+// verifyBody makes reading resp.Body fail with ErrTruncatedResponse if it is cut short or corrupted.
+func verifyBody(resp *http.Response) {
+	b := &verifiedBody{ReadCloser: resp.Body, resp: resp, hashes: map[string]hash.Hash{}}
+	// Trailers are announced before the body, with empty values.
+	_, announced := resp.Trailer[http.CanonicalHeaderKey("Content-Digest")]
+	_, announcedLegacy := resp.Trailer[http.CanonicalHeaderKey("Digest")]
+	if announced || announcedLegacy || resp.Header.Get("Content-Digest") != "" || resp.Header.Get("Digest") != "" {
+		for name, newHash := range digestAlgorithms {
+			b.hashes[name] = newHash()
+		}
+	}
+	resp.Body = b
+}
+
+func (b *verifiedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	for _, h := range b.hashes {
+		h.Write(p[:n])
+	}
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		err = &ErrTruncatedResponse{Want: b.resp.ContentLength, Got: b.n}
+	case err == io.EOF:
+		if verr := b.verify(); verr != nil {
+			err = verr
+		}
+	}
+	if err != nil {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *verifiedBody) verify() error {
+	if b.resp.ContentLength >= 0 && b.n != b.resp.ContentLength {
+		return &ErrTruncatedResponse{Want: b.resp.ContentLength, Got: b.n}
+	}
+	for _, field := range []string{"Content-Digest", "Digest"} {
+		for _, digest := range append(b.resp.Header.Values(field), b.resp.Trailer.Values(field)...) {
+			for _, item := range strings.Split(digest, ",") {
+				name, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+				if !ok {
+					continue
+				}
+				name = strings.ToLower(name)
+				h, ok := b.hashes[name]
+				if !ok {
+					continue
+				}
+				want, err := base64.StdEncoding.DecodeString(strings.Trim(value, ":"))
+				if err != nil {
+					continue
+				}
+				if !bytes.Equal(h.Sum(nil), want) {
+					return &ErrTruncatedResponse{Want: b.resp.ContentLength, Got: b.n, Algorithm: name}
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package opencat_api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestVerifyBody(t *testing.T) {
+	audio := []byte("ID3 some mp3 frames")
+	sum := sha256.Sum256(audio)
+	digest := "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+
+	var handler http.HandlerFunc
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) { handler(w, r) })
+	speech := func() ([]byte, error) {
+		r, err := c.Speech(context.Background(), SpeechRequest{Model: SpeechModelTTS1, Input: "hi"})
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantErr bool
+	}{
+		{
+			"trailer matches", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Trailer", "Content-Digest")
+				w.Write(audio)
+				w.Header().Set("Content-Digest", digest)
+			}, false,
+		},
+		{
+			"trailer mismatch", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Trailer", "Content-Digest")
+				w.Write(audio[:5])
+				w.Header().Set("Content-Digest", digest)
+			}, true,
+		},
+		{
+			"short body", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "100")
+				w.Write(audio)
+			}, true,
+		},
+	}
+	for _, tt := range tests {
+		handler = tt.handler
+		data, err := speech()
+		var truncated *ErrTruncatedResponse
+		if tt.wantErr != errors.As(err, &truncated) {
+			t.Errorf("%s: got %d bytes, error %v", tt.name, len(data), err)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const readImageTextPrompt = `Extract all text visible in the image, in reading order.
+Reply with a JSON object only, no prose and no code fences, in this shape:
+{"lines": [{"text": "...", "box": {"x": 0.1, "y": 0.2, "width": 0.5, "height": 0.04}}]}
+Each line is one visual line of text, transcribed exactly, keeping the original language.
+"box" is the approximate bounding box of the line as fractions of the image width and height;
+omit it if you cannot tell where the line is.
+If there is no text, reply with {"lines": []}.`
+
+// BoundingBox is an approximate region of an image, in fractions of the image width and height.
+type BoundingBox struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+type TextLine struct {
+	Text string       `json:"text"`
+	Box  *BoundingBox `json:"box,omitempty"`
+}
+
+type ImageText struct {
+	Lines []TextLine `json:"lines"`
+}
+
+// String returns the recognized text, one line per line.
+func (t ImageText) String() string {
+	lines := make([]string, len(t.Lines))
+	for i, l := range t.Lines {
+		lines[i] = l.Text
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ReadImageText extracts the text in an image, such as a screenshot or a photo of a document.
+// Bounding boxes are best-effort hints and may be missing. A reply that isn't valid JSON is sent back
+// to the model to be fixed.
+func (c *Client) ReadImageText(ctx context.Context, image Image) (ImageText, error) {
+	// The image is sent again on every retry.
+	image, err := image.replayable()
+	if err != nil {
+		return ImageText{}, err
+	}
+
+	var text ImageText
+	_, err = c.chatValidated(
+		ctx,
+		func() ChatRequest {
+			return ChatRequest{
+				Model:     ChatModelGPT4VisionPreview,
+				MaxTokens: 4096,
+				Messages: []Message{
+					{
+						Role:    RoleUser,
+						Content: readImageTextPrompt,
+						Images:  []Image{image},
+					},
+				},
+			}
+		},
+		func(content string) error {
+			text = ImageText{}
+			return decodeJSON(content, &text)
+		},
+		1,
+	)
+	return text, err
+}
+
+type CaptionStyle string
+
+var (
+	// CaptionAltText is a concise description suitable for the alt attribute of an image.
+	CaptionAltText CaptionStyle = "alt"
+	// CaptionShort is a title-like caption of a few words.
+	CaptionShort CaptionStyle = "short"
+	// CaptionDetailed is a longer description of up to a few sentences.
+	CaptionDetailed CaptionStyle = "detailed"
+)
+
+var captionStyles = map[CaptionStyle]struct {
+	prompt   string
+	maxChars int
+}{
+	CaptionAltText: {
+		prompt: "Write alt text for this image for screen reader users. " +
+			"Describe what matters in one sentence, without starting with \"Image of\" or \"Picture of\".",
+		maxChars: 125,
+	},
+	CaptionShort: {
+		prompt:   "Write a short caption for this image, a few words like a title, without a trailing period.",
+		maxChars: 60,
+	},
+	CaptionDetailed: {
+		prompt:   "Describe this image in at most three sentences, covering the subject, setting and any visible text.",
+		maxChars: 400,
+	},
+}
+
+// Caption describes an image in the given style, for example as alt text for accessibility.
+// The length limit of the style is enforced, the model is asked to retry when it is exceeded.
+func (c *Client) Caption(ctx context.Context, image Image, style CaptionStyle) (string, error) {
+	s, ok := captionStyles[style]
+	if !ok {
+		return "", fmt.Errorf("unknown caption style: %q", style)
+	}
+	// The image is sent again on every retry.
+	image, err := image.replayable()
+	if err != nil {
+		return "", err
+	}
+
+	prompt := fmt.Sprintf("%s Use at most %d characters. Reply with the caption only.", s.prompt, s.maxChars)
+	resp, err := c.chatValidated(
+		ctx,
+		func() ChatRequest {
+			return ChatRequest{
+				Model:     ChatModelGPT4VisionPreview,
+				MaxTokens: 300,
+				Messages: []Message{
+					{
+						Role:    RoleUser,
+						Content: prompt,
+						Images:  []Image{image},
+					},
+				},
+			}
+		},
+		func(content string) error {
+			caption := cleanCaption(content)
+			if caption == "" {
+				return errors.New("the caption is empty")
+			}
+			if n := utf8.RuneCountInString(caption); n > s.maxChars {
+				return fmt.Errorf("the caption is %d characters long, it must be at most %d", n, s.maxChars)
+			}
+			return nil
+		},
+		2,
+	)
+	if err != nil {
+		return "", err
+	}
+	return cleanCaption(resp.Choices[0].Message.Content), nil
+}
+
+func cleanCaption(s string) string {
+	s = strings.TrimSpace(s)
+	return strings.Trim(s, `"'“”`)
+}
+
+const extractReceiptPrompt = `Extract the fields of the receipt or invoice in the image.
+Reply with a JSON object only, no prose and no code fences, matching this JSON schema:
+{
+  "type": "object",
+  "properties": {
+    "vendor": {"type": "string", "description": "name of the merchant or issuer"},
+    "date": {"type": "string", "description": "issue date as YYYY-MM-DD"},
+    "currency": {"type": "string", "description": "ISO 4217 code, e.g. USD or CNY"},
+    "items": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "description": {"type": "string"},
+          "quantity": {"type": "number"},
+          "unit_price": {"type": "number"},
+          "amount": {"type": "number", "description": "total price of the line"}
+        },
+        "required": ["description", "amount"]
+      }
+    },
+    "subtotal": {"type": "number"},
+    "tax": {"type": "number"},
+    "total": {"type": "number"}
+  },
+  "required": ["vendor", "items", "total"]
+}
+Use numbers without currency symbols. Leave out fields that are not on the document.`
+
+type ReceiptItem struct {
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity,omitempty"`
+	UnitPrice   float64 `json:"unit_price,omitempty"`
+	Amount      float64 `json:"amount"`
+}
+
+// Receipt holds the fields extracted from a receipt or an invoice.
+type Receipt struct {
+	Vendor   string        `json:"vendor"`
+	Date     string        `json:"date,omitempty"`
+	Currency string        `json:"currency,omitempty"`
+	Items    []ReceiptItem `json:"items"`
+	Subtotal float64       `json:"subtotal,omitempty"`
+	Tax      float64       `json:"tax,omitempty"`
+	Total    float64       `json:"total"`
+}
+
+// ExtractReceipt reads the vendor, date, line items and totals from a photo or scan of a receipt or invoice.
+// The values are extracted by a vision model and should be double-checked where accuracy matters.
+func (c *Client) ExtractReceipt(ctx context.Context, image Image) (Receipt, error) {
+	// The image is sent again on every retry.
+	image, err := image.replayable()
+	if err != nil {
+		return Receipt{}, err
+	}
+
+	var receipt Receipt
+	_, err = c.chatValidated(
+		ctx,
+		func() ChatRequest {
+			return ChatRequest{
+				Model:     ChatModelGPT4VisionPreview,
+				MaxTokens: 4096,
+				Messages: []Message{
+					{
+						Role:    RoleUser,
+						Content: extractReceiptPrompt,
+						Images:  []Image{image},
+					},
+				},
+			}
+		},
+		func(content string) error {
+			receipt = Receipt{}
+			return decodeJSON(content, &receipt)
+		},
+		1,
+	)
+	return receipt, err
+}
+
+// decodeJSON decodes the content of a reply as JSON,
+// tolerating the Markdown code fences models like to wrap JSON in.
+func decodeJSON(content string, v any) error {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```json")
+		content = strings.TrimPrefix(content, "```")
+		content = strings.TrimSuffix(content, "```")
+	}
+	return json.Unmarshal([]byte(content), v)
+}
@@ -0,0 +1,87 @@
+package opencat_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Voice describes a voice of a speech model, see Voices.
+type Voice struct {
+	// Name is the value of SpeechRequest.Voice, like alloy or zh-CN-XiaoxiaoNeural.
+	Name        string
+	DisplayName string
+	// Gender is Male or Female, empty if unknown.
+	Gender string
+	// Locale is the locale of the voice, like zh-CN. OpenAI voices have none, they speak the language of the input.
+	Locale string
+	// Styles are the speaking styles of the voice, see SSML.Style.
+	Styles []string
+}
+
+// openAIVoices are the voices of tts-1 and tts-1-hd.
+var openAIVoices = []Voice{
+	{Name: "alloy", DisplayName: "Alloy"},
+	{Name: "echo", DisplayName: "Echo", Gender: "Male"},
+	{Name: "fable", DisplayName: "Fable"},
+	{Name: "onyx", DisplayName: "Onyx", Gender: "Male"},
+	{Name: "nova", DisplayName: "Nova", Gender: "Female"},
+	{Name: "shimmer", DisplayName: "Shimmer", Gender: "Female"},
+}
+
+// Voices returns the voices of a speech model. The voices of Azure are listed for the region of the client
+// and sorted by name, the voices of OpenAI are known without a request.
+func (c *Client) Voices(ctx context.Context, model SpeechModel) ([]Voice, error) {
+	switch model {
+	case SpeechModelTTS1, SpeechModelTTS1HD:
+		return append([]Voice(nil), openAIVoices...), nil
+	case SpeechModelAzure:
+	default:
+		return nil, fmt.Errorf("unknown speech model %s", model)
+	}
+
+	req, err := c.newRequest(ctx, "GET", "/cognitiveservices/voices/list", nil)
+	if err != nil {
+		return nil, err
+	}
+	region := c.config().AzureSpeech.Region
+	if region == "" {
+		region = defaultAzureRegion
+	}
+	req.Header.Set("X-Region", region)
+
+	resp, err := c.do(req, string(model))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, NewAPIError(resp)
+	}
+
+	var data []struct {
+		ShortName   string   `json:"ShortName"`
+		DisplayName string   `json:"DisplayName"`
+		Gender      string   `json:"Gender"`
+		Locale      string   `json:"Locale"`
+		StyleList   []string `json:"StyleList"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return nil, err
+	}
+	voices := make([]Voice, len(data))
+	for i, v := range data {
+		voices[i] = Voice{
+			Name:        v.ShortName,
+			DisplayName: v.DisplayName,
+			Gender:      v.Gender,
+			Locale:      v.Locale,
+			Styles:      v.StyleList,
+		}
+	}
+	sort.Slice(voices, func(i, j int) bool { return voices[i].Name < voices[j].Name })
+	return voices, nil
+}
@@ -0,0 +1,51 @@
+package opencat_api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestVoices(t *testing.T) {
+	var region string
+	c := newTestClient(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			region = r.Header.Get("X-Region")
+			io.WriteString(
+				w, `[
+{"Name": "Microsoft Server Speech Text to Speech Voice (zh-CN, XiaoxiaoNeural)", "DisplayName": "Xiaoxiao",
+ "ShortName": "zh-CN-XiaoxiaoNeural", "Gender": "Female", "Locale": "zh-CN", "StyleList": ["cheerful", "sad"],
+ "VoiceType": "Neural"},
+{"Name": "Microsoft Server Speech Text to Speech Voice (en-US, GuyNeural)", "DisplayName": "Guy",
+ "ShortName": "en-US-GuyNeural", "Gender": "Male", "Locale": "en-US", "VoiceType": "Neural"}
+]`,
+			)
+		},
+		WithAzureSpeech(AzureSpeechConfig{Region: "westus"}),
+	)
+
+	voices, err := c.Voices(context.Background(), SpeechModelAzure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Voice{
+		{Name: "en-US-GuyNeural", DisplayName: "Guy", Gender: "Male", Locale: "en-US"},
+		{
+			Name: "zh-CN-XiaoxiaoNeural", DisplayName: "Xiaoxiao", Gender: "Female", Locale: "zh-CN",
+			Styles: []string{"cheerful", "sad"},
+		},
+	}
+	if !reflect.DeepEqual(voices, want) {
+		t.Errorf("got %+v", voices)
+	}
+	if region != "westus" {
+		t.Errorf("unexpected region %q", region)
+	}
+
+	voices, err = c.Voices(context.Background(), SpeechModelTTS1HD)
+	if err != nil || len(voices) != 6 || voices[0].Name != "alloy" {
+		t.Errorf("unexpected OpenAI voices %+v, %v", voices, err)
+	}
+}
@@ -0,0 +1,119 @@
+package opencat_api
+
+import (
+	"fmt"
+	"time"
+)
+
+// WarningCode identifies the kind of a WarningEvent.
+type WarningCode string
+
+var (
+	// WarningDeprecatedModel means a request used a model that is being retired.
+	WarningDeprecatedModel WarningCode = "deprecated_model"
+	// WarningUsageNearLimit means the account has used most of its quota.
+	WarningUsageNearLimit WarningCode = "usage_near_limit"
+	// WarningSchemaDrift means a response did not have the expected shape, the API may have changed.
+	WarningSchemaDrift WarningCode = "schema_drift"
+	// WarningStreamParse means an event of a chat stream could not be parsed and was skipped.
+	WarningStreamParse WarningCode = "stream_parse"
+	// WarningStreamFallback means a chat stream didn't come as server-sent events, as happens behind some
+	// proxies, and was read in a fallback format.
+	WarningStreamFallback WarningCode = "stream_fallback"
+)
+
+// WarningEvent is published for problems that don't fail the call but may need an operator's attention.
+type WarningEvent struct {
+	Time    time.Time
+	Code    WarningCode
+	Model   string
+	Message string
+}
+
+func (WarningEvent) event() {}
+
+// usageWarningThreshold is the fraction of the quota that triggers WarningUsageNearLimit.
+const usageWarningThreshold = 0.9
+
+// OnWarning calls fn for every warning of the client. Call the returned function to stop.
+func (c *Client) OnWarning(fn func(WarningEvent)) (unsubscribe func()) {
+	return c.events.Subscribe(
+		func(e Event) {
+			if w, ok := e.(WarningEvent); ok {
+				fn(w)
+			}
+		},
+	)
+}
+
+func (c *Client) warn(code WarningCode, model ChatModel, format string, args ...any) {
+	c.events.Publish(
+		WarningEvent{
+			Time:    c.clock.Now(),
+			Code:    code,
+			Model:   string(model),
+			Message: fmt.Sprintf(format, args...),
+		},
+	)
+}
+
+// checkDeprecated warns about a deprecated model and returns the model to use: its replacement once
+// retired if Config.ReplaceDeprecatedModels is set, otherwise the model itself.
+func (c *Client) checkDeprecated(model ChatModel) ChatModel {
+	d, ok := DeprecationOf(model)
+	if !ok {
+		return model
+	}
+	switch {
+	case d.Sunset.IsZero():
+		c.warn(WarningDeprecatedModel, model, "model %s is deprecated, use %s instead", model, d.Replacement)
+	case !d.sunsetPassed(c.clock.Now()):
+		c.warn(
+			WarningDeprecatedModel, model, "model %s is deprecated and will be retired on %s, use %s instead",
+			model, d.Sunset.Format(time.DateOnly), d.Replacement,
+		)
+	case c.config().ReplaceDeprecatedModels:
+		c.warn(
+			WarningDeprecatedModel, model, "model %s was retired on %s, using %s instead",
+			model, d.Sunset.Format(time.DateOnly), d.Replacement,
+		)
+		return d.Replacement
+	default:
+		c.warn(
+			WarningDeprecatedModel, model, "model %s was retired on %s, use %s instead",
+			model, d.Sunset.Format(time.DateOnly), d.Replacement,
+		)
+	}
+	return model
+}
+
+func (c *Client) checkChatResponse(model ChatModel, resp ChatResponse) {
+	if len(resp.Choices) == 0 {
+		c.warn(WarningSchemaDrift, model, "chat response %q has no choices", resp.ID)
+		return
+	}
+	for _, choice := range resp.Choices {
+		if choice.Message.Content == "" && len(choice.Message.ToolCalls) == 0 && choice.FinishReason == "" {
+			c.warn(WarningSchemaDrift, model, "choice %d of chat response %q is empty", choice.Index, resp.ID)
+		}
+	}
+}
+
+func (c *Client) checkUsage(usages []Usage) {
+	ratios := map[string]float64{}
+	for _, u := range usages {
+		if u.Limit <= 0 {
+			continue
+		}
+		var used float64
+		for _, v := range u.Usage {
+			used += float64(v)
+		}
+		ratio := used / float64(u.Limit)
+		ratios[u.Product] = ratio
+		if ratio >= usageWarningThreshold {
+			c.warn(WarningUsageNearLimit, "", "%s has used %.0f%% of its limit", u.Product, ratio*100)
+		}
+	}
+	c.usage.set(ratios)
+}
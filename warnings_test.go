@@ -0,0 +1,75 @@
+package opencat_api
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestWarnings(t *testing.T) {
+	c := NewClient("token")
+	var warnings []WarningEvent
+	c.OnWarning(func(w WarningEvent) { warnings = append(warnings, w) })
+
+	c.checkUsage(
+		[]Usage{
+			{Product: "pro", Limit: 100, Usage: map[string]float32{"gpt-4": 60, "claude": 35}},
+			{Product: "basic", Limit: 100, Usage: map[string]float32{"gpt-4": 10}},
+		},
+	)
+	c.checkChatResponse(ChatModelGPT4, ChatResponse{ID: "chatcmpl-1"})
+	c.checkDeprecated(ChatModelGPT4)
+	c.checkDeprecated(ChatModelClaudeInstant1)
+
+	want := []WarningCode{WarningUsageNearLimit, WarningSchemaDrift, WarningDeprecatedModel}
+	if len(warnings) != len(want) {
+		t.Fatalf("got %d warnings, want %d: %+v", len(warnings), len(want), warnings)
+	}
+	for i, w := range warnings {
+		if w.Code != want[i] {
+			t.Errorf("warning %d: code = %s, want %s", i, w.Code, want[i])
+		}
+		t.Log(w.Message)
+	}
+}
+
+// restoreDeprecations restores the deprecation table when the test ends, for tests that change it.
+func restoreDeprecations(t *testing.T) {
+	deprecationsMu.RLock()
+	saved := maps.Clone(deprecations)
+	deprecationsMu.RUnlock()
+	t.Cleanup(
+		func() {
+			deprecationsMu.Lock()
+			defer deprecationsMu.Unlock()
+			deprecations = saved
+		},
+	)
+}
+
+func TestDeprecatedModelReplaced(t *testing.T) {
+	restoreDeprecations(t)
+	Deprecate("test-model-v1", Deprecation{Replacement: "test-model-v2", Sunset: date(2020, time.January, 1)})
+	Deprecate("test-model-v3", Deprecation{Replacement: "test-model-v4", Sunset: time.Now().AddDate(1, 0, 0)})
+
+	c := NewClient("token")
+	var warnings []WarningEvent
+	c.OnWarning(func(w WarningEvent) { warnings = append(warnings, w) })
+
+	if got := c.checkDeprecated("test-model-v1"); got != "test-model-v1" {
+		t.Errorf("model replaced without ReplaceDeprecatedModels: %s", got)
+	}
+	c.updateConfig(func(cfg *Config) { cfg.ReplaceDeprecatedModels = true })
+	if got := c.checkDeprecated("test-model-v1"); got != "test-model-v2" {
+		t.Errorf("retired model not replaced: %s", got)
+	}
+	if got := c.checkDeprecated("test-model-v3"); got != "test-model-v3" {
+		t.Errorf("model replaced before its sunset: %s", got)
+	}
+	if len(warnings) != 3 {
+		t.Fatalf("got %d warnings, want 3: %+v", len(warnings), warnings)
+	}
+	for _, w := range warnings {
+		t.Log(w.Message)
+	}
+}